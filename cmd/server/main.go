@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"net/http"
@@ -11,16 +12,25 @@ import (
 	"time"
 
 	"notification-service/config"
+	"notification-service/internal/domain/entity"
+	grpcHandlers "notification-service/internal/handler/grpc"
 	httpHandlers "notification-service/internal/handler/http"
 	"notification-service/internal/infrastructure/client/business"
+	"notification-service/internal/infrastructure/push"
+	"notification-service/internal/infrastructure/queue"
 	"notification-service/internal/infrastructure/repository/postgres"
 	"notification-service/internal/infrastructure/websocket"
 	"notification-service/internal/usecase"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/events"
 	"notification-service/pkg/logging"
+	"notification-service/pkg/template"
+	"notification-service/pkg/throttling"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -57,6 +67,14 @@ func main() {
 	tokenRepo := postgres.NewTokenRepository(dbConn)
 	notificationRepo := postgres.NewNotificationRepository(dbConn)
 	deliveryRepo := postgres.NewDeliveryRepository(dbConn)
+	messageQueueRepo := postgres.NewMessageQueueRepository(dbConn)
+	signingKeyRepo := postgres.NewSigningKeyRepository(dbConn)
+	sessionRepo := postgres.NewSessionRepository(dbConn)
+	refreshTokenRepo := postgres.NewRefreshTokenRepository(dbConn)
+	apiKeyRepo := postgres.NewAPIKeyRepository(dbConn)
+	tenantRepo := postgres.NewTenantRepository(dbConn)
+	quotaUsageRepo := postgres.NewQuotaUsageRepository(dbConn)
+	templateRepo := postgres.NewTemplateRepository(dbConn)
 
 	// Crear cliente para comunicación con el servicio de negocio
 	businessClient, err := business.NewBusinessClient(cfg.BusinessService.GRPCAddress)
@@ -65,17 +83,95 @@ func main() {
 	}
 	defer businessClient.Close()
 
+	// Cargar las claves de firma de los tokens
+	keyRing := auth.NewKeyRing()
+	if cfg.JWT.AcceptLegacyTokens {
+		keyRing.SetLegacySecret([]byte(cfg.JWT.Secret))
+	}
+
+	keyEncryptionKey := cfg.JWT.KeyEncryptionKey
+	if keyEncryptionKey == "" {
+		keyEncryptionKey = cfg.JWT.Secret
+	}
+	keyCipher, err := auth.NewKeyCipher([]byte(keyEncryptionKey))
+	if err != nil {
+		logger.Fatal("Failed to create signing key cipher: %v", err)
+	}
+
+	signingKeyService := usecase.NewSigningKeyService(
+		signingKeyRepo,
+		keyRing,
+		keyCipher,
+		cfg.JWT.Algorithm,
+		cfg.JWT.KeyRotationInterval,
+		// Los tokens firmados con una clave retirada deben poder verificarse hasta que expiran
+		max(cfg.JWT.KeyGracePeriod, cfg.JWT.TokenExpiry, cfg.JWT.TemporaryTokenExpiry),
+		cfg.JWT.KeyRefreshInterval,
+		logger,
+	)
+	if err := signingKeyService.Load(context.Background()); err != nil {
+		logger.Fatal("Failed to load JWT signing keys: %v", err)
+	}
+
+	keysCtx, stopKeys := context.WithCancel(context.Background())
+	defer stopKeys()
+	go signingKeyService.Run(keysCtx)
+
+	// Cargar las sesiones revocadas
+	sessionService := usecase.NewSessionService(sessionRepo, cfg.JWT.RevocationRefreshInterval, logger)
+	if err := sessionService.Load(context.Background()); err != nil {
+		logger.Fatal("Failed to load revoked sessions: %v", err)
+	}
+	go sessionService.Run(keysCtx)
+
 	// Crear servicios de dominio
 	tokenService := usecase.NewTokenService(
 		tokenRepo,
 		deviceRepo,
-		cfg.JWT.Secret,
+		refreshTokenRepo,
+		keyRing,
+		sessionService,
 		cfg.JWT.TokenExpiry,
 		cfg.JWT.TemporaryTokenExpiry,
+		cfg.JWT.RefreshTokenExpiry,
 	)
+	go tokenService.Run(keysCtx)
 
 	deviceService := usecase.NewDeviceService(deviceRepo, tokenRepo)
 
+	// Tenants que comparten el servicio y sus credenciales de push
+	tenantService := usecase.NewTenantService(tenantRepo, keyCipher, logger)
+
+	// Límites de tasa de las API keys y de las cuotas por segundo, propios de
+	// cada instancia o compartidos entre todas
+	var rateLimiter throttling.RateLimiter
+	switch cfg.RateLimit.Backend {
+	case "memory":
+		memoryLimiter := throttling.NewMemoryRateLimiter(10 * time.Minute)
+		defer memoryLimiter.Stop()
+		rateLimiter = memoryLimiter
+	case "postgres":
+		postgresLimiter := postgres.NewRateLimiter(dbConn, logger)
+		go postgresLimiter.Run(keysCtx, cfg.RateLimit.CleanupInterval)
+		rateLimiter = postgresLimiter
+	default:
+		logger.Fatal("Unknown RATE_LIMIT_BACKEND %q, expected memory or postgres", cfg.RateLimit.Backend)
+	}
+
+	// Autenticación de los servicios que envían notificaciones
+	serviceAuthService := usecase.NewServiceAuthService(
+		apiKeyRepo,
+		cfg.ServiceAuth.MTLSIdentities,
+		cfg.ServiceAuth.AdminAPIKey,
+		cfg.ServiceAuth.DefaultRateLimit,
+		cfg.ServiceAuth.DefaultRateBurst,
+		rateLimiter,
+		logger,
+	)
+	if cfg.ServiceAuth.AdminAPIKey == "" && len(cfg.ServiceAuth.MTLSIdentities) == 0 {
+		logger.Warn("No SERVICE_ADMIN_API_KEY or SERVICE_MTLS_IDENTITIES configured; API keys can only be created directly in the database")
+	}
+
 	// Crear servicio de entrega
 	deliveryService := usecase.NewDeliveryService(
 		deliveryRepo,
@@ -84,23 +180,144 @@ func main() {
 		logger,
 	)
 
+	// Crear gestor de eventos (presencia, entregas, etc.)
+	eventManager := events.NewEventManager(logger)
+
+	// Cola persistente para los mensajes que no caben en la cola de una conexión
+	spillQueue := queue.NewPersistentQueue(messageQueueRepo, logger)
+
 	// Crear websocket manager
 	wsManager := websocket.NewWebSocketManager(
 		tokenService,
 		deviceService,
 		deliveryService,
+		eventManager,
+		websocket.ClientOptions{
+			WriteWait:          cfg.WebSocket.WriteWait,
+			PongWait:           cfg.WebSocket.PongWait,
+			PingInterval:       cfg.WebSocket.PingInterval,
+			MaxMessageSize:     cfg.WebSocket.MaxMessageSize,
+			SendBufferSize:     cfg.WebSocket.MessageBufferSize,
+			SlowConsumerPolicy: websocket.SlowConsumerPolicy(cfg.WebSocket.SlowConsumerPolicy),
+			AllowedOrigins:     cfg.WebSocket.AllowedOrigins,
+			Overrides: map[string]websocket.ClientOverride{
+				"mobile": {
+					PingInterval: cfg.WebSocket.MobilePingInterval,
+					PongWait:     cfg.WebSocket.MobilePongWait,
+				},
+			},
+			ReconnectWindow:   cfg.WebSocket.ReconnectWindow,
+			ExpiryWarning:     cfg.WebSocket.TokenExpiryWarning,
+			AuthTimeout:       cfg.WebSocket.AuthTimeout,
+			CleanupInterval:   cfg.WebSocket.CleanupInterval,
+			InactivityTimeout: cfg.WebSocket.InactivityTimeout,
+
+			MaxConnectionsPerDevice: cfg.WebSocket.MaxConnectionsPerDevice,
+			MaxConnectionsPerUser:   cfg.WebSocket.MaxConnectionsPerUser,
+			ConnectionLimitPolicy:   websocket.ConnectionLimitPolicy(cfg.WebSocket.ConnectionLimitPolicy),
+			TrustForwardedFor:       cfg.WebSocket.TrustForwardedFor,
+		},
+		spillQueue,
+		logger,
 	)
 
+	// Limitar la tasa de conexiones nuevas por IP
+	connectThrottler := throttling.NewUserThrottler(cfg.WebSocket.ConnectRateLimit, cfg.WebSocket.ConnectRateBurst, 10*time.Minute, throttling.StrategyDrop)
+	defer connectThrottler.Stop()
+	wsManager.SetConnectionThrottler(connectThrottler)
+
+	// Revocar una sesión cierra sus conexiones
+	sessionService.SetDisconnector(wsManager)
+
+	// Reenviar al servicio de negocio los eventos que envían los clientes
+	wsManager.RegisterMessageRoute("client_event", websocket.NewClientEventRoute(businessClient, cfg.BusinessService.Timeout))
+
 	// Iniciar el websocket manager
 	wsManager.Start()
 
+	// Crear servicio de buzón para dispositivos con long-polling
+	inboxService := usecase.NewInboxService(deliveryRepo, notificationRepo, tokenRepo, deviceRepo, logger)
+
+	// Cuotas de envío por niveles, del global al dispositivo
+	quotaService := usecase.NewQuotaService(
+		quotaUsageRepo,
+		tenantService,
+		rateLimiter,
+		map[entity.QuotaLevel]usecase.Quota{
+			entity.QuotaLevelGlobal: {PerSecond: cfg.Quota.GlobalPerSecond, PerDay: cfg.Quota.GlobalPerDay},
+			entity.QuotaLevelTenant: {PerSecond: cfg.Quota.TenantPerSecond, PerDay: cfg.Quota.TenantPerDay},
+			entity.QuotaLevelSender: {PerSecond: cfg.Quota.SenderPerSecond, PerDay: cfg.Quota.SenderPerDay},
+			entity.QuotaLevelUser:   {PerSecond: cfg.Quota.UserPerSecond, PerDay: cfg.Quota.UserPerDay},
+			entity.QuotaLevelDevice: {PerSecond: cfg.Quota.DevicePerSecond, PerDay: cfg.Quota.DevicePerDay},
+		},
+		eventManager,
+		cfg.Quota.FlushInterval,
+		logger,
+	)
+	go quotaService.Run(keysCtx)
+
+	// Repartir los workers de envío entre los carriles de prioridad
+	sendScheduler := usecase.NewSendScheduler(
+		map[entity.SendLane]usecase.SendLaneConfig{
+			entity.SendLaneUrgent: {Weight: cfg.SendQueue.UrgentWeight, Capacity: cfg.SendQueue.UrgentCapacity},
+			entity.SendLaneNormal: {Weight: cfg.SendQueue.NormalWeight, Capacity: cfg.SendQueue.NormalCapacity},
+			entity.SendLaneBulk:   {Weight: cfg.SendQueue.BulkWeight, Capacity: cfg.SendQueue.BulkCapacity},
+		},
+		cfg.SendQueue.Workers,
+		logger,
+	)
+	sendScheduler.Start()
+	defer sendScheduler.Stop()
+
+	// Plantillas de notificaciones: las de ficheros y las publicadas en la
+	// base de datos
+	templateManager := template.NewTemplateManager(cfg.Templates.Folder, cfg.Templates.DefaultLocale, logger)
+	if err := templateManager.LoadTemplates(); err != nil {
+		logger.Fatal("Failed to load templates: %v", err)
+	}
+	templateService := usecase.NewTemplateService(templateRepo, templateManager, cfg.Templates.RefreshInterval, logger)
+	if err := templateService.Load(context.Background()); err != nil {
+		logger.Fatal("Failed to load published templates: %v", err)
+	}
+	go templateService.Run(keysCtx)
+
 	// Ahora podemos crear el servicio de notificaciones
-	notificationService := usecase.NewNotificationService(notificationRepo, deliveryRepo, deviceRepo, tokenRepo, wsManager, logger)
+	notificationService := usecase.NewNotificationService(notificationRepo, deliveryRepo, deviceRepo, tokenRepo, wsManager, inboxService, quotaService, sendScheduler, templateService, logger)
+
+	// Adaptadores de FCM y APNS de cada tenant, creados con sus credenciales
+	pushAdapters := push.NewTenantAdapters(tenantRepo, keyCipher, logger)
+	pushAdapters.SetLimits(
+		push.Limits{Title: cfg.Push.FCMTitleMaxLength, Body: cfg.Push.FCMBodyMaxLength},
+		push.Limits{Title: cfg.Push.APNSTitleMaxLength, Body: cfg.Push.APNSBodyMaxLength},
+	)
+	notificationService.SetPushAdapters(pushAdapters)
+
+	// Crear servicio de presencia
+	presenceService := usecase.NewPresenceService(wsManager, deviceRepo)
+
+	// Crear servicio de push de la API gRPC
+	pushService := usecase.NewPushService(deviceRepo, tokenRepo, deliveryRepo, wsManager, pushAdapters, logger)
 
 	// Crear handlers HTTP
 	notificationHandler := httpHandlers.NewNotificationHandler(notificationService)
 	deviceHandler := httpHandlers.NewDeviceHandler(deviceService, tokenService)
+	inboxHandler := httpHandlers.NewInboxHandler(inboxService)
+	presenceHandler := httpHandlers.NewPresenceHandler(presenceService)
 	healthHandler := httpHandlers.NewHealthHandler()
+	jwksHandler := httpHandlers.NewJWKSHandler(keyRing)
+	sessionHandler := httpHandlers.NewSessionHandler(sessionService)
+	authHandler := httpHandlers.NewAuthHandler(tokenService)
+	apiKeyHandler := httpHandlers.NewAPIKeyHandler(serviceAuthService, tenantService)
+	tenantHandler := httpHandlers.NewTenantHandler(tenantService)
+	usageHandler := httpHandlers.NewUsageHandler(quotaService)
+	templateHandler := httpHandlers.NewTemplateHandler(templateService)
+
+	// Cada ruta exige un ámbito a quien llama, servicio o dispositivo, y
+	// actúa sobre su tenant
+	authorizer := httpHandlers.NewAuthorizer(serviceAuthService, tokenService, tenantService)
+	require := authorizer.Require
+	anonymous := authorizer.Anonymous
+	optional := authorizer.Optional
 
 	// Crear router
 	router := mux.NewRouter()
@@ -111,29 +328,73 @@ func main() {
 	// En tu archivo main.go, reemplaza la configuración actual del router por esta:
 
 	// Rutas de notificaciones
-	apiRouter.HandleFunc("/notifications/send", notificationHandler.SendNotification).Methods("POST")
-	apiRouter.HandleFunc("/notifications/{id}", notificationHandler.GetNotification).Methods("GET")
-	apiRouter.HandleFunc("/notifications/delivery-status", notificationHandler.GetDeliveryStatus).Methods("GET")
-	apiRouter.HandleFunc("/notifications/confirm", notificationHandler.ConfirmDelivery).Methods("POST")
-	apiRouter.HandleFunc("/notifications/send-hybrid", notificationHandler.SendHybridNotification).Methods("POST")
+	apiRouter.Handle("/notifications/send", require(auth.ScopeNotificationsSend, notificationHandler.SendNotification)).Methods("POST")
+	apiRouter.Handle("/notifications/{id}", require(auth.ScopeNotificationsRead, notificationHandler.GetNotification)).Methods("GET")
+	apiRouter.Handle("/notifications/delivery-status", require(auth.ScopeNotificationsRead, notificationHandler.GetDeliveryStatus)).Methods("GET")
+	apiRouter.Handle("/notifications/confirm", require(auth.ScopeNotificationsRead, notificationHandler.ConfirmDelivery)).Methods("POST")
+	apiRouter.Handle("/notifications/send-hybrid", require(auth.ScopeNotificationsSend, notificationHandler.SendHybridNotification)).Methods("POST")
 
 	// Rutas de usuarios y sus notificaciones
-	apiRouter.HandleFunc("/users/{user_id}/notifications", notificationHandler.GetUserNotifications).Methods("GET")
+	apiRouter.Handle("/users/{user_id}/notifications", require(auth.ScopeNotificationsRead, notificationHandler.GetUserNotifications)).Methods("GET")
+
+	// Rutas para tokens
+	apiRouter.HandleFunc("/auth/refresh", authHandler.Refresh).Methods("POST")
+
+	// Rutas para sesiones
+	apiRouter.Handle("/users/{user_id}/sessions", require(auth.ScopeSessionsRead, sessionHandler.ListSessions)).Methods("GET")
+	apiRouter.Handle("/users/{user_id}/sessions", require(auth.ScopeSessionsWrite, sessionHandler.RevokeAllSessions)).Methods("DELETE")
+	apiRouter.Handle("/users/{user_id}/sessions/{id}", require(auth.ScopeSessionsWrite, sessionHandler.RevokeSession)).Methods("DELETE")
+
+	// Rutas de administración de API keys
+	apiRouter.Handle("/admin/api-keys", require(auth.ScopeAdmin, apiKeyHandler.CreateAPIKey)).Methods("POST")
+	apiRouter.Handle("/admin/api-keys", require(auth.ScopeAdmin, apiKeyHandler.ListAPIKeys)).Methods("GET")
+	apiRouter.Handle("/admin/api-keys/{id}", require(auth.ScopeAdmin, apiKeyHandler.RevokeAPIKey)).Methods("DELETE")
+
+	// Rutas de administración de tenants
+	apiRouter.Handle("/admin/tenants", require(auth.ScopeAdmin, tenantHandler.CreateTenant)).Methods("POST")
+	apiRouter.Handle("/admin/tenants", require(auth.ScopeAdmin, tenantHandler.ListTenants)).Methods("GET")
+	apiRouter.Handle("/admin/tenants/{id}", require(auth.ScopeAdmin, tenantHandler.UpdateTenant)).Methods("PUT")
+
+	// Uso de las cuotas de envío
+	apiRouter.Handle("/admin/usage", require(auth.ScopeAdmin, usageHandler.GetUsage)).Methods("GET")
+
+	// Rutas de plantillas y sus versiones
+	apiRouter.Handle("/templates", require(auth.ScopeTemplatesRead, templateHandler.ListTemplates)).Methods("GET")
+	apiRouter.Handle("/templates", require(auth.ScopeTemplatesWrite, templateHandler.CreateTemplate)).Methods("POST")
+	apiRouter.Handle("/templates/{id}", require(auth.ScopeTemplatesRead, templateHandler.GetTemplate)).Methods("GET")
+	apiRouter.Handle("/templates/{id}", require(auth.ScopeTemplatesWrite, templateHandler.UpdateTemplate)).Methods("PUT")
+	apiRouter.Handle("/templates/{id}", require(auth.ScopeTemplatesWrite, templateHandler.DeleteTemplate)).Methods("DELETE")
+	apiRouter.Handle("/templates/{id}/preview", require(auth.ScopeTemplatesRead, templateHandler.PreviewTemplate)).Methods("POST")
+	apiRouter.Handle("/templates/{id}/versions", require(auth.ScopeTemplatesRead, templateHandler.ListVersions)).Methods("GET")
+	apiRouter.Handle("/templates/{id}/versions/{version}", require(auth.ScopeTemplatesRead, templateHandler.GetVersion)).Methods("GET")
+	apiRouter.Handle("/templates/{id}/versions/{version}/publish", require(auth.ScopeTemplatesWrite, templateHandler.PublishVersion)).Methods("POST")
 
 	// Rutas de dispositivos
-	apiRouter.HandleFunc("/devices/register", deviceHandler.RegisterDevice).Methods("POST")
-	apiRouter.HandleFunc("/devices/register-without-user", deviceHandler.RegisterDeviceWithoutUser).Methods("POST")
-	apiRouter.HandleFunc("/devices/{id}", deviceHandler.GetDevice).Methods("GET")
-	apiRouter.HandleFunc("/devices/user", deviceHandler.GetUserDevices).Methods("GET")
-	apiRouter.HandleFunc("/devices/link", deviceHandler.LinkDeviceToUser).Methods("POST")
-	apiRouter.HandleFunc("/devices/update-token", deviceHandler.UpdateToken).Methods("POST")
-	apiRouter.HandleFunc("/devices/renew-token", deviceHandler.RenewToken).Methods("POST")
-	apiRouter.HandleFunc("/devices/sync-tokens", deviceHandler.SyncTokens).Methods("POST")
-	apiRouter.HandleFunc("/devices/update-apns-token", deviceHandler.UpdateAPNSToken).Methods("POST")
-	apiRouter.HandleFunc("/devices/update-fcm-token", deviceHandler.UpdateFCMToken).Methods("POST")
+	apiRouter.Handle("/devices/register", optional(auth.ScopeDevicesWrite, deviceHandler.RegisterDevice)).Methods("POST")
+	apiRouter.Handle("/devices/register-without-user", anonymous(deviceHandler.RegisterDeviceWithoutUser)).Methods("POST")
+	// /devices/user antes que /devices/{id}, que también la reconocería
+	apiRouter.Handle("/devices/user", require(auth.ScopeDevicesRead, deviceHandler.GetUserDevices)).Methods("GET")
+	apiRouter.Handle("/devices/{id}", require(auth.ScopeDevicesRead, deviceHandler.GetDevice)).Methods("GET")
+	apiRouter.Handle("/devices/link", require(auth.ScopeDevicesWrite, deviceHandler.LinkDeviceToUser)).Methods("POST")
+	apiRouter.Handle("/devices/update-token", require(auth.ScopeDevicesWrite, deviceHandler.UpdateToken)).Methods("POST")
+	apiRouter.Handle("/devices/sync-tokens", require(auth.ScopeDevicesWrite, deviceHandler.SyncTokens)).Methods("POST")
+	apiRouter.Handle("/devices/update-apns-token", require(auth.ScopeDevicesWrite, deviceHandler.UpdateAPNSToken)).Methods("POST")
+	apiRouter.Handle("/devices/update-fcm-token", require(auth.ScopeDevicesWrite, deviceHandler.UpdateFCMToken)).Methods("POST")
+	apiRouter.Handle("/devices/{id}/poll", require(auth.ScopeNotificationsRead, inboxHandler.Poll)).Methods("GET")
+
+	// Rutas de presencia
+	apiRouter.Handle("/presence/users/query", require(auth.ScopePresenceRead, presenceHandler.QueryUsersPresence)).Methods("POST")
+	apiRouter.Handle("/presence/users/{id}", require(auth.ScopePresenceRead, presenceHandler.GetUserPresence)).Methods("GET")
+	apiRouter.Handle("/presence/devices/{id}", require(auth.ScopePresenceRead, presenceHandler.GetDevicePresence)).Methods("GET")
 
 	// Ruta de WebSocket
-	router.HandleFunc("/ws", wsManager.HandleConnection)
+	router.HandleFunc(cfg.WebSocket.Path, wsManager.HandleConnection)
+
+	// Ruta de Server-Sent Events (alternativa a WebSocket)
+	router.HandleFunc("/sse", wsManager.HandleSSE).Methods("GET")
+
+	// Claves públicas de los tokens
+	router.HandleFunc("/.well-known/jwks.json", jwksHandler.GetJWKS).Methods("GET")
 
 	// Rutas de health y métricas
 	router.HandleFunc("/health", healthHandler.Check).Methods("GET")
@@ -170,7 +431,7 @@ func main() {
 	} */
 
 	// Configurar middleware
-	router.Use(createLoggingMiddleware(logger))
+	router.Use(httpHandlers.LoggingMiddleware(logger))
 
 	// Configurar servidor HTTP
 	srv := &http.Server{
@@ -181,31 +442,62 @@ func main() {
 		IdleTimeout:  120 * time.Second,
 	}
 
+	// Con certificado, el servidor usa TLS y acepta certificados de cliente
+	useTLS := cfg.Server.TLSCertFile != ""
+	if useTLS {
+		srv.TLSConfig, err = auth.NewServerTLSConfig(cfg.Server.ClientCAFile)
+		if err != nil {
+			logger.Fatal("Failed to configure TLS: %v", err)
+		}
+
+		// El certificado va en la configuración para compartirla con gRPC
+		cert, err := tls.LoadX509KeyPair(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		if err != nil {
+			logger.Fatal("Failed to load TLS certificate: %v", err)
+		}
+		srv.TLSConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// Crear servidor gRPC, con la misma configuración TLS que el HTTP
+	grpcServer := grpcHandlers.NewGRPCServer(
+		grpcHandlers.NewNotificationServer(notificationService, deviceService, tokenService, pushService, presenceService, logger),
+		serviceAuthService,
+		tenantService,
+		srv.TLSConfig,
+	)
+
+	// Iniciar el servidor gRPC en una goroutine
+	go func() {
+		if err := grpcHandlers.StartGRPCServer(cfg.Server.GRPCPort, grpcServer, logger); err != nil {
+			logger.Fatal("Failed to start gRPC server: %v", err)
+		}
+	}()
+
 	// Iniciar el servidor en una goroutine
 	go func() {
-		logger.Info("Starting server on port %d", cfg.Server.Port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("Starting server on port %d (TLS: %v)", cfg.Server.Port, useTLS)
+		var err error
+		if useTLS {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatal("Failed to start server: %v", err)
 		}
 	}()
 
 	// Configurar grácilmente el cierre
-	gracefulShutdown(srv, wsManager, cfg.Server.ShutdownTimeout, logger)
-}
+	gracefulShutdown(srv, grpcServer, wsManager, cfg.Server.ShutdownTimeout, cfg.WebSocket.DrainTimeout, logger)
 
-// Middleware para loggear peticiones
-func createLoggingMiddleware(logger *logging.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			next.ServeHTTP(w, r)
-			logger.Info("%s %s %s", r.Method, r.RequestURI, time.Since(start))
-		})
+	// Guardar el uso de las cuotas que quede pendiente
+	if err := quotaService.Flush(context.Background()); err != nil {
+		logger.Error("Failed to save quota usage: %v", err)
 	}
 }
 
 // Manejo de cierre gracioso
-func gracefulShutdown(srv *http.Server, wsManager *websocket.WebSocketManager, timeout time.Duration, logger *logging.Logger) {
+func gracefulShutdown(srv *http.Server, grpcServer *grpc.Server, wsManager *websocket.WebSocketManager, timeout, drainTimeout time.Duration, logger *logging.Logger) {
 	// Canal para recibir señales de sistema
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
@@ -218,11 +510,30 @@ func gracefulShutdown(srv *http.Server, wsManager *websocket.WebSocketManager, t
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// Primero cerrar el servidor HTTP
+	// Primero cerrar ordenadamente las conexiones en tiempo real; el
+	// servidor HTTP no espera a las conexiones WebSocket ya establecidas
+	drainCtx, drainCancel := context.WithTimeout(ctx, drainTimeout)
+	wsManager.Drain(drainCtx)
+	drainCancel()
+
+	// Después cerrar el servidor HTTP
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Error("HTTP server shutdown error: %v", err)
 	}
 
+	// Y el gRPC, esperando a las llamadas en curso hasta el plazo
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logger.Error("gRPC server shutdown error: %v", ctx.Err())
+		grpcServer.Stop()
+	}
+
 	// Luego cerrar el WebSocket manager
 	wsManager.Shutdown()
 
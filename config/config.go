@@ -4,28 +4,49 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Entorno de producción (APP_ENV)
+const EnvironmentProduction = "production"
+
+// Secreto JWT por defecto, solo válido fuera de producción
+const defaultJWTSecret = "your-secret-key"
+
 // Config contiene todas las configuraciones del servicio
 type Config struct {
+	Environment     string
 	Server          ServerConfig
 	Database        DatabaseConfig
 	JWT             JWTConfig
 	BusinessService BusinessServiceConfig
 	WebSocket       WebSocketConfig
+	ServiceAuth     ServiceAuthConfig
+	Quota           QuotaConfig
+	RateLimit       RateLimitConfig
+	SendQueue       SendQueueConfig
+	Templates       TemplateConfig
+	Push            PushConfig
 	Monitoring      MonitoringConfig
 	Logging         LoggingConfig
 }
 
-// ServerConfig contiene la configuración del servidor HTTP
+// ServerConfig contiene la configuración de los servidores HTTP y gRPC
 type ServerConfig struct {
 	Port            int
+	GRPCPort        int
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	ShutdownTimeout time.Duration
+
+	// TLS del servidor; con ClientCAFile se aceptan certificados de cliente
+	// (mTLS) para autenticar a los servicios
+	TLSCertFile  string
+	TLSKeyFile   string
+	ClientCAFile string
 }
 
 // DatabaseConfig contiene la configuración de la base de datos
@@ -39,11 +60,49 @@ type DatabaseConfig struct {
 	SSLMode  string
 }
 
+// String oculta la contraseña al imprimir la configuración
+func (c DatabaseConfig) String() string {
+	return fmt.Sprintf(
+		"{Host:%s Port:%d User:%s Name:%s Schema:%s SSLMode:%s}",
+		c.Host, c.Port, c.User, c.Name, c.Schema, c.SSLMode,
+	)
+}
+
 // JWTConfig contiene la configuración de JWT
 type JWTConfig struct {
+	// Secreto HS256 anterior a la rotación de claves; se sigue aceptando
+	// para los tokens sin kid y, si no hay KeyEncryptionKey, cifra las claves
 	Secret               string
 	TokenExpiry          time.Duration
 	TemporaryTokenExpiry time.Duration
+
+	// Duración de una familia de tokens de refresco (y de su sesión)
+	RefreshTokenExpiry time.Duration
+
+	// Algoritmo de las claves de firma: HS256, RS256, ES256 o EdDSA
+	Algorithm string
+
+	// Rotación de las claves de firma
+	KeyRotationInterval time.Duration
+	KeyGracePeriod      time.Duration
+	KeyRefreshInterval  time.Duration
+
+	// Secreto con el que se cifran las claves privadas en la base de datos
+	KeyEncryptionKey string
+
+	// Aceptar tokens sin kid firmados con Secret
+	AcceptLegacyTokens bool
+
+	// Cada cuánto se recargan las sesiones revocadas en otras instancias
+	RevocationRefreshInterval time.Duration
+}
+
+// String oculta los secretos al imprimir la configuración
+func (c JWTConfig) String() string {
+	return fmt.Sprintf(
+		"{Algorithm:%s TokenExpiry:%s TemporaryTokenExpiry:%s RefreshTokenExpiry:%s KeyRotationInterval:%s KeyGracePeriod:%s AcceptLegacyTokens:%t RevocationRefreshInterval:%s}",
+		c.Algorithm, c.TokenExpiry, c.TemporaryTokenExpiry, c.RefreshTokenExpiry, c.KeyRotationInterval, c.KeyGracePeriod, c.AcceptLegacyTokens, c.RevocationRefreshInterval,
+	)
 }
 
 // BusinessServiceConfig contiene la configuración para comunicarse con el servicio de negocio
@@ -54,12 +113,131 @@ type BusinessServiceConfig struct {
 
 // WebSocketConfig contiene la configuración del servidor WebSocket
 type WebSocketConfig struct {
-	Path              string
-	PingInterval      time.Duration
-	PongWait          time.Duration
-	MaxMessageSize    int64
-	WriteWait         time.Duration
-	MessageBufferSize int
+	Path               string
+	PingInterval       time.Duration
+	PongWait           time.Duration
+	MaxMessageSize     int64
+	WriteWait          time.Duration
+	MessageBufferSize  int
+	SlowConsumerPolicy string
+	AllowedOrigins     []string
+
+	// Keepalive de los clientes móviles (client_type=mobile)
+	MobilePingInterval time.Duration
+	MobilePongWait     time.Duration
+
+	// Cierre ordenado de las conexiones al apagar el servidor
+	DrainTimeout    time.Duration
+	ReconnectWindow time.Duration
+
+	// Antelación del aviso token_expiring antes de cerrar la conexión
+	TokenExpiryWarning time.Duration
+
+	// Plazo para recibir el mensaje auth de una conexión sin token
+	AuthTimeout time.Duration
+
+	// Limpieza de conexiones sin actividad
+	CleanupInterval   time.Duration
+	InactivityTimeout time.Duration
+
+	// Límites de conexiones simultáneas y política al superarlos
+	// (reject o kick_oldest)
+	MaxConnectionsPerDevice int
+	MaxConnectionsPerUser   int
+	ConnectionLimitPolicy   string
+
+	// Tasa de conexiones nuevas por IP
+	ConnectRateLimit  float64
+	ConnectRateBurst  int
+	TrustForwardedFor bool
+}
+
+// ServiceAuthConfig contiene la configuración de la autenticación de los
+// servicios que llaman a la API de envío
+type ServiceAuthConfig struct {
+	// API key con ámbito admin para crear las demás
+	AdminAPIKey string
+
+	// Common Name de los certificados de cliente aceptados y sus ámbitos
+	MTLSIdentities map[string][]string
+
+	// Límite de peticiones por defecto de las API keys nuevas
+	DefaultRateLimit float64
+	DefaultRateBurst int
+}
+
+// String oculta la API key de administración al imprimir la configuración
+func (c ServiceAuthConfig) String() string {
+	return fmt.Sprintf(
+		"{AdminAPIKeySet:%t MTLSIdentities:%v DefaultRateLimit:%g DefaultRateBurst:%d}",
+		c.AdminAPIKey != "", c.MTLSIdentities, c.DefaultRateLimit, c.DefaultRateBurst,
+	)
+}
+
+// QuotaConfig contiene las cuotas de envío de cada nivel, por segundo y por
+// día (UTC). Un valor 0 no limita.
+type QuotaConfig struct {
+	GlobalPerSecond float64
+	GlobalPerDay    int64
+	TenantPerSecond float64
+	TenantPerDay    int64
+	SenderPerSecond float64
+	SenderPerDay    int64
+	UserPerSecond   float64
+	UserPerDay      int64
+	DevicePerSecond float64
+	DevicePerDay    int64
+
+	// Cada cuánto se guarda el uso y se sincronizan las cuotas diarias
+	// entre instancias
+	FlushInterval time.Duration
+}
+
+// RateLimitConfig contiene dónde se guardan los límites de tasa de las API
+// keys y de las cuotas por segundo
+type RateLimitConfig struct {
+	// memory: cada instancia aplica su propio límite; postgres: las
+	// instancias comparten el límite a través de la base de datos
+	Backend string
+
+	// Cada cuánto se borran los límites que ya no retienen a nadie
+	CleanupInterval time.Duration
+}
+
+// SendQueueConfig contiene el reparto de los workers de envío entre los
+// carriles de prioridad
+type SendQueueConfig struct {
+	Workers int
+
+	// Peso de cada carril en el reparto de los workers
+	UrgentWeight int
+	NormalWeight int
+	BulkWeight   int
+
+	// Máximo de envíos en espera de cada carril
+	UrgentCapacity int
+	NormalCapacity int
+	BulkCapacity   int
+}
+
+// TemplateConfig contiene la configuración de las plantillas de
+// notificaciones
+type TemplateConfig struct {
+	// Carpeta con las plantillas JSON del tenant por defecto
+	Folder        string
+	DefaultLocale string
+
+	// Cada cuánto se recargan las plantillas publicadas en otras instancias
+	RefreshInterval time.Duration
+}
+
+// PushConfig contiene las longitudes máximas, en caracteres visibles, del
+// título y el cuerpo que se envían por FCM y APNS. Cero es sin límite.
+type PushConfig struct {
+	FCMTitleMaxLength  int
+	FCMBodyMaxLength   int
+	APNSTitleMaxLength int
+	APNSBodyMaxLength  int
 }
 
 // MonitoringConfig contiene la configuración de monitoreo
@@ -80,11 +258,16 @@ func LoadConfig() (*Config, error) {
 	_ = godotenv.Load() // No importa si falla (en producción no se usa .env)
 
 	config := &Config{
+		Environment: getEnv("APP_ENV", "development"),
 		Server: ServerConfig{
 			Port:            getEnvAsInt("SERVER_PORT", 8080),
+			GRPCPort:        getEnvAsInt("SERVER_GRPC_PORT", 50052),
 			ReadTimeout:     getEnvAsDuration("SERVER_READ_TIMEOUT", 10*time.Second),
 			WriteTimeout:    getEnvAsDuration("SERVER_WRITE_TIMEOUT", 10*time.Second),
 			ShutdownTimeout: getEnvAsDuration("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
+			TLSCertFile:     getEnv("SERVER_TLS_CERT_FILE", ""),
+			TLSKeyFile:      getEnv("SERVER_TLS_KEY_FILE", ""),
+			ClientCAFile:    getEnv("SERVER_TLS_CLIENT_CA_FILE", ""),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -96,9 +279,18 @@ func LoadConfig() (*Config, error) {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		JWT: JWTConfig{
-			Secret:               getEnv("JWT_SECRET", "your-secret-key"),
-			TokenExpiry:          getEnvAsDuration("JWT_TOKEN_EXPIRY", 24*time.Hour),
-			TemporaryTokenExpiry: getEnvAsDuration("JWT_TEMP_TOKEN_EXPIRY", 30*time.Minute),
+			Secret:               getEnv("JWT_SECRET", defaultJWTSecret),
+			TokenExpiry:          getEnvAsDuration("JWT_TOKEN_EXPIRY", 15*time.Minute),
+			TemporaryTokenExpiry: getEnvAsDuration("JWT_TEMP_TOKEN_EXPIRY", 15*time.Minute),
+			RefreshTokenExpiry:   getEnvAsDuration("JWT_REFRESH_TOKEN_EXPIRY", 30*24*time.Hour),
+			Algorithm:            getEnv("JWT_ALGORITHM", "RS256"),
+			KeyRotationInterval:  getEnvAsDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+			KeyGracePeriod:       getEnvAsDuration("JWT_KEY_GRACE_PERIOD", 25*time.Hour),
+			KeyRefreshInterval:   getEnvAsDuration("JWT_KEY_REFRESH_INTERVAL", time.Minute),
+			KeyEncryptionKey:     getEnv("JWT_KEY_ENCRYPTION_KEY", ""),
+			AcceptLegacyTokens:   getEnvAsBool("JWT_ACCEPT_LEGACY_TOKENS", true),
+
+			RevocationRefreshInterval: getEnvAsDuration("JWT_REVOCATION_REFRESH_INTERVAL", 10*time.Second),
 		},
 		BusinessService: BusinessServiceConfig{
 			GRPCAddress: getEnv("BUSINESS_SERVICE_GRPC_ADDRESS", "localhost:50051"),
@@ -111,6 +303,68 @@ func LoadConfig() (*Config, error) {
 			MaxMessageSize:    getEnvAsInt64("WS_MAX_MESSAGE_SIZE", 4096),
 			WriteWait:         getEnvAsDuration("WS_WRITE_WAIT", 10*time.Second),
 			MessageBufferSize: getEnvAsInt("WS_MESSAGE_BUFFER_SIZE", 256),
+			// drop_oldest o disconnect
+			SlowConsumerPolicy: getEnv("WS_SLOW_CONSUMER_POLICY", "drop_oldest"),
+			// Lista separada por comas; vacía solo permite el mismo origen
+			AllowedOrigins:     getEnvAsSlice("WS_ALLOWED_ORIGINS", nil),
+			MobilePingInterval: getEnvAsDuration("WS_MOBILE_PING_INTERVAL", 2*time.Minute),
+			MobilePongWait:     getEnvAsDuration("WS_MOBILE_PONG_WAIT", 150*time.Second),
+			DrainTimeout:       getEnvAsDuration("WS_DRAIN_TIMEOUT", 10*time.Second),
+			ReconnectWindow:    getEnvAsDuration("WS_RECONNECT_WINDOW", 30*time.Second),
+			TokenExpiryWarning: getEnvAsDuration("WS_TOKEN_EXPIRY_WARNING", time.Minute),
+			AuthTimeout:        getEnvAsDuration("WS_AUTH_TIMEOUT", 5*time.Second),
+			CleanupInterval:    getEnvAsDuration("WS_CLEANUP_INTERVAL", time.Minute),
+			InactivityTimeout:  getEnvAsDuration("WS_INACTIVITY_TIMEOUT", 5*time.Minute),
+
+			MaxConnectionsPerDevice: getEnvAsInt("WS_MAX_CONNECTIONS_PER_DEVICE", 5),
+			MaxConnectionsPerUser:   getEnvAsInt("WS_MAX_CONNECTIONS_PER_USER", 20),
+			ConnectionLimitPolicy:   getEnv("WS_CONNECTION_LIMIT_POLICY", "kick_oldest"),
+			ConnectRateLimit:        getEnvAsFloat("WS_CONNECT_RATE_LIMIT", 5),
+			ConnectRateBurst:        getEnvAsInt("WS_CONNECT_RATE_BURST", 20),
+			TrustForwardedFor:       getEnvAsBool("WS_TRUST_FORWARDED_FOR", false),
+		},
+		ServiceAuth: ServiceAuthConfig{
+			AdminAPIKey:      getEnv("SERVICE_ADMIN_API_KEY", ""),
+			MTLSIdentities:   getEnvAsScopeMap("SERVICE_MTLS_IDENTITIES"),
+			DefaultRateLimit: getEnvAsFloat("SERVICE_API_KEY_RATE_LIMIT", 50),
+			DefaultRateBurst: getEnvAsInt("SERVICE_API_KEY_RATE_BURST", 100),
+		},
+		Quota: QuotaConfig{
+			GlobalPerSecond: getEnvAsFloat("QUOTA_GLOBAL_PER_SECOND", 0),
+			GlobalPerDay:    getEnvAsInt64("QUOTA_GLOBAL_PER_DAY", 0),
+			TenantPerSecond: getEnvAsFloat("QUOTA_TENANT_PER_SECOND", 0),
+			TenantPerDay:    getEnvAsInt64("QUOTA_TENANT_PER_DAY", 0),
+			SenderPerSecond: getEnvAsFloat("QUOTA_SENDER_PER_SECOND", 0),
+			SenderPerDay:    getEnvAsInt64("QUOTA_SENDER_PER_DAY", 0),
+			UserPerSecond:   getEnvAsFloat("QUOTA_USER_PER_SECOND", 0),
+			UserPerDay:      getEnvAsInt64("QUOTA_USER_PER_DAY", 0),
+			DevicePerSecond: getEnvAsFloat("QUOTA_DEVICE_PER_SECOND", 0),
+			DevicePerDay:    getEnvAsInt64("QUOTA_DEVICE_PER_DAY", 0),
+			FlushInterval:   getEnvAsDuration("QUOTA_FLUSH_INTERVAL", 10*time.Second),
+		},
+		RateLimit: RateLimitConfig{
+			Backend:         getEnv("RATE_LIMIT_BACKEND", "memory"),
+			CleanupInterval: getEnvAsDuration("RATE_LIMIT_CLEANUP_INTERVAL", time.Minute),
+		},
+		SendQueue: SendQueueConfig{
+			Workers:        getEnvAsInt("SEND_WORKERS", 32),
+			UrgentWeight:   getEnvAsInt("SEND_LANE_URGENT_WEIGHT", 8),
+			NormalWeight:   getEnvAsInt("SEND_LANE_NORMAL_WEIGHT", 4),
+			BulkWeight:     getEnvAsInt("SEND_LANE_BULK_WEIGHT", 1),
+			UrgentCapacity: getEnvAsInt("SEND_LANE_URGENT_CAPACITY", 1000),
+			NormalCapacity: getEnvAsInt("SEND_LANE_NORMAL_CAPACITY", 1000),
+			BulkCapacity:   getEnvAsInt("SEND_LANE_BULK_CAPACITY", 10000),
+		},
+		Templates: TemplateConfig{
+			Folder:          getEnv("TEMPLATE_FOLDER", "./templates"),
+			DefaultLocale:   getEnv("TEMPLATE_DEFAULT_LOCALE", "es"),
+			RefreshInterval: getEnvAsDuration("TEMPLATE_REFRESH_INTERVAL", 10*time.Second),
+		},
+		Push: PushConfig{
+			FCMTitleMaxLength:  getEnvAsInt("PUSH_FCM_TITLE_MAX_LENGTH", 65),
+			FCMBodyMaxLength:   getEnvAsInt("PUSH_FCM_BODY_MAX_LENGTH", 240),
+			APNSTitleMaxLength: getEnvAsInt("PUSH_APNS_TITLE_MAX_LENGTH", 50),
+			APNSBodyMaxLength:  getEnvAsInt("PUSH_APNS_BODY_MAX_LENGTH", 1000),
 		},
 		Monitoring: MonitoringConfig{
 			MetricsEnabled: getEnvAsBool("METRICS_ENABLED", true),
@@ -122,6 +376,16 @@ func LoadConfig() (*Config, error) {
 		},
 	}
 
+	// En producción no se admite el secreto por defecto
+	if config.Environment == EnvironmentProduction {
+		if config.JWT.Secret == defaultJWTSecret {
+			return nil, fmt.Errorf("JWT_SECRET must be set in production")
+		}
+		if config.JWT.KeyEncryptionKey == defaultJWTSecret {
+			return nil, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY must not use the default secret in production")
+		}
+	}
+
 	return config, nil
 }
 
@@ -159,6 +423,14 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
 	valueStr := getEnv(key, "")
 	if value, err := strconv.ParseBool(valueStr); err == nil {
@@ -167,6 +439,34 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, value := range strings.Split(valueStr, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsScopeMap lee una lista de identidades con sus ámbitos separados
+// por espacios, por ejemplo "billing=notifications:send,ops=admin"
+func getEnvAsScopeMap(key string) map[string][]string {
+	identities := make(map[string][]string)
+	for _, entry := range getEnvAsSlice(key, nil) {
+		name, scopes, _ := strings.Cut(entry, "=")
+		if name = strings.TrimSpace(name); name != "" {
+			identities[name] = strings.Fields(scopes)
+		}
+	}
+	return identities
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	valueStr := getEnv(key, "")
 	if value, err := time.ParseDuration(valueStr); err == nil {
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConfigStringHidesSecrets(t *testing.T) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := map[string]*string{
+		"JWT.Secret":              &cfg.JWT.Secret,
+		"JWT.KeyEncryptionKey":    &cfg.JWT.KeyEncryptionKey,
+		"ServiceAuth.AdminAPIKey": &cfg.ServiceAuth.AdminAPIKey,
+		"Database.Password":       &cfg.Database.Password,
+	}
+	for name, field := range secrets {
+		*field = "secret-" + strings.ToLower(strings.ReplaceAll(name, ".", "-"))
+	}
+
+	// main registra la configuración con %+v al arrancar
+	for _, format := range []string{"%v", "%+v"} {
+		printed := fmt.Sprintf(format, cfg)
+		for name, field := range secrets {
+			if strings.Contains(printed, *field) {
+				t.Errorf("%s printed with %s: %s", name, format, printed)
+			}
+		}
+	}
+}
+
+func TestPushLimits(t *testing.T) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.Push, (PushConfig{65, 240, 50, 1000}); got != want {
+		t.Errorf("default push limits = %+v, want %+v", got, want)
+	}
+
+	t.Setenv("PUSH_FCM_TITLE_MAX_LENGTH", "40")
+	t.Setenv("PUSH_APNS_BODY_MAX_LENGTH", "0")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.Push, (PushConfig{40, 240, 50, 0}); got != want {
+		t.Errorf("push limits = %+v, want %+v", got, want)
+	}
+}
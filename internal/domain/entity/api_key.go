@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey es una credencial de un servicio que llama a la API. Solo se guarda
+// el hash de la clave; Prefix son sus primeros caracteres, para reconocerla.
+// Una clave sin tenant es de la plataforma y puede actuar sobre cualquiera.
+type APIKey struct {
+	ID        uuid.UUID  `json:"id"`
+	TenantID  string     `json:"tenant_id,omitempty"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	KeyHash   string     `json:"-"`
+	Scopes    []string   `json:"scopes"`
+	RateLimit float64    `json:"rate_limit"`
+	RateBurst int        `json:"rate_burst"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive indica si la clave no está revocada ni expirada
+func (k *APIKey) IsActive() bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt)
+}
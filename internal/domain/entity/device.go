@@ -9,8 +9,10 @@ import (
 // Device representa un dispositivo que puede recibir notificaciones
 type Device struct {
 	ID               uuid.UUID  `json:"id"`
+	TenantID         string     `json:"tenant_id"`
 	UserID           *uint      `json:"user_id,omitempty"`
 	Model            *string    `json:"model,omitempty"`
+	Locale           *string    `json:"locale,omitempty"`
 	DeviceIdentifier string     `json:"device_identifier"`
 	Verified         bool       `json:"verified"`
 	LastAccess       time.Time  `json:"last_access"`
@@ -22,10 +24,11 @@ type Device struct {
 }
 
 // NewDevice crea una nueva instancia de Device
-func NewDevice(deviceIdentifier string, userID *uint, model *string) *Device {
+func NewDevice(tenantID, deviceIdentifier string, userID *uint, model *string) *Device {
 	now := time.Now()
 	return &Device{
 		ID:               uuid.New(),
+		TenantID:         tenantID,
 		UserID:           userID,
 		Model:            model,
 		DeviceIdentifier: deviceIdentifier,
@@ -55,6 +58,16 @@ func (d *Device) IsActive() bool {
 	return d.Status == "active" && d.DeleteTime == nil
 }
 
+// SetLocale establece el idioma del dispositivo. Uno vacío no cambia el
+// que tenía.
+func (d *Device) SetLocale(locale string) {
+	if locale == "" {
+		return
+	}
+	d.Locale = &locale
+	d.UpdateTime = time.Now()
+}
+
 // LinkToUser vincula el dispositivo a un usuario
 func (d *Device) LinkToUser(userID uint) {
 	d.UserID = &userID
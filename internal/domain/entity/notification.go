@@ -17,9 +17,28 @@ const (
 	NotificationTypeMessage NotificationType = "message"
 )
 
+// Prioridades de una notificación. La alta se envía a FCM y APNS con
+// prioridad alta; la masiva (campañas) cede la capacidad de envío a las demás.
+const (
+	PriorityBulk   = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// SendLane representa los carriles de envío en los que se reparte la
+// capacidad de los workers
+type SendLane string
+
+const (
+	SendLaneUrgent SendLane = "urgent"
+	SendLaneNormal SendLane = "normal"
+	SendLaneBulk   SendLane = "bulk"
+)
+
 // Notification representa una notificación a enviar
 type Notification struct {
 	ID               uuid.UUID        `json:"id"`
+	TenantID         string           `json:"tenant_id"`
 	UserID           string           `json:"user_id"`
 	Title            string           `json:"title"`
 	Message          string           `json:"message"`
@@ -29,6 +48,12 @@ type Notification struct {
 	Priority         int              `json:"priority"`
 	CreatedAt        time.Time        `json:"created_at"`
 	ExpiresAt        *time.Time       `json:"expires_at,omitempty"`
+
+	// Plantilla, versión e idioma con los que se renderizó, si se envió con
+	// una plantilla
+	TemplateID      string `json:"template_id,omitempty"`
+	TemplateVersion int    `json:"template_version,omitempty"`
+	Locale          string `json:"locale,omitempty"`
 }
 
 // NewNotification crea una nueva notificación
@@ -44,12 +69,13 @@ func NewNotification(userID, title, message string, data map[string]interface{},
 
 	return &Notification{
 		ID:               uuid.New(),
+		TenantID:         DefaultTenantID,
 		UserID:           userID,
 		Title:            title,
 		Message:          message,
 		Data:             dataJSON,
 		NotificationType: notificationType,
-		Priority:         0, // Prioridad normal por defecto
+		Priority:         PriorityNormal,
 		CreatedAt:        time.Now(),
 	}, nil
 }
@@ -59,11 +85,57 @@ func (n *Notification) SetPriority(priority int) {
 	n.Priority = priority
 }
 
+// Lane devuelve el carril de envío de la notificación: el urgente para las
+// de tipo urgent o system y las de prioridad alta, el masivo para las de
+// prioridad masiva y el normal para el resto
+func (n *Notification) Lane() SendLane {
+	switch {
+	case n.NotificationType == NotificationTypeUrgent,
+		n.NotificationType == NotificationTypeSystem,
+		n.Priority >= PriorityHigh:
+		return SendLaneUrgent
+	case n.Priority <= PriorityBulk:
+		return SendLaneBulk
+	default:
+		return SendLaneNormal
+	}
+}
+
+// SetTenant establece el tenant de la notificación
+func (n *Notification) SetTenant(tenantID string) {
+	n.TenantID = tenantID
+}
+
 // SetSender establece el remitente de la notificación
 func (n *Notification) SetSender(senderID string) {
 	n.SenderID = senderID
 }
 
+// SetContent reemplaza el título, el mensaje y los datos de la notificación
+func (n *Notification) SetContent(title, message string, data map[string]interface{}) error {
+	var dataJSON json.RawMessage
+	if data != nil {
+		var err error
+		dataJSON, err = json.Marshal(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	n.Title = title
+	n.Message = message
+	n.Data = dataJSON
+	return nil
+}
+
+// SetTemplate guarda la plantilla, la versión y el idioma con los que se
+// renderizó la notificación
+func (n *Notification) SetTemplate(templateID string, version int, locale string) {
+	n.TemplateID = templateID
+	n.TemplateVersion = version
+	n.Locale = locale
+}
+
 // SetExpiry establece el tiempo de expiración
 func (n *Notification) SetExpiry(duration time.Duration) {
 	expiryTime := n.CreatedAt.Add(duration)
@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QueuedMessageStatus representa el estado de un mensaje en la cola persistente
+type QueuedMessageStatus string
+
+const (
+	QueuedMessageStatusPending QueuedMessageStatus = "pending"
+)
+
+// Número máximo de reintentos por defecto de un mensaje encolado
+const defaultQueuedMessageMaxRetries = 5
+
+// QueuedMessage es un mensaje en tiempo real que no pudo entregarse a una
+// conexión y se guarda para reenviarlo cuando el dispositivo vuelva a estar
+// disponible
+type QueuedMessage struct {
+	ID             uuid.UUID           `json:"id"`
+	NotificationID uuid.UUID           `json:"notification_id"`
+	DeviceID       uuid.UUID           `json:"device_id"`
+	Payload        json.RawMessage     `json:"payload"`
+	Status         QueuedMessageStatus `json:"status"`
+	CreatedAt      time.Time           `json:"created_at"`
+	NextAttemptAt  time.Time           `json:"next_attempt_at"`
+	RetryCount     int                 `json:"retry_count"`
+	MaxRetries     int                 `json:"max_retries"`
+}
+
+// NewQueuedMessage crea un nuevo mensaje para la cola persistente
+func NewQueuedMessage(notificationID, deviceID uuid.UUID, payload []byte) *QueuedMessage {
+	now := time.Now()
+	return &QueuedMessage{
+		ID:             uuid.New(),
+		NotificationID: notificationID,
+		DeviceID:       deviceID,
+		Payload:        json.RawMessage(payload),
+		Status:         QueuedMessageStatusPending,
+		CreatedAt:      now,
+		NextAttemptAt:  now,
+		RetryCount:     0,
+		MaxRetries:     defaultQueuedMessageMaxRetries,
+	}
+}
@@ -0,0 +1,33 @@
+package entity
+
+import "time"
+
+// QuotaLevel es un nivel de las cuotas de envío, del más general al más
+// concreto
+type QuotaLevel string
+
+const (
+	// QuotaLevelGlobal limita todos los envíos del servicio
+	QuotaLevelGlobal QuotaLevel = "global"
+	// QuotaLevelTenant limita los envíos de un tenant
+	QuotaLevelTenant QuotaLevel = "tenant"
+	// QuotaLevelSender limita los envíos de un servicio (API key o
+	// certificado) de un tenant
+	QuotaLevelSender QuotaLevel = "sender"
+	// QuotaLevelUser limita las notificaciones que recibe un usuario
+	QuotaLevelUser QuotaLevel = "user"
+	// QuotaLevelDevice limita las notificaciones que recibe un dispositivo
+	QuotaLevelDevice QuotaLevel = "device"
+)
+
+// QuotaUsage es el uso de una cuota durante un día (UTC): los envíos
+// admitidos y los rechazados por superarla. Subject identifica lo limitado
+// dentro del nivel: el tenant, el servicio, el usuario o el dispositivo.
+type QuotaUsage struct {
+	Day       time.Time  `json:"day"`
+	Level     QuotaLevel `json:"level"`
+	TenantID  string     `json:"tenant_id"`
+	Subject   string     `json:"subject"`
+	Count     int64      `json:"count"`
+	Throttled int64      `json:"throttled"`
+}
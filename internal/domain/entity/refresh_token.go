@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken es un token de refresco emitido para un dispositivo. Solo se
+// guarda el hash del token. Cada uso lo sustituye por otro de la misma
+// familia; si se presenta uno ya usado, se revoca la familia entera.
+type RefreshToken struct {
+	ID        uuid.UUID
+	FamilyID  uuid.UUID
+	DeviceID  uuid.UUID
+	UserID    string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// NewRefreshToken crea un nuevo token de refresco. userID está vacío en los
+// dispositivos sin usuario, que reciben tokens de acceso temporales.
+func NewRefreshToken(familyID, deviceID uuid.UUID, userID, tokenHash string, expiresAt time.Time) *RefreshToken {
+	return &RefreshToken{
+		ID:        uuid.New(),
+		FamilyID:  familyID,
+		DeviceID:  deviceID,
+		UserID:    userID,
+		TokenHash: tokenHash,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+}
+
+// IsTemporary indica si el token emite tokens de acceso temporales
+func (t *RefreshToken) IsTemporary() bool {
+	return t.UserID == ""
+}
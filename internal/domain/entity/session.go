@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session es una sesión de un usuario en un dispositivo. Empieza al emitir
+// los primeros tokens permanentes y dura lo que su familia de tokens de
+// refresco; los tokens de acceso la indican en el claim sid. Revocarla
+// invalida sus tokens y cierra las conexiones abiertas con ellos.
+type Session struct {
+	ID               uuid.UUID  `json:"id"`
+	TenantID         string     `json:"tenant_id"`
+	UserID           string     `json:"user_id"`
+	DeviceID         uuid.UUID  `json:"device_id"`
+	DeviceIdentifier string     `json:"device_identifier"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewSession crea una nueva sesión
+func NewSession(id uuid.UUID, tenantID, userID string, deviceID uuid.UUID, deviceIdentifier string, expiresAt time.Time) *Session {
+	return &Session{
+		ID:               id,
+		TenantID:         tenantID,
+		UserID:           userID,
+		DeviceID:         deviceID,
+		DeviceIdentifier: deviceIdentifier,
+		CreatedAt:        time.Now(),
+		ExpiresAt:        expiresAt,
+	}
+}
+
+// IsActive indica si la sesión no está revocada ni expirada
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"time"
+)
+
+// SigningKey es una clave de firma de los tokens JWT, identificada por el
+// kid que llevan los tokens en su cabecera. La clave privada se guarda
+// cifrada; la pública se publica en el JWKS.
+type SigningKey struct {
+	ID         string     `json:"id"`
+	Algorithm  string     `json:"algorithm"`
+	PrivateKey []byte     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// IsActive indica si la clave se usa para firmar tokens nuevos. Una clave
+// retirada solo sirve para verificar los tokens que firmó hasta ExpiresAt.
+func (k *SigningKey) IsActive() bool {
+	return k.RetiredAt == nil
+}
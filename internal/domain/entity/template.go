@@ -0,0 +1,73 @@
+package entity
+
+import "time"
+
+// TemplateStatus es el estado de una versión de una plantilla
+type TemplateStatus string
+
+const (
+	// TemplateStatusDraft es una versión que aún no se ha publicado
+	TemplateStatusDraft TemplateStatus = "draft"
+	// TemplateStatusPublished es la versión que se usa al enviar
+	TemplateStatusPublished TemplateStatus = "published"
+	// TemplateStatusArchived es una versión publicada antes que la actual;
+	// se puede volver a publicar para deshacer un cambio
+	TemplateStatusArchived TemplateStatus = "archived"
+)
+
+// Template es una plantilla de notificaciones de un tenant. Su contenido
+// está en sus versiones; al enviar se usa la publicada.
+type Template struct {
+	TenantID    string `json:"-"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// Última versión creada y versión publicada (0 si no hay ninguna)
+	LatestVersion    int `json:"latest_version"`
+	PublishedVersion int `json:"published_version"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TemplateVersion es una versión de una plantilla. Su contenido no cambia
+// una vez creada; editar la plantilla crea una versión nueva.
+type TemplateVersion struct {
+	TenantID   string `json:"-"`
+	TemplateID string `json:"template_id"`
+	Version    int    `json:"version"`
+
+	Title   string                    `json:"title"`
+	Body    string                    `json:"body"`
+	Data    map[string]string         `json:"data,omitempty"`
+	Locales map[string]TemplateLocale `json:"locales,omitempty"`
+
+	// Variables que usan los textos; sin declarar, se exigen todas las usadas
+	Variables map[string]TemplateVariable `json:"variables,omitempty"`
+
+	Status TemplateStatus `json:"status"`
+
+	// Servicio que creó la versión
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// TemplateLocale es el contenido de una versión de una plantilla en un
+// idioma
+type TemplateLocale struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// TemplateVariable declara una variable de una versión de una plantilla: su
+// tipo (string, number, integer, boolean, object o array; cualquiera si no
+// se indica), si es obligatoria y su valor por defecto
+type TemplateVariable struct {
+	Type        string      `json:"type,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
@@ -0,0 +1,40 @@
+package entity
+
+import "time"
+
+// DefaultTenantID es el tenant de los datos anteriores a la multi-tenencia y
+// de las peticiones que no indican otro
+const DefaultTenantID = "default"
+
+// Tenant es una aplicación que comparte el servicio con las demás. Sus
+// dispositivos, notificaciones, plantillas y límites están aislados de los
+// de otros tenants, y envía por FCM y APNS con sus propias credenciales.
+type Tenant struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Credenciales de APNS: topic (bundle ID) y certificado de cliente
+	APNSBundleID        string `json:"apns_bundle_id,omitempty"`
+	APNSCertificatePath string `json:"apns_certificate_path,omitempty"`
+	APNSProduction      bool   `json:"apns_production"`
+
+	// Server key de FCM, cifrada con la clave maestra del servicio
+	FCMServerKey []byte `json:"-"`
+
+	// Cuotas de envío del tenant; 0 usa las de la configuración
+	QuotaPerSecond float64 `json:"quota_per_second"`
+	QuotaPerDay    int64   `json:"quota_per_day"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// HasAPNS indica si el tenant tiene credenciales de APNS
+func (t *Tenant) HasAPNS() bool {
+	return t.APNSBundleID != "" && t.APNSCertificatePath != ""
+}
+
+// HasFCM indica si el tenant tiene credenciales de FCM
+func (t *Tenant) HasFCM() bool {
+	return len(t.FCMServerKey) > 0
+}
@@ -13,6 +13,7 @@ const (
 	TokenTypeWebSocket TokenType = "websocket"
 	TokenTypeAPNS      TokenType = "apns"
 	TokenTypeFCM       TokenType = "fcm"
+	TokenTypePoll      TokenType = "poll"
 )
 
 // NotificationToken almacena información de tokens para diferentes canales
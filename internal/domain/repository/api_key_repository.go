@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"notification-service/internal/domain/entity"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyRepository define las operaciones sobre las API keys de servicio
+type APIKeyRepository interface {
+	// Guardar una clave nueva
+	Create(ctx context.Context, key *entity.APIKey) error
+
+	// Obtener una clave por el hash de su valor
+	GetByHash(ctx context.Context, keyHash string) (*entity.APIKey, error)
+
+	// Obtener una clave por su ID
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.APIKey, error)
+
+	// Obtener todas las claves, incluidas las revocadas
+	List(ctx context.Context) ([]*entity.APIKey, error)
+
+	// Obtener las claves de un tenant, incluidas las revocadas
+	ListByTenant(ctx context.Context, tenantID string) ([]*entity.APIKey, error)
+
+	// Revocar una clave
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
@@ -41,6 +41,12 @@ type DeliveryRepository interface {
 	// Obtener entregas fallidas por período
 	GetFailedByTimeRange(ctx context.Context, start, end time.Time) ([]*entity.DeliveryTracking, error)
 
+	// Reclamar entregas pendientes de un dispositivo y canal, marcándolas como enviadas
+	ClaimPendingForDevice(ctx context.Context, deviceID uuid.UUID, channel entity.TokenType, limit int, sentAt time.Time) ([]*entity.DeliveryTracking, error)
+
+	// Marcar como entregadas las entregas enviadas hasta un instante dado
+	MarkDeliveredUpTo(ctx context.Context, deviceID uuid.UUID, channel entity.TokenType, sentAt time.Time) (int64, error)
+
 	// Obtener estadísticas de entrega por usuario
 	GetUserDeliveryStats(ctx context.Context, userID string) (map[entity.DeliveryStatus]int, error)
 }
@@ -14,11 +14,11 @@ type DeviceRepository interface {
 	// Obtener un dispositivo por su ID
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.Device, error)
 
-	// Obtener un dispositivo por su identificador único
-	GetByDeviceIdentifier(ctx context.Context, identifier string) (*entity.Device, error)
+	// Obtener un dispositivo por su identificador, único dentro del tenant
+	GetByDeviceIdentifier(ctx context.Context, tenantID, identifier string) (*entity.Device, error)
 
-	// Obtener todos los dispositivos de un usuario
-	GetByUserID(ctx context.Context, userID uint) ([]*entity.Device, error)
+	// Obtener todos los dispositivos de un usuario de un tenant
+	GetByUserID(ctx context.Context, tenantID string, userID uint) ([]*entity.Device, error)
 
 	// Guardar un nuevo dispositivo
 	Save(ctx context.Context, device *entity.Device) error
@@ -6,6 +6,12 @@ var (
 	ErrNotificationNotFound = NewError("notification not found")
 	ErrDeviceNotFound       = NewError("device not found")
 	ErrTokenNotFound        = NewError("token not found")
+	ErrSessionNotFound      = NewError("session not found")
+	ErrRefreshTokenNotFound = NewError("refresh token not found")
+	ErrAPIKeyNotFound       = NewError("api key not found")
+	ErrTenantNotFound       = NewError("tenant not found")
+	ErrTemplateNotFound     = NewError("template not found")
+	ErrTemplateExists       = NewError("template already exists")
 )
 
 // NewError crea una nueva instancia de Error
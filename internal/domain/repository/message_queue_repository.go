@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"notification-service/internal/domain/entity"
+
+	"github.com/google/uuid"
+)
+
+// MessageQueueRepository define las operaciones sobre la cola persistente de
+// mensajes en tiempo real
+type MessageQueueRepository interface {
+	// Encolar un mensaje
+	Enqueue(ctx context.Context, message *entity.QueuedMessage) error
+
+	// Extraer (y eliminar) los mensajes pendientes más antiguos de un dispositivo
+	DequeueForDevice(ctx context.Context, deviceID uuid.UUID, limit int) ([]*entity.QueuedMessage, error)
+}
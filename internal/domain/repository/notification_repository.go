@@ -15,8 +15,8 @@ type NotificationRepository interface {
 	// Obtener una notificación por su ID
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.Notification, error)
 	
-	// Obtener notificaciones por usuario
-	GetByUserID(ctx context.Context, userID string, limit, offset int) ([]*entity.Notification, error)
+	// Obtener notificaciones por usuario de un tenant
+	GetByUserID(ctx context.Context, tenantID, userID string, limit, offset int) ([]*entity.Notification, error)
 	
 	// Actualizar una notificación
 	Update(ctx context.Context, notification *entity.Notification) error
@@ -30,6 +30,6 @@ type NotificationRepository interface {
 	// Obtener notificaciones expiradas
 	GetExpiredNotifications(ctx context.Context) ([]*entity.Notification, error)
 	
-	// Contar notificaciones no leídas por usuario
-	CountUnreadByUser(ctx context.Context, userID string) (int, error)
+	// Contar notificaciones no leídas por usuario de un tenant
+	CountUnreadByUser(ctx context.Context, tenantID, userID string) (int, error)
 }
\ No newline at end of file
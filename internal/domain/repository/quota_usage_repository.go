@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"notification-service/internal/domain/entity"
+)
+
+// QuotaUsageRepository define las operaciones sobre el uso diario de las
+// cuotas de envío
+type QuotaUsageRepository interface {
+	// Sumar los envíos de usage al uso guardado y devolver el total
+	Add(ctx context.Context, usage *entity.QuotaUsage) (*entity.QuotaUsage, error)
+
+	// Obtener el uso de los niveles indicados entre dos días, ambos
+	// incluidos, de un tenant o de todos si tenantID está vacío
+	List(ctx context.Context, tenantID string, levels []entity.QuotaLevel, from, to time.Time) ([]*entity.QuotaUsage, error)
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"notification-service/internal/domain/entity"
+
+	"github.com/google/uuid"
+)
+
+// RefreshTokenRepository define las operaciones sobre los tokens de refresco
+type RefreshTokenRepository interface {
+	// Guardar un token nuevo
+	Create(ctx context.Context, token *entity.RefreshToken) error
+
+	// Obtener un token por el hash de su valor
+	GetByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error)
+
+	// Marcar un token como usado; devuelve false si ya estaba usado o revocado
+	MarkUsed(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// Revocar todos los tokens de una familia
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+
+	// Revocar todos los tokens de un dispositivo
+	RevokeByDevice(ctx context.Context, deviceID uuid.UUID) error
+
+	// Eliminar los tokens expirados
+	DeleteExpired(ctx context.Context) error
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+
+	"notification-service/internal/domain/entity"
+
+	"github.com/google/uuid"
+)
+
+// SessionRepository define las operaciones sobre las sesiones de usuario
+type SessionRepository interface {
+	// Guardar una sesión nueva
+	Create(ctx context.Context, session *entity.Session) error
+
+	// Obtener una sesión por su ID (jti)
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Session, error)
+
+	// Obtener las sesiones no revocadas ni expiradas de un usuario de un tenant
+	ListActiveByUser(ctx context.Context, tenantID, userID string) ([]*entity.Session, error)
+
+	// Revocar una sesión
+	Revoke(ctx context.Context, id uuid.UUID) error
+
+	// Revocar las sesiones activas de un usuario de un tenant; devuelve las
+	// revocadas
+	RevokeByUser(ctx context.Context, tenantID, userID string) ([]*entity.Session, error)
+
+	// Revocar las sesiones activas de un dispositivo; devuelve las revocadas
+	RevokeByDevice(ctx context.Context, deviceID uuid.UUID) ([]*entity.Session, error)
+
+	// Obtener las sesiones revocadas cuyo token todavía no ha expirado
+	ListRevoked(ctx context.Context) ([]*entity.Session, error)
+
+	// Eliminar las sesiones expiradas
+	DeleteExpired(ctx context.Context) error
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"notification-service/internal/domain/entity"
+)
+
+// SigningKeyRepository define las operaciones sobre las claves de firma de
+// los tokens JWT
+type SigningKeyRepository interface {
+	// Guardar una clave nueva
+	Create(ctx context.Context, key *entity.SigningKey) error
+
+	// Obtener las claves que siguen sirviendo para verificar tokens
+	ListValid(ctx context.Context) ([]*entity.SigningKey, error)
+
+	// Retirar las claves activas creadas antes de una fecha; se siguen
+	// aceptando para verificar hasta expiresAt
+	RetireBefore(ctx context.Context, createdBefore, retiredAt, expiresAt time.Time) error
+
+	// Eliminar las claves cuyo periodo de gracia ya terminó
+	DeleteExpired(ctx context.Context) error
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+
+	"notification-service/internal/domain/entity"
+)
+
+// TemplateRepository define las operaciones sobre las plantillas de
+// notificaciones y sus versiones
+type TemplateRepository interface {
+	// Guardar una plantilla nueva con su primera versión
+	Create(ctx context.Context, template *entity.Template, version *entity.TemplateVersion) error
+
+	// Obtener una plantilla de un tenant por su ID
+	GetByID(ctx context.Context, tenantID, id string) (*entity.Template, error)
+
+	// Obtener las plantillas de un tenant
+	List(ctx context.Context, tenantID string) ([]*entity.Template, error)
+
+	// Actualizar el nombre y la descripción de una plantilla y añadirle una
+	// versión, a la que asigna el número siguiente a la última
+	AddVersion(ctx context.Context, template *entity.Template, version *entity.TemplateVersion) error
+
+	// Eliminar una plantilla con todas sus versiones
+	Delete(ctx context.Context, tenantID, id string) error
+
+	// Obtener una versión de una plantilla
+	GetVersion(ctx context.Context, tenantID, id string, version int) (*entity.TemplateVersion, error)
+
+	// Obtener las versiones de una plantilla, de la más reciente a la más
+	// antigua
+	ListVersions(ctx context.Context, tenantID, id string) ([]*entity.TemplateVersion, error)
+
+	// Publicar una versión de una plantilla y archivar la que estaba
+	// publicada
+	Publish(ctx context.Context, tenantID, id string, version int) error
+
+	// Obtener la versión publicada de las plantillas de todos los tenants
+	ListPublished(ctx context.Context) ([]*entity.TemplateVersion, error)
+}
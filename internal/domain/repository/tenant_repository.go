@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+
+	"notification-service/internal/domain/entity"
+)
+
+// TenantRepository define las operaciones sobre los tenants
+type TenantRepository interface {
+	// Guardar un tenant nuevo
+	Create(ctx context.Context, tenant *entity.Tenant) error
+
+	// Obtener un tenant por su ID
+	GetByID(ctx context.Context, id string) (*entity.Tenant, error)
+
+	// Obtener todos los tenants
+	List(ctx context.Context) ([]*entity.Tenant, error)
+
+	// Actualizar el nombre, las credenciales y las cuotas de un tenant
+	Update(ctx context.Context, tenant *entity.Tenant) error
+}
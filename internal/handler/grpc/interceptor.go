@@ -0,0 +1,125 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"notification-service/internal/usecase"
+	"notification-service/pkg/auth"
+	pb "notification-service/pkg/proto"
+)
+
+// apiKeyMetadata es la clave de metadatos con la que los servicios envían su
+// API key
+const apiKeyMetadata = "x-api-key"
+
+// tenantMetadata es la clave de metadatos con la que se indica el tenant
+var tenantMetadata = strings.ToLower(auth.TenantHeader)
+
+// ServiceMethodScopes son los métodos de la API gRPC, que solo pueden llamar
+// los servicios autenticados, con el ámbito que requiere cada uno
+var ServiceMethodScopes = map[string]string{
+	pb.NotificationService_SendNotification_FullMethodName:  auth.ScopeNotificationsSend,
+	pb.NotificationService_VerifyDeviceToken_FullMethodName: auth.ScopeDevicesRead,
+	pb.NotificationService_RegisterDevice_FullMethodName:    auth.ScopeDevicesWrite,
+	pb.NotificationService_LinkDeviceToUser_FullMethodName:  auth.ScopeDevicesWrite,
+	pb.NotificationService_UpdateDeviceToken_FullMethodName: auth.ScopeDevicesWrite,
+	pb.NotificationService_GetDeliveryStatus_FullMethodName: auth.ScopeNotificationsRead,
+	pb.NotificationService_GetUserPresence_FullMethodName:   auth.ScopePresenceRead,
+	pb.NotificationService_GetUsersPresence_FullMethodName:  auth.ScopePresenceRead,
+}
+
+// ServiceAuthInterceptor exige credenciales de servicio en todas las
+// llamadas: un certificado de cliente verificado (mTLS) o una API key en los
+// metadatos x-api-key. El servicio autenticado queda en el contexto. Los
+// métodos que no están en methodScopes se rechazan.
+//
+// Todas las llamadas actúan sobre un tenant: el de la API key o, con una
+// credencial de la plataforma, el de los metadatos x-tenant-id (el tenant
+// por defecto si no se indica).
+func ServiceAuthInterceptor(
+	authService *usecase.ServiceAuthService,
+	tenantService *usecase.TenantService,
+	methodScopes map[string]string,
+) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		scope, ok := methodScopes[info.FullMethod]
+		if !ok {
+			return nil, status.Error(codes.PermissionDenied, "method not allowed")
+		}
+
+		principal, err := authenticateService(ctx, authService)
+		if err != nil {
+			switch {
+			case errors.Is(err, usecase.ErrCredentialsRequired),
+				errors.Is(err, usecase.ErrInvalidAPIKey),
+				errors.Is(err, usecase.ErrUnknownClientCertificate):
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			default:
+				return nil, status.Error(codes.Internal, "failed to authenticate service")
+			}
+		}
+
+		if !principal.HasScope(scope) {
+			return nil, status.Error(codes.PermissionDenied, "missing scope "+scope)
+		}
+
+		tenantID, ok := principal.Tenant(metadataValue(ctx, tenantMetadata))
+		if !ok {
+			return nil, status.Error(codes.PermissionDenied, "not allowed to access this tenant")
+		}
+		if err := checkTenant(ctx, tenantService, tenantID); err != nil {
+			return nil, err
+		}
+
+		if ok, wait := authService.Allow(ctx, principal); !ok {
+			setRetryAfter(ctx, wait)
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+
+		return handler(auth.WithTenant(auth.WithPrincipal(ctx, principal), tenantID), req)
+	}
+}
+
+// checkTenant comprueba que existe el tenant de una llamada
+func checkTenant(ctx context.Context, tenantService *usecase.TenantService, tenantID string) error {
+	exists, err := tenantService.Exists(ctx, tenantID)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to get tenant")
+	}
+	if !exists {
+		return status.Error(codes.InvalidArgument, "unknown tenant")
+	}
+	return nil
+}
+
+// metadataValue devuelve el primer valor de una clave de los metadatos de la
+// llamada, o "" si no está
+func metadataValue(ctx context.Context, key string) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// authenticateService obtiene el servicio que hace una llamada, por su
+// certificado de cliente o por su API key
+func authenticateService(ctx context.Context, authService *usecase.ServiceAuthService) (*auth.Principal, error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+			return authService.AuthenticateCertificate(tlsInfo.State.VerifiedChains[0][0])
+		}
+	}
+
+	return authService.AuthenticateAPIKey(ctx, metadataValue(ctx, apiKeyMetadata))
+}
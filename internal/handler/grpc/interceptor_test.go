@@ -0,0 +1,101 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/internal/usecase"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/logging"
+	pb "notification-service/pkg/proto"
+)
+
+// memTenantRepo conoce los tenants default y acme
+type memTenantRepo struct {
+	repository.TenantRepository
+}
+
+func (memTenantRepo) GetByID(ctx context.Context, id string) (*entity.Tenant, error) {
+	if id != entity.DefaultTenantID && id != "acme" {
+		return nil, repository.ErrTenantNotFound
+	}
+	return &entity.Tenant{ID: id}, nil
+}
+
+// serviceContext simula una llamada de un servicio con un certificado de
+// cliente verificado y unos metadatos
+func serviceContext(commonName string, md metadata.MD) context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	if commonName == "" {
+		return ctx
+	}
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	return peer.NewContext(ctx, &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}},
+	})
+}
+
+func TestServiceAuthInterceptor(t *testing.T) {
+	logger := logging.NewLogger(logging.WithOutput(io.Discard))
+	authService := usecase.NewServiceAuthService(nil, map[string][]string{
+		"backend": {auth.ScopeDevicesRead, auth.ScopeDevicesWrite},
+		"sender":  {auth.ScopeNotificationsSend},
+	}, "", 0, 0, nil, logger)
+	tenantService := usecase.NewTenantService(memTenantRepo{}, nil, logger)
+	interceptor := ServiceAuthInterceptor(authService, tenantService, ServiceMethodScopes)
+
+	tests := []struct {
+		name       string
+		method     string
+		service    string
+		tenant     string
+		wantCode   codes.Code
+		wantTenant string
+	}{
+		{"register without credentials", pb.NotificationService_RegisterDevice_FullMethodName, "", "", codes.Unauthenticated, ""},
+		{"link without credentials", pb.NotificationService_LinkDeviceToUser_FullMethodName, "", "acme", codes.Unauthenticated, ""},
+		{"verify without credentials", pb.NotificationService_VerifyDeviceToken_FullMethodName, "", "", codes.Unauthenticated, ""},
+		{"register without scope", pb.NotificationService_RegisterDevice_FullMethodName, "sender", "", codes.PermissionDenied, ""},
+		{"unknown method", "/notification.NotificationService/Unknown", "backend", "", codes.PermissionDenied, ""},
+		{"register", pb.NotificationService_RegisterDevice_FullMethodName, "backend", "", codes.OK, entity.DefaultTenantID},
+		{"verify in tenant", pb.NotificationService_VerifyDeviceToken_FullMethodName, "backend", "acme", codes.OK, "acme"},
+		{"unknown tenant", pb.NotificationService_LinkDeviceToUser_FullMethodName, "backend", "other", codes.InvalidArgument, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.MD{}
+			if tt.tenant != "" {
+				md.Set(tenantMetadata, tt.tenant)
+			}
+
+			var gotTenant string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				gotTenant = auth.TenantFromContext(ctx)
+				return nil, nil
+			}
+
+			_, err := interceptor(serviceContext(tt.service, md), nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v (%v)", code, tt.wantCode, err)
+			}
+			if gotTenant != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", gotTenant, tt.wantTenant)
+			}
+		})
+	}
+}
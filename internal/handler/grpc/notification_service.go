@@ -2,18 +2,24 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"math"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"notification-service/internal/domain/entity"
 	"notification-service/internal/usecase"
+	"notification-service/pkg/auth"
 	"notification-service/pkg/logging"
 	pb "notification-service/pkg/proto"
 )
@@ -25,7 +31,8 @@ type NotificationServer struct {
 	deviceService       *usecase.DeviceService
 	tokenService        *usecase.TokenService
 	pushService         usecase.PushService
-	logger              logging.Logger
+	presenceService     *usecase.PresenceService
+	logger              *logging.Logger
 }
 
 // NewNotificationServer crea una nueva instancia del servidor gRPC
@@ -34,17 +41,33 @@ func NewNotificationServer(
 	deviceService *usecase.DeviceService,
 	tokenService *usecase.TokenService,
 	pushService usecase.PushService,
-	logger logging.Logger,
+	presenceService *usecase.PresenceService,
+	logger *logging.Logger,
 ) *NotificationServer {
 	return &NotificationServer{
 		notificationService: notificationService,
 		deviceService:       deviceService,
 		tokenService:        tokenService,
 		pushService:         pushService,
+		presenceService:     presenceService,
 		logger:              logger,
 	}
 }
 
+// quotaExceededStatus devuelve el error ResourceExhausted de un envío que
+// supera una cuota, con los segundos que esperar en los metadatos retry-after
+func quotaExceededStatus(ctx context.Context, quotaErr *usecase.QuotaExceededError) error {
+	setRetryAfter(ctx, quotaErr.RetryAfter)
+	return status.Error(codes.ResourceExhausted, quotaErr.Error())
+}
+
+// setRetryAfter envía en los metadatos retry-after la espera en segundos
+// enteros, como mínimo uno
+func setRetryAfter(ctx context.Context, wait time.Duration) {
+	seconds := max(int(math.Ceil(wait.Seconds())), 1)
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(seconds)))
+}
+
 // SendNotification implementa el método RPC SendNotification
 func (s *NotificationServer) SendNotification(
 	ctx context.Context,
@@ -54,8 +77,11 @@ func (s *NotificationServer) SendNotification(
 	if req.UserId == "" {
 		return nil, status.Error(codes.InvalidArgument, "user_id is required")
 	}
-	if req.Title == "" || req.Message == "" {
-		return nil, status.Error(codes.InvalidArgument, "title and message are required")
+	if req.TemplateId == "" && (req.Title == "" || req.Message == "") {
+		return nil, status.Error(codes.InvalidArgument, "template_id or title and message are required")
+	}
+	if req.TemplateVersion < 0 {
+		return nil, status.Error(codes.InvalidArgument, "template_version must not be negative")
 	}
 
 	// Determinar el tipo de notificación
@@ -78,30 +104,110 @@ func (s *NotificationServer) SendNotification(
 	// Crear y guardar la notificación
 	notification, err := entity.NewNotification(req.UserId, req.Title, req.Message, data, notificationType)
 	if err != nil {
-		s.logger.Error("Error creating notification", "error", err)
+		s.logger.Error("Error creating notification: %v", err)
 		return nil, status.Error(codes.Internal, "error creating notification")
 	}
 
 	// Establecer propiedades adicionales
-	if req.Priority > 0 {
-		notification.SetPriority(int(req.Priority))
-	}
-	if req.SenderId != "" {
-		notification.SetSender(req.SenderId)
+	notification.SetTenant(auth.TenantFromContext(ctx))
+	notification.SetPriority(int(req.Priority))
+	// El remitente es el servicio autenticado, no el que indique la petición
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		notification.SetSender(principal.Name)
 	}
 	if req.Expiry > 0 {
 		expiryTime := time.Duration(req.Expiry) * time.Second
 		notification.SetExpiry(expiryTime)
 	}
 
+	// Renderizar título, mensaje y datos con la plantilla, si se indica
+	if err := s.applyTemplate(ctx, notification, req); err != nil {
+		return nil, err
+	}
+
+	// Enviar en el carril de la notificación
+	var response *pb.SendNotificationResponse
+	err = s.notificationService.Schedule(ctx, notification, func(ctx context.Context) error {
+		var err error
+		response, err = s.sendNotification(ctx, notification)
+		return err
+	})
+	switch {
+	case errors.Is(err, usecase.ErrSendQueueFull):
+		setRetryAfter(ctx, time.Second)
+		return nil, status.Error(codes.Unavailable, "send queue is full")
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return nil, status.FromContextError(err).Err()
+	case err != nil:
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// applyTemplate renderiza la notificación con la plantilla de la petición, si
+// indica una
+func (s *NotificationServer) applyTemplate(
+	ctx context.Context,
+	notification *entity.Notification,
+	req *pb.SendNotificationRequest,
+) error {
+	if req.TemplateId == "" {
+		return nil
+	}
+
+	variables := make(map[string]interface{}, len(req.Variables))
+	for k, v := range req.Variables {
+		variables[k] = v
+	}
+
+	err := s.notificationService.ApplyTemplate(ctx, notification, &usecase.TemplateRef{
+		ID:        req.TemplateId,
+		Version:   int(req.TemplateVersion),
+		Locale:    req.Locale,
+		Variables: variables,
+	}, nil)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, usecase.ErrTemplateNotFound):
+		return status.Error(codes.NotFound, "template not found")
+	case errors.Is(err, usecase.ErrInvalidTemplate), errors.Is(err, usecase.ErrTemplateRenderFailed):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		s.logger.Error("Error rendering template: %v", err)
+		return status.Error(codes.Internal, "error rendering template")
+	}
+}
+
+// sendNotification comprueba las cuotas, guarda la notificación y la envía a
+// los dispositivos de su usuario
+func (s *NotificationServer) sendNotification(
+	ctx context.Context,
+	notification *entity.Notification,
+) (*pb.SendNotificationResponse, error) {
+	// Comprobar las cuotas de envío antes de guardar la notificación
+	deviceIDs, err := s.notificationService.AdmitUserNotification(ctx, notification.UserID)
+	if err != nil {
+		var quotaErr *usecase.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			return nil, quotaExceededStatus(ctx, quotaErr)
+		}
+		s.logger.Error("Error checking quotas: %v", err)
+		return nil, status.Error(codes.Internal, "error checking quotas")
+	}
+
 	// Guardar la notificación
 	if err := s.notificationService.SaveNotification(ctx, notification); err != nil {
-		s.logger.Error("Error saving notification", "error", err)
+		s.logger.Error("Error saving notification: %v", err)
 		return nil, status.Error(codes.Internal, "error saving notification")
 	}
 
-	// Enviar la notificación a todos los dispositivos del usuario
-	results, errors := s.pushService.SendUserNotification(ctx, req.UserId, notification)
+	// Enviar la notificación a los dispositivos del usuario que no superan su cuota
+	results, errors := s.pushService.SendBatchNotification(ctx, deviceIDs, notification)
+	if len(deviceIDs) == 0 {
+		errors[uuid.Nil] = usecase.ErrUserHasNoDevices
+	}
 
 	// Preparar la respuesta
 	response := &pb.SendNotificationResponse{
@@ -131,8 +237,11 @@ func (s *NotificationServer) VerifyDeviceToken(
 		return nil, status.Error(codes.InvalidArgument, "token is required")
 	}
 
-	// Verificar el token
+	// Verificar el token; los de otros tenants no son válidos
 	claims, err := s.tokenService.VerifyToken(ctx, req.Token)
+	if err == nil && claims.Tenant() != auth.TenantFromContext(ctx) {
+		err = usecase.ErrInvalidToken
+	}
 	if err != nil {
 		if errors.Is(err, usecase.ErrTokenExpired) {
 			return &pb.VerifyDeviceTokenResponse{
@@ -178,6 +287,7 @@ func (s *NotificationServer) RegisterDevice(
 			req.DeviceIdentifier,
 			userID,
 			&req.DeviceModel,
+			req.Locale,
 		)
 	} else {
 		// Registrar dispositivo sin usuario
@@ -185,33 +295,30 @@ func (s *NotificationServer) RegisterDevice(
 			ctx,
 			req.DeviceIdentifier,
 			&req.DeviceModel,
+			req.Locale,
 		)
 	}
 
 	if err != nil {
-		s.logger.Error("Error registering device", "error", err)
+		s.logger.Error("Error registering device: %v", err)
 		return &pb.RegisterDeviceResponse{
 			Success:      false,
 			ErrorMessage: err.Error(),
 		}, nil
 	}
 
-	// Generar token según corresponda
-	var token string
+	// Generar tokens según corresponda
+	var tokens *usecase.TokenPair
 	if req.UserId != "" {
-		// Convertir UserId a uint
-		var userID uint
-		fmt.Sscanf(req.UserId, "%d", &userID)
-
-		// Generar token permanente
-		token, err = s.tokenService.GeneratePermanentToken(req.UserId, device.ID)
+		// Generar tokens permanentes
+		tokens, err = s.tokenService.IssuePermanentTokens(ctx, req.UserId, device.ID)
 	} else {
-		// Generar token temporal
-		token, err = s.tokenService.GenerateTemporaryToken(req.DeviceIdentifier)
+		// Generar tokens temporales
+		tokens, err = s.tokenService.IssueTemporaryTokens(ctx, device.ID)
 	}
 
 	if err != nil {
-		s.logger.Error("Error generating token", "error", err)
+		s.logger.Error("Error generating token: %v", err)
 		return &pb.RegisterDeviceResponse{
 			DeviceId:     device.ID.String(),
 			Success:      false,
@@ -220,9 +327,10 @@ func (s *NotificationServer) RegisterDevice(
 	}
 
 	return &pb.RegisterDeviceResponse{
-		DeviceId: device.ID.String(),
-		Token:    token,
-		Success:  true,
+		DeviceId:     device.ID.String(),
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		Success:      true,
 	}, nil
 }
 
@@ -249,7 +357,7 @@ func (s *NotificationServer) LinkDeviceToUser(
 	// Obtener el dispositivo para verificar su identificador
 	device, err := s.deviceService.GetDevice(ctx, deviceID)
 	if err != nil {
-		s.logger.Error("Error getting device", "deviceID", deviceID, "error", err)
+		s.logger.Error("Error getting device %s: %v", deviceID, err)
 		return &pb.LinkDeviceToUserResponse{
 			Success:      false,
 			ErrorMessage: "error getting device: " + err.Error(),
@@ -270,17 +378,17 @@ func (s *NotificationServer) LinkDeviceToUser(
 
 	// Vincular dispositivo a usuario
 	if err := s.deviceService.LinkDeviceToUser(ctx, deviceID, userID); err != nil {
-		s.logger.Error("Error linking device to user", "error", err)
+		s.logger.Error("Error linking device to user: %v", err)
 		return &pb.LinkDeviceToUserResponse{
 			Success:      false,
 			ErrorMessage: "error linking device to user: " + err.Error(),
 		}, nil
 	}
 
-	// Generar nuevo token permanente
-	newToken, err := s.tokenService.GeneratePermanentToken(req.UserId, deviceID)
+	// Generar nuevos tokens permanentes
+	tokens, err := s.tokenService.IssuePermanentTokens(ctx, req.UserId, deviceID)
 	if err != nil {
-		s.logger.Error("Error generating permanent token", "error", err)
+		s.logger.Error("Error generating permanent token: %v", err)
 		return &pb.LinkDeviceToUserResponse{
 			Success:      true,
 			ErrorMessage: "device linked but token generation failed: " + err.Error(),
@@ -288,8 +396,9 @@ func (s *NotificationServer) LinkDeviceToUser(
 	}
 
 	return &pb.LinkDeviceToUserResponse{
-		NewToken: newToken,
-		Success:  true,
+		NewToken:     tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		Success:      true,
 	}, nil
 }
 
@@ -324,7 +433,7 @@ func (s *NotificationServer) UpdateDeviceToken(
 	// Actualizar o crear token
 	err = s.tokenService.SaveToken(ctx, deviceID, req.Token, tokenType)
 	if err != nil {
-		s.logger.Error("Error saving token", "error", err)
+		s.logger.Error("Error saving token: %v", err)
 		return &pb.UpdateDeviceTokenResponse{
 			Success:      false,
 			ErrorMessage: "error saving token: " + err.Error(),
@@ -354,7 +463,7 @@ func (s *NotificationServer) GetDeliveryStatus(
 	// Obtener el estado de entrega
 	deliveries, err := s.notificationService.GetDeliveryStatus(ctx, notificationID)
 	if err != nil {
-		s.logger.Error("Error getting delivery status", "error", err)
+		s.logger.Error("Error getting delivery status: %v", err)
 		return &pb.GetDeliveryStatusResponse{
 			NotificationId: req.NotificationId,
 			Success:        false,
@@ -392,16 +501,100 @@ func (s *NotificationServer) GetDeliveryStatus(
 	}, nil
 }
 
-// StartGRPCServer inicia el servidor gRPC
-func StartGRPCServer(port int, server *NotificationServer, logger logging.Logger) error {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+// GetUserPresence implementa el método RPC GetUserPresence
+func (s *NotificationServer) GetUserPresence(
+	ctx context.Context,
+	req *pb.GetUserPresenceRequest,
+) (*pb.GetUserPresenceResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	presence := s.presenceService.GetUserPresence(ctx, req.UserId)
+
+	return &pb.GetUserPresenceResponse{
+		Presence: toPBUserPresence(presence),
+		Success:  true,
+	}, nil
+}
+
+// GetUsersPresence implementa el método RPC GetUsersPresence
+func (s *NotificationServer) GetUsersPresence(
+	ctx context.Context,
+	req *pb.GetUsersPresenceRequest,
+) (*pb.GetUsersPresenceResponse, error) {
+	if len(req.UserIds) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_ids is required")
+	}
+
+	users, err := s.presenceService.GetUsersPresence(ctx, req.UserIds)
 	if err != nil {
-		return fmt.Errorf("failed to listen: %v", err)
+		if errors.Is(err, usecase.ErrTooManyPresenceIDs) {
+			return nil, status.Errorf(codes.InvalidArgument, "at most %d user_ids are allowed", usecase.MaxPresenceQuerySize)
+		}
+		return nil, status.Error(codes.Internal, "error getting presence")
 	}
 
-	s := grpc.NewServer()
+	result := make([]*pb.UserPresence, 0, len(users))
+	for _, presence := range users {
+		result = append(result, toPBUserPresence(presence))
+	}
+
+	return &pb.GetUsersPresenceResponse{
+		Users:   result,
+		Success: true,
+	}, nil
+}
+
+// toPBUserPresence convierte la presencia de un usuario al mensaje protobuf
+func toPBUserPresence(presence *usecase.UserPresence) *pb.UserPresence {
+	connections := make([]*pb.ConnectionInfo, 0, len(presence.Connections))
+	for _, conn := range presence.Connections {
+		connections = append(connections, &pb.ConnectionInfo{
+			DeviceId:    conn.DeviceID.String(),
+			Transport:   conn.Transport,
+			ConnectedAt: conn.ConnectedAt.Unix(),
+		})
+	}
+
+	return &pb.UserPresence{
+		UserId:      presence.UserID,
+		Online:      presence.Online,
+		Connections: connections,
+	}
+}
+
+// NewGRPCServer crea el servidor gRPC con NotificationService registrado.
+// Todos sus métodos exigen credenciales de servicio; con
+// tlsConfig, el servidor acepta TLS y certificados de cliente.
+func NewGRPCServer(
+	server *NotificationServer,
+	authService *usecase.ServiceAuthService,
+	tenantService *usecase.TenantService,
+	tlsConfig *tls.Config,
+) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			ServiceAuthInterceptor(authService, tenantService, ServiceMethodScopes),
+		),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	s := grpc.NewServer(opts...)
 	pb.RegisterNotificationServiceServer(s, server)
+	return s
+}
+
+// StartGRPCServer atiende las llamadas gRPC en un puerto hasta que se
+// detiene el servidor
+func StartGRPCServer(port int, s *grpc.Server, logger *logging.Logger) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %v", err)
+	}
 
-	logger.Info("Starting gRPC server", "port", port)
+	logger.Info("Starting gRPC server on port %d", port)
 	return s.Serve(lis)
 }
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"notification-service/internal/usecase"
+	"notification-service/pkg/auth"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// APIKeyHandler administra las API keys de los servicios. Un administrador
+// de la plataforma gestiona las de todos los tenants; el de un tenant, solo
+// las suyas.
+type APIKeyHandler struct {
+	serviceAuthService *usecase.ServiceAuthService
+	tenantService      *usecase.TenantService
+}
+
+// NewAPIKeyHandler crea un nuevo APIKeyHandler
+func NewAPIKeyHandler(serviceAuthService *usecase.ServiceAuthService, tenantService *usecase.TenantService) *APIKeyHandler {
+	return &APIKeyHandler{
+		serviceAuthService: serviceAuthService,
+		tenantService:      tenantService,
+	}
+}
+
+// CreateAPIKey crea una API key. La clave en claro solo se devuelve aquí.
+// Sin tenant_id, un administrador de la plataforma crea una clave de la
+// plataforma; el de un tenant siempre crea claves de su tenant.
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TenantID  string     `json:"tenant_id,omitempty"`
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		RateLimit float64    `json:"rate_limit,omitempty"`
+		RateBurst int        `json:"rate_burst,omitempty"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tenantID, ok := h.keyTenant(w, r, req.TenantID)
+	if !ok {
+		return
+	}
+
+	key, plaintext, err := h.serviceAuthService.CreateAPIKey(r.Context(), tenantID, req.Name, req.Scopes, req.RateLimit, req.RateBurst, req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidAPIKeyRequest) {
+			respondWithError(w, http.StatusBadRequest, "name and known scopes are required")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"api_key": plaintext,
+		"key":     key,
+	})
+}
+
+// ListAPIKeys obtiene las API keys que administra quien llama, sin su valor
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.serviceAuthService.ListAPIKeys(r.Context(), principalTenant(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get API keys")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": keys,
+	})
+}
+
+// RevokeAPIKey revoca una API key
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := h.serviceAuthService.RevokeAPIKey(r.Context(), principalTenant(r), id); err != nil {
+		if errors.Is(err, usecase.ErrAPIKeyNotFound) {
+			respondWithError(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+	})
+}
+
+// keyTenant obtiene el tenant de una API key nueva. Si quien llama no puede
+// crear claves en él, o no existe, responde y devuelve false.
+func (h *APIKeyHandler) keyTenant(w http.ResponseWriter, r *http.Request, requested string) (string, bool) {
+	if tenantID := principalTenant(r); tenantID != "" {
+		if requested != "" && requested != tenantID {
+			respondWithError(w, http.StatusForbidden, "Not allowed to access this tenant")
+			return "", false
+		}
+		return tenantID, true
+	}
+
+	if requested == "" {
+		return "", true
+	}
+
+	exists, err := h.tenantService.Exists(r.Context(), requested)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get tenant")
+		return "", false
+	}
+	if !exists {
+		respondWithError(w, http.StatusBadRequest, "Unknown tenant")
+		return "", false
+	}
+
+	return requested, true
+}
+
+// principalTenant devuelve el tenant de la credencial de quien llama, o ""
+// si es de la plataforma
+func principalTenant(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return principal.TenantID
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"notification-service/internal/usecase"
+)
+
+// AuthHandler maneja la renovación de los tokens de acceso
+type AuthHandler struct {
+	tokenService *usecase.TokenService
+}
+
+// NewAuthHandler crea un nuevo AuthHandler
+func NewAuthHandler(tokenService *usecase.TokenService) *AuthHandler {
+	return &AuthHandler{
+		tokenService: tokenService,
+	}
+}
+
+// Refresh canjea un token de refresco por un token de acceso nuevo y el
+// siguiente token de refresco
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.RefreshToken == "" {
+		respondWithError(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	tokens, err := h.tokenService.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrRefreshTokenReused):
+			respondWithError(w, http.StatusUnauthorized, "Refresh token reuse detected")
+		case errors.Is(err, usecase.ErrRefreshTokenExpired):
+			respondWithError(w, http.StatusUnauthorized, "Refresh token expired")
+		case errors.Is(err, usecase.ErrInvalidRefreshToken), errors.Is(err, usecase.ErrTokenRevoked),
+			errors.Is(err, usecase.ErrDeviceNotFound):
+			respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Failed to refresh token")
+		}
+		return
+	}
+
+	response := map[string]interface{}{}
+	addTokenFields(response, tokens)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// addTokenFields añade a una respuesta los tokens emitidos para un
+// dispositivo; token es el token de acceso
+func addTokenFields(response map[string]interface{}, tokens *usecase.TokenPair) {
+	response["token"] = tokens.AccessToken
+	response["token_type"] = tokens.TokenType
+	response["expires_at"] = tokens.ExpiresAt.Format(time.RFC3339)
+	response["refresh_token"] = tokens.RefreshToken
+	response["refresh_expires_at"] = tokens.RefreshExpiresAt.Format(time.RFC3339)
+}
@@ -0,0 +1,337 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/internal/usecase"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// memDeviceRepo guarda los dispositivos en memoria
+type memDeviceRepo struct {
+	repository.DeviceRepository
+
+	mu      sync.Mutex
+	devices map[uuid.UUID]*entity.Device
+}
+
+func (r *memDeviceRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device, ok := r.devices[id]
+	if !ok {
+		return nil, repository.ErrDeviceNotFound
+	}
+	return device, nil
+}
+
+func (r *memDeviceRepo) GetByDeviceIdentifier(ctx context.Context, tenantID, identifier string) (*entity.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, device := range r.devices {
+		if device.TenantID == tenantID && device.DeviceIdentifier == identifier {
+			return device, nil
+		}
+	}
+	return nil, repository.ErrDeviceNotFound
+}
+
+func (r *memDeviceRepo) GetByUserID(ctx context.Context, tenantID string, userID uint) ([]*entity.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var devices []*entity.Device
+	for _, device := range r.devices {
+		if device.TenantID == tenantID && device.UserID != nil && *device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (r *memDeviceRepo) Save(ctx context.Context, device *entity.Device) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices[device.ID] = device
+	return nil
+}
+
+func (r *memDeviceRepo) Update(ctx context.Context, device *entity.Device) error {
+	return r.Save(ctx, device)
+}
+
+// memTenantRepo solo conoce el tenant por defecto
+type memTenantRepo struct {
+	repository.TenantRepository
+}
+
+func (memTenantRepo) GetByID(ctx context.Context, id string) (*entity.Tenant, error) {
+	if id != entity.DefaultTenantID {
+		return nil, repository.ErrTenantNotFound
+	}
+	return &entity.Tenant{ID: id}, nil
+}
+
+// memRefreshRepo acepta los tokens de refresco sin guardarlos
+type memRefreshRepo struct {
+	repository.RefreshTokenRepository
+}
+
+func (memRefreshRepo) Create(ctx context.Context, token *entity.RefreshToken) error { return nil }
+
+// memSessionRepo acepta las sesiones sin guardarlas
+type memSessionRepo struct {
+	repository.SessionRepository
+}
+
+func (memSessionRepo) Create(ctx context.Context, session *entity.Session) error { return nil }
+
+func (memSessionRepo) ListActiveByUser(ctx context.Context, tenantID, userID string) ([]*entity.Session, error) {
+	return nil, nil
+}
+
+// authTestEnv es la API de dispositivos y sesiones, enrutada como en
+// main.go, con un dispositivo de cada tipo ya registrado
+type authTestEnv struct {
+	router *mux.Router
+
+	// Dispositivo sin usuario y su token temporal
+	tempDevice *entity.Device
+	tempToken  string
+
+	// Dispositivo del usuario 7, su token permanente y un token temporal
+	// que obtuvo antes de vincularse
+	userDevice      *entity.Device
+	userToken       string
+	linkedTempToken string
+}
+
+// Servicios autenticados por mTLS: backend gestiona dispositivos y
+// sesiones; sender solo envía notificaciones
+var testServiceIdentities = map[string][]string{
+	"backend": {auth.ScopeDevicesRead, auth.ScopeDevicesWrite, auth.ScopeSessionsRead},
+	"sender":  {auth.ScopeNotificationsSend},
+}
+
+func newAuthTestEnv(t *testing.T) *authTestEnv {
+	t.Helper()
+
+	logger := logging.NewLogger(logging.WithOutput(io.Discard))
+	keyRing := auth.NewKeyRing()
+	keyRing.Replace(auth.NewHMACKey("test", []byte("test-secret")), nil)
+
+	deviceRepo := &memDeviceRepo{devices: make(map[uuid.UUID]*entity.Device)}
+	sessionService := usecase.NewSessionService(memSessionRepo{}, time.Minute, logger)
+	tokenService := usecase.NewTokenService(nil, deviceRepo, memRefreshRepo{}, keyRing, sessionService, time.Hour, time.Hour, time.Hour)
+	deviceService := usecase.NewDeviceService(deviceRepo, nil)
+	serviceAuthService := usecase.NewServiceAuthService(nil, testServiceIdentities, "", 0, 0, nil, logger)
+	tenantService := usecase.NewTenantService(memTenantRepo{}, nil, logger)
+
+	authorizer := NewAuthorizer(serviceAuthService, tokenService, tenantService)
+	deviceHandler := NewDeviceHandler(deviceService, tokenService)
+	sessionHandler := NewSessionHandler(sessionService)
+
+	router := mux.NewRouter()
+	apiRouter := router.PathPrefix("/api").Subrouter()
+	apiRouter.Handle("/users/{user_id}/sessions", authorizer.Require(auth.ScopeSessionsRead, sessionHandler.ListSessions)).Methods("GET")
+	apiRouter.Handle("/devices/register", authorizer.Optional(auth.ScopeDevicesWrite, deviceHandler.RegisterDevice)).Methods("POST")
+	apiRouter.Handle("/devices/register-without-user", authorizer.Anonymous(deviceHandler.RegisterDeviceWithoutUser)).Methods("POST")
+	apiRouter.Handle("/devices/user", authorizer.Require(auth.ScopeDevicesRead, deviceHandler.GetUserDevices)).Methods("GET")
+	apiRouter.Handle("/devices/{id}", authorizer.Require(auth.ScopeDevicesRead, deviceHandler.GetDevice)).Methods("GET")
+	apiRouter.Handle("/devices/link", authorizer.Require(auth.ScopeDevicesWrite, deviceHandler.LinkDeviceToUser)).Methods("POST")
+	apiRouter.Handle("/devices/update-fcm-token", authorizer.Require(auth.ScopeDevicesWrite, deviceHandler.UpdateFCMToken)).Methods("POST")
+
+	env := &authTestEnv{router: router}
+	ctx := auth.WithTenant(context.Background(), entity.DefaultTenantID)
+
+	var err error
+	env.tempDevice, err = deviceService.RegisterDeviceWithoutUser(ctx, "temp-device", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := tokenService.IssueTemporaryTokens(ctx, env.tempDevice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.tempToken = tokens.AccessToken
+
+	env.userDevice, err = deviceService.RegisterDeviceWithUser(ctx, "user-device", 7, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err = tokenService.IssuePermanentTokens(ctx, "7", env.userDevice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.userToken = tokens.AccessToken
+	tokens, err = tokenService.IssueTemporaryTokens(ctx, env.userDevice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.linkedTempToken = tokens.AccessToken
+
+	return env
+}
+
+// principal es quien hace una petición de prueba
+type principal string
+
+const (
+	anonymousCaller       principal = "anonymous"
+	temporaryCaller       principal = "temporary"
+	linkedTemporaryCaller principal = "linked temporary"
+	permanentCaller       principal = "permanent"
+	serviceCaller         principal = "service"
+	senderCaller          principal = "sender"
+)
+
+// do hace una petición como caller y devuelve la respuesta
+func (env *authTestEnv) do(t *testing.T, caller principal, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	switch caller {
+	case temporaryCaller:
+		req.Header.Set("Authorization", "Bearer "+env.tempToken)
+	case linkedTemporaryCaller:
+		req.Header.Set("Authorization", "Bearer "+env.linkedTempToken)
+	case permanentCaller:
+		req.Header.Set("Authorization", "Bearer "+env.userToken)
+	case serviceCaller:
+		req.TLS = verifiedClientTLS("backend")
+	case senderCaller:
+		req.TLS = verifiedClientTLS("sender")
+	}
+
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// verifiedClientTLS simula una conexión con un certificado de cliente ya
+// verificado contra la CA
+func verifiedClientTLS(commonName string) *tls.ConnectionState {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func TestRouteAuthorization(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller principal
+		method string
+		// path y body pueden usar {temp} y {user} para los ID de los
+		// dispositivos de prueba
+		path       string
+		body       string
+		wantStatus int
+	}{
+		// Registro: abierto sin usuario; vincular un usuario es de servicios
+		{"register anonymous", anonymousCaller, "POST", "/api/devices/register", `{"device_identifier":"new"}`, http.StatusOK},
+		{"register anonymous with user", anonymousCaller, "POST", "/api/devices/register", `{"device_identifier":"new","user_id":"7"}`, http.StatusUnauthorized},
+		{"register temporary with user", temporaryCaller, "POST", "/api/devices/register", `{"device_identifier":"new","user_id":"7"}`, http.StatusForbidden},
+		{"register permanent with own user", permanentCaller, "POST", "/api/devices/register", `{"device_identifier":"new","user_id":"7"}`, http.StatusForbidden},
+		{"register service with user", serviceCaller, "POST", "/api/devices/register", `{"device_identifier":"new","user_id":"7"}`, http.StatusOK},
+		{"register service without scope", senderCaller, "POST", "/api/devices/register", `{"device_identifier":"new","user_id":"7"}`, http.StatusForbidden},
+
+		// Un dispositivo vinculado no se vuelve a registrar sin usuario
+		{"register anonymous existing", anonymousCaller, "POST", "/api/devices/register", `{"device_identifier":"temp-device"}`, http.StatusOK},
+		{"register anonymous linked", anonymousCaller, "POST", "/api/devices/register", `{"device_identifier":"user-device"}`, http.StatusConflict},
+		{"register without user linked", anonymousCaller, "POST", "/api/devices/register-without-user", `{"device_identifier":"user-device"}`, http.StatusConflict},
+		{"register service linked", serviceCaller, "POST", "/api/devices/register", `{"device_identifier":"user-device","user_id":"7"}`, http.StatusOK},
+
+		// Vinculación: un servicio, o el dispositivo con el token de ese usuario
+		{"link anonymous", anonymousCaller, "POST", "/api/devices/link", `{"device_id":"{temp}","user_id":"7"}`, http.StatusUnauthorized},
+		{"link temporary own device", temporaryCaller, "POST", "/api/devices/link", `{"device_id":"{temp}","user_id":"7"}`, http.StatusForbidden},
+		{"link permanent own user", permanentCaller, "POST", "/api/devices/link", `{"device_id":"{user}","user_id":"7"}`, http.StatusOK},
+		{"link permanent other user", permanentCaller, "POST", "/api/devices/link", `{"device_id":"{user}","user_id":"8"}`, http.StatusForbidden},
+		{"link permanent other device", permanentCaller, "POST", "/api/devices/link", `{"device_id":"{temp}","user_id":"7"}`, http.StatusForbidden},
+		{"link service", serviceCaller, "POST", "/api/devices/link", `{"device_id":"{temp}","user_id":"8"}`, http.StatusOK},
+
+		// Dispositivo: solo él mismo o un servicio
+		{"device anonymous", anonymousCaller, "GET", "/api/devices/{temp}", "", http.StatusUnauthorized},
+		{"device temporary own", temporaryCaller, "GET", "/api/devices/{temp}", "", http.StatusOK},
+		{"device temporary other", temporaryCaller, "GET", "/api/devices/{user}", "", http.StatusForbidden},
+		{"device permanent other", permanentCaller, "GET", "/api/devices/{temp}", "", http.StatusForbidden},
+		{"device service", serviceCaller, "GET", "/api/devices/{user}", "", http.StatusOK},
+
+		// El token temporal de un dispositivo ya vinculado no sirve
+		{"device linked temporary", linkedTemporaryCaller, "GET", "/api/devices/{user}", "", http.StatusForbidden},
+		{"fcm token linked temporary", linkedTemporaryCaller, "POST", "/api/devices/update-fcm-token", `{"device_id":"{user}","token":"attacker"}`, http.StatusForbidden},
+
+		// Dispositivos de un usuario: solo su usuario o un servicio
+		{"user devices temporary", temporaryCaller, "GET", "/api/devices/user?user_id=7", "", http.StatusForbidden},
+		{"user devices permanent own", permanentCaller, "GET", "/api/devices/user?user_id=7", "", http.StatusOK},
+		{"user devices permanent other", permanentCaller, "GET", "/api/devices/user?user_id=8", "", http.StatusForbidden},
+		{"user devices service", serviceCaller, "GET", "/api/devices/user?user_id=8", "", http.StatusOK},
+
+		// Sesiones: los tokens temporales no tienen el ámbito
+		{"sessions anonymous", anonymousCaller, "GET", "/api/users/7/sessions", "", http.StatusUnauthorized},
+		{"sessions temporary", temporaryCaller, "GET", "/api/users/7/sessions", "", http.StatusForbidden},
+		{"sessions permanent own", permanentCaller, "GET", "/api/users/7/sessions", "", http.StatusOK},
+		{"sessions permanent other", permanentCaller, "GET", "/api/users/8/sessions", "", http.StatusForbidden},
+		{"sessions service", serviceCaller, "GET", "/api/users/8/sessions", "", http.StatusOK},
+		{"sessions service without scope", senderCaller, "GET", "/api/users/8/sessions", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newAuthTestEnv(t)
+			ids := strings.NewReplacer("{temp}", env.tempDevice.ID.String(), "{user}", env.userDevice.ID.String())
+
+			rec := env.do(t, tt.caller, tt.method, ids.Replace(tt.path), ids.Replace(tt.body))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRegisterDeviceTokenType(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller principal
+		body   string
+		want   string
+	}{
+		{"anonymous", anonymousCaller, `{"device_identifier":"new"}`, usecase.TokenTypeTemporary},
+		{"temporary", temporaryCaller, `{"device_identifier":"new"}`, usecase.TokenTypeTemporary},
+		{"service without user", serviceCaller, `{"device_identifier":"new"}`, usecase.TokenTypeTemporary},
+		{"service with user", serviceCaller, `{"device_identifier":"new","user_id":"7"}`, usecase.TokenTypePermanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newAuthTestEnv(t)
+
+			rec := env.do(t, tt.caller, "POST", "/api/devices/register", tt.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var response map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response["token_type"] != tt.want {
+				t.Errorf("token_type = %v, want %s", response["token_type"], tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"notification-service/internal/domain/entity"
 	"notification-service/internal/usecase"
@@ -25,12 +26,15 @@ func NewDeviceHandler(deviceService *usecase.DeviceService, tokenService *usecas
 	}
 }
 
-// RegisterDevice registra un nuevo dispositivo
+// RegisterDevice registra un nuevo dispositivo. Sin user_id emite tokens
+// temporales a cualquiera; con user_id solo un servicio autenticado puede
+// vincular el usuario y obtener tokens permanentes.
 func (h *DeviceHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		DeviceIdentifier string `json:"device_identifier"`
 		UserID           string `json:"user_id,omitempty"`
 		Model            string `json:"model,omitempty"`
+		Locale           string `json:"locale,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -46,10 +50,14 @@ func (h *DeviceHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
 
 	var device *entity.Device
 	var err error
-	var token string
+	var tokens *usecase.TokenPair
 
 	// Si se proporciona un usuario, asociar el dispositivo al usuario
 	if req.UserID != "" {
+		if !authorizeService(w, r) {
+			return
+		}
+
 		userID, err := strconv.ParseUint(req.UserID, 10, 32)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, "Invalid user_id")
@@ -59,37 +67,40 @@ func (h *DeviceHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
 		model := req.Model
 		uintUserID := uint(userID)
 
-		device, err = h.deviceService.RegisterDeviceWithUser(r.Context(), req.DeviceIdentifier, uintUserID, &model)
+		device, err = h.deviceService.RegisterDeviceWithUser(r.Context(), req.DeviceIdentifier, uintUserID, &model, req.Locale)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		// Generar token permanente
-		token, err = h.tokenService.GeneratePermanentToken(req.UserID, device.ID)
+		// Generar tokens permanentes
+		tokens, err = h.tokenService.IssuePermanentTokens(r.Context(), req.UserID, device.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
 	} else {
 		// Registrar dispositivo sin usuario
 		model := req.Model
-		device, err = h.deviceService.RegisterDeviceWithoutUser(r.Context(), req.DeviceIdentifier, &model)
+		device, err = h.deviceService.RegisterDeviceWithoutUser(r.Context(), req.DeviceIdentifier, &model, req.Locale)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, err.Error())
+			respondWithRegisterError(w, err)
 			return
 		}
 
-		// Generar token temporal
-		token, err = h.tokenService.GenerateTemporaryToken(device.DeviceIdentifier)
-	}
-
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
-		return
+		// Generar tokens temporales
+		tokens, err = h.tokenService.IssueTemporaryTokens(r.Context(), device.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
 	}
 
 	response := map[string]interface{}{
 		"device_id":   device.ID,
-		"token":       token,
 		"is_verified": device.Verified,
 	}
+	addTokenFields(response, tokens)
 
 	respondWithJSON(w, http.StatusOK, response)
 }
@@ -100,6 +111,7 @@ func (h *DeviceHandler) RegisterDeviceWithoutUser(w http.ResponseWriter, r *http
 	var req struct {
 		DeviceIdentifier string `json:"device_identifier"`
 		Model            string `json:"model,omitempty"`
+		Locale           string `json:"locale,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -115,14 +127,14 @@ func (h *DeviceHandler) RegisterDeviceWithoutUser(w http.ResponseWriter, r *http
 
 	// Registrar dispositivo sin usuario
 	model := req.Model
-	device, err := h.deviceService.RegisterDeviceWithoutUser(r.Context(), req.DeviceIdentifier, &model)
+	device, err := h.deviceService.RegisterDeviceWithoutUser(r.Context(), req.DeviceIdentifier, &model, req.Locale)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithRegisterError(w, err)
 		return
 	}
 
-	// Generar token temporal
-	token, err := h.tokenService.GenerateTemporaryToken(device.DeviceIdentifier)
+	// Generar tokens temporales
+	tokens, err := h.tokenService.IssueTemporaryTokens(r.Context(), device.ID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
@@ -130,13 +142,24 @@ func (h *DeviceHandler) RegisterDeviceWithoutUser(w http.ResponseWriter, r *http
 
 	response := map[string]interface{}{
 		"device_id":   device.ID,
-		"token":       token,
 		"is_verified": device.Verified,
 	}
+	addTokenFields(response, tokens)
 
 	respondWithJSON(w, http.StatusOK, response)
 }
 
+// respondWithRegisterError responde al error de registrar un dispositivo sin
+// usuario. Uno ya vinculado solo lo puede registrar un servicio con su
+// usuario; el propio dispositivo usa su token permanente.
+func respondWithRegisterError(w http.ResponseWriter, err error) {
+	if errors.Is(err, usecase.ErrDeviceLinkedToUser) {
+		respondWithError(w, http.StatusConflict, "Device is linked to a user; use its permanent token or service credentials")
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, err.Error())
+}
+
 // GetDevice obtiene información de un dispositivo
 func (h *DeviceHandler) GetDevice(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -145,6 +168,9 @@ func (h *DeviceHandler) GetDevice(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid device ID")
 		return
 	}
+	if !authorizeDevice(w, r, deviceID.String()) {
+		return
+	}
 
 	device, err := h.deviceService.GetDevice(r.Context(), deviceID)
 	if err != nil {
@@ -170,6 +196,9 @@ func (h *DeviceHandler) GetUserDevices(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid user_id")
 		return
 	}
+	if !authorizeUser(w, r, userIDStr) {
+		return
+	}
 
 	// Obtener dispositivos
 	devices, err := h.deviceService.GetUserDevices(r.Context(), uint(userID))
@@ -183,7 +212,9 @@ func (h *DeviceHandler) GetUserDevices(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// LinkDeviceToUser vincula un dispositivo a un usuario
+// LinkDeviceToUser vincula un dispositivo a un usuario. Puede hacerlo un
+// servicio o el propio dispositivo con el token permanente de ese usuario;
+// un token temporal no basta.
 func (h *DeviceHandler) LinkDeviceToUser(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		DeviceID string `json:"device_id"`
@@ -217,30 +248,40 @@ func (h *DeviceHandler) LinkDeviceToUser(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusBadRequest, "Invalid device ID")
 		return
 	}
+	if !authorizeDevice(w, r, deviceID.String()) {
+		return
+	}
 
 	userID, err := strconv.ParseUint(req.UserID, 10, 32)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
+	if !authorizeUser(w, r, req.UserID) {
+		return
+	}
 
 	// Vincular dispositivo a usuario
 	err = h.deviceService.LinkDeviceToUser(r.Context(), deviceID, uint(userID))
 	if err != nil {
+		if errors.Is(err, usecase.ErrDeviceNotFound) {
+			respondWithError(w, http.StatusNotFound, "Device not found")
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Generar nuevo token permanente
-	newToken, err := h.tokenService.GeneratePermanentToken(req.UserID, deviceID)
+	// Generar nuevos tokens permanentes
+	tokens, err := h.tokenService.IssuePermanentTokens(r.Context(), req.UserID, deviceID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{
-		"token": newToken,
-	})
+	response := map[string]interface{}{}
+	addTokenFields(response, tokens)
+	respondWithJSON(w, http.StatusOK, response)
 }
 
 // UpdateToken actualiza un token de notificación
@@ -279,98 +320,23 @@ func (h *DeviceHandler) UpdateToken(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid device ID")
 		return
 	}
-
-	// Actualizar token
-	err = h.tokenService.SaveToken(r.Context(), deviceID, req.Token, tokenType)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	respondWithJSON(w, http.StatusOK, map[string]string{
-		"status": "success",
-	})
-}
-
-// RenewToken renueva un token de autenticación
-func (h *DeviceHandler) RenewToken(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Token          string `json:"token"`
-		DeviceID       string `json:"device_id,omitempty"`
-		ForceTemporary bool   `json:"force_temporary,omitempty"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	// Validar campos obligatorios
-	if req.Token == "" {
-		respondWithError(w, http.StatusBadRequest, "token is required")
+	if !authorizeDevice(w, r, deviceID.String()) {
 		return
 	}
 
-	// Verificar token actual
-	claims, err := h.tokenService.VerifyToken(r.Context(), req.Token)
+	// Actualizar token
+	err = h.tokenService.SaveToken(r.Context(), deviceID, req.Token, tokenType)
 	if err != nil {
-		// Si el token está expirado pero podemos extraer el deviceIdentifier, generar uno nuevo
-		deviceIdentifier, extractErr := h.tokenService.ExtractDeviceIdentifierFromToken(req.Token)
-		if extractErr != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
-		}
-
-		// Si forzamos token temporal o no hay ID de usuario en las claims
-		if req.ForceTemporary || (claims == nil || claims.UserID == "") {
-			// Generar token temporal
-			newToken, err := h.tokenService.GenerateTemporaryToken(deviceIdentifier)
-			if err != nil {
-				respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
-				return
-			}
-
-			respondWithJSON(w, http.StatusOK, map[string]string{
-				"token":      newToken,
-				"token_type": "temporary",
-			})
-			return
-		}
-
-		// Si hay claims con ID de usuario, generar token permanente
-		if claims != nil && claims.UserID != "" && claims.DeviceID != "" {
-			deviceID, err := uuid.Parse(claims.DeviceID)
-			if err != nil {
-				respondWithError(w, http.StatusBadRequest, "Invalid device ID in token")
-				return
-			}
-
-			newToken, err := h.tokenService.GeneratePermanentToken(claims.UserID, deviceID)
-			if err != nil {
-				respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
-				return
-			}
-
-			respondWithJSON(w, http.StatusOK, map[string]string{
-				"token":      newToken,
-				"token_type": "permanent",
-			})
+		if errors.Is(err, usecase.ErrDeviceNotFound) {
+			respondWithError(w, http.StatusNotFound, "Device not found")
 			return
 		}
-
-		respondWithError(w, http.StatusBadRequest, "Cannot renew token")
+		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Si el token es válido, simplemente devolvemos el mismo token
-	tokenType := "permanent"
-	if claims.IsTemporary {
-		tokenType = "temporary"
-	}
-
 	respondWithJSON(w, http.StatusOK, map[string]string{
-		"token":      req.Token,
-		"token_type": tokenType,
+		"status": "success",
 	})
 }
 
@@ -381,7 +347,6 @@ func (h *DeviceHandler) SyncTokens(w http.ResponseWriter, r *http.Request) {
 		WSToken   string `json:"ws_token,omitempty"`
 		FCMToken  string `json:"fcm_token,omitempty"`
 		APNSToken string `json:"apns_token,omitempty"`
-		AuthToken string `json:"auth_token"` // Token de autenticación para validar la solicitud
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -390,15 +355,8 @@ func (h *DeviceHandler) SyncTokens(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validar campos obligatorios
-	if req.DeviceID == "" || req.AuthToken == "" {
-		respondWithError(w, http.StatusBadRequest, "device_id and auth_token are required")
-		return
-	}
-
-	// Verificar token de autenticación
-	_, err := h.tokenService.VerifyToken(r.Context(), req.AuthToken)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid authentication token")
+	if req.DeviceID == "" {
+		respondWithError(w, http.StatusBadRequest, "device_id is required")
 		return
 	}
 
@@ -408,6 +366,9 @@ func (h *DeviceHandler) SyncTokens(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid device ID")
 		return
 	}
+	if !authorizeDevice(w, r, deviceID.String()) {
+		return
+	}
 
 	// Actualizar cada tipo de token si está presente
 	var updatedTokens []string
@@ -442,9 +403,8 @@ func (h *DeviceHandler) SyncTokens(w http.ResponseWriter, r *http.Request) {
 // UpdateAPNSToken actualiza específicamente un token APNS
 func (h *DeviceHandler) UpdateAPNSToken(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		DeviceID  string `json:"device_id"`
-		Token     string `json:"token"`
-		AuthToken string `json:"auth_token,omitempty"` // Token de autenticación opcional
+		DeviceID string `json:"device_id"`
+		Token    string `json:"token"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -458,25 +418,23 @@ func (h *DeviceHandler) UpdateAPNSToken(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Si se proporciona un token de autenticación, verificarlo
-	if req.AuthToken != "" {
-		_, err := h.tokenService.VerifyToken(r.Context(), req.AuthToken)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid authentication token")
-			return
-		}
-	}
-
 	// Convertir DeviceID a UUID
 	deviceID, err := uuid.Parse(req.DeviceID)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid device ID")
 		return
 	}
+	if !authorizeDevice(w, r, deviceID.String()) {
+		return
+	}
 
 	// Actualizar token APNS
 	err = h.tokenService.SaveToken(r.Context(), deviceID, req.Token, entity.TokenTypeAPNS)
 	if err != nil {
+		if errors.Is(err, usecase.ErrDeviceNotFound) {
+			respondWithError(w, http.StatusNotFound, "Device not found")
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -489,9 +447,8 @@ func (h *DeviceHandler) UpdateAPNSToken(w http.ResponseWriter, r *http.Request)
 // UpdateFCMToken actualiza específicamente un token FCM
 func (h *DeviceHandler) UpdateFCMToken(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		DeviceID  string `json:"device_id"`
-		Token     string `json:"token"`
-		AuthToken string `json:"auth_token,omitempty"` // Token de autenticación opcional
+		DeviceID string `json:"device_id"`
+		Token    string `json:"token"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -505,25 +462,23 @@ func (h *DeviceHandler) UpdateFCMToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Si se proporciona un token de autenticación, verificarlo
-	if req.AuthToken != "" {
-		_, err := h.tokenService.VerifyToken(r.Context(), req.AuthToken)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid authentication token")
-			return
-		}
-	}
-
 	// Convertir DeviceID a UUID
 	deviceID, err := uuid.Parse(req.DeviceID)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid device ID")
 		return
 	}
+	if !authorizeDevice(w, r, deviceID.String()) {
+		return
+	}
 
 	// Actualizar token FCM
 	err = h.tokenService.SaveToken(r.Context(), deviceID, req.Token, entity.TokenTypeFCM)
 	if err != nil {
+		if errors.Is(err, usecase.ErrDeviceNotFound) {
+			respondWithError(w, http.StatusNotFound, "Device not found")
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"notification-service/internal/usecase"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// Tiempo de espera por defecto y máximo de un sondeo
+	defaultPollWait = 30 * time.Second
+	maxPollWait     = 60 * time.Second
+
+	// Tamaño por defecto y máximo de un lote
+	defaultPollLimit = 50
+	maxPollLimit     = 100
+)
+
+// InboxHandler maneja el buzón de long-polling de los dispositivos
+type InboxHandler struct {
+	inboxService *usecase.InboxService
+}
+
+// NewInboxHandler crea un nuevo InboxHandler
+func NewInboxHandler(inboxService *usecase.InboxService) *InboxHandler {
+	return &InboxHandler{
+		inboxService: inboxService,
+	}
+}
+
+// Poll espera notificaciones pendientes para un dispositivo y las devuelve en un lote
+func (h *InboxHandler) Poll(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	deviceID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+	if !authorizeDevice(w, r, deviceID.String()) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	wait := defaultPollWait
+	if value := query.Get("wait"); value != "" {
+		wait, err = time.ParseDuration(value)
+		if err != nil || wait < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid wait duration")
+			return
+		}
+		if wait > maxPollWait {
+			wait = maxPollWait
+		}
+	}
+
+	limit := defaultPollLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		if limit > maxPollLimit {
+			limit = maxPollLimit
+		}
+	}
+
+	// La respuesta puede tardar más que el WriteTimeout del servidor
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 10*time.Second))
+
+	result, err := h.inboxService.Poll(r.Context(), deviceID, query.Get("cursor"), wait, limit)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidCursor) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, usecase.ErrDeviceNotFound) {
+			respondWithError(w, http.StatusNotFound, "Device not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to poll notifications")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"device_id":     deviceID.String(),
+		"notifications": result.Notifications,
+		"cursor":        result.Cursor,
+		"has_more":      result.HasMore,
+	})
+}
@@ -0,0 +1,28 @@
+package http
+
+import (
+	"net/http"
+
+	"notification-service/pkg/auth"
+)
+
+// JWKSHandler publica las claves públicas con las que se firman los tokens,
+// para que otros servicios los verifiquen sin compartir un secreto
+type JWKSHandler struct {
+	keyRing *auth.KeyRing
+}
+
+// NewJWKSHandler crea un nuevo JWKSHandler
+func NewJWKSHandler(keyRing *auth.KeyRing) *JWKSHandler {
+	return &JWKSHandler{
+		keyRing: keyRing,
+	}
+}
+
+// GetJWKS devuelve el documento /.well-known/jwks.json
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	// Las claves nuevas se publican antes de empezar a firmar, así que una
+	// caché corta es segura
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, h.keyRing.JWKS())
+}
@@ -1 +1,272 @@
-package http
\ No newline at end of file
+package http
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"notification-service/internal/usecase"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/logging"
+)
+
+// apiKeyHeader es la cabecera con la que los servicios envían su API key
+const apiKeyHeader = "X-API-Key"
+
+// Parámetros de la URL cuyo valor no debe aparecer en los logs
+var redactedQueryParams = []string{"token"}
+
+// LoggingMiddleware registra cada petición con su duración. Los tokens que
+// lleguen en la URL se ocultan.
+func LoggingMiddleware(logger *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			next.ServeHTTP(w, r)
+			logger.Info("%s %s %s", r.Method, redactedURI(r.URL), time.Since(start))
+		})
+	}
+}
+
+// redactedURI devuelve la ruta y la query de una URL con los valores
+// sensibles reemplazados
+func redactedURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+
+	query := u.Query()
+	redacted := false
+	for _, param := range redactedQueryParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.RequestURI()
+	}
+
+	safe := *u
+	safe.RawQuery = query.Encode()
+	return safe.RequestURI()
+}
+
+// Authorizer autentica a quien llama a la API y comprueba que tiene el
+// ámbito que exige cada ruta. Los servicios se autentican con un certificado
+// de cliente verificado (mTLS) o una API key en la cabecera X-API-Key; los
+// dispositivos, con su JWT en la cabecera Authorization.
+//
+// Cada petición actúa sobre un tenant: el de la credencial o, con una
+// credencial de la plataforma, el de la cabecera X-Tenant-ID (el tenant por
+// defecto si no se indica).
+type Authorizer struct {
+	serviceAuthService *usecase.ServiceAuthService
+	tokenService       *usecase.TokenService
+	tenantService      *usecase.TenantService
+}
+
+// NewAuthorizer crea un nuevo Authorizer
+func NewAuthorizer(
+	serviceAuthService *usecase.ServiceAuthService,
+	tokenService *usecase.TokenService,
+	tenantService *usecase.TenantService,
+) *Authorizer {
+	return &Authorizer{
+		serviceAuthService: serviceAuthService,
+		tokenService:       tokenService,
+		tenantService:      tenantService,
+	}
+}
+
+// Require exige el ámbito indicado para llamar al handler. Quien llama y su
+// tenant quedan en el contexto de la petición; los handlers comprueban además
+// que un dispositivo solo actúe sobre sí mismo y su usuario.
+func (a *Authorizer) Require(scope string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.authenticate(r)
+		if err != nil {
+			switch {
+			case errors.Is(err, usecase.ErrTokenExpired):
+				respondWithError(w, http.StatusUnauthorized, "token_expired")
+			case errors.Is(err, usecase.ErrCredentialsRequired),
+				errors.Is(err, usecase.ErrInvalidAPIKey),
+				errors.Is(err, usecase.ErrUnknownClientCertificate),
+				errors.Is(err, usecase.ErrInvalidToken),
+				errors.Is(err, usecase.ErrTokenRevoked):
+				respondWithError(w, http.StatusUnauthorized, err.Error())
+			default:
+				respondWithError(w, http.StatusInternalServerError, "Failed to authenticate request")
+			}
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			respondWithError(w, http.StatusForbidden, "Missing scope "+scope)
+			return
+		}
+
+		tenantID, ok := principal.Tenant(r.Header.Get(auth.TenantHeader))
+		if !ok {
+			respondWithError(w, http.StatusForbidden, "Not allowed to access this tenant")
+			return
+		}
+		if !a.checkTenant(w, r, tenantID) {
+			return
+		}
+
+		if ok, wait := a.serviceAuthService.Allow(r.Context(), principal); !ok {
+			setRetryAfter(w, wait)
+			respondWithError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+
+		ctx := auth.WithTenant(auth.WithPrincipal(r.Context(), principal), tenantID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Anonymous deja llamar al handler sin credenciales, como en el registro de
+// dispositivos. El tenant es el de la cabecera X-Tenant-ID, o el tenant por
+// defecto si no se indica.
+func (a *Authorizer) Anonymous(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := r.Header.Get(auth.TenantHeader)
+		if tenantID == "" {
+			tenantID = auth.DefaultTenant
+		}
+		if !a.checkTenant(w, r, tenantID) {
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithTenant(r.Context(), tenantID)))
+	})
+}
+
+// Optional autentica la petición, como Require, si trae credenciales, y si
+// no la deja pasar como Anonymous. Así el registro de dispositivos sigue
+// abierto, pero un servicio puede identificarse para vincular un usuario.
+func (a *Authorizer) Optional(scope string, next http.HandlerFunc) http.Handler {
+	require := a.Require(scope, next)
+	anonymous := a.Anonymous(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hasCredentials(r) {
+			require.ServeHTTP(w, r)
+			return
+		}
+		anonymous.ServeHTTP(w, r)
+	})
+}
+
+// hasCredentials indica si una petición trae alguna credencial: un
+// certificado de cliente verificado, una API key o un token
+func hasCredentials(r *http.Request) bool {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+	return r.Header.Get(apiKeyHeader) != "" || r.Header.Get("Authorization") != ""
+}
+
+// checkTenant comprueba que existe el tenant de una petición. Si no, responde
+// 400 y devuelve false.
+func (a *Authorizer) checkTenant(w http.ResponseWriter, r *http.Request, tenantID string) bool {
+	exists, err := a.tenantService.Exists(r.Context(), tenantID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get tenant")
+		return false
+	}
+	if !exists {
+		respondWithError(w, http.StatusBadRequest, "Unknown tenant")
+		return false
+	}
+	return true
+}
+
+// authenticate obtiene quién hace una petición: un servicio por su
+// certificado de cliente o su API key, o un dispositivo por su token. Una API
+// key también se acepta como token Bearer.
+func (a *Authorizer) authenticate(r *http.Request) (*auth.Principal, error) {
+	// Solo cuentan los certificados verificados contra la CA de clientes
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return a.serviceAuthService.AuthenticateCertificate(r.TLS.VerifiedChains[0][0])
+	}
+
+	if apiKey := r.Header.Get(apiKeyHeader); apiKey != "" {
+		return a.serviceAuthService.AuthenticateAPIKey(r.Context(), apiKey)
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, usecase.ErrCredentialsRequired
+	}
+	if auth.IsAPIKey(token) {
+		return a.serviceAuthService.AuthenticateAPIKey(r.Context(), token)
+	}
+	return a.tokenService.Authenticate(r.Context(), token)
+}
+
+// setRetryAfter fija la cabecera Retry-After con la espera en segundos
+// enteros, como mínimo uno, y la devuelve
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) int {
+	seconds := max(int(math.Ceil(wait.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return seconds
+}
+
+// authorizeDevice comprueba que quien llama puede actuar sobre un
+// dispositivo. Si no, responde 403 y devuelve false.
+func authorizeDevice(w http.ResponseWriter, r *http.Request, deviceID string) bool {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok || !principal.CanAccessDevice(deviceID) {
+		respondWithError(w, http.StatusForbidden, "Not allowed to access this device")
+		return false
+	}
+	return true
+}
+
+// authorizePlatform comprueba que quien llama tiene una credencial de la
+// plataforma y no de un tenant. Si no, responde 403 y devuelve false.
+func authorizePlatform(w http.ResponseWriter, r *http.Request) bool {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok || principal.IsDevice() || !principal.IsPlatform() {
+		respondWithError(w, http.StatusForbidden, "Platform credentials required")
+		return false
+	}
+	return true
+}
+
+// authorizeService comprueba que quien llama es un servicio autenticado y
+// no un dispositivo. Sin credenciales responde 401; con las de un
+// dispositivo, 403. Devuelve false si no es un servicio.
+func authorizeService(w http.ResponseWriter, r *http.Request) bool {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, usecase.ErrCredentialsRequired.Error())
+		return false
+	}
+	if principal.IsDevice() {
+		respondWithError(w, http.StatusForbidden, "Service credentials required")
+		return false
+	}
+	return true
+}
+
+// authorizeUser comprueba que quien llama puede actuar sobre un usuario. Si
+// no, responde 403 y devuelve false.
+func authorizeUser(w http.ResponseWriter, r *http.Request, userID string) bool {
+	if !canAccessUser(r, userID) {
+		respondWithError(w, http.StatusForbidden, "Not allowed to access this user")
+		return false
+	}
+	return true
+}
+
+// canAccessUser indica si quien llama puede actuar sobre un usuario
+func canAccessUser(r *http.Request, userID string) bool {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	return ok && principal.CanAccessUser(userID)
+}
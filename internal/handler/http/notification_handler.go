@@ -2,11 +2,14 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"notification-service/internal/domain/entity"
 	"notification-service/internal/usecase"
+	"notification-service/pkg/auth"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -24,6 +27,40 @@ func NewNotificationHandler(notificationService *usecase.NotificationService) *N
 	}
 }
 
+// sendTemplateRequest son los campos con los que una petición de envío
+// indica la plantilla con la que renderizar la notificación
+type sendTemplateRequest struct {
+	TemplateID      string                 `json:"template_id,omitempty"`
+	TemplateVersion int                    `json:"template_version,omitempty"` // 0=publicada
+	Locale          string                 `json:"locale,omitempty"`           // por defecto, el de los dispositivos
+	Variables       map[string]interface{} `json:"variables,omitempty"`
+}
+
+// template devuelve la plantilla de la petición, o nil si no indica ninguna
+func (req *sendTemplateRequest) template() *usecase.TemplateRef {
+	if req.TemplateID == "" {
+		return nil
+	}
+	return &usecase.TemplateRef{
+		ID:        req.TemplateID,
+		Version:   req.TemplateVersion,
+		Locale:    req.Locale,
+		Variables: req.Variables,
+	}
+}
+
+// validate comprueba que la petición indica el destinatario y una plantilla
+// o el título y el mensaje
+func (req *sendTemplateRequest) validate(userID, title, message string) error {
+	if userID == "" || (req.TemplateID == "" && (title == "" || message == "")) {
+		return errors.New("user_id and either template_id or title and message are required")
+	}
+	if req.TemplateVersion < 0 {
+		return errors.New("template_version must not be negative")
+	}
+	return nil
+}
+
 // SendNotification envía una notificación a un usuario
 func (h *NotificationHandler) SendNotification(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -32,6 +69,8 @@ func (h *NotificationHandler) SendNotification(w http.ResponseWriter, r *http.Re
 		Message          string                 `json:"message"`
 		Data             map[string]interface{} `json:"data"`
 		NotificationType string                 `json:"notification_type"`
+		Priority         int                    `json:"priority"` // -1=masiva, 0=normal, 1=alta
+		sendTemplateRequest
 	}
 
 	// Decodificar el cuerpo de la petición
@@ -41,8 +80,8 @@ func (h *NotificationHandler) SendNotification(w http.ResponseWriter, r *http.Re
 	}
 
 	// Validar campos requeridos
-	if req.UserID == "" || req.Title == "" || req.Message == "" {
-		respondWithError(w, http.StatusBadRequest, "user_id, title and message are required")
+	if err := req.validate(req.UserID, req.Title, req.Message); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -66,11 +105,13 @@ func (h *NotificationHandler) SendNotification(w http.ResponseWriter, r *http.Re
 		req.Title,
 		req.Message,
 		req.Data,
+		req.template(),
 		notificationType,
+		req.Priority,
 	)
 
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithSendError(w, err)
 		return
 	}
 
@@ -104,6 +145,12 @@ func (h *NotificationHandler) GetNotification(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Un dispositivo solo ve las notificaciones de su usuario
+	if !canAccessUser(r, notification.UserID) {
+		respondWithError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+
 	// Obtener el estado de entrega
 	deliveryStatus, err := h.notificationService.GetDeliveryStatus(r.Context(), notificationID)
 	if err != nil {
@@ -148,7 +195,7 @@ func (h *NotificationHandler) GetNotification(w http.ResponseWriter, r *http.Req
 	dataMap, _ := notification.GetDataMap()
 
 	// Responder con la notificación y su estado de entrega
-	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+	response := map[string]interface{}{
 		"id":         notification.ID.String(),
 		"user_id":    notification.UserID,
 		"title":      notification.Title,
@@ -157,7 +204,14 @@ func (h *NotificationHandler) GetNotification(w http.ResponseWriter, r *http.Req
 		"type":       notification.NotificationType,
 		"created_at": notification.CreatedAt.Unix(),
 		"deliveries": deliveryInfo,
-	})
+	}
+	if notification.TemplateID != "" {
+		response["template_id"] = notification.TemplateID
+		response["template_version"] = notification.TemplateVersion
+		response["locale"] = notification.Locale
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
 }
 
 // GetUserNotifications obtiene las notificaciones de un usuario
@@ -165,6 +219,9 @@ func (h *NotificationHandler) GetUserNotifications(w http.ResponseWriter, r *htt
 	// Obtener ID del usuario de la URL
 	vars := mux.Vars(r)
 	userID := vars["user_id"]
+	if !authorizeUser(w, r, userID) {
+		return
+	}
 
 	// Parámetros de paginación
 	limitStr := r.URL.Query().Get("limit")
@@ -257,10 +314,17 @@ func (h *NotificationHandler) ConfirmDelivery(w http.ResponseWriter, r *http.Req
 		respondWithError(w, http.StatusBadRequest, "Invalid device ID")
 		return
 	}
+	if !authorizeDevice(w, r, deviceID.String()) {
+		return
+	}
 
 	// Confirmar entrega
 	err = h.notificationService.ConfirmDelivery(r.Context(), notificationID, deviceID)
 	if err != nil {
+		if errors.Is(err, usecase.ErrNotificationNotFound) {
+			respondWithError(w, http.StatusNotFound, "Notification not found")
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -292,9 +356,22 @@ func (h *NotificationHandler) GetDeliveryStatus(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	// Un dispositivo solo ve las notificaciones de su usuario
+	if principal, _ := auth.PrincipalFromContext(r.Context()); principal == nil || principal.IsDevice() {
+		notification, err := h.notificationService.GetNotification(r.Context(), notificationID)
+		if err != nil || !canAccessUser(r, notification.UserID) {
+			respondWithError(w, http.StatusNotFound, "Notification not found")
+			return
+		}
+	}
+
 	// Obtener estado de entrega
 	deliveries, err := h.notificationService.GetDeliveryStatus(r.Context(), notificationID)
 	if err != nil {
+		if errors.Is(err, usecase.ErrNotificationNotFound) {
+			respondWithError(w, http.StatusNotFound, "Notification not found")
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -347,8 +424,9 @@ func (h *NotificationHandler) SendHybridNotification(w http.ResponseWriter, r *h
 		Message          string                 `json:"message"`
 		Data             map[string]interface{} `json:"data,omitempty"`
 		NotificationType string                 `json:"notification_type,omitempty"` // normal, urgent, system, message
-		Priority         int                    `json:"priority,omitempty"`          // 0=normal, 1=alta
+		Priority         int                    `json:"priority,omitempty"`          // -1=masiva, 0=normal, 1=alta
 		Channels         []string               `json:"channels,omitempty"`          // websocket, fcm, apns - si no se especifica, usa todos
+		sendTemplateRequest
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -357,8 +435,8 @@ func (h *NotificationHandler) SendHybridNotification(w http.ResponseWriter, r *h
 	}
 
 	// Validar campos obligatorios
-	if req.UserID == "" || req.Title == "" || req.Message == "" {
-		respondWithError(w, http.StatusBadRequest, "user_id, title and message are required")
+	if err := req.validate(req.UserID, req.Title, req.Message); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -399,6 +477,7 @@ func (h *NotificationHandler) SendHybridNotification(w http.ResponseWriter, r *h
 			req.Title,
 			req.Message,
 			req.Data,
+			req.template(),
 			notificationType,
 			req.Priority,
 			req.Channels,
@@ -411,12 +490,14 @@ func (h *NotificationHandler) SendHybridNotification(w http.ResponseWriter, r *h
 			req.Title,
 			req.Message,
 			req.Data,
+			req.template(),
 			notificationType,
+			req.Priority,
 		)
 	}
 
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithSendError(w, err)
 		return
 	}
 
@@ -425,3 +506,39 @@ func (h *NotificationHandler) SendHybridNotification(w http.ResponseWriter, r *h
 		"status":          "success",
 	})
 }
+
+// respondWithSendError responde al error de un envío: 429 con Retry-After si
+// supera una cuota, 503 con Retry-After si su carril de envío está lleno,
+// 404 o 400 si su plantilla no existe o no se puede renderizar y 500 en otro
+// caso
+func respondWithSendError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrSendQueueFull):
+		setRetryAfter(w, time.Second)
+		respondWithError(w, http.StatusServiceUnavailable, "Send queue is full")
+		return
+	case errors.Is(err, usecase.ErrTemplateNotFound):
+		respondWithError(w, http.StatusNotFound, "Template not found")
+		return
+	case errors.Is(err, usecase.ErrTemplateRenderFailed):
+		respondWithRenderError(w, err)
+		return
+	case errors.Is(err, usecase.ErrInvalidTemplate):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var quotaErr *usecase.QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	retryAfter := setRetryAfter(w, quotaErr.RetryAfter)
+	respondWithJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+		"error":       "Quota exceeded",
+		"level":       quotaErr.Level,
+		"window":      quotaErr.Window,
+		"retry_after": retryAfter,
+	})
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"notification-service/internal/usecase"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// PresenceHandler maneja las consultas de presencia (quién está conectado)
+type PresenceHandler struct {
+	presenceService *usecase.PresenceService
+}
+
+// NewPresenceHandler crea un nuevo PresenceHandler
+func NewPresenceHandler(presenceService *usecase.PresenceService) *PresenceHandler {
+	return &PresenceHandler{
+		presenceService: presenceService,
+	}
+}
+
+// GetUserPresence obtiene la presencia de un usuario
+func (h *PresenceHandler) GetUserPresence(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	if userID == "" {
+		respondWithError(w, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, h.presenceService.GetUserPresence(r.Context(), userID))
+}
+
+// QueryUsersPresence obtiene la presencia de varios usuarios
+func (h *PresenceHandler) QueryUsersPresence(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserIDs []string `json:"user_ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.UserIDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "user_ids is required")
+		return
+	}
+
+	users, err := h.presenceService.GetUsersPresence(r.Context(), req.UserIDs)
+	if err != nil {
+		if errors.Is(err, usecase.ErrTooManyPresenceIDs) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("at most %d user_ids are allowed", usecase.MaxPresenceQuerySize))
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get presence")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+	})
+}
+
+// GetDevicePresence obtiene la presencia de un dispositivo
+func (h *PresenceHandler) GetDevicePresence(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+
+	presence, err := h.presenceService.GetDevicePresence(r.Context(), deviceID)
+	if err != nil {
+		if errors.Is(err, usecase.ErrDeviceNotFound) {
+			respondWithError(w, http.StatusNotFound, "Device not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get presence")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, presence)
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"notification-service/internal/usecase"
+	"notification-service/pkg/auth"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// SessionHandler maneja las peticiones HTTP relacionadas con las sesiones de
+// usuario
+type SessionHandler struct {
+	sessionService *usecase.SessionService
+}
+
+// NewSessionHandler crea un nuevo SessionHandler
+func NewSessionHandler(sessionService *usecase.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+// ListSessions obtiene las sesiones activas de un usuario
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if userID == "" {
+		respondWithError(w, http.StatusBadRequest, "User ID is required")
+		return
+	}
+	if !authorizeUser(w, r, userID) {
+		return
+	}
+
+	sessions, err := h.sessionService.ListUserSessions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get sessions")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+// RevokeSession revoca una sesión de un usuario y cierra sus conexiones
+func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+	if userID == "" {
+		respondWithError(w, http.StatusBadRequest, "User ID is required")
+		return
+	}
+	if !authorizeUser(w, r, userID) {
+		return
+	}
+
+	sessionID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(r.Context(), auth.TenantFromContext(r.Context()), userID, sessionID); err != nil {
+		if errors.Is(err, usecase.ErrSessionNotFound) {
+			respondWithError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+	})
+}
+
+// RevokeAllSessions revoca todas las sesiones de un usuario y cierra sus
+// conexiones
+func (h *SessionHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if userID == "" {
+		respondWithError(w, http.StatusBadRequest, "User ID is required")
+		return
+	}
+	if !authorizeUser(w, r, userID) {
+		return
+	}
+
+	revoked, err := h.sessionService.RevokeUserSessions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"revoked": revoked,
+	})
+}
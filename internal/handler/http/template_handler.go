@@ -0,0 +1,257 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/usecase"
+	"notification-service/pkg/template"
+
+	"github.com/gorilla/mux"
+)
+
+// TemplateHandler administra las plantillas de notificaciones del tenant de
+// quien llama y sus versiones
+type TemplateHandler struct {
+	templateService *usecase.TemplateService
+}
+
+// NewTemplateHandler crea un nuevo TemplateHandler
+func NewTemplateHandler(templateService *usecase.TemplateService) *TemplateHandler {
+	return &TemplateHandler{
+		templateService: templateService,
+	}
+}
+
+// templateRequest es el cuerpo con el que se crea una plantilla o una
+// versión nueva
+type templateRequest struct {
+	ID          string                             `json:"id"`
+	Name        string                             `json:"name"`
+	Description string                             `json:"description"`
+	Title       string                             `json:"title"`
+	Body        string                             `json:"body"`
+	Data        map[string]string                  `json:"data"`
+	Locales     map[string]entity.TemplateLocale   `json:"locales"`
+	Variables   map[string]entity.TemplateVariable `json:"variables"`
+}
+
+// content devuelve el contenido de la versión de la petición
+func (req *templateRequest) content() usecase.TemplateContent {
+	return usecase.TemplateContent{
+		Title:     req.Title,
+		Body:      req.Body,
+		Data:      req.Data,
+		Locales:   req.Locales,
+		Variables: req.Variables,
+	}
+}
+
+// ListTemplates obtiene las plantillas del tenant
+func (h *TemplateHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.templateService.ListTemplates(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get templates")
+		return
+	}
+	if templates == nil {
+		templates = []*entity.Template{}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"templates": templates,
+	})
+}
+
+// CreateTemplate crea una plantilla con su primera versión, en borrador
+func (h *TemplateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req templateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tmpl, version, err := h.templateService.CreateTemplate(r.Context(), req.ID, req.Name, req.Description, req.content())
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidTemplate):
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, usecase.ErrTemplateAlreadyExists):
+			respondWithError(w, http.StatusConflict, "Template already exists")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Failed to create template")
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"template": tmpl,
+		"version":  version,
+	})
+}
+
+// GetTemplate obtiene una plantilla
+func (h *TemplateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.templateService.GetTemplate(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		respondWithTemplateError(w, err, "Failed to get template")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tmpl)
+}
+
+// UpdateTemplate crea una versión nueva, en borrador, de una plantilla. La
+// versión publicada no cambia hasta que se publica la nueva.
+func (h *TemplateHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req templateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	version, err := h.templateService.UpdateTemplate(r.Context(), mux.Vars(r)["id"], req.Name, req.Description, req.content())
+	if err != nil {
+		respondWithTemplateError(w, err, "Failed to update template")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, version)
+}
+
+// DeleteTemplate elimina una plantilla con todas sus versiones
+func (h *TemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := h.templateService.DeleteTemplate(r.Context(), mux.Vars(r)["id"]); err != nil {
+		respondWithTemplateError(w, err, "Failed to delete template")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListVersions obtiene las versiones de una plantilla, de la más reciente a
+// la más antigua
+func (h *TemplateHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.templateService.ListVersions(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		respondWithTemplateError(w, err, "Failed to get template versions")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"versions": versions,
+	})
+}
+
+// GetVersion obtiene una versión de una plantilla
+func (h *TemplateHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	number, ok := templateVersionNumber(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := h.templateService.GetVersion(r.Context(), mux.Vars(r)["id"], number)
+	if err != nil {
+		respondWithTemplateError(w, err, "Failed to get template version")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, version)
+}
+
+// PublishVersion publica una versión de una plantilla; con una anterior a
+// la publicada, deshace los cambios
+func (h *TemplateHandler) PublishVersion(w http.ResponseWriter, r *http.Request) {
+	number, ok := templateVersionNumber(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := h.templateService.PublishVersion(r.Context(), mux.Vars(r)["id"], number)
+	if err != nil {
+		respondWithTemplateError(w, err, "Failed to publish template version")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, version)
+}
+
+// PreviewTemplate renderiza una versión de una plantilla (por defecto, la
+// última) con los datos de la petición, sin enviarla
+func (h *TemplateHandler) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Version int                    `json:"version"`
+		Locale  string                 `json:"locale"`
+		Data    map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	title, body, data, err := h.templateService.PreviewVersion(r.Context(), mux.Vars(r)["id"], req.Version, req.Locale, req.Data)
+	if err != nil {
+		// Los errores de renderizado se deben a los datos de la petición
+		if errors.Is(err, usecase.ErrTemplateRenderFailed) {
+			respondWithRenderError(w, err)
+			return
+		}
+		respondWithTemplateError(w, err, "Failed to preview template")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"title": title,
+		"body":  body,
+		"data":  data,
+	})
+}
+
+// templateVersionNumber lee el número de versión de la URL
+func templateVersionNumber(w http.ResponseWriter, r *http.Request) (int, bool) {
+	number, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil || number < 1 {
+		respondWithError(w, http.StatusBadRequest, "Invalid template version")
+		return 0, false
+	}
+	return number, true
+}
+
+// respondWithRenderError responde 400 al error de renderizar una plantilla
+// con unos datos; si faltan variables o no son de su tipo, las enumera en
+// missing e invalid
+func respondWithRenderError(w http.ResponseWriter, err error) {
+	var variablesErr *template.VariablesError
+	if !errors.As(err, &variablesErr) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := map[string]interface{}{
+		"error": "Invalid template variables",
+	}
+	if len(variablesErr.Missing) > 0 {
+		response["missing"] = variablesErr.Missing
+	}
+	if len(variablesErr.Invalid) > 0 {
+		response["invalid"] = variablesErr.Invalid
+	}
+	respondWithJSON(w, http.StatusBadRequest, response)
+}
+
+// respondWithTemplateError responde al error de una operación sobre una
+// plantilla: 404 si no existe, 400 si su contenido no es válido y 500 con
+// message en otro caso
+func respondWithTemplateError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, usecase.ErrTemplateNotFound):
+		respondWithError(w, http.StatusNotFound, "Template not found")
+	case errors.Is(err, usecase.ErrInvalidTemplate):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondWithError(w, http.StatusInternalServerError, message)
+	}
+}
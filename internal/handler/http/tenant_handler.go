@@ -0,0 +1,169 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/usecase"
+
+	"github.com/gorilla/mux"
+)
+
+// TenantHandler administra los tenants. Solo pueden hacerlo los
+// administradores de la plataforma.
+type TenantHandler struct {
+	tenantService *usecase.TenantService
+}
+
+// NewTenantHandler crea un nuevo TenantHandler
+func NewTenantHandler(tenantService *usecase.TenantService) *TenantHandler {
+	return &TenantHandler{
+		tenantService: tenantService,
+	}
+}
+
+// tenantRequest es el cuerpo con el que se crea o actualiza un tenant
+type tenantRequest struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	APNSBundleID        string `json:"apns_bundle_id"`
+	APNSCertificatePath string `json:"apns_certificate_path"`
+	APNSProduction      bool   `json:"apns_production"`
+	FCMServerKey        string `json:"fcm_server_key"`
+
+	// Cuotas de envío; sin ellas se usan las de la configuración al crear y
+	// se conservan las actuales al actualizar
+	Quota *struct {
+		PerSecond float64 `json:"per_second"`
+		PerDay    int64   `json:"per_day"`
+	} `json:"quota"`
+}
+
+// credentials devuelve las credenciales de push de la petición
+func (req *tenantRequest) credentials() usecase.TenantCredentials {
+	return usecase.TenantCredentials{
+		APNSBundleID:        req.APNSBundleID,
+		APNSCertificatePath: req.APNSCertificatePath,
+		APNSProduction:      req.APNSProduction,
+		FCMServerKey:        req.FCMServerKey,
+	}
+}
+
+// quota devuelve las cuotas de la petición, o nil si no las indica
+func (req *tenantRequest) quota() *usecase.Quota {
+	if req.Quota == nil {
+		return nil
+	}
+	return &usecase.Quota{
+		PerSecond: req.Quota.PerSecond,
+		PerDay:    req.Quota.PerDay,
+	}
+}
+
+// CreateTenant crea un tenant con sus credenciales de FCM y APNS y sus
+// cuotas de envío
+func (h *TenantHandler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	if !authorizePlatform(w, r) {
+		return
+	}
+
+	var req tenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var quota usecase.Quota
+	if q := req.quota(); q != nil {
+		quota = *q
+	}
+
+	tenant, err := h.tenantService.CreateTenant(r.Context(), req.ID, req.Name, req.credentials(), quota)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidTenantRequest):
+			respondWithError(w, http.StatusBadRequest, "A valid id (lowercase letters, digits and dashes), a name and non-negative quotas are required")
+		case errors.Is(err, usecase.ErrTenantAlreadyExists):
+			respondWithError(w, http.StatusConflict, "Tenant already exists")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Failed to create tenant")
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, tenantResponse(tenant))
+}
+
+// ListTenants obtiene todos los tenants, sin sus secretos
+func (h *TenantHandler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	if !authorizePlatform(w, r) {
+		return
+	}
+
+	tenants, err := h.tenantService.ListTenants(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get tenants")
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(tenants))
+	for _, tenant := range tenants {
+		result = append(result, tenantResponse(tenant))
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"tenants": result,
+	})
+}
+
+// UpdateTenant cambia el nombre, las credenciales y las cuotas de un tenant.
+// Sin fcm_server_key o sin quota se conservan los actuales.
+func (h *TenantHandler) UpdateTenant(w http.ResponseWriter, r *http.Request) {
+	if !authorizePlatform(w, r) {
+		return
+	}
+
+	var req tenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	tenant, err := h.tenantService.UpdateTenant(r.Context(), id, req.Name, req.credentials(), req.quota())
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrTenantNotFound):
+			respondWithError(w, http.StatusNotFound, "Tenant not found")
+			return
+		case errors.Is(err, usecase.ErrInvalidTenantRequest):
+			respondWithError(w, http.StatusBadRequest, "Quotas must not be negative")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to update tenant")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tenantResponse(tenant))
+}
+
+// tenantResponse devuelve un tenant sin su server key de FCM, indicando solo
+// si la tiene
+func tenantResponse(tenant *entity.Tenant) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                    tenant.ID,
+		"name":                  tenant.Name,
+		"apns_bundle_id":        tenant.APNSBundleID,
+		"apns_certificate_path": tenant.APNSCertificatePath,
+		"apns_production":       tenant.APNSProduction,
+		"fcm_configured":        tenant.HasFCM(),
+		"created_at":            tenant.CreatedAt,
+		"updated_at":            tenant.UpdatedAt,
+		"quota": map[string]interface{}{
+			"per_second": tenant.QuotaPerSecond,
+			"per_day":    tenant.QuotaPerDay,
+		},
+	}
+}
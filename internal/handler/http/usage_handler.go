@@ -0,0 +1,77 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"notification-service/internal/usecase"
+)
+
+// usageDayLayout es el formato de los días de la consulta de uso
+const usageDayLayout = "2006-01-02"
+
+// UsageHandler consulta el uso de las cuotas de envío para facturación e
+// informes
+type UsageHandler struct {
+	quotaService *usecase.QuotaService
+}
+
+// NewUsageHandler crea un nuevo UsageHandler
+func NewUsageHandler(quotaService *usecase.QuotaService) *UsageHandler {
+	return &UsageHandler{
+		quotaService: quotaService,
+	}
+}
+
+// GetUsage obtiene los envíos diarios de los tenants y de sus servicios entre
+// from y to (por defecto, el mes en curso). Un administrador de un tenant
+// solo ve el suyo; uno de la plataforma, el de tenant_id o todos.
+func (h *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	tenantID := query.Get("tenant_id")
+	if own := principalTenant(r); own != "" {
+		if tenantID != "" && tenantID != own {
+			respondWithError(w, http.StatusForbidden, "Not allowed to access this tenant")
+			return
+		}
+		tenantID = own
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := today
+
+	if value := query.Get("from"); value != "" {
+		day, err := time.Parse(usageDayLayout, value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid from, expected YYYY-MM-DD")
+			return
+		}
+		from = day
+	}
+	if value := query.Get("to"); value != "" {
+		day, err := time.Parse(usageDayLayout, value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid to, expected YYYY-MM-DD")
+			return
+		}
+		to = day
+	}
+	if to.Before(from) {
+		respondWithError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	usage, err := h.quotaService.Usage(r.Context(), tenantID, from, to)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get usage")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"from":  from.Format(usageDayLayout),
+		"to":    to.Format(usageDayLayout),
+		"usage": usage,
+	})
+}
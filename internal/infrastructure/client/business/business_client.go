@@ -169,3 +169,27 @@ func (c *BusinessClient) GetUserDevices(ctx context.Context, userID string) ([]*
 
 	return devices, nil
 }
+
+// ReportClientEvent envía al servicio de negocio un evento recibido de un
+// cliente conectado y devuelve la respuesta (JSON) para el cliente, si la hay.
+// No se reintenta: los eventos de cliente son efímeros (indicadores de
+// escritura, estado de la app) y un reintento tardío no aporta nada.
+func (c *BusinessClient) ReportClientEvent(ctx context.Context, messageID, userID, deviceID, eventType string, payload []byte) ([]byte, error) {
+	resp, err := c.client.ReportClientEvent(ctx, &pb.ReportClientEventRequest{
+		MessageId: messageID,
+		UserId:    userID,
+		DeviceId:  deviceID,
+		EventType: eventType,
+		Payload:   payload,
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error calling ReportClientEvent: %w", err)
+	}
+
+	if !resp.Success {
+		return nil, fmt.Errorf("business service error: %s", resp.ErrorMessage)
+	}
+
+	return resp.Payload, nil
+}
@@ -26,7 +26,8 @@ type APNSAdapter struct {
 	bundleID     string
 	isProduction bool
 	httpClient   *http.Client
-	logger       logging.Logger
+	limits       Limits
+	logger       *logging.Logger
 }
 
 // APNSPayload representa el payload de una notificación APNS
@@ -64,7 +65,7 @@ func NewAPNSAdapter(
 	certificatePassword string,
 	bundleID string,
 	isProduction bool,
-	logger logging.Logger,
+	logger *logging.Logger,
 ) (*APNSAdapter, error) {
 	// Cargar el certificado de cliente
 	cert, err := tls.LoadX509KeyPair(certificatePath, certificatePath)
@@ -89,10 +90,16 @@ func NewAPNSAdapter(
 		bundleID:     bundleID,
 		isProduction: isProduction,
 		httpClient:   httpClient,
+		limits:       DefaultAPNSLimits,
 		logger:       logger,
 	}, nil
 }
 
+// SetLimits cambia las longitudes máximas del título y el cuerpo
+func (a *APNSAdapter) SetLimits(limits Limits) {
+	a.limits = limits
+}
+
 // Send envía una notificación a través de APNS
 func (a *APNSAdapter) Send(ctx context.Context, token string, notification *entity.Notification) (string, error) {
 	// Determinar el host APNS según el entorno
@@ -120,12 +127,14 @@ func (a *APNSAdapter) Send(ctx context.Context, token string, notification *enti
 	// Añadir notification_id a los datos para rastreo
 	dataMap["notification_id"] = notification.ID.String()
 
-	// Crear el payload de APNS
+	// Crear el payload de APNS, con el título y el cuerpo cortados a lo que
+	// se muestra
+	title, body := a.limits.apply(notification.Title, notification.Message)
 	payload := APNSPayload{
 		Aps: APSPayload{
 			Alert: APSAlert{
-				Title: notification.Title,
-				Body:  notification.Message,
+				Title: title,
+				Body:  body,
 			},
 			Sound: "default",
 			Badge: 1,
@@ -174,7 +183,7 @@ func (a *APNSAdapter) Send(ctx context.Context, token string, notification *enti
 	// Enviar la solicitud
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
-		a.logger.Error("Error sending APNS notification", "error", err, "token", token)
+		a.logger.Error("Error sending APNS notification to %s: %v", token, err)
 		return "", fmt.Errorf("error sending APNS request: %w", err)
 	}
 	defer resp.Body.Close()
@@ -186,15 +195,11 @@ func (a *APNSAdapter) Send(ctx context.Context, token string, notification *enti
 		}
 
 		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
-			a.logger.Error("Error decoding APNS error response", "status", resp.StatusCode, "error", err)
+			a.logger.Error("Error decoding APNS error response (status %d): %v", resp.StatusCode, err)
 			return "", fmt.Errorf("APNS server error: status=%d", resp.StatusCode)
 		}
 
-		a.logger.Error("APNS notification failed",
-			"status", resp.StatusCode,
-			"reason", errorResponse.Reason,
-			"token", token,
-		)
+		a.logger.Error("APNS notification to %s failed (status %d): %s", token, resp.StatusCode, errorResponse.Reason)
 
 		// Manejo específico de errores
 		if errorResponse.Reason == "BadDeviceToken" || errorResponse.Reason == "Unregistered" {
@@ -207,7 +212,7 @@ func (a *APNSAdapter) Send(ctx context.Context, token string, notification *enti
 
 	// Obtener el ID del mensaje desde las cabeceras
 	apnsID = resp.Header.Get("apns-id")
-	a.logger.Info("APNS notification sent successfully", "apnsID", apnsID, "token", token)
+	a.logger.Info("APNS notification %s sent to %s", apnsID, token)
 
 	return apnsID, nil
 }
@@ -22,7 +22,8 @@ const (
 type FCMAdapter struct {
 	apiKey     string
 	httpClient *http.Client
-	logger     logging.Logger
+	limits     Limits
+	logger     *logging.Logger
 }
 
 // FCMNotification representa una notificación FCM
@@ -59,16 +60,22 @@ type Result struct {
 }
 
 // NewFCMAdapter crea una nueva instancia de FCMAdapter
-func NewFCMAdapter(apiKey string, logger logging.Logger) *FCMAdapter {
+func NewFCMAdapter(apiKey string, logger *logging.Logger) *FCMAdapter {
 	return &FCMAdapter{
 		apiKey: apiKey,
 		httpClient: &http.Client{
 			Timeout: fcmTimeout,
 		},
+		limits: DefaultFCMLimits,
 		logger: logger,
 	}
 }
 
+// SetLimits cambia las longitudes máximas del título y el cuerpo
+func (a *FCMAdapter) SetLimits(limits Limits) {
+	a.limits = limits
+}
+
 // Send envía una notificación a través de FCM
 func (a *FCMAdapter) Send(ctx context.Context, token string, notification *entity.Notification) (string, error) {
 	// Convertir los datos de notification.Data a un mapa
@@ -93,12 +100,14 @@ func (a *FCMAdapter) Send(ctx context.Context, token string, notification *entit
 		priority = "high"
 	}
 
-	// Crear el payload de FCM
+	// Crear el payload de FCM, con el título y el cuerpo cortados a lo que
+	// se muestra
+	title, body := a.limits.apply(notification.Title, notification.Message)
 	payload := FCMPayload{
 		To: token,
 		Notification: FCMNotification{
-			Title: notification.Title,
-			Body:  notification.Message,
+			Title: title,
+			Body:  body,
 			Sound: "default",
 		},
 		Data:     dataMap,
@@ -133,28 +142,28 @@ func (a *FCMAdapter) Send(ctx context.Context, token string, notification *entit
 	// Enviar la solicitud
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
-		a.logger.Error("Error sending FCM notification", "error", err, "token", token)
+		a.logger.Error("Error sending FCM notification to %s: %v", token, err)
 		return "", fmt.Errorf("error sending FCM request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Comprobar el código de estado
 	if resp.StatusCode != http.StatusOK {
-		a.logger.Error("FCM server returned error", "status", resp.StatusCode, "token", token)
+		a.logger.Error("FCM server returned status %d for %s", resp.StatusCode, token)
 		return "", fmt.Errorf("FCM server returned error, status: %d", resp.StatusCode)
 	}
 
 	// Decodificar la respuesta
 	var fcmResponse FCMResponse
 	if err := json.NewDecoder(resp.Body).Decode(&fcmResponse); err != nil {
-		a.logger.Error("Error decoding FCM response", "error", err, "token", token)
+		a.logger.Error("Error decoding FCM response for %s: %v", token, err)
 		return "", fmt.Errorf("error decoding FCM response: %w", err)
 	}
 
 	// Comprobar si hubo éxito
 	if fcmResponse.Success == 0 {
 		if len(fcmResponse.Results) > 0 && fcmResponse.Results[0].Error != "" {
-			a.logger.Error("FCM notification failed", "error", fcmResponse.Results[0].Error, "token", token)
+			a.logger.Error("FCM notification to %s failed: %s", token, fcmResponse.Results[0].Error)
 			return "", fmt.Errorf("FCM notification failed: %s", fcmResponse.Results[0].Error)
 		}
 		return "", errors.New("FCM notification failed without specific error")
@@ -164,15 +173,12 @@ func (a *FCMAdapter) Send(ctx context.Context, token string, notification *entit
 	messageID := ""
 	if len(fcmResponse.Results) > 0 && fcmResponse.Results[0].MessageID != "" {
 		messageID = fcmResponse.Results[0].MessageID
-		a.logger.Info("FCM notification sent", "messageID", messageID, "token", token)
+		a.logger.Info("FCM notification %s sent to %s", messageID, token)
 	}
 
 	// Comprobar si el token ha cambiado
 	if len(fcmResponse.Results) > 0 && fcmResponse.Results[0].RegistrationID != "" {
-		a.logger.Info("FCM token has changed",
-			"oldToken", token,
-			"newToken", fcmResponse.Results[0].RegistrationID,
-		)
+		a.logger.Info("FCM token has changed from %s to %s", token, fcmResponse.Results[0].RegistrationID)
 		// Aquí se podría implementar una función para actualizar el token en la base de datos
 	}
 
@@ -0,0 +1,25 @@
+package push
+
+import "notification-service/pkg/template"
+
+// Limits son las longitudes máximas, en caracteres visibles, del título y el
+// cuerpo que se envían por un canal. Cero es sin límite.
+type Limits struct {
+	Title int
+	Body  int
+}
+
+var (
+	// DefaultFCMLimits es lo que Android muestra de una notificación
+	// contraída antes de cortarla él mismo
+	DefaultFCMLimits = Limits{Title: 65, Body: 240}
+
+	// DefaultAPNSLimits es lo que iOS muestra en la pantalla de bloqueo; el
+	// cuerpo se deja más largo porque se ve al expandir la notificación
+	DefaultAPNSLimits = Limits{Title: 50, Body: 1000}
+)
+
+// apply corta el título y el cuerpo de una notificación a los límites
+func (l Limits) apply(title, body string) (string, string) {
+	return template.Truncate(title, l.Title), template.Truncate(body, l.Body)
+}
@@ -0,0 +1,147 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// capturingTransport responde 200 a todas las peticiones y guarda el último
+// cuerpo enviado
+type capturingTransport struct {
+	response string
+	body     []byte
+}
+
+func (c *capturingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.body = body
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(c.response)),
+		Header:     make(http.Header),
+		Request:    req,
+	}, nil
+}
+
+// memTenantRepo tiene un tenant acme con una server key de FCM
+type memTenantRepo struct {
+	repository.TenantRepository
+	tenant *entity.Tenant
+}
+
+func (r memTenantRepo) GetByID(ctx context.Context, id string) (*entity.Tenant, error) {
+	if id != r.tenant.ID {
+		return nil, repository.ErrTenantNotFound
+	}
+	return r.tenant, nil
+}
+
+func longNotification() *entity.Notification {
+	return &entity.Notification{
+		ID:      uuid.New(),
+		Title:   strings.Repeat("t", 100),
+		Message: strings.Repeat("b", 2000),
+	}
+}
+
+// visibleLength cuenta los caracteres de un texto cortado, "…" incluido
+func visibleLength(s string) int {
+	return len([]rune(s))
+}
+
+func TestTenantAdaptersApplyFCMLimits(t *testing.T) {
+	keyCipher, err := auth.NewKeyCipher([]byte("test-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverKey, err := keyCipher.Encrypt([]byte("server-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		limits    *Limits
+		wantTitle int
+		wantBody  int
+	}{
+		{"default", nil, DefaultFCMLimits.Title, DefaultFCMLimits.Body},
+		{"configured", &Limits{Title: 20, Body: 80}, 20, 80},
+		{"unlimited", &Limits{}, 100, 2000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapters := NewTenantAdapters(
+				memTenantRepo{tenant: &entity.Tenant{ID: "acme", FCMServerKey: serverKey}},
+				keyCipher,
+				logging.NewLogger(logging.WithOutput(io.Discard)),
+			)
+			if tt.limits != nil {
+				adapters.SetLimits(*tt.limits, DefaultAPNSLimits)
+			}
+
+			adapter, err := adapters.Resolve(context.Background(), "acme", entity.TokenTypeFCM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transport := &capturingTransport{response: `{"success":1,"results":[{"message_id":"m1"}]}`}
+			adapter.(*FCMAdapter).httpClient.Transport = transport
+
+			if _, err := adapter.Send(context.Background(), "token", longNotification()); err != nil {
+				t.Fatal(err)
+			}
+
+			var payload FCMPayload
+			if err := json.Unmarshal(transport.body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if got := visibleLength(payload.Notification.Title); got != tt.wantTitle {
+				t.Errorf("title length = %d, want %d", got, tt.wantTitle)
+			}
+			if got := visibleLength(payload.Notification.Body); got != tt.wantBody {
+				t.Errorf("body length = %d, want %d", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPNSAdapterAppliesLimits(t *testing.T) {
+	transport := &capturingTransport{}
+	adapter := &APNSAdapter{
+		bundleID:   "com.example.app",
+		httpClient: &http.Client{Transport: transport},
+		limits:     DefaultAPNSLimits,
+		logger:     logging.NewLogger(logging.WithOutput(io.Discard)),
+	}
+	adapter.SetLimits(Limits{Title: 10, Body: 30})
+
+	if _, err := adapter.Send(context.Background(), "token", longNotification()); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload APNSPayload
+	if err := json.Unmarshal(transport.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if got := payload.Aps.Alert.Title; visibleLength(got) != 10 || !strings.HasSuffix(got, "…") {
+		t.Errorf("title = %q, want 10 characters ending in …", got)
+	}
+	if got := payload.Aps.Alert.Body; visibleLength(got) != 30 || !strings.HasSuffix(got, "…") {
+		t.Errorf("body = %q, want 30 characters ending in …", got)
+	}
+}
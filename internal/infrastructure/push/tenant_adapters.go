@@ -0,0 +1,139 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/internal/usecase"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/logging"
+)
+
+// tenantAdaptersTTL es cuánto tiempo se reutilizan los adaptadores de un
+// tenant; un cambio de credenciales tarda como mucho esto en aplicarse
+const tenantAdaptersTTL = time.Minute
+
+// ErrTenantChannelNotConfigured indica que el tenant no tiene credenciales
+// para el canal
+var ErrTenantChannelNotConfigured = errors.New("push channel not configured for tenant")
+
+// tenantAdapters son los adaptadores de FCM y APNS de un tenant
+type tenantAdapters struct {
+	fcm      usecase.PushAdapter
+	apns     usecase.PushAdapter
+	loadedAt time.Time
+}
+
+// TenantAdapters crea y guarda los adaptadores de FCM y APNS de cada tenant
+// con sus propias credenciales, de forma que cada aplicación envía con su
+// server key y su certificado
+type TenantAdapters struct {
+	tenantRepo repository.TenantRepository
+	keyCipher  *auth.KeyCipher
+	logger     *logging.Logger
+
+	// Longitudes máximas de los textos en cada canal
+	fcmLimits  Limits
+	apnsLimits Limits
+
+	mu       sync.Mutex
+	adapters map[string]*tenantAdapters
+}
+
+// NewTenantAdapters crea una nueva instancia de TenantAdapters
+func NewTenantAdapters(tenantRepo repository.TenantRepository, keyCipher *auth.KeyCipher, logger *logging.Logger) *TenantAdapters {
+	return &TenantAdapters{
+		tenantRepo: tenantRepo,
+		keyCipher:  keyCipher,
+		logger:     logger,
+		fcmLimits:  DefaultFCMLimits,
+		apnsLimits: DefaultAPNSLimits,
+		adapters:   make(map[string]*tenantAdapters),
+	}
+}
+
+// SetLimits cambia las longitudes máximas de los textos de FCM y APNS de
+// los adaptadores que se creen a partir de ahora
+func (t *TenantAdapters) SetLimits(fcm, apns Limits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.fcmLimits = fcm
+	t.apnsLimits = apns
+	t.adapters = make(map[string]*tenantAdapters)
+}
+
+// Resolve devuelve el adaptador de un tenant para un tipo de token
+func (t *TenantAdapters) Resolve(ctx context.Context, tenantID string, tokenType entity.TokenType) (usecase.PushAdapter, error) {
+	adapters, err := t.load(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var adapter usecase.PushAdapter
+	switch tokenType {
+	case entity.TokenTypeFCM:
+		adapter = adapters.fcm
+	case entity.TokenTypeAPNS:
+		adapter = adapters.apns
+	}
+	if adapter == nil {
+		return nil, ErrTenantChannelNotConfigured
+	}
+
+	return adapter, nil
+}
+
+// load obtiene los adaptadores de un tenant, de la caché o creándolos con
+// las credenciales guardadas
+func (t *TenantAdapters) load(ctx context.Context, tenantID string) (*tenantAdapters, error) {
+	t.mu.Lock()
+	cached, ok := t.adapters[tenantID]
+	t.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < tenantAdaptersTTL {
+		return cached, nil
+	}
+
+	tenant, err := t.tenantRepo.GetByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tenant %s: %w", tenantID, err)
+	}
+
+	t.mu.Lock()
+	fcmLimits, apnsLimits := t.fcmLimits, t.apnsLimits
+	t.mu.Unlock()
+
+	adapters := &tenantAdapters{loadedAt: time.Now()}
+
+	if tenant.HasFCM() {
+		serverKey, err := t.keyCipher.Decrypt(tenant.FCMServerKey)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting FCM key of tenant %s: %w", tenantID, err)
+		}
+		fcm := NewFCMAdapter(string(serverKey), t.logger)
+		fcm.SetLimits(fcmLimits)
+		adapters.fcm = fcm
+	}
+
+	if tenant.HasAPNS() {
+		apns, err := NewAPNSAdapter(tenant.APNSCertificatePath, "", tenant.APNSBundleID, tenant.APNSProduction, t.logger)
+		if err != nil {
+			// Sin APNS el tenant puede seguir enviando por los demás canales
+			t.logger.Error("Error creating APNS adapter for tenant %s: %v", tenantID, err)
+		} else {
+			apns.SetLimits(apnsLimits)
+			adapters.apns = apns
+		}
+	}
+
+	t.mu.Lock()
+	t.adapters[tenantID] = adapters
+	t.mu.Unlock()
+
+	return adapters, nil
+}
@@ -1 +1,55 @@
-package queue
\ No newline at end of file
+package queue
+
+import (
+	"context"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// PersistentQueue es una cola respaldada por base de datos para los mensajes
+// en tiempo real que no caben en la cola de salida de una conexión. Los
+// mensajes se conservan hasta que el dispositivo vuelve a conectarse.
+type PersistentQueue struct {
+	repo   repository.MessageQueueRepository
+	logger *logging.Logger
+}
+
+// NewPersistentQueue crea una nueva instancia de PersistentQueue
+func NewPersistentQueue(repo repository.MessageQueueRepository, logger *logging.Logger) *PersistentQueue {
+	return &PersistentQueue{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Push guarda un mensaje de una notificación para un dispositivo
+func (q *PersistentQueue) Push(ctx context.Context, notificationID, deviceID uuid.UUID, payload []byte) error {
+	message := entity.NewQueuedMessage(notificationID, deviceID, payload)
+	if err := q.repo.Enqueue(ctx, message); err != nil {
+		q.logger.Error("Failed to queue message for device %s: %v", deviceID, err)
+		return err
+	}
+
+	q.logger.Debug("Queued message %s for device %s", message.ID, deviceID)
+	return nil
+}
+
+// Pop extrae hasta limit mensajes pendientes de un dispositivo, del más
+// antiguo al más reciente
+func (q *PersistentQueue) Pop(ctx context.Context, deviceID uuid.UUID, limit int) ([][]byte, error) {
+	messages, err := q.repo.DequeueForDevice(ctx, deviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	payloads := make([][]byte, 0, len(messages))
+	for _, message := range messages {
+		payloads = append(payloads, message.Payload)
+	}
+
+	return payloads, nil
+}
@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Columnas de una API key, en el orden que espera query
+const apiKeyColumns = `id, tenant_id, name, prefix, key_hash, scopes, rate_limit, rate_burst, created_at, expires_at, revoked_at`
+
+// APIKeyRepository implementa repository.APIKeyRepository
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKeyRepository crea una instancia de APIKeyRepository
+func NewAPIKeyRepository(db *sql.DB) repository.APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create guarda una clave nueva
+func (r *APIKeyRepository) Create(ctx context.Context, key *entity.APIKey) error {
+	query := `
+		INSERT INTO notification_service.api_keys
+		(` + apiKeyColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		key.ID,
+		sql.NullString{String: key.TenantID, Valid: key.TenantID != ""},
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		pq.Array(key.Scopes),
+		key.RateLimit,
+		key.RateBurst,
+		key.CreatedAt,
+		key.ExpiresAt,
+		key.RevokedAt,
+	)
+
+	return err
+}
+
+// GetByHash obtiene una clave por el hash de su valor
+func (r *APIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM notification_service.api_keys WHERE key_hash = $1`
+
+	keys, err := r.query(ctx, query, keyHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, repository.ErrAPIKeyNotFound
+	}
+
+	return keys[0], nil
+}
+
+// List obtiene todas las claves, de la más reciente a la más antigua
+func (r *APIKeyRepository) List(ctx context.Context) ([]*entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM notification_service.api_keys ORDER BY created_at DESC`
+
+	return r.query(ctx, query)
+}
+
+// ListByTenant obtiene las claves de un tenant, de la más reciente a la más
+// antigua
+func (r *APIKeyRepository) ListByTenant(ctx context.Context, tenantID string) ([]*entity.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM notification_service.api_keys
+		WHERE tenant_id = $1
+		ORDER BY created_at DESC
+	`
+
+	return r.query(ctx, query, tenantID)
+}
+
+// GetByID obtiene una clave por su ID
+func (r *APIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM notification_service.api_keys WHERE id = $1`
+
+	keys, err := r.query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, repository.ErrAPIKeyNotFound
+	}
+
+	return keys[0], nil
+}
+
+// Revoke revoca una clave
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE notification_service.api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return repository.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// query ejecuta una consulta que devuelve API keys
+func (r *APIKeyRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entity.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*entity.APIKey
+
+	for rows.Next() {
+		var key entity.APIKey
+		var tenantID sql.NullString
+		var expiresAt, revokedAt sql.NullTime
+
+		err := rows.Scan(
+			&key.ID,
+			&tenantID,
+			&key.Name,
+			&key.Prefix,
+			&key.KeyHash,
+			pq.Array(&key.Scopes),
+			&key.RateLimit,
+			&key.RateBurst,
+			&key.CreatedAt,
+			&expiresAt,
+			&revokedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		key.TenantID = tenantID.String
+		if expiresAt.Valid {
+			key.ExpiresAt = &expiresAt.Time
+		}
+		if revokedAt.Valid {
+			key.RevokedAt = &revokedAt.Time
+		}
+
+		keys = append(keys, &key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"sort"
 	"time"
 
 	"notification-service/internal/domain/entity"
@@ -397,3 +398,116 @@ func (r *DeliveryRepository) GetFailedByTimeRange(ctx context.Context, start, en
 
 	return deliveries, nil
 }
+
+// ClaimPendingForDevice marca como enviadas las entregas pendientes más
+// antiguas de un dispositivo y canal, y las devuelve. Las filas bloqueadas por
+// otra petición se omiten, de modo que dos sondeos concurrentes no reciben las
+// mismas entregas.
+func (r *DeliveryRepository) ClaimPendingForDevice(ctx context.Context, deviceID uuid.UUID, channel entity.TokenType, limit int, sentAt time.Time) ([]*entity.DeliveryTracking, error) {
+	query := `
+		UPDATE notification_service.delivery_tracking
+		SET status = $4, sent_at = $5, updated_at = $5
+		WHERE id IN (
+			SELECT id
+			FROM notification_service.delivery_tracking
+			WHERE device_id = $1 AND channel = $2 AND status = $6
+			ORDER BY created_at ASC
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, notification_id, device_id, channel, status, sent_at, delivered_at, failed_at,
+		          retry_count, error_message, created_at, updated_at
+	`
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		deviceID,
+		string(channel),
+		limit,
+		string(entity.DeliveryStatusSent),
+		sentAt,
+		string(entity.DeliveryStatusPending),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*entity.DeliveryTracking
+
+	for rows.Next() {
+		var delivery entity.DeliveryTracking
+		var sentAt, deliveredAt, failedAt sql.NullTime
+		var errorMessage sql.NullString
+
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.NotificationID,
+			&delivery.DeviceID,
+			&delivery.Channel,
+			&delivery.Status,
+			&sentAt,
+			&deliveredAt,
+			&failedAt,
+			&delivery.RetryCount,
+			&errorMessage,
+			&delivery.CreatedAt,
+			&delivery.UpdatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if sentAt.Valid {
+			delivery.SentAt = &sentAt.Time
+		}
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = &deliveredAt.Time
+		}
+		if failedAt.Valid {
+			delivery.FailedAt = &failedAt.Time
+		}
+		delivery.ErrorMessage = errorMessage.String
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING no garantiza el orden de la subconsulta
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+	})
+
+	return deliveries, nil
+}
+
+// MarkDeliveredUpTo marca como entregadas las entregas de un dispositivo y
+// canal que se enviaron hasta sentAt (inclusive)
+func (r *DeliveryRepository) MarkDeliveredUpTo(ctx context.Context, deviceID uuid.UUID, channel entity.TokenType, sentAt time.Time) (int64, error) {
+	query := `
+		UPDATE notification_service.delivery_tracking
+		SET status = $4, delivered_at = $5, updated_at = $5
+		WHERE device_id = $1 AND channel = $2 AND status = $6 AND sent_at <= $3
+	`
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		deviceID,
+		string(channel),
+		sentAt,
+		string(entity.DeliveryStatusDelivered),
+		time.Now(),
+		string(entity.DeliveryStatusSent),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -25,19 +25,21 @@ func NewDeviceRepository(db *sql.DB) repository.DeviceRepository {
 // GetByID obtiene un dispositivo por su ID
 func (r *DeviceRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Device, error) {
 	query := `
-        SELECT id, user_id, model, device_identifier, verified, last_access, created_at, updated_at 
+        SELECT id, tenant_id, user_id, model, locale, device_identifier, verified, last_access, created_at, updated_at 
         FROM notification_service.devices 
         WHERE id = $1
     `
 
 	var device entity.Device
 	var userID sql.NullInt64
-	var model sql.NullString
+	var model, locale sql.NullString
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&device.ID,
+		&device.TenantID,
 		&userID,
 		&model,
+		&locale,
 		&device.DeviceIdentifier,
 		&device.Verified,
 		&device.LastAccess,
@@ -61,25 +63,31 @@ func (r *DeviceRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.D
 		device.Model = &model.String
 	}
 
+	if locale.Valid {
+		device.Locale = &locale.String
+	}
+
 	return &device, nil
 }
 
-// GetByDeviceIdentifier obtiene un dispositivo por su identificador
-func (r *DeviceRepository) GetByDeviceIdentifier(ctx context.Context, identifier string) (*entity.Device, error) {
+// GetByDeviceIdentifier obtiene un dispositivo de un tenant por su identificador
+func (r *DeviceRepository) GetByDeviceIdentifier(ctx context.Context, tenantID, identifier string) (*entity.Device, error) {
 	query := `
-        SELECT id, user_id, model, device_identifier, verified, last_access, created_at, updated_at 
+        SELECT id, tenant_id, user_id, model, locale, device_identifier, verified, last_access, created_at, updated_at 
         FROM notification_service.devices 
-        WHERE device_identifier = $1
+        WHERE tenant_id = $1 AND device_identifier = $2
     `
 
 	var device entity.Device
 	var userID sql.NullInt64
-	var model sql.NullString
+	var model, locale sql.NullString
 
-	err := r.db.QueryRowContext(ctx, query, identifier).Scan(
+	err := r.db.QueryRowContext(ctx, query, tenantID, identifier).Scan(
 		&device.ID,
+		&device.TenantID,
 		&userID,
 		&model,
+		&locale,
 		&device.DeviceIdentifier,
 		&device.Verified,
 		&device.LastAccess,
@@ -103,18 +111,22 @@ func (r *DeviceRepository) GetByDeviceIdentifier(ctx context.Context, identifier
 		device.Model = &model.String
 	}
 
+	if locale.Valid {
+		device.Locale = &locale.String
+	}
+
 	return &device, nil
 }
 
-// GetByUserID obtiene todos los dispositivos de un usuario
-func (r *DeviceRepository) GetByUserID(ctx context.Context, userID uint) ([]*entity.Device, error) {
+// GetByUserID obtiene todos los dispositivos de un usuario de un tenant
+func (r *DeviceRepository) GetByUserID(ctx context.Context, tenantID string, userID uint) ([]*entity.Device, error) {
 	query := `
-        SELECT id, user_id, model, device_identifier, verified, last_access, created_at, updated_at 
+        SELECT id, tenant_id, user_id, model, locale, device_identifier, verified, last_access, created_at, updated_at 
         FROM notification_service.devices 
-        WHERE user_id = $1
+        WHERE tenant_id = $1 AND user_id = $2
     `
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	rows, err := r.db.QueryContext(ctx, query, tenantID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -125,12 +137,14 @@ func (r *DeviceRepository) GetByUserID(ctx context.Context, userID uint) ([]*ent
 	for rows.Next() {
 		var device entity.Device
 		var dbUserID sql.NullInt64
-		var model sql.NullString
+		var model, locale sql.NullString
 
 		err := rows.Scan(
 			&device.ID,
+			&device.TenantID,
 			&dbUserID,
 			&model,
+			&locale,
 			&device.DeviceIdentifier,
 			&device.Verified,
 			&device.LastAccess,
@@ -151,6 +165,10 @@ func (r *DeviceRepository) GetByUserID(ctx context.Context, userID uint) ([]*ent
 			device.Model = &model.String
 		}
 
+		if locale.Valid {
+			device.Locale = &locale.String
+		}
+
 		devices = append(devices, &device)
 	}
 
@@ -164,8 +182,8 @@ func (r *DeviceRepository) GetByUserID(ctx context.Context, userID uint) ([]*ent
 // Save guarda un nuevo dispositivo
 func (r *DeviceRepository) Save(ctx context.Context, device *entity.Device) error {
 	query := `
-        INSERT INTO notification_service.devices (id, user_id, model, device_identifier, verified, last_access, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        INSERT INTO notification_service.devices (id, tenant_id, user_id, model, locale, device_identifier, verified, last_access, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
     `
 
 	var userID sql.NullInt64
@@ -180,12 +198,20 @@ func (r *DeviceRepository) Save(ctx context.Context, device *entity.Device) erro
 		model.Valid = true
 	}
 
+	var locale sql.NullString
+	if device.Locale != nil {
+		locale.String = *device.Locale
+		locale.Valid = true
+	}
+
 	_, err := r.db.ExecContext(
 		ctx,
 		query,
 		device.ID,
+		device.TenantID,
 		userID,
 		model,
+		locale,
 		device.DeviceIdentifier,
 		device.Verified,
 		device.LastAccess,
@@ -200,7 +226,7 @@ func (r *DeviceRepository) Save(ctx context.Context, device *entity.Device) erro
 func (r *DeviceRepository) Update(ctx context.Context, device *entity.Device) error {
 	query := `
         UPDATE notification_service.devices 
-        SET user_id = $2, model = $3, device_identifier = $4, verified = $5, last_access = $6, updated_at = $7
+        SET user_id = $2, model = $3, locale = $4, device_identifier = $5, verified = $6, last_access = $7, updated_at = $8
         WHERE id = $1
     `
 
@@ -216,12 +242,19 @@ func (r *DeviceRepository) Update(ctx context.Context, device *entity.Device) er
 		model.Valid = true
 	}
 
+	var locale sql.NullString
+	if device.Locale != nil {
+		locale.String = *device.Locale
+		locale.Valid = true
+	}
+
 	_, err := r.db.ExecContext(
 		ctx,
 		query,
 		device.ID,
 		userID,
 		model,
+		locale,
 		device.DeviceIdentifier,
 		device.Verified,
 		device.LastAccess,
@@ -270,7 +303,7 @@ func (r *DeviceRepository) MarkAsVerified(ctx context.Context, deviceID uuid.UUI
 // GetInactiveDevices obtiene los dispositivos inactivos
 func (r *DeviceRepository) GetInactiveDevices(ctx context.Context, threshold time.Time) ([]*entity.Device, error) {
 	query := `
-        SELECT id, user_id, model, device_identifier, verified, last_access, created_at, updated_at 
+        SELECT id, tenant_id, user_id, model, locale, device_identifier, verified, last_access, created_at, updated_at 
         FROM notification_service.devices 
         WHERE last_access < $1
     `
@@ -286,12 +319,14 @@ func (r *DeviceRepository) GetInactiveDevices(ctx context.Context, threshold tim
 	for rows.Next() {
 		var device entity.Device
 		var userID sql.NullInt64
-		var model sql.NullString
+		var model, locale sql.NullString
 
 		err := rows.Scan(
 			&device.ID,
+			&device.TenantID,
 			&userID,
 			&model,
+			&locale,
 			&device.DeviceIdentifier,
 			&device.Verified,
 			&device.LastAccess,
@@ -312,6 +347,10 @@ func (r *DeviceRepository) GetInactiveDevices(ctx context.Context, threshold tim
 			device.Model = &model.String
 		}
 
+		if locale.Valid {
+			device.Locale = &locale.String
+		}
+
 		devices = append(devices, &device)
 	}
 
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+
+	"github.com/google/uuid"
+)
+
+// MessageQueueRepository implementa repository.MessageQueueRepository
+type MessageQueueRepository struct {
+	db *sql.DB
+}
+
+// NewMessageQueueRepository crea una instancia de MessageQueueRepository
+func NewMessageQueueRepository(db *sql.DB) repository.MessageQueueRepository {
+	return &MessageQueueRepository{db: db}
+}
+
+// Enqueue guarda un mensaje en la cola persistente
+func (r *MessageQueueRepository) Enqueue(ctx context.Context, message *entity.QueuedMessage) error {
+	query := `
+		INSERT INTO notification_service.message_queue
+		(id, notification_id, device_id, payload, status, created_at, next_attempt_at, retry_count, max_retries)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		message.ID,
+		message.NotificationID,
+		message.DeviceID,
+		[]byte(message.Payload),
+		string(message.Status),
+		message.CreatedAt,
+		message.NextAttemptAt,
+		message.RetryCount,
+		message.MaxRetries,
+	)
+
+	return err
+}
+
+// DequeueForDevice elimina y devuelve los mensajes pendientes más antiguos de
+// un dispositivo. Las filas bloqueadas por otra instancia se omiten, de modo
+// que un mensaje solo se entrega una vez.
+func (r *MessageQueueRepository) DequeueForDevice(ctx context.Context, deviceID uuid.UUID, limit int) ([]*entity.QueuedMessage, error) {
+	query := `
+		DELETE FROM notification_service.message_queue
+		WHERE id IN (
+			SELECT id
+			FROM notification_service.message_queue
+			WHERE device_id = $1 AND status = $2 AND next_attempt_at <= NOW()
+			ORDER BY created_at ASC
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, notification_id, device_id, payload, status, created_at, next_attempt_at,
+		          retry_count, max_retries
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, deviceID, string(entity.QueuedMessageStatusPending), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*entity.QueuedMessage
+
+	for rows.Next() {
+		var message entity.QueuedMessage
+		var payload []byte
+
+		err := rows.Scan(
+			&message.ID,
+			&message.NotificationID,
+			&message.DeviceID,
+			&payload,
+			&message.Status,
+			&message.CreatedAt,
+			&message.NextAttemptAt,
+			&message.RetryCount,
+			&message.MaxRetries,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		message.Payload = payload
+		messages = append(messages, &message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING no garantiza el orden de la subconsulta
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+
+	return messages, nil
+}
@@ -26,14 +26,25 @@ func NewNotificationRepository(db *sql.DB) repository.NotificationRepository {
 func (r *NotificationRepository) Save(ctx context.Context, notification *entity.Notification) error {
 	query := `
 		INSERT INTO notification_service.notifications 
-		(id, user_id, title, message, data, notification_type, sender_id, priority, created_at, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		(id, tenant_id, user_id, title, message, data, notification_type, sender_id, priority, created_at, expires_at,
+		 template_id, template_version, locale)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`
 
+	// Las notificaciones sin plantilla la guardan como NULL
+	var templateID, locale sql.NullString
+	var templateVersion sql.NullInt64
+	if notification.TemplateID != "" {
+		templateID = sql.NullString{String: notification.TemplateID, Valid: true}
+		templateVersion = sql.NullInt64{Int64: int64(notification.TemplateVersion), Valid: true}
+		locale = sql.NullString{String: notification.Locale, Valid: true}
+	}
+
 	_, err := r.db.ExecContext(
 		ctx,
 		query,
 		notification.ID,
+		notification.TenantID,
 		notification.UserID,
 		notification.Title,
 		notification.Message,
@@ -43,6 +54,9 @@ func (r *NotificationRepository) Save(ctx context.Context, notification *entity.
 		notification.Priority,
 		notification.CreatedAt,
 		notification.ExpiresAt,
+		templateID,
+		templateVersion,
+		locale,
 	)
 
 	return err
@@ -51,7 +65,8 @@ func (r *NotificationRepository) Save(ctx context.Context, notification *entity.
 // GetByID obtiene una notificación por su ID
 func (r *NotificationRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Notification, error) {
 	query := `
-		SELECT id, user_id, title, message, data, notification_type, sender_id, priority, created_at, expires_at
+		SELECT id, tenant_id, user_id, title, message, data, notification_type, sender_id, priority, created_at, expires_at,
+		       template_id, template_version, locale
 		FROM notification_service.notifications
 		WHERE id = $1
 	`
@@ -59,9 +74,12 @@ func (r *NotificationRepository) GetByID(ctx context.Context, id uuid.UUID) (*en
 	var notification entity.Notification
 	var expiresAt sql.NullTime
 	var data []byte
+	var templateID, locale sql.NullString
+	var templateVersion sql.NullInt64
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&notification.ID,
+		&notification.TenantID,
 		&notification.UserID,
 		&notification.Title,
 		&notification.Message,
@@ -71,6 +89,9 @@ func (r *NotificationRepository) GetByID(ctx context.Context, id uuid.UUID) (*en
 		&notification.Priority,
 		&notification.CreatedAt,
 		&expiresAt,
+		&templateID,
+		&templateVersion,
+		&locale,
 	)
 
 	if err != nil {
@@ -86,20 +107,23 @@ func (r *NotificationRepository) GetByID(ctx context.Context, id uuid.UUID) (*en
 		notification.ExpiresAt = &expiresAt.Time
 	}
 
+	notification.SetTemplate(templateID.String, int(templateVersion.Int64), locale.String)
+
 	return &notification, nil
 }
 
-// GetByUserID obtiene notificaciones por usuario
-func (r *NotificationRepository) GetByUserID(ctx context.Context, userID string, limit, offset int) ([]*entity.Notification, error) {
+// GetByUserID obtiene notificaciones por usuario de un tenant
+func (r *NotificationRepository) GetByUserID(ctx context.Context, tenantID, userID string, limit, offset int) ([]*entity.Notification, error) {
 	query := `
-		SELECT id, user_id, title, message, data, notification_type, sender_id, priority, created_at, expires_at
+		SELECT id, tenant_id, user_id, title, message, data, notification_type, sender_id, priority, created_at, expires_at,
+		       template_id, template_version, locale
 		FROM notification_service.notifications
-		WHERE user_id = $1
+		WHERE tenant_id = $1 AND user_id = $2
 		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
+		LIMIT $3 OFFSET $4
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, tenantID, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -111,9 +135,12 @@ func (r *NotificationRepository) GetByUserID(ctx context.Context, userID string,
 		var notification entity.Notification
 		var expiresAt sql.NullTime
 		var data []byte
+		var templateID, locale sql.NullString
+		var templateVersion sql.NullInt64
 
 		err := rows.Scan(
 			&notification.ID,
+			&notification.TenantID,
 			&notification.UserID,
 			&notification.Title,
 			&notification.Message,
@@ -123,6 +150,9 @@ func (r *NotificationRepository) GetByUserID(ctx context.Context, userID string,
 			&notification.Priority,
 			&notification.CreatedAt,
 			&expiresAt,
+			&templateID,
+			&templateVersion,
+			&locale,
 		)
 
 		if err != nil {
@@ -135,6 +165,8 @@ func (r *NotificationRepository) GetByUserID(ctx context.Context, userID string,
 			notification.ExpiresAt = &expiresAt.Time
 		}
 
+		notification.SetTemplate(templateID.String, int(templateVersion.Int64), locale.String)
+
 		notifications = append(notifications, &notification)
 	}
 
@@ -196,7 +228,8 @@ func (r *NotificationRepository) MarkAsExpired(ctx context.Context, id uuid.UUID
 // GetExpiredNotifications obtiene notificaciones expiradas
 func (r *NotificationRepository) GetExpiredNotifications(ctx context.Context) ([]*entity.Notification, error) {
 	query := `
-		SELECT id, user_id, title, message, data, notification_type, sender_id, priority, created_at, expires_at
+		SELECT id, tenant_id, user_id, title, message, data, notification_type, sender_id, priority, created_at, expires_at,
+		       template_id, template_version, locale
 		FROM notification_service.notifications
 		WHERE expires_at IS NOT NULL AND expires_at < $1
 	`
@@ -213,9 +246,12 @@ func (r *NotificationRepository) GetExpiredNotifications(ctx context.Context) ([
 		var notification entity.Notification
 		var expiresAt sql.NullTime
 		var data []byte
+		var templateID, locale sql.NullString
+		var templateVersion sql.NullInt64
 
 		err := rows.Scan(
 			&notification.ID,
+			&notification.TenantID,
 			&notification.UserID,
 			&notification.Title,
 			&notification.Message,
@@ -225,6 +261,9 @@ func (r *NotificationRepository) GetExpiredNotifications(ctx context.Context) ([
 			&notification.Priority,
 			&notification.CreatedAt,
 			&expiresAt,
+			&templateID,
+			&templateVersion,
+			&locale,
 		)
 
 		if err != nil {
@@ -237,6 +276,8 @@ func (r *NotificationRepository) GetExpiredNotifications(ctx context.Context) ([
 			notification.ExpiresAt = &expiresAt.Time
 		}
 
+		notification.SetTemplate(templateID.String, int(templateVersion.Int64), locale.String)
+
 		notifications = append(notifications, &notification)
 	}
 
@@ -247,17 +288,17 @@ func (r *NotificationRepository) GetExpiredNotifications(ctx context.Context) ([
 	return notifications, nil
 }
 
-// CountUnreadByUser cuenta notificaciones no leídas por usuario
-func (r *NotificationRepository) CountUnreadByUser(ctx context.Context, userID string) (int, error) {
+// CountUnreadByUser cuenta notificaciones no leídas por usuario de un tenant
+func (r *NotificationRepository) CountUnreadByUser(ctx context.Context, tenantID, userID string) (int, error) {
 	query := `
 		SELECT COUNT(*)
 		FROM notification_service.notifications n
 		LEFT JOIN notification_service.delivery_tracking d ON n.id = d.notification_id
-		WHERE n.user_id = $1 AND d.status != 'delivered'
+		WHERE n.tenant_id = $1 AND n.user_id = $2 AND d.status != 'delivered'
 	`
 
 	var count int
-	err := r.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query, tenantID, userID).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
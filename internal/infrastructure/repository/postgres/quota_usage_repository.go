@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+
+	"github.com/lib/pq"
+)
+
+// Columnas del uso de una cuota, en el orden que espera query
+const quotaUsageColumns = `day, level, tenant_id, subject, count, throttled`
+
+// QuotaUsageRepository implementa repository.QuotaUsageRepository
+type QuotaUsageRepository struct {
+	db *sql.DB
+}
+
+// NewQuotaUsageRepository crea una instancia de QuotaUsageRepository
+func NewQuotaUsageRepository(db *sql.DB) repository.QuotaUsageRepository {
+	return &QuotaUsageRepository{db: db}
+}
+
+// Add suma los envíos de usage al uso guardado y devuelve el total. Varias
+// instancias suman a la vez sin perder envíos.
+func (r *QuotaUsageRepository) Add(ctx context.Context, usage *entity.QuotaUsage) (*entity.QuotaUsage, error) {
+	query := `
+		INSERT INTO notification_service.quota_usage
+		(` + quotaUsageColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (day, level, tenant_id, subject) DO UPDATE
+		SET count = quota_usage.count + EXCLUDED.count,
+		    throttled = quota_usage.throttled + EXCLUDED.throttled,
+		    updated_at = NOW()
+		RETURNING ` + quotaUsageColumns
+
+	usages, err := r.query(
+		ctx,
+		query,
+		usage.Day,
+		usage.Level,
+		usage.TenantID,
+		usage.Subject,
+		usage.Count,
+		usage.Throttled,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return usages[0], nil
+}
+
+// List obtiene el uso de los niveles indicados entre dos días, ambos
+// incluidos, de un tenant o de todos si tenantID está vacío
+func (r *QuotaUsageRepository) List(
+	ctx context.Context,
+	tenantID string,
+	levels []entity.QuotaLevel,
+	from, to time.Time,
+) ([]*entity.QuotaUsage, error) {
+	query := `
+		SELECT ` + quotaUsageColumns + `
+		FROM notification_service.quota_usage
+		WHERE ($1 = '' OR tenant_id = $1) AND level = ANY($2) AND day BETWEEN $3 AND $4
+		ORDER BY day, tenant_id, level, subject
+	`
+
+	names := make([]string, 0, len(levels))
+	for _, level := range levels {
+		names = append(names, string(level))
+	}
+
+	return r.query(ctx, query, tenantID, pq.Array(names), from, to)
+}
+
+// query ejecuta una consulta que devuelve usos de cuotas
+func (r *QuotaUsageRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entity.QuotaUsage, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usages []*entity.QuotaUsage
+
+	for rows.Next() {
+		var usage entity.QuotaUsage
+
+		err := rows.Scan(
+			&usage.Day,
+			&usage.Level,
+			&usage.TenantID,
+			&usage.Subject,
+			&usage.Count,
+			&usage.Throttled,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		usages = append(usages, &usage)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return usages, nil
+}
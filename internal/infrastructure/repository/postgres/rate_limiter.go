@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"notification-service/pkg/logging"
+	"notification-service/pkg/throttling"
+)
+
+// RateLimiter implementa throttling.RateLimiter con GCRA sobre una tabla
+// compartida por todas las instancias. Cada clave guarda el instante teórico
+// de llegada (TAT) del siguiente evento: un evento se admite si, tras
+// sumarle el intervalo entre eventos, el TAT no queda más allá de la ráfaga
+// permitida. La comprobación y la actualización son un único upsert, así que
+// el límite se mantiene con peticiones simultáneas de varias instancias. Los
+// instantes son los del reloj de la base de datos.
+type RateLimiter struct {
+	db     *sql.DB
+	logger *logging.Logger
+}
+
+// NewRateLimiter crea una instancia de RateLimiter
+func NewRateLimiter(db *sql.DB, logger *logging.Logger) *RateLimiter {
+	return &RateLimiter{db: db, logger: logger}
+}
+
+// Reserve consume un evento de la clave si su límite lo permite
+func (l *RateLimiter) Reserve(ctx context.Context, key string, limit throttling.Limit) (*throttling.Reservation, error) {
+	// Intervalo entre eventos y ráfaga, en segundos
+	interval := 1 / limit.Rate
+	tolerance := interval * float64(max(limit.Burst, 1))
+
+	query := `
+		INSERT INTO notification_service.rate_limits AS r (key, tat)
+		VALUES ($1, NOW() + make_interval(secs => $2))
+		ON CONFLICT (key) DO UPDATE
+		SET tat = GREATEST(r.tat, NOW()) + make_interval(secs => $2)
+		WHERE GREATEST(r.tat, NOW()) + make_interval(secs => $2) <= NOW() + make_interval(secs => $3)
+		RETURNING tat
+	`
+
+	var tat time.Time
+	err := l.db.QueryRowContext(ctx, query, key, interval, tolerance).Scan(&tat)
+	if err == nil {
+		return throttling.NewReservation(func(ctx context.Context) error {
+			return l.release(ctx, key, interval)
+		}), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// Rechazado: el evento cabe cuando el TAT vuelva a estar dentro de la
+	// ráfaga
+	var ahead float64
+	query = `SELECT EXTRACT(EPOCH FROM tat - NOW()) FROM notification_service.rate_limits WHERE key = $1`
+	if err := l.db.QueryRowContext(ctx, query, key).Scan(&ahead); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	retryAfter := time.Duration((ahead + interval - tolerance) * float64(time.Second))
+
+	return throttling.NewRejection(max(retryAfter, 0)), nil
+}
+
+// DeleteExpired borra las claves cuyo TAT ya ha pasado, que no limitan nada
+func (l *RateLimiter) DeleteExpired(ctx context.Context) error {
+	_, err := l.db.ExecContext(ctx, `DELETE FROM notification_service.rate_limits WHERE tat < NOW()`)
+	return err
+}
+
+// Run borra periódicamente las claves caducadas hasta que se cancele el
+// contexto
+func (l *RateLimiter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.DeleteExpired(ctx); err != nil {
+				l.logger.Error("Error deleting expired rate limits: %v", err)
+			}
+		}
+	}
+}
+
+// release devuelve un evento consumido restando su intervalo al TAT de la
+// clave
+func (l *RateLimiter) release(ctx context.Context, key string, interval float64) error {
+	query := `
+		UPDATE notification_service.rate_limits
+		SET tat = tat - make_interval(secs => $2)
+		WHERE key = $1
+	`
+
+	_, err := l.db.ExecContext(ctx, query, key, interval)
+	return err
+}
@@ -0,0 +1,355 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"notification-service/pkg/logging"
+	"notification-service/pkg/throttling"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// gcraStore es una tabla rate_limits en memoria con su propio reloj. Un
+// mutex hace de bloqueo de fila: cada sentencia se ejecuta de forma atómica,
+// como el upsert en Postgres.
+type gcraStore struct {
+	mu  sync.Mutex
+	now time.Time
+	tat map[string]time.Time
+}
+
+func newGCRAStore() *gcraStore {
+	return &gcraStore{now: time.Unix(1700000000, 0), tat: make(map[string]time.Time)}
+}
+
+// advance adelanta el reloj de la base de datos
+func (s *gcraStore) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+// seconds convierte un argumento make_interval(secs => ...) en una duración
+func seconds(value driver.Value) time.Duration {
+	return time.Duration(value.(float64) * float64(time.Second))
+}
+
+// query ejecuta las consultas de RateLimiter.Reserve
+func (s *gcraStore) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := args[0].Value.(string)
+	switch {
+	case strings.Contains(query, "INSERT INTO notification_service.rate_limits"):
+		interval, tolerance := seconds(args[1].Value), seconds(args[2].Value)
+		tat, ok := s.tat[key]
+		if !ok {
+			s.tat[key] = s.now.Add(interval)
+			return &gcraRows{values: []driver.Value{s.tat[key]}}, nil
+		}
+		next := maxTime(tat, s.now).Add(interval)
+		if next.After(s.now.Add(tolerance)) {
+			return &gcraRows{}, nil
+		}
+		s.tat[key] = next
+		return &gcraRows{values: []driver.Value{next}}, nil
+
+	case strings.Contains(query, "SELECT EXTRACT(EPOCH FROM tat - NOW())"):
+		tat, ok := s.tat[key]
+		if !ok {
+			return &gcraRows{}, nil
+		}
+		return &gcraRows{values: []driver.Value{tat.Sub(s.now).Seconds()}}, nil
+	}
+	return nil, fmt.Errorf("unexpected query: %s", query)
+}
+
+// exec ejecuta las sentencias de release y DeleteExpired
+func (s *gcraStore) exec(query string, args []driver.NamedValue) (driver.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case strings.Contains(query, "UPDATE notification_service.rate_limits"):
+		key := args[0].Value.(string)
+		if tat, ok := s.tat[key]; ok {
+			s.tat[key] = tat.Add(-seconds(args[1].Value))
+		}
+		return driver.RowsAffected(1), nil
+
+	case strings.Contains(query, "DELETE FROM notification_service.rate_limits"):
+		var deleted int64
+		for key, tat := range s.tat {
+			if tat.Before(s.now) {
+				delete(s.tat, key)
+				deleted++
+			}
+		}
+		return driver.RowsAffected(deleted), nil
+	}
+	return nil, fmt.Errorf("unexpected statement: %s", query)
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// gcraConn es una conexión de database/sql a un gcraStore
+type gcraConn struct {
+	store *gcraStore
+}
+
+func (c *gcraConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepared statements not supported")
+}
+func (c *gcraConn) Close() error              { return nil }
+func (c *gcraConn) Begin() (driver.Tx, error) { return nil, fmt.Errorf("transactions not supported") }
+
+func (c *gcraConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.store.query(query, args)
+}
+
+func (c *gcraConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.store.exec(query, args)
+}
+
+// gcraRows devuelve una fila de una columna, o ninguna si values es nil
+type gcraRows struct {
+	values []driver.Value
+	read   bool
+}
+
+func (r *gcraRows) Columns() []string { return []string{"value"} }
+func (r *gcraRows) Close() error      { return nil }
+
+func (r *gcraRows) Next(dest []driver.Value) error {
+	if r.values == nil || r.read {
+		return io.EOF
+	}
+	r.read = true
+	copy(dest, r.values)
+	return nil
+}
+
+// gcraConnector abre conexiones a un gcraStore
+type gcraConnector struct {
+	store *gcraStore
+}
+
+func (c gcraConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &gcraConn{store: c.store}, nil
+}
+func (c gcraConnector) Driver() driver.Driver { return gcraDriver{} }
+
+type gcraDriver struct{}
+
+func (gcraDriver) Open(name string) (driver.Conn, error) {
+	return nil, fmt.Errorf("use gcraConnector")
+}
+
+// rateLimiterStore es la base de datos contra la que se prueba el
+// RateLimiter, con una forma de dejar pasar el tiempo en ella
+type rateLimiterStore struct {
+	db      *sql.DB
+	advance func(time.Duration)
+}
+
+// testRateLimiterStores devuelve la tabla en memoria y, si se indica
+// TEST_DATABASE_URL (una base de datos con las migraciones aplicadas),
+// también Postgres
+func testRateLimiterStores(t *testing.T) map[string]rateLimiterStore {
+	t.Helper()
+
+	store := newGCRAStore()
+	stores := map[string]rateLimiterStore{
+		"memory": {db: sql.OpenDB(gcraConnector{store: store}), advance: store.advance},
+	}
+
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		db, err := sql.Open("postgres", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		stores["postgres"] = rateLimiterStore{db: db, advance: time.Sleep}
+	}
+
+	return stores
+}
+
+// testKey devuelve una clave que no comparte ninguna otra prueba
+func testKey(name string) string {
+	return "test:" + name + ":" + uuid.NewString()
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	for name, store := range testRateLimiterStores(t) {
+		t.Run(name, func(t *testing.T) {
+			limiter := NewRateLimiter(store.db, logging.NewLogger(logging.WithOutput(io.Discard)))
+			key := testKey("burst")
+			// Un evento cada 1000 s: durante la prueba no se recupera nada
+			limit := throttling.Limit{Rate: 0.001, Burst: 3}
+
+			for i := 0; i < limit.Burst; i++ {
+				reservation, err := limiter.Reserve(context.Background(), key, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reservation.OK {
+					t.Fatalf("event %d rejected within the burst", i)
+				}
+			}
+
+			reservation, err := limiter.Reserve(context.Background(), key, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reservation.OK {
+				t.Fatal("event beyond the burst admitted")
+			}
+			if reservation.RetryAfter < 999*time.Second || reservation.RetryAfter > 1000*time.Second {
+				t.Errorf("retry after = %v, want about one interval (1000s)", reservation.RetryAfter)
+			}
+
+			// Otra clave tiene su propio límite
+			reservation, err = limiter.Reserve(context.Background(), testKey("other"), limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reservation.OK {
+				t.Error("event of another key rejected")
+			}
+		})
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	for name, store := range testRateLimiterStores(t) {
+		t.Run(name, func(t *testing.T) {
+			limiter := NewRateLimiter(store.db, logging.NewLogger(logging.WithOutput(io.Discard)))
+			key := testKey("refill")
+			limit := throttling.Limit{Rate: 10, Burst: 2}
+
+			reserve := func() *throttling.Reservation {
+				t.Helper()
+				reservation, err := limiter.Reserve(context.Background(), key, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return reservation
+			}
+
+			reserve()
+			reserve()
+			rejected := reserve()
+			if rejected.OK {
+				t.Fatal("event beyond the burst admitted")
+			}
+			if rejected.RetryAfter <= 0 || rejected.RetryAfter > 100*time.Millisecond {
+				t.Fatalf("retry after = %v, want at most one interval (100ms)", rejected.RetryAfter)
+			}
+
+			// Pasado RetryAfter cabe exactamente un evento más
+			store.advance(rejected.RetryAfter + time.Millisecond)
+			if !reserve().OK {
+				t.Fatal("event rejected after retry after")
+			}
+			if reserve().OK {
+				t.Fatal("second event admitted after one interval")
+			}
+
+			// Un evento devuelto deja sitio para otro
+			store.advance(100 * time.Millisecond)
+			admitted := reserve()
+			if !admitted.OK {
+				t.Fatal("event rejected after one interval")
+			}
+			if err := admitted.Cancel(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if !reserve().OK {
+				t.Error("event rejected after cancelling a reservation")
+			}
+		})
+	}
+}
+
+func TestRateLimiterConcurrentAdmission(t *testing.T) {
+	for name, store := range testRateLimiterStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// Varias instancias comparten la tabla
+			limiters := []*RateLimiter{
+				NewRateLimiter(store.db, logging.NewLogger(logging.WithOutput(io.Discard))),
+				NewRateLimiter(store.db, logging.NewLogger(logging.WithOutput(io.Discard))),
+			}
+			key := testKey("concurrent")
+			limit := throttling.Limit{Rate: 0.001, Burst: 10}
+
+			var admitted atomic.Int32
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(limiter *RateLimiter) {
+					defer wg.Done()
+					for j := 0; j < 10; j++ {
+						reservation, err := limiter.Reserve(context.Background(), key, limit)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						if reservation.OK {
+							admitted.Add(1)
+						}
+					}
+				}(limiters[i%len(limiters)])
+			}
+			wg.Wait()
+
+			if got := admitted.Load(); got != int32(limit.Burst) {
+				t.Errorf("admitted = %d, want %d", got, limit.Burst)
+			}
+		})
+	}
+}
+
+func TestRateLimiterDeleteExpired(t *testing.T) {
+	store := newGCRAStore()
+	limiter := NewRateLimiter(sql.OpenDB(gcraConnector{store: store}), logging.NewLogger(logging.WithOutput(io.Discard)))
+	limit := throttling.Limit{Rate: 1, Burst: 1}
+
+	if _, err := limiter.Reserve(context.Background(), "short", limit); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limiter.Reserve(context.Background(), "long", throttling.Limit{Rate: 0.01, Burst: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	store.advance(2 * time.Second)
+	if err := limiter.DeleteExpired(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.tat["short"]; ok {
+		t.Error("expired key not deleted")
+	}
+	if _, ok := store.tat["long"]; !ok {
+		t.Error("key still limiting was deleted")
+	}
+}
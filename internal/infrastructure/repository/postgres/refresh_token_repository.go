@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+
+	"github.com/google/uuid"
+)
+
+// RefreshTokenRepository implementa repository.RefreshTokenRepository
+type RefreshTokenRepository struct {
+	db *sql.DB
+}
+
+// NewRefreshTokenRepository crea una instancia de RefreshTokenRepository
+func NewRefreshTokenRepository(db *sql.DB) repository.RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+// Create guarda un token nuevo
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *entity.RefreshToken) error {
+	query := `
+		INSERT INTO notification_service.refresh_tokens
+		(id, family_id, device_id, user_id, token_hash, created_at, expires_at, used_at, revoked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	// Los dispositivos sin usuario se guardan con user_id NULL
+	userID := sql.NullString{String: token.UserID, Valid: token.UserID != ""}
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		token.ID,
+		token.FamilyID,
+		token.DeviceID,
+		userID,
+		token.TokenHash,
+		token.CreatedAt,
+		token.ExpiresAt,
+		token.UsedAt,
+		token.RevokedAt,
+	)
+
+	return err
+}
+
+// GetByHash obtiene un token por el hash de su valor
+func (r *RefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	query := `
+		SELECT id, family_id, device_id, user_id, token_hash, created_at, expires_at, used_at, revoked_at
+		FROM notification_service.refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var token entity.RefreshToken
+	var userID sql.NullString
+	var usedAt, revokedAt sql.NullTime
+
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.FamilyID,
+		&token.DeviceID,
+		&userID,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&usedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	token.UserID = userID.String
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return &token, nil
+}
+
+// MarkUsed marca un token como usado. La condición sobre used_at hace que,
+// si dos peticiones usan el mismo token a la vez, solo una lo consiga.
+func (r *RefreshTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE notification_service.refresh_tokens
+		SET used_at = NOW()
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// RevokeFamily revoca todos los tokens de una familia
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `
+		UPDATE notification_service.refresh_tokens
+		SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, familyID)
+	return err
+}
+
+// RevokeByDevice revoca todos los tokens de un dispositivo
+func (r *RefreshTokenRepository) RevokeByDevice(ctx context.Context, deviceID uuid.UUID) error {
+	query := `
+		UPDATE notification_service.refresh_tokens
+		SET revoked_at = NOW()
+		WHERE device_id = $1 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, deviceID)
+	return err
+}
+
+// DeleteExpired elimina los tokens expirados
+func (r *RefreshTokenRepository) DeleteExpired(ctx context.Context) error {
+	query := `DELETE FROM notification_service.refresh_tokens WHERE expires_at <= NOW()`
+
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+
+	"github.com/google/uuid"
+)
+
+// Columnas de una sesión, en el orden que espera scanSessions
+const sessionColumns = `id, tenant_id, user_id, device_id, device_identifier, created_at, expires_at, revoked_at`
+
+// SessionRepository implementa repository.SessionRepository
+type SessionRepository struct {
+	db *sql.DB
+}
+
+// NewSessionRepository crea una instancia de SessionRepository
+func NewSessionRepository(db *sql.DB) repository.SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create guarda una sesión nueva
+func (r *SessionRepository) Create(ctx context.Context, session *entity.Session) error {
+	query := `
+		INSERT INTO notification_service.sessions
+		(` + sessionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		session.ID,
+		session.TenantID,
+		session.UserID,
+		session.DeviceID,
+		session.DeviceIdentifier,
+		session.CreatedAt,
+		session.ExpiresAt,
+		session.RevokedAt,
+	)
+
+	return err
+}
+
+// GetByID obtiene una sesión por su ID
+func (r *SessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM notification_service.sessions WHERE id = $1`
+
+	sessions, err := r.query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, repository.ErrSessionNotFound
+	}
+
+	return sessions[0], nil
+}
+
+// ListActiveByUser obtiene las sesiones no revocadas ni expiradas de un
+// usuario de un tenant
+func (r *SessionRepository) ListActiveByUser(ctx context.Context, tenantID, userID string) ([]*entity.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM notification_service.sessions
+		WHERE tenant_id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+
+	return r.query(ctx, query, tenantID, userID)
+}
+
+// Revoke revoca una sesión
+func (r *SessionRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE notification_service.sessions
+		SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		// La sesión no existe o ya estaba revocada
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RevokeByUser revoca las sesiones activas de un usuario de un tenant
+func (r *SessionRepository) RevokeByUser(ctx context.Context, tenantID, userID string) ([]*entity.Session, error) {
+	query := `
+		UPDATE notification_service.sessions
+		SET revoked_at = NOW()
+		WHERE tenant_id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING ` + sessionColumns
+
+	return r.query(ctx, query, tenantID, userID)
+}
+
+// RevokeByDevice revoca las sesiones activas de un dispositivo
+func (r *SessionRepository) RevokeByDevice(ctx context.Context, deviceID uuid.UUID) ([]*entity.Session, error) {
+	query := `
+		UPDATE notification_service.sessions
+		SET revoked_at = NOW()
+		WHERE device_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING ` + sessionColumns
+
+	return r.query(ctx, query, deviceID)
+}
+
+// ListRevoked obtiene las sesiones revocadas cuyo token todavía no ha expirado
+func (r *SessionRepository) ListRevoked(ctx context.Context) ([]*entity.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM notification_service.sessions
+		WHERE revoked_at IS NOT NULL AND expires_at > NOW()
+	`
+
+	return r.query(ctx, query)
+}
+
+// DeleteExpired elimina las sesiones expiradas
+func (r *SessionRepository) DeleteExpired(ctx context.Context) error {
+	query := `DELETE FROM notification_service.sessions WHERE expires_at <= NOW()`
+
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+// query ejecuta una consulta que devuelve sesiones
+func (r *SessionRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entity.Session, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*entity.Session
+
+	for rows.Next() {
+		var session entity.Session
+		var revokedAt sql.NullTime
+
+		err := rows.Scan(
+			&session.ID,
+			&session.TenantID,
+			&session.UserID,
+			&session.DeviceID,
+			&session.DeviceIdentifier,
+			&session.CreatedAt,
+			&session.ExpiresAt,
+			&revokedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if revokedAt.Valid {
+			session.RevokedAt = &revokedAt.Time
+		}
+
+		sessions = append(sessions, &session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
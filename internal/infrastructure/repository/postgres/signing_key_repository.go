@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+)
+
+// SigningKeyRepository implementa repository.SigningKeyRepository
+type SigningKeyRepository struct {
+	db *sql.DB
+}
+
+// NewSigningKeyRepository crea una instancia de SigningKeyRepository
+func NewSigningKeyRepository(db *sql.DB) repository.SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// Create guarda una clave nueva
+func (r *SigningKeyRepository) Create(ctx context.Context, key *entity.SigningKey) error {
+	query := `
+		INSERT INTO notification_service.signing_keys
+		(id, algorithm, private_key, created_at, retired_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		key.ID,
+		key.Algorithm,
+		key.PrivateKey,
+		key.CreatedAt,
+		key.RetiredAt,
+		key.ExpiresAt,
+	)
+
+	return err
+}
+
+// ListValid obtiene las claves activas y las retiradas cuyo periodo de
+// gracia no ha terminado, de la más reciente a la más antigua
+func (r *SigningKeyRepository) ListValid(ctx context.Context) ([]*entity.SigningKey, error) {
+	query := `
+		SELECT id, algorithm, private_key, created_at, retired_at, expires_at
+		FROM notification_service.signing_keys
+		WHERE expires_at IS NULL OR expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*entity.SigningKey
+
+	for rows.Next() {
+		var key entity.SigningKey
+		var retiredAt, expiresAt sql.NullTime
+
+		err := rows.Scan(
+			&key.ID,
+			&key.Algorithm,
+			&key.PrivateKey,
+			&key.CreatedAt,
+			&retiredAt,
+			&expiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if retiredAt.Valid {
+			key.RetiredAt = &retiredAt.Time
+		}
+		if expiresAt.Valid {
+			key.ExpiresAt = &expiresAt.Time
+		}
+
+		keys = append(keys, &key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RetireBefore retira las claves activas creadas antes de createdBefore. Si
+// dos instancias rotan a la vez, la clave más reciente queda como activa.
+func (r *SigningKeyRepository) RetireBefore(ctx context.Context, createdBefore, retiredAt, expiresAt time.Time) error {
+	query := `
+		UPDATE notification_service.signing_keys
+		SET retired_at = $2, expires_at = $3
+		WHERE retired_at IS NULL AND created_at < $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, createdBefore, retiredAt, expiresAt)
+	return err
+}
+
+// DeleteExpired elimina las claves cuyo periodo de gracia ya terminó
+func (r *SigningKeyRepository) DeleteExpired(ctx context.Context) error {
+	query := `
+		DELETE FROM notification_service.signing_keys
+		WHERE expires_at IS NOT NULL AND expires_at <= NOW()
+	`
+
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,406 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+)
+
+// Columnas de una plantilla, en el orden que espera query
+const templateColumns = `tenant_id, id, name, description, latest_version, published_version, created_at, updated_at`
+
+// Columnas de una versión de una plantilla, en el orden que espera
+// queryVersions
+const templateVersionColumns = `tenant_id, template_id, version, title, body, data, locales, variables, status, created_by, created_at, published_at`
+
+// TemplateRepository implementa repository.TemplateRepository
+type TemplateRepository struct {
+	db *sql.DB
+}
+
+// NewTemplateRepository crea una instancia de TemplateRepository
+func NewTemplateRepository(db *sql.DB) repository.TemplateRepository {
+	return &TemplateRepository{db: db}
+}
+
+// Create guarda una plantilla nueva con su primera versión
+func (r *TemplateRepository) Create(ctx context.Context, template *entity.Template, version *entity.TemplateVersion) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO notification_service.templates
+		(` + templateColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (tenant_id, id) DO NOTHING
+	`
+
+	result, err := tx.ExecContext(
+		ctx,
+		query,
+		template.TenantID,
+		template.ID,
+		template.Name,
+		template.Description,
+		template.LatestVersion,
+		template.PublishedVersion,
+		template.CreatedAt,
+		template.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return repository.ErrTemplateExists
+	}
+
+	if err := r.insertVersion(ctx, tx, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetByID obtiene una plantilla de un tenant por su ID
+func (r *TemplateRepository) GetByID(ctx context.Context, tenantID, id string) (*entity.Template, error) {
+	query := `SELECT ` + templateColumns + ` FROM notification_service.templates WHERE tenant_id = $1 AND id = $2`
+
+	templates, err := r.query(ctx, query, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, repository.ErrTemplateNotFound
+	}
+
+	return templates[0], nil
+}
+
+// List obtiene las plantillas de un tenant
+func (r *TemplateRepository) List(ctx context.Context, tenantID string) ([]*entity.Template, error) {
+	query := `SELECT ` + templateColumns + ` FROM notification_service.templates WHERE tenant_id = $1 ORDER BY id`
+
+	return r.query(ctx, query, tenantID)
+}
+
+// AddVersion actualiza el nombre y la descripción de una plantilla y le
+// añade una versión con el número siguiente a la última
+func (r *TemplateRepository) AddVersion(ctx context.Context, template *entity.Template, version *entity.TemplateVersion) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE notification_service.templates
+		SET name = $3, description = $4, latest_version = latest_version + 1, updated_at = $5
+		WHERE tenant_id = $1 AND id = $2
+		RETURNING latest_version
+	`
+
+	var latest int
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		template.TenantID,
+		template.ID,
+		template.Name,
+		template.Description,
+		template.UpdatedAt,
+	).Scan(&latest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrTemplateNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	version.Version = latest
+	if err := r.insertVersion(ctx, tx, version); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	template.LatestVersion = latest
+	return nil
+}
+
+// Delete elimina una plantilla con todas sus versiones
+func (r *TemplateRepository) Delete(ctx context.Context, tenantID, id string) error {
+	query := `DELETE FROM notification_service.templates WHERE tenant_id = $1 AND id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, tenantID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return repository.ErrTemplateNotFound
+	}
+
+	return nil
+}
+
+// GetVersion obtiene una versión de una plantilla
+func (r *TemplateRepository) GetVersion(ctx context.Context, tenantID, id string, version int) (*entity.TemplateVersion, error) {
+	query := `
+		SELECT ` + templateVersionColumns + `
+		FROM notification_service.template_versions
+		WHERE tenant_id = $1 AND template_id = $2 AND version = $3
+	`
+
+	versions, err := r.queryVersions(ctx, query, tenantID, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, repository.ErrTemplateNotFound
+	}
+
+	return versions[0], nil
+}
+
+// ListVersions obtiene las versiones de una plantilla, de la más reciente a
+// la más antigua
+func (r *TemplateRepository) ListVersions(ctx context.Context, tenantID, id string) ([]*entity.TemplateVersion, error) {
+	query := `
+		SELECT ` + templateVersionColumns + `
+		FROM notification_service.template_versions
+		WHERE tenant_id = $1 AND template_id = $2
+		ORDER BY version DESC
+	`
+
+	return r.queryVersions(ctx, query, tenantID, id)
+}
+
+// Publish publica una versión de una plantilla y archiva la que estaba
+// publicada
+func (r *TemplateRepository) Publish(ctx context.Context, tenantID, id string, version int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE notification_service.template_versions
+		SET status = $4, published_at = NOW()
+		WHERE tenant_id = $1 AND template_id = $2 AND version = $3
+	`
+
+	result, err := tx.ExecContext(ctx, query, tenantID, id, version, entity.TemplateStatusPublished)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return repository.ErrTemplateNotFound
+	}
+
+	query = `
+		UPDATE notification_service.template_versions
+		SET status = $4
+		WHERE tenant_id = $1 AND template_id = $2 AND version <> $3 AND status = $5
+	`
+
+	_, err = tx.ExecContext(ctx, query, tenantID, id, version, entity.TemplateStatusArchived, entity.TemplateStatusPublished)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE notification_service.templates
+		SET published_version = $3, updated_at = NOW()
+		WHERE tenant_id = $1 AND id = $2
+	`
+
+	if _, err := tx.ExecContext(ctx, query, tenantID, id, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListPublished obtiene la versión publicada de las plantillas de todos los
+// tenants
+func (r *TemplateRepository) ListPublished(ctx context.Context) ([]*entity.TemplateVersion, error) {
+	query := `
+		SELECT ` + templateVersionColumns + `
+		FROM notification_service.template_versions
+		WHERE status = $1
+	`
+
+	return r.queryVersions(ctx, query, entity.TemplateStatusPublished)
+}
+
+// insertVersion guarda una versión de una plantilla dentro de una
+// transacción
+func (r *TemplateRepository) insertVersion(ctx context.Context, tx *sql.Tx, version *entity.TemplateVersion) error {
+	// Los mapas vacíos se guardan como NULL
+	var data, locales, variables sql.NullString
+	if len(version.Data) > 0 {
+		encoded, err := json.Marshal(version.Data)
+		if err != nil {
+			return err
+		}
+		data = sql.NullString{String: string(encoded), Valid: true}
+	}
+	if len(version.Locales) > 0 {
+		encoded, err := json.Marshal(version.Locales)
+		if err != nil {
+			return err
+		}
+		locales = sql.NullString{String: string(encoded), Valid: true}
+	}
+	if len(version.Variables) > 0 {
+		encoded, err := json.Marshal(version.Variables)
+		if err != nil {
+			return err
+		}
+		variables = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	query := `
+		INSERT INTO notification_service.template_versions
+		(` + templateVersionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	_, err := tx.ExecContext(
+		ctx,
+		query,
+		version.TenantID,
+		version.TemplateID,
+		version.Version,
+		version.Title,
+		version.Body,
+		data,
+		locales,
+		variables,
+		version.Status,
+		version.CreatedBy,
+		version.CreatedAt,
+		version.PublishedAt,
+	)
+
+	return err
+}
+
+// query ejecuta una consulta que devuelve plantillas
+func (r *TemplateRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entity.Template, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*entity.Template
+
+	for rows.Next() {
+		var template entity.Template
+
+		err := rows.Scan(
+			&template.TenantID,
+			&template.ID,
+			&template.Name,
+			&template.Description,
+			&template.LatestVersion,
+			&template.PublishedVersion,
+			&template.CreatedAt,
+			&template.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, &template)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// queryVersions ejecuta una consulta que devuelve versiones de plantillas
+func (r *TemplateRepository) queryVersions(ctx context.Context, query string, args ...interface{}) ([]*entity.TemplateVersion, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*entity.TemplateVersion
+
+	for rows.Next() {
+		var version entity.TemplateVersion
+		var data, locales, variables []byte
+
+		err := rows.Scan(
+			&version.TenantID,
+			&version.TemplateID,
+			&version.Version,
+			&version.Title,
+			&version.Body,
+			&data,
+			&locales,
+			&variables,
+			&version.Status,
+			&version.CreatedBy,
+			&version.CreatedAt,
+			&version.PublishedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &version.Data); err != nil {
+				return nil, err
+			}
+		}
+		if len(locales) > 0 {
+			if err := json.Unmarshal(locales, &version.Locales); err != nil {
+				return nil, err
+			}
+		}
+		if len(variables) > 0 {
+			if err := json.Unmarshal(variables, &version.Variables); err != nil {
+				return nil, err
+			}
+		}
+
+		versions = append(versions, &version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
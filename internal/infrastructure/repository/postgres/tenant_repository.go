@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+)
+
+// Columnas de un tenant, en el orden que espera query
+const tenantColumns = `id, name, apns_bundle_id, apns_certificate_path, apns_production, fcm_server_key, quota_per_second, quota_per_day, created_at, updated_at`
+
+// TenantRepository implementa repository.TenantRepository
+type TenantRepository struct {
+	db *sql.DB
+}
+
+// NewTenantRepository crea una instancia de TenantRepository
+func NewTenantRepository(db *sql.DB) repository.TenantRepository {
+	return &TenantRepository{db: db}
+}
+
+// Create guarda un tenant nuevo
+func (r *TenantRepository) Create(ctx context.Context, tenant *entity.Tenant) error {
+	query := `
+		INSERT INTO notification_service.tenants
+		(` + tenantColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		tenant.ID,
+		tenant.Name,
+		tenant.APNSBundleID,
+		tenant.APNSCertificatePath,
+		tenant.APNSProduction,
+		tenant.FCMServerKey,
+		tenant.QuotaPerSecond,
+		tenant.QuotaPerDay,
+		tenant.CreatedAt,
+		tenant.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByID obtiene un tenant por su ID
+func (r *TenantRepository) GetByID(ctx context.Context, id string) (*entity.Tenant, error) {
+	query := `SELECT ` + tenantColumns + ` FROM notification_service.tenants WHERE id = $1`
+
+	tenants, err := r.query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(tenants) == 0 {
+		return nil, repository.ErrTenantNotFound
+	}
+
+	return tenants[0], nil
+}
+
+// List obtiene todos los tenants
+func (r *TenantRepository) List(ctx context.Context) ([]*entity.Tenant, error) {
+	query := `SELECT ` + tenantColumns + ` FROM notification_service.tenants ORDER BY id`
+
+	return r.query(ctx, query)
+}
+
+// Update actualiza el nombre, las credenciales y las cuotas de un tenant
+func (r *TenantRepository) Update(ctx context.Context, tenant *entity.Tenant) error {
+	query := `
+		UPDATE notification_service.tenants
+		SET name = $2, apns_bundle_id = $3, apns_certificate_path = $4, apns_production = $5,
+		    fcm_server_key = $6, quota_per_second = $7, quota_per_day = $8, updated_at = $9
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		tenant.ID,
+		tenant.Name,
+		tenant.APNSBundleID,
+		tenant.APNSCertificatePath,
+		tenant.APNSProduction,
+		tenant.FCMServerKey,
+		tenant.QuotaPerSecond,
+		tenant.QuotaPerDay,
+		tenant.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return repository.ErrTenantNotFound
+	}
+
+	return nil
+}
+
+// query ejecuta una consulta que devuelve tenants
+func (r *TenantRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entity.Tenant, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []*entity.Tenant
+
+	for rows.Next() {
+		var tenant entity.Tenant
+
+		err := rows.Scan(
+			&tenant.ID,
+			&tenant.Name,
+			&tenant.APNSBundleID,
+			&tenant.APNSCertificatePath,
+			&tenant.APNSProduction,
+			&tenant.FCMServerKey,
+			&tenant.QuotaPerSecond,
+			&tenant.QuotaPerDay,
+			&tenant.CreatedAt,
+			&tenant.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		tenants = append(tenants, &tenant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tenants, nil
+}
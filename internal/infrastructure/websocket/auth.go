@@ -0,0 +1,133 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"notification-service/internal/usecase"
+
+	"github.com/gorilla/websocket"
+)
+
+// setAuth guarda el token y los claims con los que se autenticó el cliente
+// y programa el aviso y el cierre por expiración
+func (c *Client) setAuth(token string, claims *usecase.Claims) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	c.token = token
+	c.sessionID = claims.Session()
+	c.temporary = claims.IsTemporary
+	c.expiresAt = time.Time{}
+	if claims.ExpiresAt != nil {
+		c.expiresAt = claims.ExpiresAt.Time
+	}
+
+	c.scheduleExpiry()
+}
+
+// matchesClaims verifica que unos claims corresponden al tenant, al
+// dispositivo y al usuario del cliente. La conexión está indexada por ambos en el hub, así que
+// para cambiar de identidad (por ejemplo, al vincular un usuario) hay que
+// reconectar.
+func (c *Client) matchesClaims(claims *usecase.Claims) bool {
+	if claims.Tenant() != c.tenantID || claims.DeviceIdentifier != c.deviceIdentifier || claims.UserID != c.userID {
+		return false
+	}
+	return claims.DeviceID == "" || claims.DeviceID == c.deviceID.String()
+}
+
+// IsTemporary indica si el cliente se autenticó con un token temporal
+func (c *Client) IsTemporary() bool {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	return c.temporary
+}
+
+// TokenExpiresAt devuelve cuándo expira el token del cliente
+func (c *Client) TokenExpiresAt() time.Time {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	return c.expiresAt
+}
+
+// scheduleExpiry programa el aviso token_expiring antes de que expire el
+// token; el aviso a su vez programa el cierre. Debe llamarse con authMu tomado.
+func (c *Client) scheduleExpiry() {
+	if c.expiryTimer != nil {
+		c.expiryTimer.Stop()
+		c.expiryTimer = nil
+	}
+	if c.expiresAt.IsZero() {
+		return
+	}
+
+	expiresAt := c.expiresAt
+	warnIn := max(time.Until(expiresAt)-c.options.ExpiryWarning, 0)
+	c.expiryTimer = time.AfterFunc(warnIn, func() {
+		c.warnExpiry(expiresAt)
+	})
+}
+
+// warnExpiry avisa al cliente de que su token va a expirar y programa el
+// cierre de la conexión, salvo que el token se haya renovado entretanto
+func (c *Client) warnExpiry(expiresAt time.Time) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if !c.expiresAt.Equal(expiresAt) {
+		return
+	}
+
+	warning, _ := json.Marshal(map[string]interface{}{
+		"type":       "token_expiring",
+		"expires_at": expiresAt.Format(time.RFC3339),
+	})
+	c.Send(warning)
+
+	c.expiryTimer = time.AfterFunc(time.Until(expiresAt), func() {
+		c.expire(expiresAt)
+	})
+}
+
+// expire cierra la conexión con el código de violación de política si el
+// token no se renovó a tiempo
+func (c *Client) expire(expiresAt time.Time) {
+	c.authMu.Lock()
+	refreshed := !c.expiresAt.Equal(expiresAt)
+	c.authMu.Unlock()
+
+	if refreshed {
+		return
+	}
+
+	c.hub.unregister(c, DisconnectTokenExpired, websocket.ClosePolicyViolation, "token expired")
+}
+
+// stopExpiry detiene los temporizadores de expiración del cliente
+func (c *Client) stopExpiry() {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.expiryTimer != nil {
+		c.expiryTimer.Stop()
+		c.expiryTimer = nil
+	}
+}
+
+// sendError envía al cliente un error relativo a uno de sus mensajes, con
+// su message_id si lo trae para que pueda correlacionarlo
+func (c *Client) sendError(msg *ClientMessage, code string) {
+	response := map[string]interface{}{
+		"type":         "error",
+		"request_type": msg.Type,
+		"error":        code,
+	}
+	if msg.ID != "" {
+		response["message_id"] = msg.ID
+	}
+	responseJSON, _ := json.Marshal(response)
+	c.Send(responseJSON)
+}
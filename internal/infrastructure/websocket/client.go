@@ -2,28 +2,17 @@ package websocket
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
-const (
-	// Tiempo máximo para escribir un mensaje al cliente
-	writeWait = 10 * time.Second
-
-	// Tiempo máximo para leer el siguiente pong del cliente
-	pongWait = 60 * time.Second
-
-	// Enviar pings al cliente con esta periodicidad
-	pingPeriod = (pongWait * 9) / 10
-
-	// Tamaño máximo del mensaje
-	maxMessageSize = 4096
-)
-
 // ClientMessage representa un mensaje del cliente
 type ClientMessage struct {
+	// Identificador opcional que el servidor devuelve en la respuesta
+	ID      string          `json:"message_id,omitempty"`
 	Type    string          `json:"type"`
 	Payload json.RawMessage `json:"payload,omitempty"`
 }
@@ -32,61 +21,86 @@ type ClientMessage struct {
 type Client struct {
 	hub               *Hub
 	conn              *websocket.Conn
-	send              chan []byte
+	send              *sendQueue
+	tenantID          string
 	userID            string
 	deviceID          uuid.UUID
 	deviceIdentifier  string
 	token             string
-	lastActivity      time.Time
+	lastActivity      activityClock
+	connectedAt       time.Time
 	connectionHandler ConnectionHandler
+	codec             FrameCodec
+	options           ClientOptions
+
+	// Estado de autenticación; el token puede renovarse con token_refresh
+	authMu      sync.Mutex
+	sessionID   string
+	temporary   bool
+	expiresAt   time.Time
+	expiryTimer *time.Timer
 }
 
 // ConnectionHandler define las operaciones para manejar eventos de conexión
 type ConnectionHandler interface {
-	OnConnect(client *Client)
-	OnDisconnect(client *Client)
+	OnConnect(conn Connection)
+	OnDisconnect(conn Connection)
 	OnMessage(client *Client, messageType int, message []byte)
-	OnError(client *Client, err error)
+	OnError(conn Connection, err error)
 }
 
 // NewClient crea un nuevo cliente WebSocket
 func NewClient(
 	hub *Hub,
 	conn *websocket.Conn,
+	tenantID string,
 	userID string,
 	deviceID uuid.UUID,
 	deviceIdentifier string,
 	token string,
 	handler ConnectionHandler,
+	codec FrameCodec,
+	options ClientOptions,
 ) *Client {
-	return &Client{
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	options = options.withDefaults()
+
+	client := &Client{
 		hub:               hub,
 		conn:              conn,
-		send:              make(chan []byte, 256),
+		send:              newSendQueue(options),
+		tenantID:          tenantID,
 		userID:            userID,
 		deviceID:          deviceID,
 		deviceIdentifier:  deviceIdentifier,
 		token:             token,
-		lastActivity:      time.Now(),
+		connectedAt:       time.Now(),
 		connectionHandler: handler,
+		codec:             codec,
+		options:           options,
 	}
+	client.lastActivity.touch()
+	return client
 }
 
 // readPump bombea mensajes desde la conexión WebSocket al hub
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.stopExpiry()
+		c.hub.Unregister(c)
 		c.conn.Close()
 		if c.connectionHandler != nil {
 			c.connectionHandler.OnDisconnect(c)
 		}
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetReadLimit(c.options.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.options.PongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.lastActivity = time.Now()
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.lastActivity.touch()
+		c.conn.SetReadDeadline(time.Now().Add(c.options.PongWait))
 		return nil
 	})
 
@@ -105,7 +119,7 @@ func (c *Client) readPump() {
 			break
 		}
 
-		c.lastActivity = time.Now()
+		c.lastActivity.touch()
 
 		if c.connectionHandler != nil {
 			c.connectionHandler.OnMessage(c, messageType, message)
@@ -115,7 +129,7 @@ func (c *Client) readPump() {
 
 // writePump bombea mensajes desde el hub a la conexión WebSocket
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.options.PingInterval)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -127,33 +141,25 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		case message, ok := <-c.send.ch:
+			c.conn.SetWriteDeadline(time.Now().Add(c.options.WriteWait))
 			if !ok {
 				// El hub cerró el canal
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
+				closeMessage := []byte{}
+				if code, reason := c.send.closeStatus(); code != 0 {
+					closeMessage = websocket.FormatCloseMessage(code, reason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
-			w.Write(message)
-
-			// Agregar todos los mensajes pendientes
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
 
-			if err := w.Close(); err != nil {
+			// Cada mensaje se envía en su propio frame; no se concatenan
+			if err := c.writeFrame(message); err != nil {
 				return
 			}
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(c.options.WriteWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -161,14 +167,24 @@ func (c *Client) writePump() {
 	}
 }
 
+// writeFrame codifica un mensaje y lo escribe como un único frame
+func (c *Client) writeFrame(message []byte) error {
+	frame, err := c.codec.Encode(message)
+	if err != nil {
+		// Un mensaje que no se puede codificar no debe cerrar la conexión
+		if c.connectionHandler != nil {
+			c.connectionHandler.OnError(c, err)
+		}
+		return nil
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.options.WriteWait))
+	return c.conn.WriteMessage(c.codec.MessageType(), frame)
+}
+
 // Send envía un mensaje al cliente
 func (c *Client) Send(message []byte) bool {
-	select {
-	case c.send <- message:
-		return true
-	default:
-		return false
-	}
+	return c.hub.deliver(c, message).queued()
 }
 
 // Close cierra la conexión del cliente
@@ -176,17 +192,65 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
+// queue devuelve la cola de salida del cliente
+func (c *Client) queue() *sendQueue {
+	return c.send
+}
+
+// TenantID devuelve el tenant del dispositivo del cliente
+func (c *Client) TenantID() string {
+	return c.tenantID
+}
+
+// UserID devuelve el usuario asociado al cliente
+func (c *Client) UserID() string {
+	return c.userID
+}
+
+// DeviceID devuelve el dispositivo asociado al cliente
+func (c *Client) DeviceID() uuid.UUID {
+	return c.deviceID
+}
+
+// DeviceIdentifier devuelve el identificador físico del dispositivo
+func (c *Client) DeviceIdentifier() string {
+	return c.deviceIdentifier
+}
+
+// SessionID devuelve la sesión del token actual del cliente
+func (c *Client) SessionID() string {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	return c.sessionID
+}
+
+// Transport devuelve el transporte del cliente
+func (c *Client) Transport() TransportType {
+	return TransportWebSocket
+}
+
+// Encoding devuelve el formato de frames negociado por el cliente
+func (c *Client) Encoding() FrameEncoding {
+	return c.codec.Encoding()
+}
+
 // IsActive verifica si el cliente está activo
 func (c *Client) IsActive() bool {
-	return time.Since(c.lastActivity) <= pongWait
+	return time.Since(c.lastActivity.last()) <= c.options.PongWait
 }
 
 // GetLastActivity devuelve la última vez que el cliente estuvo activo
 func (c *Client) GetLastActivity() time.Time {
-	return c.lastActivity
+	return c.lastActivity.last()
 }
 
 // UpdateLastActivity actualiza la última actividad del cliente
 func (c *Client) UpdateLastActivity() {
-	c.lastActivity = time.Now()
+	c.lastActivity.touch()
+}
+
+// ConnectedAt devuelve el momento en que se estableció la conexión
+func (c *Client) ConnectedAt() time.Time {
+	return c.connectedAt
 }
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// ClientEventReporter reenvía al servicio de negocio los eventos que envían
+// los clientes por la conexión en tiempo real
+type ClientEventReporter interface {
+	ReportClientEvent(ctx context.Context, messageID, userID, deviceID, eventType string, payload []byte) ([]byte, error)
+}
+
+// NewClientEventRoute crea la ruta de los mensajes client_event. El payload
+// del mensaje es {"event_type": "...", "data": {...}}; data se reenvía tal
+// cual y la respuesta del servicio de negocio se devuelve al cliente en un
+// client_event_response con el mismo message_id.
+func NewClientEventRoute(reporter ClientEventReporter, timeout time.Duration) MessageRoute {
+	return MessageRoute{
+		Handler: func(ctx context.Context, client *Client, msg *ClientMessage) (map[string]interface{}, error) {
+			var event struct {
+				EventType string          `json:"event_type"`
+				Data      json.RawMessage `json:"data,omitempty"`
+			}
+			if err := json.Unmarshal(msg.Payload, &event); err != nil || event.EventType == "" {
+				return nil, NewMessageError(errCodeInvalidPayload)
+			}
+
+			result, err := reporter.ReportClientEvent(ctx, msg.ID, client.UserID(), client.DeviceID().String(), event.EventType, event.Data)
+			if err != nil {
+				return nil, &MessageError{Code: "client_event_failed", Err: err}
+			}
+
+			response := map[string]interface{}{
+				"type":       "client_event_response",
+				"event_type": event.EventType,
+				"success":    true,
+			}
+			if len(result) > 0 && json.Valid(result) {
+				response["payload"] = json.RawMessage(result)
+			}
+			return response, nil
+		},
+		// Los eventos actúan en nombre del usuario y llaman a otro servicio
+		RequiresPermanentToken: true,
+		Async:                  true,
+		Timeout:                timeout,
+	}
+}
@@ -0,0 +1,220 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	pb "notification-service/pkg/proto"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// Subprotocolos WebSocket soportados para negociar el formato de los frames
+const (
+	SubprotocolJSON     = "notification.v1.json"
+	SubprotocolProtobuf = "notification.v1.protobuf"
+)
+
+// FrameEncoding identifica el formato de los frames de una conexión
+type FrameEncoding string
+
+const (
+	FrameEncodingJSON     FrameEncoding = "json"
+	FrameEncodingProtobuf FrameEncoding = "protobuf"
+)
+
+var (
+	ErrUnsupportedFrame = errors.New("unsupported frame type")
+)
+
+// FrameCodec convierte los mensajes internos (JSON) al formato de la conexión
+type FrameCodec interface {
+	// Encoding devuelve el formato del codec
+	Encoding() FrameEncoding
+
+	// MessageType devuelve el tipo de mensaje WebSocket usado para escribir
+	MessageType() int
+
+	// Encode convierte un mensaje interno en un frame
+	Encode(message []byte) ([]byte, error)
+
+	// Decode convierte un frame recibido en un mensaje del cliente
+	Decode(messageType int, data []byte) (*ClientMessage, error)
+}
+
+// negotiateCodec selecciona el codec a partir del subprotocolo aceptado o,
+// en su defecto, del parámetro de consulta "encoding"
+func negotiateCodec(conn *websocket.Conn, r *http.Request) FrameCodec {
+	switch conn.Subprotocol() {
+	case SubprotocolProtobuf:
+		return ProtobufCodec{}
+	case SubprotocolJSON:
+		return JSONCodec{}
+	}
+
+	if strings.EqualFold(r.URL.Query().Get("encoding"), string(FrameEncodingProtobuf)) {
+		return ProtobufCodec{}
+	}
+
+	return JSONCodec{}
+}
+
+// JSONCodec envía cada mensaje como un frame de texto JSON
+type JSONCodec struct{}
+
+// Encoding devuelve el formato del codec
+func (JSONCodec) Encoding() FrameEncoding {
+	return FrameEncodingJSON
+}
+
+// MessageType devuelve el tipo de mensaje WebSocket usado para escribir
+func (JSONCodec) MessageType() int {
+	return websocket.TextMessage
+}
+
+// Encode devuelve el mensaje sin cambios
+func (JSONCodec) Encode(message []byte) ([]byte, error) {
+	return message, nil
+}
+
+// Decode interpreta un frame de texto JSON
+func (JSONCodec) Decode(messageType int, data []byte) (*ClientMessage, error) {
+	if messageType != websocket.TextMessage {
+		return nil, ErrUnsupportedFrame
+	}
+
+	var msg ClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// ProtobufCodec envía cada mensaje como un frame binario pb.WebSocketFrame
+type ProtobufCodec struct{}
+
+// Encoding devuelve el formato del codec
+func (ProtobufCodec) Encoding() FrameEncoding {
+	return FrameEncodingProtobuf
+}
+
+// MessageType devuelve el tipo de mensaje WebSocket usado para escribir
+func (ProtobufCodec) MessageType() int {
+	return websocket.BinaryMessage
+}
+
+// Encode convierte un mensaje JSON interno en un pb.WebSocketFrame
+func (ProtobufCodec) Encode(message []byte) ([]byte, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(message, &fields); err != nil {
+		return nil, fmt.Errorf("invalid internal message: %w", err)
+	}
+
+	frame := &pb.WebSocketFrame{
+		Type:      stringField(fields, "type"),
+		MessageId: stringField(fields, "message_id"),
+		Timestamp: time.Now().Unix(),
+	}
+	if ts, ok := fields["timestamp"].(float64); ok {
+		frame.Timestamp = int64(ts)
+	}
+
+	if frame.Type == "notification" {
+		frame.Notification = &pb.NotificationFrame{
+			NotificationId:   stringField(fields, "notification_id"),
+			Title:            stringField(fields, "title"),
+			Message:          stringField(fields, "message"),
+			Data:             stringMap(fields["data"]),
+			NotificationType: stringField(fields, "notification_type"),
+		}
+		if priority, ok := fields["priority"].(float64); ok {
+			frame.Notification.Priority = int32(priority)
+		}
+	} else {
+		payload, err := framePayload(message)
+		if err != nil {
+			return nil, fmt.Errorf("invalid internal message: %w", err)
+		}
+		frame.Payload = payload
+	}
+
+	return proto.Marshal(frame)
+}
+
+// framePayload extrae el payload de un mensaje que no es una notificación:
+// los campos del mensaje salvo los que ya van en el frame (type, message_id
+// y timestamp). Si el único campo restante es "payload", se usa su valor, de
+// modo que un mensaje con la forma de ClientMessage conserva su payload al
+// pasar por Encode y Decode
+func framePayload(message []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil {
+		return nil, err
+	}
+
+	delete(fields, "type")
+	delete(fields, "message_id")
+	delete(fields, "timestamp")
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if inner, ok := fields["payload"]; ok && len(fields) == 1 {
+		return inner, nil
+	}
+	return json.Marshal(fields)
+}
+
+// Decode interpreta un frame binario pb.WebSocketFrame
+func (ProtobufCodec) Decode(messageType int, data []byte) (*ClientMessage, error) {
+	if messageType != websocket.BinaryMessage {
+		return nil, ErrUnsupportedFrame
+	}
+
+	var frame pb.WebSocketFrame
+	if err := proto.Unmarshal(data, &frame); err != nil {
+		return nil, err
+	}
+
+	msg := &ClientMessage{ID: frame.MessageId, Type: frame.Type}
+	if len(frame.Payload) > 0 {
+		msg.Payload = json.RawMessage(frame.Payload)
+	}
+	return msg, nil
+}
+
+// stringField obtiene un campo de texto de un mensaje JSON decodificado
+func stringField(fields map[string]interface{}, key string) string {
+	if value, ok := fields[key].(string); ok {
+		return value
+	}
+	return ""
+}
+
+// stringMap convierte los datos de una notificación en un mapa de texto,
+// serializando como JSON los valores que no son cadenas
+func stringMap(value interface{}) map[string]string {
+	data, ok := value.(map[string]interface{})
+	if !ok || len(data) == 0 {
+		return nil
+	}
+
+	result := make(map[string]string, len(data))
+	for k, v := range data {
+		if s, ok := v.(string); ok {
+			result[k] = s
+			continue
+		}
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		result[k] = string(encoded)
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	pb "notification-service/pkg/proto"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtobufCodecEncodePayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		payload string
+	}{
+		{
+			name:    "flat server message",
+			message: `{"type":"token_refresh_response","message_id":"m1","timestamp":1700000000,"token":"abc","success":true}`,
+			payload: `{"success":true,"token":"abc"}`,
+		},
+		{
+			name:    "client message shape",
+			message: `{"type":"ack","message_id":"m2","payload":{"notification_id":"n1"}}`,
+			payload: `{"notification_id":"n1"}`,
+		},
+		{
+			name:    "payload next to other fields",
+			message: `{"type":"client_event_response","event_type":"x","payload":{"ok":1}}`,
+			payload: `{"event_type":"x","payload":{"ok":1}}`,
+		},
+		{
+			name:    "envelope only",
+			message: `{"type":"pong","timestamp":1700000000}`,
+			payload: ``,
+		},
+	}
+
+	codec := ProtobufCodec{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := codec.Encode([]byte(tt.message))
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			var frame pb.WebSocketFrame
+			if err := proto.Unmarshal(data, &frame); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if frame.Notification != nil {
+				t.Fatalf("unexpected notification in %q frame", frame.Type)
+			}
+			if string(frame.Payload) != tt.payload {
+				t.Errorf("payload = %s, want %s", frame.Payload, tt.payload)
+			}
+		})
+	}
+}
+
+func TestProtobufCodecRoundTrip(t *testing.T) {
+	original := ClientMessage{
+		ID:      "m1",
+		Type:    "ack",
+		Payload: json.RawMessage(`{"notification_id":"n1"}`),
+	}
+	message, err := json.Marshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codec := ProtobufCodec{}
+	data, err := codec.Encode(message)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := codec.Decode(websocket.BinaryMessage, data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if decoded.ID != original.ID || decoded.Type != original.Type {
+		t.Errorf("decoded = %+v, want %+v", decoded, original)
+	}
+	if string(decoded.Payload) != string(original.Payload) {
+		t.Errorf("payload = %s, want %s", decoded.Payload, original.Payload)
+	}
+}
+
+func TestProtobufCodecEncodeNotification(t *testing.T) {
+	message := `{"type":"notification","notification_id":"n1","title":"Hola","message":"Texto","data":{"a":"b","n":1},"priority":2}`
+
+	data, err := ProtobufCodec{}.Encode([]byte(message))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	var frame pb.WebSocketFrame
+	if err := proto.Unmarshal(data, &frame); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(frame.Payload) != 0 {
+		t.Errorf("notification frame has payload %s", frame.Payload)
+	}
+	n := frame.Notification
+	if n == nil || n.NotificationId != "n1" || n.Title != "Hola" || n.Priority != 2 {
+		t.Fatalf("notification = %+v", n)
+	}
+	if n.Data["a"] != "b" || n.Data["n"] != "1" {
+		t.Errorf("data = %v", n.Data)
+	}
+}
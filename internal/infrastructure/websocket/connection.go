@@ -1 +1,87 @@
-package websocket
\ No newline at end of file
+package websocket
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransportType identifica el transporte de una conexión en tiempo real
+type TransportType string
+
+const (
+	TransportWebSocket TransportType = "websocket"
+	TransportSSE       TransportType = "sse"
+)
+
+// DisconnectReason indica por qué se dio de baja una conexión; se publica
+// en el evento client.disconnected
+type DisconnectReason string
+
+const (
+	DisconnectClientClosed   DisconnectReason = "client_closed"
+	DisconnectInactive       DisconnectReason = "inactive"
+	DisconnectSlowConsumer   DisconnectReason = "slow_consumer"
+	DisconnectTokenExpired   DisconnectReason = "token_expired"
+	DisconnectServerShutdown DisconnectReason = "server_shutdown"
+	DisconnectReplaced       DisconnectReason = "session_replaced"
+	DisconnectRevoked        DisconnectReason = "session_revoked"
+)
+
+// activityClock guarda el instante de la última actividad de una conexión.
+// Lo actualizan las goroutines de lectura y escritura de la conexión y lo
+// lee el limpiador, por eso es atómico.
+type activityClock struct {
+	unixNano atomic.Int64
+}
+
+// touch registra actividad en este instante
+func (a *activityClock) touch() {
+	a.unixNano.Store(time.Now().UnixNano())
+}
+
+// last devuelve el instante de la última actividad
+func (a *activityClock) last() time.Time {
+	return time.Unix(0, a.unixNano.Load())
+}
+
+// Connection representa una conexión en tiempo real registrada en el Hub.
+// El Hub y el código de entrega trabajan con esta interfaz, de modo que no
+// dependen del transporte que use cada dispositivo.
+type Connection interface {
+	// TenantID devuelve el tenant del dispositivo de la conexión
+	TenantID() string
+
+	// UserID devuelve el usuario asociado a la conexión (puede estar vacío)
+	UserID() string
+
+	// DeviceID devuelve el dispositivo asociado a la conexión
+	DeviceID() uuid.UUID
+
+	// DeviceIdentifier devuelve el identificador físico del dispositivo
+	DeviceIdentifier() string
+
+	// SessionID devuelve la sesión del token con el que se autenticó la
+	// conexión, o "" si el token no pertenece a ninguna
+	SessionID() string
+
+	// Transport devuelve el transporte de la conexión
+	Transport() TransportType
+
+	// Send encola un mensaje sin bloquear aplicando la política de
+	// consumidores lentos; devuelve false si el mensaje no se encoló
+	Send(message []byte) bool
+
+	// Close cierra la conexión subyacente
+	Close()
+
+	// GetLastActivity devuelve la última vez que la conexión estuvo activa
+	GetLastActivity() time.Time
+
+	// ConnectedAt devuelve el momento en que se estableció la conexión
+	ConnectedAt() time.Time
+
+	// queue devuelve la cola de salida de la conexión
+	queue() *sendQueue
+}
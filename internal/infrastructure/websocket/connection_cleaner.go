@@ -5,17 +5,21 @@ import (
 	"time"
 
 	"notification-service/pkg/logging"
+
+	"github.com/gorilla/websocket"
 )
 
-// ConnectionCleaner se encarga de limpiar las conexiones WebSocket inactivas
+// ConnectionCleaner se encarga de limpiar las conexiones WebSocket inactivas.
+// Trabaja sobre una copia de los clientes del hub y los da de baja a través
+// de él, de modo que no accede a sus mapas sin su mutex.
 type ConnectionCleaner struct {
 	hub            *Hub
 	interval       time.Duration
 	inactivityTime time.Duration
 	stopCh         chan struct{}
+	stopOnce       sync.Once
 	wg             sync.WaitGroup
 	logger         *logging.Logger
-	clientsMutex   sync.RWMutex // Mutex adicional para acceder al mapa clients
 }
 
 // NewConnectionCleaner crea una nueva instancia de ConnectionCleaner
@@ -35,9 +39,12 @@ func (c *ConnectionCleaner) Start() {
 	go c.run()
 }
 
-// Stop detiene el proceso de limpieza de conexiones
+// Stop detiene el proceso de limpieza de conexiones. Puede llamarse más de
+// una vez.
 func (c *ConnectionCleaner) Stop() {
-	close(c.stopCh)
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
 	c.wg.Wait()
 }
 
@@ -58,52 +65,31 @@ func (c *ConnectionCleaner) run() {
 	}
 }
 
-// cleanInactiveConnections limpia las conexiones inactivas
-func (c *ConnectionCleaner) cleanInactiveConnections() {
-	now := time.Now()
-	inactiveThreshold := now.Add(-c.inactivityTime)
-
-	// Crear lista temporal para no modificar el mapa mientras lo recorremos
-	var inactiveClients []*Client
+// cleanInactiveConnections da de baja las conexiones sin actividad desde
+// hace más de inactivityTime y devuelve cuántas se cerraron
+func (c *ConnectionCleaner) cleanInactiveConnections() int {
+	inactiveThreshold := time.Now().Add(-c.inactivityTime)
 
-	// Usar una copia segura del mapa de clientes para no bloquear el hub
-	c.clientsMutex.Lock()
-	clientsCopy := make([]*Client, 0, len(c.hub.clients))
-	for client := range c.hub.clients {
-		clientsCopy = append(clientsCopy, client)
-	}
-	c.clientsMutex.Unlock()
-
-	// Verificar inactividad sin bloquear el mapa completo
-	for _, client := range clientsCopy {
-		if client.GetLastActivity().Before(inactiveThreshold) {
-			inactiveClients = append(inactiveClients, client)
+	cleaned := 0
+	for _, client := range c.hub.snapshot() {
+		lastActivity := client.GetLastActivity()
+		if !lastActivity.Before(inactiveThreshold) {
+			continue
 		}
-	}
 
-	// Cerrar las conexiones inactivas
-	for _, client := range inactiveClients {
-		c.logger.Info("Closing inactive WebSocket connection: DeviceID=%s, UserID=%s, LastActivity=%s",
-			client.deviceID, client.userID, client.GetLastActivity().Format(time.RFC3339))
+		if c.logger != nil {
+			c.logger.Info("Closing inactive %s connection: DeviceID=%s, UserID=%s, LastActivity=%s",
+				client.Transport(), client.DeviceID(), client.UserID(), lastActivity.Format(time.RFC3339))
+		}
 
-		// Usar el canal de unregister para que el hub maneje correctamente la desconexión
-		c.hub.unregister <- client
+		// Dar de baja en el hub; cierra la cola de salida y con ella la conexión
+		c.hub.unregister(client, DisconnectInactive, websocket.CloseNormalClosure, "connection inactive")
+		cleaned++
 	}
 
-	if len(inactiveClients) > 0 {
-		c.logger.Info("Cleaned %d inactive WebSocket connections", len(inactiveClients))
+	if cleaned > 0 && c.logger != nil {
+		c.logger.Info("Cleaned %d inactive real-time connections", cleaned)
 	}
-}
-
-// También se necesitan estos métodos en el cliente para que funcione el connection cleaner:
-
-/* // GetLastActivity devuelve la última vez que el cliente estuvo activo
-func (c *Client) GetLastActivity() time.Time {
-	return c.lastActivity
-}
 
-// UpdateLastActivity actualiza la última actividad del cliente
-func (c *Client) UpdateLastActivity() {
-	c.lastActivity = time.Now()
+	return cleaned
 }
-*/
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestConnectionCleanerClosesInactiveConnections(t *testing.T) {
+	hub := NewHub(nil, nil)
+
+	active := newTestConn(hub, ClientOptions{})
+	inactive := newTestConn(hub, ClientOptions{})
+	inactive.activity.unixNano.Store(time.Now().Add(-time.Hour).UnixNano())
+
+	for _, client := range []*testConn{active, inactive} {
+		if err := hub.Register(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cleaner := NewConnectionCleaner(hub, time.Minute, 5*time.Minute, nil)
+	if cleaned := cleaner.cleanInactiveConnections(); cleaned != 1 {
+		t.Fatalf("cleaned = %d, want 1", cleaned)
+	}
+
+	if hub.IsDeviceConnected(inactive.deviceID) {
+		t.Error("inactive connection still registered")
+	}
+	if !hub.IsDeviceConnected(active.deviceID) {
+		t.Error("active connection was closed")
+	}
+
+	// La cola del cliente inactivo se cierra con el código de cierre normal
+	if result, _ := inactive.q.push([]byte(`{}`)); result != pushClosed {
+		t.Errorf("inactive queue push = %v, want pushClosed", result)
+	}
+	if code, _ := inactive.q.closeStatus(); code != websocket.CloseNormalClosure {
+		t.Errorf("close code = %d, want %d", code, websocket.CloseNormalClosure)
+	}
+
+	// Una segunda pasada no vuelve a cerrar nada
+	if cleaned := cleaner.cleanInactiveConnections(); cleaned != 0 {
+		t.Errorf("second pass cleaned = %d, want 0", cleaned)
+	}
+}
+
+func TestConnectionCleanerConcurrentWithHub(t *testing.T) {
+	hub := NewHub(nil, nil)
+	cleaner := NewConnectionCleaner(hub, time.Millisecond, time.Millisecond, nil)
+	cleaner.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				client := newTestConn(hub, ClientOptions{})
+				if err := hub.Register(client); err != nil {
+					t.Error(err)
+					return
+				}
+				// Actividad concurrente con la lectura del limpiador
+				client.activity.touch()
+				client.Send([]byte(`{"type":"pong"}`))
+				if j%2 == 0 {
+					hub.Unregister(client)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Sin actividad, el limpiador acaba cerrando todas las conexiones
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.GetClientCount() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("client count = %d, want 0", hub.GetClientCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cleaner.Stop()
+	cleaner.Stop()
+}
@@ -0,0 +1,170 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"notification-service/internal/usecase"
+	"notification-service/pkg/metrics"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Prefijo del subprotocolo que transporta el token en Sec-WebSocket-Protocol
+// (por ejemplo "bearer.eyJhbGciOi..."). El servidor nunca lo selecciona: el
+// cliente debe ofrecer además uno de los subprotocolos de formato.
+const bearerSubprotocolPrefix = "bearer."
+
+// Errores de autenticación de conexiones en tiempo real
+var (
+	errInvalidToken    = errors.New("invalid token")
+	errTokenExpired    = errors.New("token expired")
+	errInvalidDeviceID = errors.New("invalid device ID")
+)
+
+// tokenFromRequest obtiene el token de una petición de conexión. Se busca,
+// por orden, en la cabecera Authorization, en Sec-WebSocket-Protocol y en
+// el parámetro token de la URL; este último está obsoleto porque el token
+// acaba en los logs de acceso, y se indica con deprecated.
+func tokenFromRequest(r *http.Request) (token string, deprecated bool) {
+	if value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if value = strings.TrimSpace(value); value != "" {
+			return value, false
+		}
+	}
+
+	for _, protocol := range websocket.Subprotocols(r) {
+		if value, ok := strings.CutPrefix(protocol, bearerSubprotocolPrefix); ok && value != "" {
+			return value, false
+		}
+	}
+
+	if value := r.URL.Query().Get("token"); value != "" {
+		return value, true
+	}
+
+	return "", false
+}
+
+// verifyToken verifica un token de conexión y devuelve sus claims y el
+// dispositivo al que pertenece
+func (m *WebSocketManager) verifyToken(ctx context.Context, token string) (*usecase.Claims, uuid.UUID, error) {
+	claims, err := m.tokenService.VerifyToken(ctx, token)
+	if err != nil {
+		// El cliente debe obtener otro token con su token de refresco
+		if errors.Is(err, usecase.ErrTokenExpired) {
+			return nil, uuid.Nil, errTokenExpired
+		}
+		return nil, uuid.Nil, errInvalidToken
+	}
+
+	// Obtener deviceID del token
+	var deviceID uuid.UUID
+	if claims.DeviceID != "" {
+		deviceID, err = uuid.Parse(claims.DeviceID)
+		if err != nil {
+			return nil, uuid.Nil, errInvalidDeviceID
+		}
+	}
+
+	return claims, deviceID, nil
+}
+
+// authenticateFirstMessage autentica una conexión ya establecida que no
+// trajo token en el handshake: el primer mensaje debe ser un frame auth y
+// debe llegar antes de AuthTimeout. Si no, se cierra con 1008.
+func (m *WebSocketManager) authenticateFirstMessage(conn *websocket.Conn, r *http.Request) {
+	codec := negotiateCodec(conn, r)
+	options := m.clientOptions(r)
+
+	conn.SetReadLimit(options.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(options.AuthTimeout))
+
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		closeWithPolicyViolation(conn, options, "authentication timeout")
+		return
+	}
+
+	msg, err := codec.Decode(messageType, data)
+	if err != nil || msg.Type != "auth" {
+		closeWithPolicyViolation(conn, options, "authentication required")
+		return
+	}
+
+	var payload struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload.Token == "" {
+		closeWithPolicyViolation(conn, options, "authentication required")
+		return
+	}
+
+	claims, deviceID, err := m.verifyToken(r.Context(), payload.Token)
+	if err != nil {
+		response := map[string]interface{}{
+			"type":    "auth_response",
+			"success": false,
+			"error":   "invalid_token",
+		}
+
+		if errors.Is(err, errTokenExpired) {
+			response["error"] = "token_expired"
+		}
+
+		rejectAuth(conn, codec, options, response, err.Error())
+		return
+	}
+
+	if !m.hub.canRegister(deviceID, userKey(claims.Tenant(), claims.UserID)) {
+		metrics.WebSocketConnectionsRejected.WithLabelValues("connection_limit").Inc()
+		rejectAuth(conn, codec, options, map[string]interface{}{
+			"type":    "auth_response",
+			"success": false,
+			"error":   "connection_limit",
+		}, "too many connections")
+		return
+	}
+
+	client, err := m.startClient(conn, r, codec, claims, deviceID, payload.Token)
+	if err != nil {
+		return
+	}
+
+	response := map[string]interface{}{
+		"type":    "auth_response",
+		"success": true,
+	}
+	if expiresAt := client.TokenExpiresAt(); !expiresAt.IsZero() {
+		response["expires_at"] = expiresAt.Format(time.RFC3339)
+	}
+	respJSON, _ := json.Marshal(response)
+	client.Send(respJSON)
+}
+
+// rejectAuth envía la respuesta de un auth fallido y cierra la conexión con
+// el código 1008
+func rejectAuth(conn *websocket.Conn, codec FrameCodec, options ClientOptions, response map[string]interface{}, reason string) {
+	respJSON, _ := json.Marshal(response)
+	if frame, err := codec.Encode(respJSON); err == nil {
+		conn.SetWriteDeadline(time.Now().Add(options.WriteWait))
+		conn.WriteMessage(codec.MessageType(), frame)
+	}
+
+	closeWithPolicyViolation(conn, options, reason)
+}
+
+// closeWithPolicyViolation cierra una conexión no autenticada con el código 1008
+func closeWithPolicyViolation(conn *websocket.Conn, options ClientOptions, reason string) {
+	conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason),
+		time.Now().Add(options.WriteWait),
+	)
+	conn.Close()
+}
@@ -1,27 +1,47 @@
 package websocket
 
 import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
 	"sync"
+	"time"
+
+	"notification-service/pkg/events"
+	"notification-service/pkg/metrics"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Tiempo máximo para guardar un mensaje en la cola persistente
+	spillTimeout = 5 * time.Second
+
+	// Intervalo con el que se comprueba si las colas de salida se vaciaron
+	drainPollInterval = 50 * time.Millisecond
+
+	// Retraso mínimo de reconexión que se sugiere a los clientes al cerrar
+	minReconnectAfter = time.Second
 )
 
-// Hub mantiene el seguimiento de todas las conexiones activas
+// Hub mantiene el seguimiento de todas las conexiones activas.
+//
+// El registro y la baja de clientes se hacen directamente bajo un único
+// mutex, por lo que pueden invocarse desde cualquier goroutine (incluidos
+// los envíos que detectan un cliente lento) sin depender del bucle Run.
 type Hub struct {
+	// Mutex que protege los mapas de clientes
+	mu sync.RWMutex
+
 	// Clientes registrados
-	clients map[*Client]bool
+	clients map[Connection]bool
 
 	// Mapeo de deviceID a clientes
-	deviceClients map[uuid.UUID]map[*Client]bool
-
-	// Mapeo de userID a clientes
-	userClients map[string]map[*Client]bool
-
-	// Canal para registrar nuevos clientes
-	register chan *Client
+	deviceClients map[uuid.UUID]map[Connection]bool
 
-	// Canal para dar de baja clientes
-	unregister chan *Client
+	// Mapeo de usuario (userKey) a clientes
+	userClients map[string]map[Connection]bool
 
 	// Canal para enviar mensajes a todos los clientes
 	broadcast chan []byte
@@ -29,21 +49,24 @@ type Hub struct {
 	// Canal para cerrar el hub
 	shutdown chan struct{}
 
-	// Mutex para deviceClients
-	deviceMutex sync.RWMutex
+	// Gestor de eventos para publicar cambios de presencia (opcional)
+	eventManager *events.EventManager
 
-	// Mutex para userClients
-	userMutex sync.RWMutex
+	// Cola persistente para los mensajes descartados (opcional)
+	spill SpillQueue
+
+	// Máximos de conexiones por dispositivo y por usuario
+	limits connectionLimits
 }
 
 // NewHub crea un nuevo hub
-func NewHub() *Hub {
+func NewHub(eventManager *events.EventManager, spill SpillQueue) *Hub {
 	return &Hub{
-		clients:       make(map[*Client]bool),
-		deviceClients: make(map[uuid.UUID]map[*Client]bool),
-		userClients:   make(map[string]map[*Client]bool),
-		register:      make(chan *Client),
-		unregister:    make(chan *Client),
+		eventManager:  eventManager,
+		spill:         spill,
+		clients:       make(map[Connection]bool),
+		deviceClients: make(map[uuid.UUID]map[Connection]bool),
+		userClients:   make(map[string]map[Connection]bool),
 		broadcast:     make(chan []byte),
 		shutdown:      make(chan struct{}),
 	}
@@ -53,146 +76,376 @@ func NewHub() *Hub {
 func (h *Hub) Run() {
 	for {
 		select {
-		case client := <-h.register:
-			h.registerClient(client)
-
-		case client := <-h.unregister:
-			h.unregisterClient(client)
-
 		case message := <-h.broadcast:
 			// Enviar mensajes a todos los clientes conectados
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					h.unregisterClient(client)
-				}
+			for _, client := range h.snapshot() {
+				h.deliver(client, message)
 			}
 
 		case <-h.shutdown:
 			// Cerrar todas las conexiones
-			for client := range h.clients {
-				h.unregisterClient(client)
-				close(client.send)
+			for _, client := range h.snapshot() {
+				h.unregister(client, DisconnectServerShutdown, 0, "")
 			}
 			return
 		}
 	}
 }
 
-// registerClient registra un cliente en el hub
-func (h *Hub) registerClient(client *Client) {
+// userKey devuelve la clave de un usuario en el hub. Los IDs de usuario
+// solo son únicos dentro de su tenant. Vacía si no hay usuario.
+func userKey(tenantID, userID string) string {
+	if userID == "" {
+		return ""
+	}
+	return tenantID + "/" + userID
+}
+
+// Register registra un cliente en el hub. Si el dispositivo o el usuario ya
+// tiene el máximo de conexiones, según la política configurada devuelve
+// ErrConnectionLimit o cierra las conexiones más antiguas.
+func (h *Hub) Register(client Connection) error {
+	h.mu.Lock()
+
+	var replaced []Connection
+	user := userKey(client.TenantID(), client.UserID())
+	if h.exceedsLimits(client.DeviceID(), user) {
+		if h.limits.policy != ConnectionLimitKickOldest {
+			h.mu.Unlock()
+			metrics.WebSocketConnectionsRejected.WithLabelValues("connection_limit").Inc()
+			return ErrConnectionLimit
+		}
+		replaced = h.oldestToReplace(client.DeviceID(), user)
+	}
+
 	// Registrar en el mapa general de clientes
 	h.clients[client] = true
 
 	// Registrar por deviceID
-	h.deviceMutex.Lock()
-	if _, ok := h.deviceClients[client.deviceID]; !ok {
-		h.deviceClients[client.deviceID] = make(map[*Client]bool)
-	}
-	h.deviceClients[client.deviceID][client] = true
-	h.deviceMutex.Unlock()
-
-	// Registrar por userID si está disponible
-	if client.userID != "" {
-		h.userMutex.Lock()
-		if _, ok := h.userClients[client.userID]; !ok {
-			h.userClients[client.userID] = make(map[*Client]bool)
+	if _, ok := h.deviceClients[client.DeviceID()]; !ok {
+		h.deviceClients[client.DeviceID()] = make(map[Connection]bool)
+	}
+	h.deviceClients[client.DeviceID()][client] = true
+
+	// Registrar por usuario de su tenant si está disponible
+	if user != "" {
+		if _, ok := h.userClients[user]; !ok {
+			h.userClients[user] = make(map[Connection]bool)
 		}
-		h.userClients[client.userID][client] = true
-		h.userMutex.Unlock()
+		h.userClients[user][client] = true
 	}
+
+	data := h.presenceData(client)
+	h.mu.Unlock()
+
+	for _, old := range replaced {
+		h.replaceSession(old)
+	}
+
+	metrics.WebSocketConnections.Inc()
+	h.publishPresence(events.EventClientConnected, data)
+	return nil
 }
 
-// unregisterClient elimina un cliente del hub
-// unregisterClient elimina un cliente del hub
-func (h *Hub) unregisterClient(client *Client) {
+// Unregister elimina del hub un cliente que cerró la conexión y cierra su
+// cola de salida. Es idempotente: si el cliente ya no estaba registrado no
+// hace nada.
+func (h *Hub) Unregister(client Connection) {
+	h.unregister(client, DisconnectClientClosed, 0, "")
+}
+
+// unregister elimina un cliente del hub y cierra su cola de salida con el
+// código y motivo de cierre indicados (código 0 para un cierre sin estado).
+// reason se publica en el evento client.disconnected; si el cliente ya se
+// había dado de baja, prevalece el motivo de la primera baja.
+func (h *Hub) unregister(client Connection, reason DisconnectReason, closeCode int, closeReason string) {
+	h.mu.Lock()
+
 	// Eliminar del mapa general de clientes
-	if _, ok := h.clients[client]; ok {
-		delete(h.clients, client)
+	if _, ok := h.clients[client]; !ok {
+		h.mu.Unlock()
+		return
 	}
+	delete(h.clients, client)
 
 	// Eliminar del mapa deviceClients
-	h.deviceMutex.Lock()
-	if clients, ok := h.deviceClients[client.deviceID]; ok {
+	if clients, ok := h.deviceClients[client.DeviceID()]; ok {
 		delete(clients, client)
 		if len(clients) == 0 {
-			delete(h.deviceClients, client.deviceID)
+			delete(h.deviceClients, client.DeviceID())
 		}
 	}
-	h.deviceMutex.Unlock()
 
 	// Eliminar del mapa userClients
-	if client.userID != "" {
-		h.userMutex.Lock()
-		if clients, ok := h.userClients[client.userID]; ok {
+	if user := userKey(client.TenantID(), client.UserID()); user != "" {
+		if clients, ok := h.userClients[user]; ok {
 			delete(clients, client)
 			if len(clients) == 0 {
-				delete(h.userClients, client.userID)
+				delete(h.userClients, user)
 			}
 		}
-		h.userMutex.Unlock()
 	}
 
-	// Cerrar el canal del cliente
-	close(client.send)
+	data := h.presenceData(client)
+	data["reason"] = string(reason)
+	h.mu.Unlock()
+
+	// Cerrar la cola de salida del cliente
+	client.queue().closeWith(closeCode, closeReason)
+
+	metrics.WebSocketConnections.Dec()
+	h.publishPresence(events.EventClientDisconnected, data)
 }
 
-// SendToDevice envía un mensaje a todos los clientes de un dispositivo específico
-// SendToDevice envía un mensaje a todos los clientes de un dispositivo específico
-func (h *Hub) SendToDevice(deviceID uuid.UUID, message []byte) bool {
-	h.deviceMutex.RLock()
-	clients, exists := h.deviceClients[deviceID]
-	h.deviceMutex.RUnlock()
+// presenceData construye los datos de un evento de presencia con el estado
+// resultante del dispositivo y del usuario. Debe llamarse con el mutex tomado.
+func (h *Hub) presenceData(client Connection) map[string]interface{} {
+	deviceConnections := len(h.deviceClients[client.DeviceID()])
 
-	if !exists || len(clients) == 0 {
-		return false
+	data := map[string]interface{}{
+		"device_id":          client.DeviceID().String(),
+		"transport":          string(client.Transport()),
+		"device_connections": deviceConnections,
+		"device_online":      deviceConnections > 0,
 	}
 
-	sentToAny := false
-	for client := range clients {
+	data["tenant_id"] = client.TenantID()
+
+	if client.UserID() != "" {
+		userConnections := len(h.userClients[userKey(client.TenantID(), client.UserID())])
+
+		data["user_id"] = client.UserID()
+		data["user_connections"] = userConnections
+		data["user_online"] = userConnections > 0
+	}
+
+	return data
+}
+
+// publishPresence publica un cambio de presencia
+func (h *Hub) publishPresence(eventType events.EventType, data map[string]interface{}) {
+	if h.eventManager == nil {
+		return
+	}
+
+	h.eventManager.EmitEvent(eventType, data)
+}
+
+// deliver encola un mensaje en un cliente aplicando su política de
+// consumidores lentos y devuelve el resultado. Los mensajes descartados que
+// pertenecen a una notificación se guardan en la cola persistente; cuando se
+// desconecta a un cliente lento (pushFull), también los que seguían en su
+// cola y el que no cabe. Con la cola ya cerrada (pushClosed) el mensaje no
+// se guarda.
+func (h *Hub) deliver(client Connection, message []byte) pushResult {
+	result, dropped := client.queue().push(message)
+
+	switch result {
+	case pushDroppedOldest:
+		metrics.WebSocketFramesDropped.WithLabelValues(string(client.Transport()), string(SlowConsumerDropOldest)).Inc()
+		h.spillMessage(client.DeviceID(), dropped)
+
+	case pushFull:
+		// El cliente no consume lo bastante rápido: se desconecta y lo que
+		// tenía en cola, más el mensaje, se guarda para cuando vuelva
+		metrics.WebSocketFramesDropped.WithLabelValues(string(client.Transport()), string(SlowConsumerDisconnect)).Inc()
+		h.unregister(client, DisconnectSlowConsumer, 0, "")
+		client.Close()
+		h.spillMessages(client.DeviceID(), append(client.queue().takeRemaining(), message))
+	}
+
+	return result
+}
+
+// spillMessage guarda en la cola persistente un mensaje descartado. Solo se
+// guardan los mensajes de notificaciones; el resto (pong, respuestas) no
+// tiene sentido reenviarlos más tarde.
+func (h *Hub) spillMessage(deviceID uuid.UUID, message []byte) {
+	h.spillMessages(deviceID, [][]byte{message})
+}
+
+// spillMessages guarda en la cola persistente, en orden, los mensajes de
+// notificaciones de una lista
+func (h *Hub) spillMessages(deviceID uuid.UUID, messages [][]byte) {
+	if h.spill == nil {
+		return
+	}
+
+	go func() {
+		for _, message := range messages {
+			if notificationID, ok := messageNotificationID(message); ok {
+				h.storeSpilled(notificationID, deviceID, message)
+			}
+		}
+	}()
+}
+
+// storeSpilled guarda un mensaje en la cola persistente
+func (h *Hub) storeSpilled(notificationID, deviceID uuid.UUID, message []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), spillTimeout)
+	defer cancel()
+
+	if err := h.spill.Push(ctx, notificationID, deviceID, message); err != nil {
+		metrics.WebSocketFramesSpilled.WithLabelValues("error").Inc()
+		return
+	}
+	metrics.WebSocketFramesSpilled.WithLabelValues("success").Inc()
+}
+
+// replaySpilled reenvía a un cliente recién conectado los mensajes que se
+// guardaron en la cola persistente mientras su dispositivo no podía recibirlos
+func (h *Hub) replaySpilled(client Connection, limit int) {
+	if h.spill == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), spillTimeout)
+	defer cancel()
+
+	messages, err := h.spill.Pop(ctx, client.DeviceID(), limit)
+	if err != nil {
+		return
+	}
+
+	for _, message := range messages {
+		// Si el cliente se desconectó, devolver el resto a la cola; con
+		// pushFull deliver ya guardó el mensaje
+		if h.deliver(client, message) == pushClosed {
+			h.spillMessage(client.DeviceID(), message)
+		}
+	}
+}
+
+// Drain cierra ordenadamente todas las conexiones: avisa a cada cliente con
+// un frame server_going_away, espera a que vacíen su cola de salida y las
+// cierra con el código 1001. Los mensajes que siguen en cola cuando vence
+// ctx se guardan en la cola persistente.
+//
+// Cada cliente recibe un reconnect_after aleatorio dentro de
+// reconnectWindow para que no se reconecten todos a la vez.
+func (h *Hub) Drain(ctx context.Context, reconnectWindow time.Duration) {
+	clients := h.snapshot()
+
+	for _, client := range clients {
+		h.deliver(client, goingAwayMessage(reconnectWindow))
+	}
+
+	// Esperar a que los escritores vacíen las colas
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+wait:
+	for !queuesFlushed(clients) {
 		select {
-		case client.send <- message:
-			sentToAny = true
-		default:
-			// Si el buffer está lleno, desregistramos el cliente
-			// Usando una función anónima con go para enviar al canal
-			go func(c *Client) {
-				h.unregister <- c
-			}(client)
+		case <-ticker.C:
+		case <-ctx.Done():
+			break wait
 		}
 	}
 
-	return sentToAny
+	for _, client := range clients {
+		h.unregister(client, DisconnectServerShutdown, websocket.CloseGoingAway, "server shutting down")
+
+		// Lo que no se llegó a enviar se guarda para la próxima conexión
+		for _, message := range client.queue().takeRemaining() {
+			if h.spill == nil {
+				break
+			}
+			if notificationID, ok := messageNotificationID(message); ok {
+				h.storeSpilled(notificationID, client.DeviceID(), message)
+			}
+		}
+	}
+}
+
+// queuesFlushed indica si las colas de salida de los clientes están vacías
+func queuesFlushed(clients []Connection) bool {
+	for _, client := range clients {
+		if client.queue().pending() > 0 {
+			return false
+		}
+	}
+	return true
 }
 
-// SendToUser envía un mensaje a todos los clientes de un usuario específico
-// SendToUser envía un mensaje a todos los clientes de un usuario específico
-func (h *Hub) SendToUser(userID string, message []byte) bool {
-	h.userMutex.RLock()
-	clients, exists := h.userClients[userID]
-	h.userMutex.RUnlock()
+// goingAwayMessage construye el aviso de cierre del servidor con un retraso
+// de reconexión aleatorio (en milisegundos)
+func goingAwayMessage(reconnectWindow time.Duration) []byte {
+	reconnectAfter := minReconnectAfter
+	if reconnectWindow > minReconnectAfter {
+		reconnectAfter += rand.N(reconnectWindow - minReconnectAfter)
+	}
+
+	message, _ := json.Marshal(map[string]interface{}{
+		"type":            "server_going_away",
+		"reconnect_after": reconnectAfter.Milliseconds(),
+		"timestamp":       time.Now().Format(time.RFC3339),
+	})
+	return message
+}
+
+// snapshot devuelve una copia de los clientes registrados
+func (h *Hub) snapshot() []Connection {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]Connection, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+
+	return clients
+}
 
-	if !exists || len(clients) == 0 {
-		return false
+// SendToDevice envía un mensaje a todos los clientes de un dispositivo específico
+func (h *Hub) SendToDevice(deviceID uuid.UUID, message []byte) bool {
+	sentToAny := false
+	for _, client := range h.GetDeviceConnections(deviceID) {
+		if h.deliver(client, message).queued() {
+			sentToAny = true
+		}
 	}
 
+	return sentToAny
+}
+
+// SendToUser envía un mensaje a todos los clientes de un usuario de un tenant
+func (h *Hub) SendToUser(tenantID, userID string, message []byte) bool {
 	sentToAny := false
-	for client := range clients {
-		select {
-		case client.send <- message:
+	for _, client := range h.GetUserConnections(tenantID, userID) {
+		if h.deliver(client, message).queued() {
 			sentToAny = true
-		default:
-			// Si el buffer está lleno, desregistramos el cliente
-			go func(c *Client) {
-				h.unregister <- c
-			}(client)
 		}
 	}
 
 	return sentToAny
 }
 
+// DisconnectSession avisa a las conexiones autenticadas con el token de una
+// sesión revocada y las cierra. Devuelve cuántas conexiones se cerraron.
+func (h *Hub) DisconnectSession(sessionID string) int {
+	if sessionID == "" {
+		return 0
+	}
+
+	message, _ := json.Marshal(map[string]interface{}{
+		"type":      "session_revoked",
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+
+	closed := 0
+	for _, client := range h.snapshot() {
+		if client.SessionID() != sessionID {
+			continue
+		}
+		h.deliver(client, message)
+		h.unregister(client, DisconnectRevoked, websocket.ClosePolicyViolation, "session revoked")
+		closed++
+	}
+
+	return closed
+}
+
 // BroadcastAll envía un mensaje a todos los clientes conectados
 func (h *Hub) BroadcastAll(message []byte) {
 	h.broadcast <- message
@@ -200,26 +453,25 @@ func (h *Hub) BroadcastAll(message []byte) {
 
 // IsDeviceConnected verifica si un dispositivo tiene alguna conexión activa
 func (h *Hub) IsDeviceConnected(deviceID uuid.UUID) bool {
-	h.deviceMutex.RLock()
-	clients, exists := h.deviceClients[deviceID]
-	h.deviceMutex.RUnlock()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-	return exists && len(clients) > 0
+	return len(h.deviceClients[deviceID]) > 0
 }
 
-// IsUserConnected verifica si un usuario tiene alguna conexión activa
-func (h *Hub) IsUserConnected(userID string) bool {
-	h.userMutex.RLock()
-	clients, exists := h.userClients[userID]
-	h.userMutex.RUnlock()
+// IsUserConnected verifica si un usuario de un tenant tiene alguna conexión
+// activa
+func (h *Hub) IsUserConnected(tenantID, userID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-	return exists && len(clients) > 0
+	return len(h.userClients[userKey(tenantID, userID)]) > 0
 }
 
 // GetConnectedDevices devuelve una lista de todos los dispositivos conectados
 func (h *Hub) GetConnectedDevices() []uuid.UUID {
-	h.deviceMutex.RLock()
-	defer h.deviceMutex.RUnlock()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
 	devices := make([]uuid.UUID, 0, len(h.deviceClients))
 	for deviceID := range h.deviceClients {
@@ -229,10 +481,11 @@ func (h *Hub) GetConnectedDevices() []uuid.UUID {
 	return devices
 }
 
-// GetConnectedUsers devuelve una lista de todos los usuarios conectados
+// GetConnectedUsers devuelve una lista de todos los usuarios conectados, en
+// la forma tenant/usuario
 func (h *Hub) GetConnectedUsers() []string {
-	h.userMutex.RLock()
-	defer h.userMutex.RUnlock()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
 	users := make([]string, 0, len(h.userClients))
 	for userID := range h.userClients {
@@ -242,8 +495,39 @@ func (h *Hub) GetConnectedUsers() []string {
 	return users
 }
 
+// GetUserConnections devuelve una copia de las conexiones activas de un
+// usuario de un tenant
+func (h *Hub) GetUserConnections(tenantID, userID string) []Connection {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	user := userKey(tenantID, userID)
+	connections := make([]Connection, 0, len(h.userClients[user]))
+	for client := range h.userClients[user] {
+		connections = append(connections, client)
+	}
+
+	return connections
+}
+
+// GetDeviceConnections devuelve una copia de las conexiones activas de un dispositivo
+func (h *Hub) GetDeviceConnections(deviceID uuid.UUID) []Connection {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	connections := make([]Connection, 0, len(h.deviceClients[deviceID]))
+	for client := range h.deviceClients[deviceID] {
+		connections = append(connections, client)
+	}
+
+	return connections
+}
+
 // GetClientCount devuelve el número total de clientes conectados
 func (h *Hub) GetClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
 	return len(h.clients)
 }
 
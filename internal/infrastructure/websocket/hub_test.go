@@ -0,0 +1,279 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testConn es una conexión sin transporte que solo tiene su cola de salida
+type testConn struct {
+	hub      *Hub
+	tenantID string
+	userID   string
+	deviceID uuid.UUID
+	q        *sendQueue
+	activity activityClock
+	closed   atomic.Bool
+}
+
+func newTestConn(hub *Hub, options ClientOptions) *testConn {
+	c := &testConn{
+		hub:      hub,
+		tenantID: "default",
+		deviceID: uuid.New(),
+		q:        newSendQueue(options),
+	}
+	c.activity.touch()
+	return c
+}
+
+func (c *testConn) TenantID() string           { return c.tenantID }
+func (c *testConn) UserID() string             { return c.userID }
+func (c *testConn) DeviceID() uuid.UUID        { return c.deviceID }
+func (c *testConn) DeviceIdentifier() string   { return c.deviceID.String() }
+func (c *testConn) SessionID() string          { return "" }
+func (c *testConn) Transport() TransportType   { return TransportWebSocket }
+func (c *testConn) Send(message []byte) bool   { return c.hub.deliver(c, message).queued() }
+func (c *testConn) Close()                     { c.closed.Store(true) }
+func (c *testConn) GetLastActivity() time.Time { return c.activity.last() }
+func (c *testConn) ConnectedAt() time.Time     { return time.Time{} }
+func (c *testConn) queue() *sendQueue          { return c.q }
+
+// testSpill es una cola persistente en memoria
+type testSpill struct {
+	mu      sync.Mutex
+	pushed  map[uuid.UUID]int
+	pending [][]byte
+}
+
+func newTestSpill(pending ...[]byte) *testSpill {
+	return &testSpill{pushed: make(map[uuid.UUID]int), pending: pending}
+}
+
+func (s *testSpill) Push(ctx context.Context, notificationID, deviceID uuid.UUID, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pushed[notificationID]++
+	return nil
+}
+
+func (s *testSpill) Pop(ctx context.Context, deviceID uuid.UUID, limit int) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := s.pending
+	s.pending = nil
+	return messages, nil
+}
+
+// counts devuelve cuántas veces se guardó cada notificación
+func (s *testSpill) counts() map[uuid.UUID]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[uuid.UUID]int, len(s.pushed))
+	for id, n := range s.pushed {
+		counts[id] = n
+	}
+	return counts
+}
+
+// total devuelve cuántos mensajes se guardaron
+func (s *testSpill) total() int {
+	total := 0
+	for _, n := range s.counts() {
+		total += n
+	}
+	return total
+}
+
+// waitForSpill espera a que la cola persistente reciba want mensajes; los
+// guardados se hacen en goroutines
+func waitForSpill(t *testing.T, spill *testSpill, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for spill.total() < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("spilled %d messages, want %d", spill.total(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Dar tiempo a guardados de más, que serían un error
+	time.Sleep(20 * time.Millisecond)
+}
+
+// notificationMessage construye el mensaje de una notificación
+func notificationMessage(t *testing.T) (uuid.UUID, []byte) {
+	t.Helper()
+	id := uuid.New()
+	message, err := json.Marshal(map[string]interface{}{
+		"type":            "notification",
+		"notification_id": id.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, message
+}
+
+func TestHubDeliverDropOldest(t *testing.T) {
+	spill := newTestSpill()
+	hub := NewHub(nil, spill)
+	client := newTestConn(hub, ClientOptions{SendBufferSize: 2, SlowConsumerPolicy: SlowConsumerDropOldest})
+	if err := hub.Register(client); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		id, message := notificationMessage(t)
+		ids = append(ids, id)
+		if result := hub.deliver(client, message); !result.queued() {
+			t.Fatalf("message %d: result = %v, want queued", i, result)
+		}
+	}
+
+	if pending := client.q.pending(); pending != 2 {
+		t.Errorf("pending = %d, want 2", pending)
+	}
+
+	waitForSpill(t, spill, 1)
+	if counts := spill.counts(); counts[ids[0]] != 1 || len(counts) != 1 {
+		t.Errorf("spilled = %v, want only the oldest message once", counts)
+	}
+	if hub.GetClientCount() != 1 {
+		t.Error("drop_oldest must not disconnect the client")
+	}
+}
+
+func TestHubDeliverDisconnectSlowConsumer(t *testing.T) {
+	spill := newTestSpill()
+	hub := NewHub(nil, spill)
+	client := newTestConn(hub, ClientOptions{SendBufferSize: 3, SlowConsumerPolicy: SlowConsumerDisconnect})
+	if err := hub.Register(client); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tres notificaciones llenan la cola; la cuarta desconecta al cliente
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		id, message := notificationMessage(t)
+		ids = append(ids, id)
+		if result := hub.deliver(client, message); result != pushQueued {
+			t.Fatalf("message %d: result = %v, want pushQueued", i, result)
+		}
+	}
+	// Un pong en cola no se guarda
+	if result := hub.deliver(client, []byte(`{"type":"pong"}`)); result != pushFull {
+		t.Fatalf("pong result = %v, want pushFull", result)
+	}
+
+	if hub.GetClientCount() != 0 {
+		t.Error("slow consumer still registered")
+	}
+	if !client.closed.Load() {
+		t.Error("slow consumer not closed")
+	}
+
+	// Con la cola cerrada el mensaje ya no se guarda
+	_, late := notificationMessage(t)
+	if result := hub.deliver(client, late); result != pushClosed {
+		t.Fatalf("late result = %v, want pushClosed", result)
+	}
+
+	// Todas las notificaciones que seguían en cola se guardan una vez
+	waitForSpill(t, spill, len(ids))
+	counts := spill.counts()
+	if len(counts) != len(ids) {
+		t.Errorf("spilled = %v, want the %d queued notifications", counts, len(ids))
+	}
+	for _, id := range ids {
+		if counts[id] != 1 {
+			t.Errorf("queued notification %s spilled %d times, want 1", id, counts[id])
+		}
+	}
+}
+
+func TestHubReplaySpilledDoesNotDuplicate(t *testing.T) {
+	var ids []uuid.UUID
+	var messages [][]byte
+	for i := 0; i < 4; i++ {
+		id, message := notificationMessage(t)
+		ids = append(ids, id)
+		messages = append(messages, message)
+	}
+
+	spill := newTestSpill(messages...)
+	hub := NewHub(nil, spill)
+	client := newTestConn(hub, ClientOptions{SendBufferSize: 1, SlowConsumerPolicy: SlowConsumerDisconnect})
+	if err := hub.Register(client); err != nil {
+		t.Fatal(err)
+	}
+
+	// El primero cabe, el segundo desconecta al cliente y se guarda con el
+	// primero, que no llegó a enviarse; el resto se devuelve a la cola
+	hub.replaySpilled(client, len(messages))
+
+	waitForSpill(t, spill, len(ids))
+	counts := spill.counts()
+	for _, id := range ids {
+		if counts[id] != 1 {
+			t.Errorf("message %s spilled %d times, want 1", id, counts[id])
+		}
+	}
+}
+
+func TestHubConcurrentDeliverAndUnregister(t *testing.T) {
+	hub := NewHub(nil, newTestSpill())
+
+	var clients []*testConn
+	for i := 0; i < 8; i++ {
+		client := newTestConn(hub, ClientOptions{SendBufferSize: 4})
+		if err := hub.Register(client); err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, client)
+	}
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		// Un escritor que consume la cola, como writePump
+		wg.Add(1)
+		go func(c *testConn) {
+			defer wg.Done()
+			for range c.q.ch {
+			}
+		}(client)
+
+		// Varios emisores a la vez
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func(c *testConn) {
+				defer wg.Done()
+				for k := 0; k < 100; k++ {
+					hub.SendToDevice(c.deviceID, []byte(`{"type":"ping"}`))
+				}
+			}(client)
+		}
+	}
+
+	// Bajas concurrentes con los envíos
+	for _, client := range clients {
+		wg.Add(1)
+		go func(c *testConn) {
+			defer wg.Done()
+			time.Sleep(time.Millisecond)
+			hub.Unregister(c)
+		}(client)
+	}
+
+	wg.Wait()
+
+	if count := hub.GetClientCount(); count != 0 {
+		t.Errorf("client count = %d, want 0", count)
+	}
+}
@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"notification-service/pkg/metrics"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// ConnectionLimitPolicy define qué hacer cuando un dispositivo o un usuario
+// ya tiene el máximo de conexiones simultáneas
+type ConnectionLimitPolicy string
+
+const (
+	// Rechazar la conexión nueva
+	ConnectionLimitReject ConnectionLimitPolicy = "reject"
+
+	// Aceptar la conexión nueva y cerrar las más antiguas, que reciben antes
+	// un frame session_replaced
+	ConnectionLimitKickOldest ConnectionLimitPolicy = "kick_oldest"
+)
+
+// ErrConnectionLimit indica que el dispositivo o el usuario ya tiene el
+// máximo de conexiones permitido
+var ErrConnectionLimit = errors.New("connection limit reached")
+
+// ConnectionThrottler limita la tasa de conexiones nuevas por clave; el
+// gestor usa la IP del cliente. throttling.UserThrottler la implementa.
+type ConnectionThrottler interface {
+	Allow(key string) bool
+}
+
+// connectionLimits son los máximos de conexiones simultáneas del hub; cero
+// significa sin límite
+type connectionLimits struct {
+	perDevice int
+	perUser   int
+	policy    ConnectionLimitPolicy
+}
+
+// setLimits configura los máximos de conexiones del hub. Debe llamarse antes
+// de registrar clientes.
+func (h *Hub) setLimits(perDevice, perUser int, policy ConnectionLimitPolicy) {
+	h.limits = connectionLimits{
+		perDevice: perDevice,
+		perUser:   perUser,
+		policy:    policy,
+	}
+}
+
+// exceedsLimits indica si una conexión más del dispositivo o del usuario
+// superaría los máximos. Las conexiones con token temporal no tienen
+// deviceID y no se limitan por dispositivo. Debe llamarse con el mutex tomado.
+func (h *Hub) exceedsLimits(deviceID uuid.UUID, user string) bool {
+	if deviceID != uuid.Nil && h.limits.perDevice > 0 && len(h.deviceClients[deviceID]) >= h.limits.perDevice {
+		return true
+	}
+	return user != "" && h.limits.perUser > 0 && len(h.userClients[user]) >= h.limits.perUser
+}
+
+// canRegister indica si el dispositivo puede abrir otra conexión. Permite
+// rechazar la petición antes del handshake; Register vuelve a comprobarlo.
+// user es la clave del usuario en su tenant (userKey).
+func (h *Hub) canRegister(deviceID uuid.UUID, user string) bool {
+	if h.limits.policy == ConnectionLimitKickOldest {
+		return true
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return !h.exceedsLimits(deviceID, user)
+}
+
+// oldestToReplace elige las conexiones más antiguas que hay que cerrar para
+// que una conexión nueva del dispositivo y del usuario quepa en los límites.
+// Debe llamarse con el mutex tomado.
+func (h *Hub) oldestToReplace(deviceID uuid.UUID, user string) []Connection {
+	var replaced []Connection
+	selected := make(map[Connection]bool)
+
+	pick := func(connections map[Connection]bool, limit int) {
+		if limit <= 0 {
+			return
+		}
+
+		remaining := make([]Connection, 0, len(connections))
+		for client := range connections {
+			if !selected[client] {
+				remaining = append(remaining, client)
+			}
+		}
+		if len(remaining) < limit {
+			return
+		}
+
+		sort.Slice(remaining, func(i, j int) bool {
+			return remaining[i].ConnectedAt().Before(remaining[j].ConnectedAt())
+		})
+		for _, client := range remaining[:len(remaining)-limit+1] {
+			selected[client] = true
+			replaced = append(replaced, client)
+		}
+	}
+
+	if deviceID != uuid.Nil {
+		pick(h.deviceClients[deviceID], h.limits.perDevice)
+	}
+	if user != "" {
+		pick(h.userClients[user], h.limits.perUser)
+	}
+
+	return replaced
+}
+
+// replaceSession avisa a una conexión de que otra más reciente la sustituye
+// y la cierra cuando haya enviado el aviso
+func (h *Hub) replaceSession(client Connection) {
+	message, _ := json.Marshal(map[string]interface{}{
+		"type":      "session_replaced",
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+	h.deliver(client, message)
+
+	metrics.WebSocketConnectionsRejected.WithLabelValues("replaced").Inc()
+	h.unregister(client, DisconnectReplaced, websocket.CloseNormalClosure, "session replaced")
+}
+
+// clientIP obtiene la IP del cliente de una petición. X-Forwarded-For solo
+// se usa si el servidor está detrás de un proxy de confianza.
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
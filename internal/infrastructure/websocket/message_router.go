@@ -0,0 +1,144 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MessageHandler procesa un mensaje entrante de un tipo concreto. Si devuelve
+// una respuesta, se envía al cliente con el message_id del mensaje original
+// para que pueda correlacionarla. Un *MessageError se envía como frame de
+// error con su código.
+type MessageHandler func(ctx context.Context, client *Client, msg *ClientMessage) (map[string]interface{}, error)
+
+// MessageRoute describe cómo se procesa un tipo de mensaje entrante
+type MessageRoute struct {
+	Handler MessageHandler
+
+	// El mensaje actúa en nombre de un usuario: no se acepta con tokens
+	// temporales (dispositivos sin usuario vinculado)
+	RequiresPermanentToken bool
+
+	// Procesar el mensaje en su propia goroutine, para handlers que hacen
+	// llamadas remotas y no deben bloquear la lectura de la conexión
+	Async bool
+
+	// Tiempo máximo de proceso; cero para no limitarlo
+	Timeout time.Duration
+}
+
+// MessageError es un error de un mensaje que se comunica al cliente. Err
+// guarda la causa interna, que no se envía al cliente.
+type MessageError struct {
+	Code string
+	Err  error
+}
+
+func (e *MessageError) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Err.Error()
+	}
+	return e.Code
+}
+
+func (e *MessageError) Unwrap() error {
+	return e.Err
+}
+
+// NewMessageError crea un error de mensaje con el código indicado
+func NewMessageError(code string) *MessageError {
+	return &MessageError{Code: code}
+}
+
+// Códigos de error de los mensajes entrantes
+const (
+	errCodeUnsupportedType = "unsupported_message_type"
+	errCodeForbidden       = "forbidden"
+	errCodeInvalidPayload  = "invalid_payload"
+	errCodeInternal        = "internal_error"
+)
+
+// MessageRouter es el registro de handlers de mensajes entrantes. Los tipos
+// que no están registrados se rechazan.
+type MessageRouter struct {
+	mu     sync.RWMutex
+	routes map[string]MessageRoute
+}
+
+// NewMessageRouter crea un registro de handlers vacío
+func NewMessageRouter() *MessageRouter {
+	return &MessageRouter{
+		routes: make(map[string]MessageRoute),
+	}
+}
+
+// Register asocia un tipo de mensaje a su handler; sustituye el anterior si
+// ya existía
+func (r *MessageRouter) Register(messageType string, route MessageRoute) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes[messageType] = route
+}
+
+// Dispatch comprueba la política del tipo de mensaje y lo entrega a su handler
+func (r *MessageRouter) Dispatch(client *Client, msg *ClientMessage) {
+	r.mu.RLock()
+	route, ok := r.routes[msg.Type]
+	r.mu.RUnlock()
+
+	if !ok {
+		client.sendError(msg, errCodeUnsupportedType)
+		return
+	}
+	if route.RequiresPermanentToken && client.IsTemporary() {
+		client.sendError(msg, errCodeForbidden)
+		return
+	}
+
+	if route.Async {
+		go r.handle(route, client, msg)
+		return
+	}
+	r.handle(route, client, msg)
+}
+
+// handle ejecuta un handler y envía su respuesta o su error al cliente
+func (r *MessageRouter) handle(route MessageRoute, client *Client, msg *ClientMessage) {
+	ctx := context.Background()
+	if route.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, route.Timeout)
+		defer cancel()
+	}
+
+	response, err := route.Handler(ctx, client, msg)
+	if err != nil {
+		var msgErr *MessageError
+		if !errors.As(err, &msgErr) {
+			msgErr = &MessageError{Code: errCodeInternal, Err: err}
+		}
+
+		client.sendError(msg, msgErr.Code)
+		if msgErr.Err != nil && client.connectionHandler != nil {
+			client.connectionHandler.OnError(client, msgErr.Err)
+		}
+		return
+	}
+
+	if response == nil {
+		return
+	}
+	if msg.ID != "" {
+		response["message_id"] = msg.ID
+	}
+
+	respJSON, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	client.Send(respJSON)
+}
@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"notification-service/internal/usecase"
+
+	"github.com/google/uuid"
+)
+
+// newRouterTestClient crea un cliente registrado en el hub autenticado con
+// un token temporal o permanente
+func newRouterTestClient(t *testing.T, hub *Hub, temporary bool) *Client {
+	t.Helper()
+
+	userID := "42"
+	if temporary {
+		userID = ""
+	}
+	client := NewClient(hub, nil, "default", userID, uuid.New(), "device-1", "token", nil, JSONCodec{}, ClientOptions{})
+	client.setAuth("token", &usecase.Claims{UserID: userID, IsTemporary: temporary})
+	if err := hub.Register(client); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// nextMessage devuelve el siguiente mensaje encolado para el cliente
+func nextMessage(t *testing.T, client *Client) map[string]interface{} {
+	t.Helper()
+
+	select {
+	case message := <-client.send.ch:
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(message, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		return decoded
+	default:
+		t.Fatal("no message queued")
+		return nil
+	}
+}
+
+func TestBuiltinRoutesRejectTemporaryTokens(t *testing.T) {
+	handler := &ConnectionHandlerImpl{router: NewMessageRouter()}
+	handler.registerBuiltinRoutes()
+
+	tests := []struct {
+		messageType string
+		wantType    string
+		wantError   string
+	}{
+		{messageType: "ack", wantType: "error", wantError: errCodeForbidden},
+		{messageType: "ping", wantType: "pong"},
+		{messageType: "unknown", wantType: "error", wantError: errCodeUnsupportedType},
+	}
+
+	hub := NewHub(nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.messageType, func(t *testing.T) {
+			client := newRouterTestClient(t, hub, true)
+
+			handler.router.Dispatch(client, &ClientMessage{
+				ID:      "m1",
+				Type:    tt.messageType,
+				Payload: json.RawMessage(`{"notification_id":"` + uuid.NewString() + `"}`),
+			})
+
+			response := nextMessage(t, client)
+			if response["type"] != tt.wantType {
+				t.Fatalf("response = %v, want type %s", response, tt.wantType)
+			}
+			if tt.wantError != "" && response["error"] != tt.wantError {
+				t.Errorf("error = %v, want %s", response["error"], tt.wantError)
+			}
+			if response["message_id"] != "m1" {
+				t.Errorf("message_id = %v, want m1", response["message_id"])
+			}
+		})
+	}
+}
+
+func TestRouterRequiresPermanentToken(t *testing.T) {
+	router := NewMessageRouter()
+	handled := make(chan struct{}, 1)
+	router.Register("privileged", MessageRoute{
+		Handler: func(ctx context.Context, client *Client, msg *ClientMessage) (map[string]interface{}, error) {
+			handled <- struct{}{}
+			return nil, nil
+		},
+		RequiresPermanentToken: true,
+	})
+
+	hub := NewHub(nil, nil)
+
+	temporary := newRouterTestClient(t, hub, true)
+	router.Dispatch(temporary, &ClientMessage{Type: "privileged"})
+	if response := nextMessage(t, temporary); response["error"] != errCodeForbidden {
+		t.Errorf("temporary response = %v, want %s", response, errCodeForbidden)
+	}
+	select {
+	case <-handled:
+		t.Error("handler ran for a temporary token")
+	default:
+	}
+
+	permanent := newRouterTestClient(t, hub, false)
+	router.Dispatch(permanent, &ClientMessage{Type: "privileged"})
+	select {
+	case <-handled:
+	default:
+		t.Error("handler did not run for a permanent token")
+	}
+}
@@ -0,0 +1,214 @@
+package websocket
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Valores por defecto de las conexiones en tiempo real
+const (
+	// Tiempo máximo para escribir un mensaje al cliente
+	defaultWriteWait = 10 * time.Second
+
+	// Tiempo máximo para leer el siguiente pong del cliente
+	defaultPongWait = 60 * time.Second
+
+	// Tamaño máximo del mensaje
+	defaultMaxMessageSize = 4096
+
+	// Tamaño por defecto de la cola de salida de cada conexión
+	defaultSendBufferSize = 256
+
+	// Ventana por defecto en la que se reparten las reconexiones tras un cierre
+	defaultReconnectWindow = 30 * time.Second
+
+	// Antelación por defecto del aviso de expiración del token
+	defaultExpiryWarning = time.Minute
+
+	// Plazo por defecto para recibir el mensaje auth de una conexión sin token
+	defaultAuthTimeout = 5 * time.Second
+
+	// Periodicidad por defecto de la limpieza de conexiones inactivas
+	defaultCleanupInterval = time.Minute
+
+	// Inactividad por defecto tras la que se cierra una conexión
+	defaultInactivityTimeout = 5 * time.Minute
+)
+
+// ClientOptions contiene la configuración de las conexiones en tiempo real
+type ClientOptions struct {
+	// Tiempo máximo para escribir un mensaje al cliente
+	WriteWait time.Duration
+
+	// Tiempo máximo para leer el siguiente pong del cliente
+	PongWait time.Duration
+
+	// Periodicidad de los pings; debe ser menor que PongWait
+	PingInterval time.Duration
+
+	// Tamaño máximo de un mensaje del cliente
+	MaxMessageSize int64
+
+	// Tamaño de la cola de salida de cada conexión
+	SendBufferSize int
+
+	// Política a aplicar cuando la cola de salida está llena
+	SlowConsumerPolicy SlowConsumerPolicy
+
+	// Orígenes permitidos en el handshake. Admite "*" (cualquiera) y
+	// comodines de subdominio ("https://*.example.com"). Vacío solo permite
+	// el mismo origen. Las peticiones sin cabecera Origin (clientes nativos)
+	// siempre se aceptan.
+	AllowedOrigins []string
+
+	// Ajustes por tipo de cliente, indicado con el parámetro client_type
+	Overrides map[string]ClientOverride
+
+	// Ventana en la que se reparten aleatoriamente las reconexiones de los
+	// clientes cuando el servidor se cierra
+	ReconnectWindow time.Duration
+
+	// Antelación con la que se avisa al cliente de que su token va a
+	// expirar; si no lo renueva antes, se cierra la conexión
+	ExpiryWarning time.Duration
+
+	// Plazo para recibir el mensaje auth cuando el handshake no trae token
+	AuthTimeout time.Duration
+
+	// Periodicidad con la que se buscan conexiones inactivas
+	CleanupInterval time.Duration
+
+	// Tiempo sin actividad (mensajes, pongs o escrituras SSE) tras el que
+	// se cierra una conexión. Debe ser mayor que el intervalo de ping más
+	// largo, incluidos los ajustes por tipo de cliente.
+	InactivityTimeout time.Duration
+
+	// Máximo de conexiones simultáneas por dispositivo y por usuario; cero
+	// para no limitarlas
+	MaxConnectionsPerDevice int
+	MaxConnectionsPerUser   int
+
+	// Qué hacer con una conexión que supera los máximos
+	ConnectionLimitPolicy ConnectionLimitPolicy
+
+	// Usar X-Forwarded-For para obtener la IP del cliente al limitar la tasa
+	// de conexiones; solo detrás de un proxy de confianza
+	TrustForwardedFor bool
+}
+
+// ClientOverride ajusta el keepalive de un tipo de cliente; los valores a
+// cero mantienen los generales. Por ejemplo, los clientes móviles usan
+// pings más espaciados para ahorrar batería.
+type ClientOverride struct {
+	PingInterval time.Duration
+	PongWait     time.Duration
+}
+
+// withDefaults completa los valores no configurados
+func (o ClientOptions) withDefaults() ClientOptions {
+	if o.WriteWait <= 0 {
+		o.WriteWait = defaultWriteWait
+	}
+	if o.PongWait <= 0 {
+		o.PongWait = defaultPongWait
+	}
+	if o.PingInterval <= 0 || o.PingInterval >= o.PongWait {
+		o.PingInterval = (o.PongWait * 9) / 10
+	}
+	if o.MaxMessageSize <= 0 {
+		o.MaxMessageSize = defaultMaxMessageSize
+	}
+	if o.SendBufferSize <= 0 {
+		o.SendBufferSize = defaultSendBufferSize
+	}
+	if o.SlowConsumerPolicy != SlowConsumerDisconnect {
+		o.SlowConsumerPolicy = SlowConsumerDropOldest
+	}
+	if o.ReconnectWindow <= 0 {
+		o.ReconnectWindow = defaultReconnectWindow
+	}
+	if o.ExpiryWarning <= 0 {
+		o.ExpiryWarning = defaultExpiryWarning
+	}
+	if o.AuthTimeout <= 0 {
+		o.AuthTimeout = defaultAuthTimeout
+	}
+	if o.CleanupInterval <= 0 {
+		o.CleanupInterval = defaultCleanupInterval
+	}
+	if o.InactivityTimeout <= 0 {
+		o.InactivityTimeout = defaultInactivityTimeout
+	}
+	if o.ConnectionLimitPolicy != ConnectionLimitReject {
+		o.ConnectionLimitPolicy = ConnectionLimitKickOldest
+	}
+	return o
+}
+
+// forClientType devuelve las opciones con los ajustes del tipo de cliente
+func (o ClientOptions) forClientType(clientType string) ClientOptions {
+	override, ok := o.Overrides[clientType]
+	if !ok {
+		return o
+	}
+
+	if override.PongWait > 0 {
+		o.PongWait = override.PongWait
+	}
+	if override.PingInterval > 0 {
+		o.PingInterval = override.PingInterval
+	}
+	if o.PingInterval >= o.PongWait {
+		o.PingInterval = (o.PongWait * 9) / 10
+	}
+	return o
+}
+
+// checkOrigin construye la verificación de origen del handshake a partir
+// de la lista de orígenes permitidos
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+
+		// Sin lista configurada solo se acepta el mismo origen
+		if len(allowed) == 0 {
+			return strings.EqualFold(u.Host, r.Host)
+		}
+
+		for _, pattern := range allowed {
+			if matchOrigin(pattern, u) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matchOrigin verifica si un origen coincide con un patrón de la lista
+func matchOrigin(pattern string, origin *url.URL) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	p, err := url.Parse(pattern)
+	if err != nil || !strings.EqualFold(p.Scheme, origin.Scheme) {
+		return false
+	}
+
+	if suffix, ok := strings.CutPrefix(p.Host, "*."); ok {
+		host := strings.ToLower(origin.Host)
+		return strings.HasSuffix(host, "."+strings.ToLower(suffix))
+	}
+
+	return strings.EqualFold(p.Host, origin.Host)
+}
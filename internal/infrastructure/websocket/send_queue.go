@@ -0,0 +1,177 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// SlowConsumerPolicy define qué hacer cuando la cola de salida de una
+// conexión está llena
+type SlowConsumerPolicy string
+
+const (
+	// SlowConsumerDropOldest descarta el mensaje más antiguo de la cola para
+	// hacer sitio al nuevo
+	SlowConsumerDropOldest SlowConsumerPolicy = "drop_oldest"
+
+	// SlowConsumerDisconnect cierra la conexión del cliente lento
+	SlowConsumerDisconnect SlowConsumerPolicy = "disconnect"
+)
+
+// SpillQueue es una cola persistente donde se guardan los mensajes que no
+// caben en la cola de salida de una conexión, para reenviarlos cuando el
+// dispositivo vuelva a conectarse
+type SpillQueue interface {
+	// Push guarda un mensaje de una notificación para un dispositivo
+	Push(ctx context.Context, notificationID, deviceID uuid.UUID, payload []byte) error
+
+	// Pop extrae hasta limit mensajes pendientes de un dispositivo
+	Pop(ctx context.Context, deviceID uuid.UUID, limit int) ([][]byte, error)
+}
+
+// pushResult es el resultado de encolar un mensaje en una sendQueue
+type pushResult int
+
+const (
+	// El mensaje se encoló
+	pushQueued pushResult = iota
+
+	// El mensaje se encoló descartando el más antiguo
+	pushDroppedOldest
+
+	// La cola está llena y la política no permite descartar
+	pushFull
+
+	// La cola ya está cerrada
+	pushClosed
+)
+
+// queued indica si el mensaje quedó en la cola
+func (r pushResult) queued() bool {
+	return r == pushQueued || r == pushDroppedOldest
+}
+
+// sendQueue es la cola de salida acotada de una conexión. Encolar y cerrar
+// están protegidos por un mutex, de modo que nunca se escribe en un canal
+// cerrado ni se cierra dos veces.
+type sendQueue struct {
+	mu     sync.Mutex
+	ch     chan []byte
+	policy SlowConsumerPolicy
+	closed bool
+
+	// Código y motivo del frame de cierre, si se indicaron al cerrar
+	closeCode   int
+	closeReason string
+}
+
+// newSendQueue crea una cola de salida
+func newSendQueue(options ClientOptions) *sendQueue {
+	options = options.withDefaults()
+	return &sendQueue{
+		ch:     make(chan []byte, options.SendBufferSize),
+		policy: options.SlowConsumerPolicy,
+	}
+}
+
+// push encola un mensaje sin bloquear. Con la política drop_oldest devuelve
+// además el mensaje descartado.
+func (q *sendQueue) push(message []byte) (pushResult, []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return pushClosed, nil
+	}
+
+	select {
+	case q.ch <- message:
+		return pushQueued, nil
+	default:
+	}
+
+	if q.policy != SlowConsumerDropOldest {
+		return pushFull, nil
+	}
+
+	// Solo el escritor de la conexión consume de la cola, así que tras
+	// sacar un mensaje siempre queda sitio para el nuevo
+	var dropped []byte
+	select {
+	case dropped = <-q.ch:
+	default:
+	}
+	q.ch <- message
+
+	if dropped == nil {
+		return pushQueued, nil
+	}
+	return pushDroppedOldest, dropped
+}
+
+// close cierra la cola; es seguro llamarla más de una vez
+func (q *sendQueue) close() {
+	q.closeWith(0, "")
+}
+
+// closeWith cierra la cola indicando el código y motivo con los que el
+// escritor debe cerrar la conexión
+func (q *sendQueue) closeWith(code int, reason string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		q.closeCode = code
+		q.closeReason = reason
+		close(q.ch)
+	}
+}
+
+// closeStatus devuelve el código y motivo de cierre (código 0 si no se indicó)
+func (q *sendQueue) closeStatus() (int, string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.closeCode, q.closeReason
+}
+
+// pending devuelve el número de mensajes que quedan en la cola
+func (q *sendQueue) pending() int {
+	return len(q.ch)
+}
+
+// takeRemaining extrae sin bloquear los mensajes que quedan en la cola
+func (q *sendQueue) takeRemaining() [][]byte {
+	var remaining [][]byte
+	for {
+		select {
+		case message, ok := <-q.ch:
+			if !ok {
+				return remaining
+			}
+			remaining = append(remaining, message)
+		default:
+			return remaining
+		}
+	}
+}
+
+// messageNotificationID obtiene el ID de notificación de un mensaje, si lo tiene
+func messageNotificationID(message []byte) (uuid.UUID, bool) {
+	var header struct {
+		NotificationID string `json:"notification_id"`
+	}
+	if err := json.Unmarshal(message, &header); err != nil || header.NotificationID == "" {
+		return uuid.Nil, false
+	}
+
+	notificationID, err := uuid.Parse(header.NotificationID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return notificationID, true
+}
@@ -6,127 +6,141 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"notification-service/internal/usecase"
+	"notification-service/pkg/events"
+	"notification-service/pkg/logging"
+	"notification-service/pkg/metrics"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Configurar según las necesidades
-	},
-}
-
 // ConnectionHandlerImpl implementa ConnectionHandler
 type ConnectionHandlerImpl struct {
 	tokenService    *usecase.TokenService
 	deviceService   *usecase.DeviceService
 	deliveryService *usecase.DeliveryService
+	router          *MessageRouter
 }
 
 // OnConnect se llama cuando un cliente se conecta
-func (h *ConnectionHandlerImpl) OnConnect(client *Client) {
+func (h *ConnectionHandlerImpl) OnConnect(conn Connection) {
 	// Actualizar último acceso del dispositivo
-	if client.deviceID != uuid.Nil {
-		go h.deviceService.UpdateDeviceLastAccess(context.Background(), client.deviceID)
+	if conn.DeviceID() != uuid.Nil {
+		go h.deviceService.UpdateDeviceLastAccess(context.Background(), conn.DeviceID())
 	}
 }
 
 // OnDisconnect se llama cuando un cliente se desconecta
-func (h *ConnectionHandlerImpl) OnDisconnect(client *Client) {
+func (h *ConnectionHandlerImpl) OnDisconnect(conn Connection) {
 	// No necesitamos hacer nada especial al desconectar
 }
 
 // OnMessage se llama cuando se recibe un mensaje de un cliente
 func (h *ConnectionHandlerImpl) OnMessage(client *Client, messageType int, message []byte) {
-	if messageType != websocket.TextMessage {
-		return
-	}
-
 	// Actualizar último acceso
 	client.UpdateLastActivity()
 
-	// Procesar el mensaje
-	var clientMsg ClientMessage
-	if err := json.Unmarshal(message, &clientMsg); err != nil {
+	// Decodificar el mensaje según el formato negociado
+	clientMsg, err := client.codec.Decode(messageType, message)
+	if err != nil {
 		h.OnError(client, err)
 		return
 	}
 
-	// Manejar según el tipo de mensaje
-	switch clientMsg.Type {
-	case "ping":
-		// Simplemente responder con un pong
-		pongMsg := map[string]string{"type": "pong", "timestamp": time.Now().Format(time.RFC3339)}
-		pongJSON, _ := json.Marshal(pongMsg)
-		client.Send(pongJSON)
+	h.router.Dispatch(client, clientMsg)
+}
 
-	case "ack":
-		// Procesar confirmación de entrega
-		var ackData struct {
-			NotificationID string `json:"notification_id"`
-		}
-		if err := json.Unmarshal(clientMsg.Payload, &ackData); err != nil {
-			h.OnError(client, err)
-			return
-		}
+// registerBuiltinRoutes registra los mensajes que el servidor gestiona por sí
+// mismo
+func (h *ConnectionHandlerImpl) registerBuiltinRoutes() {
+	h.router.Register("ping", MessageRoute{Handler: h.handlePing})
+	// La confirmación consulta la base de datos: no debe bloquear la lectura.
+	// Marca como entregadas notificaciones del usuario, así que no se acepta
+	// de dispositivos sin usuario vinculado.
+	h.router.Register("ack", MessageRoute{Handler: h.handleAck, RequiresPermanentToken: true, Async: true})
+	h.router.Register("token_refresh", MessageRoute{Handler: h.handleTokenRefresh})
+}
 
-		if ackData.NotificationID != "" {
-			notificationID, err := uuid.Parse(ackData.NotificationID)
-			if err == nil {
-				go h.deliveryService.ConfirmDelivery(context.Background(), notificationID, client.deviceID)
-			}
-		}
+// handlePing responde con un pong
+func (h *ConnectionHandlerImpl) handlePing(ctx context.Context, client *Client, msg *ClientMessage) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"type":      "pong",
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, nil
+}
 
-	case "token_refresh":
-		// Manejar renovación de token
-		var tokenData struct {
-			Token string `json:"token"`
-		}
-		if err := json.Unmarshal(clientMsg.Payload, &tokenData); err != nil {
-			h.OnError(client, err)
-			return
-		}
+// handleAck procesa la confirmación de entrega de una notificación. Solo se
+// aceptan confirmaciones de notificaciones dirigidas al dispositivo o al
+// usuario del cliente en su tenant.
+func (h *ConnectionHandlerImpl) handleAck(ctx context.Context, client *Client, msg *ClientMessage) (map[string]interface{}, error) {
+	var ackData struct {
+		NotificationID string `json:"notification_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &ackData); err != nil {
+		return nil, &MessageError{Code: errCodeInvalidPayload, Err: err}
+	}
 
-		claims, err := h.tokenService.VerifyToken(context.Background(), tokenData.Token)
-		if err != nil {
-			// Si el token expiró, generar uno nuevo
-			if errors.Is(err, usecase.ErrTokenExpired) {
-				deviceIdentifier, extractErr := h.tokenService.ExtractDeviceIdentifierFromToken(tokenData.Token)
-				if extractErr == nil && deviceIdentifier == client.deviceIdentifier {
-					// Generar nuevo token temporal o permanente según corresponda
-					var newToken string
-					if claims != nil && claims.IsTemporary {
-						newToken, _ = h.tokenService.GenerateTemporaryToken(client.deviceIdentifier)
-					} else if client.userID != "" {
-						newToken, _ = h.tokenService.GeneratePermanentToken(client.userID, client.deviceID)
-					}
-
-					if newToken != "" {
-						response := map[string]interface{}{
-							"type":    "token_refresh_response",
-							"token":   newToken,
-							"success": true,
-						}
-						respJSON, _ := json.Marshal(response)
-						client.Send(respJSON)
-
-						// Actualizar el token del cliente
-						client.token = newToken
-					}
-				}
-			}
+	if ackData.NotificationID == "" {
+		return nil, nil
+	}
+	notificationID, err := uuid.Parse(ackData.NotificationID)
+	if err != nil {
+		return nil, nil
+	}
+
+	err = h.deliveryService.ConfirmOwnedDelivery(ctx, notificationID, client.deviceID, client.tenantID, client.userID)
+	if errors.Is(err, usecase.ErrDeliveryNotOwned) {
+		return nil, NewMessageError(errCodeForbidden)
+	}
+	return nil, err
+}
+
+// handleTokenRefresh renueva el token de una conexión. El token nuevo debe
+// pertenecer al mismo dispositivo y usuario; si es válido se reprograma la
+// expiración de la conexión.
+func (h *ConnectionHandlerImpl) handleTokenRefresh(ctx context.Context, client *Client, msg *ClientMessage) (map[string]interface{}, error) {
+	var tokenData struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(msg.Payload, &tokenData); err != nil {
+		return nil, &MessageError{Code: errCodeInvalidPayload, Err: err}
+	}
+
+	claims, err := h.tokenService.VerifyToken(ctx, tokenData.Token)
+	if err != nil {
+		if errors.Is(err, usecase.ErrTokenExpired) {
+			return nil, NewMessageError("token_expired")
 		}
+		return nil, NewMessageError("invalid_token")
+	}
+
+	if !client.matchesClaims(claims) {
+		return nil, NewMessageError("token_device_mismatch")
 	}
+
+	client.setAuth(tokenData.Token, claims)
+	return tokenRefreshResponse(client, tokenData.Token), nil
+}
+
+// tokenRefreshResponse construye la confirmación de la renovación del token
+func tokenRefreshResponse(client *Client, token string) map[string]interface{} {
+	response := map[string]interface{}{
+		"type":    "token_refresh_response",
+		"token":   token,
+		"success": true,
+	}
+	if expiresAt := client.TokenExpiresAt(); !expiresAt.IsZero() {
+		response["expires_at"] = expiresAt.Format(time.RFC3339)
+	}
+	return response
 }
 
 // OnError se llama cuando ocurre un error en la conexión
-func (h *ConnectionHandlerImpl) OnError(client *Client, err error) {
+func (h *ConnectionHandlerImpl) OnError(conn Connection, err error) {
 	// Podríamos registrar el error
 }
 
@@ -137,6 +151,19 @@ type WebSocketManager struct {
 	deviceService     *usecase.DeviceService
 	deliveryService   *usecase.DeliveryService
 	connectionHandler ConnectionHandler
+	router            *MessageRouter
+	options           ClientOptions
+	upgrader          websocket.Upgrader
+	cleaner           *ConnectionCleaner
+
+	// Limitador de la tasa de conexiones por IP (opcional)
+	throttler ConnectionThrottler
+
+	// Indica que el servidor se está cerrando y no acepta conexiones nuevas
+	draining atomic.Bool
+
+	// Conexiones cuyo escritor sigue activo
+	activeConnections atomic.Int64
 }
 
 // NewWebSocketManager crea un nuevo WebSocketManager
@@ -144,14 +171,23 @@ func NewWebSocketManager(
 	tokenService *usecase.TokenService,
 	deviceService *usecase.DeviceService,
 	deliveryService *usecase.DeliveryService,
-
+	eventManager *events.EventManager,
+	options ClientOptions,
+	spill SpillQueue,
+	logger *logging.Logger,
 ) *WebSocketManager {
-	hub := NewHub()
+	hub := NewHub(eventManager, spill)
+	router := NewMessageRouter()
 	connectionHandler := &ConnectionHandlerImpl{
 		tokenService:    tokenService,
 		deviceService:   deviceService,
 		deliveryService: deliveryService,
+		router:          router,
 	}
+	connectionHandler.registerBuiltinRoutes()
+
+	options = options.withDefaults()
+	hub.setLimits(options.MaxConnectionsPerDevice, options.MaxConnectionsPerUser, options.ConnectionLimitPolicy)
 
 	return &WebSocketManager{
 		hub:               hub,
@@ -159,91 +195,245 @@ func NewWebSocketManager(
 		deviceService:     deviceService,
 		deliveryService:   deliveryService,
 		connectionHandler: connectionHandler,
+		router:            router,
+		options:           options,
+		cleaner:           NewConnectionCleaner(hub, options.CleanupInterval, options.InactivityTimeout, logger),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			Subprotocols:    []string{SubprotocolProtobuf, SubprotocolJSON},
+			CheckOrigin:     checkOrigin(options.AllowedOrigins),
+		},
 	}
 }
 
+// RegisterMessageRoute añade un tipo de mensaje entrante y su handler. Los
+// tipos propios del servidor (ping, ack, token_refresh) se pueden sustituir.
+func (m *WebSocketManager) RegisterMessageRoute(messageType string, route MessageRoute) {
+	m.router.Register(messageType, route)
+}
+
+// SetConnectionThrottler limita la tasa de conexiones nuevas por IP en /ws
+// y /sse
+func (m *WebSocketManager) SetConnectionThrottler(throttler ConnectionThrottler) {
+	m.throttler = throttler
+}
+
 // Start inicia el WebSocketManager
 func (m *WebSocketManager) Start() {
 	go m.hub.Run()
+	m.cleaner.Start()
 }
 
-// HandleConnection maneja una nueva conexión WebSocket
-func (m *WebSocketManager) HandleConnection(w http.ResponseWriter, r *http.Request) {
+// authenticate verifica el token JWT de una petición de conexión en tiempo
+// real. Si falla, escribe la respuesta de error y devuelve false.
+func (m *WebSocketManager) authenticate(w http.ResponseWriter, r *http.Request) (*usecase.Claims, uuid.UUID, string, bool) {
 	// Obtener token
-	token := r.URL.Query().Get("token")
+	token, _ := tokenFromRequest(r)
 	if token == "" {
 		http.Error(w, "Missing token", http.StatusBadRequest)
-		return
+		return nil, uuid.Nil, "", false
 	}
 
 	// Verificar token
-	claims, er := m.tokenService.VerifyToken(r.Context(), token)
-	if er != nil {
-		// Si el token está expirado pero tenemos deviceIdentifier, generar uno nuevo
-		if errors.Is(er, usecase.ErrTokenExpired) {
-			deviceIdentifier, extractErr := m.tokenService.ExtractDeviceIdentifierFromToken(token)
-			if extractErr == nil {
-				newToken, genErr := m.tokenService.GenerateTemporaryToken(deviceIdentifier)
-				if genErr == nil {
-					// Enviar el nuevo token en la respuesta
-					w.Header().Set("X-New-Token", newToken)
-					w.Header().Set("Content-Type", "application/json")
-					w.Header().Set("Access-Control-Expose-Headers", "X-New-Token")
-					w.WriteHeader(http.StatusUnauthorized)
-
-					response := map[string]interface{}{
-						"error":     "token_expired",
-						"message":   "Please reconnect with the new token",
-						"new_token": newToken,
-					}
-					json.NewEncoder(w).Encode(response)
-					return
-				}
+	claims, deviceID, err := m.verifyToken(r.Context(), token)
+	if err != nil {
+		switch {
+		case errors.Is(err, errTokenExpired):
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+
+			response := map[string]interface{}{
+				"error":   "token_expired",
+				"message": "Refresh the token at /api/auth/refresh and reconnect",
 			}
+			json.NewEncoder(w).Encode(response)
+
+		case errors.Is(err, errInvalidDeviceID):
+			http.Error(w, "Invalid device ID", http.StatusBadRequest)
+
+		default:
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
 		}
+		return nil, uuid.Nil, "", false
+	}
 
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return
+	return claims, deviceID, token, true
+}
+
+// deprecationHeader marca la respuesta si el cliente envió el token en la URL
+func deprecationHeader(r *http.Request) http.Header {
+	header := http.Header{}
+	if _, deprecated := tokenFromRequest(r); deprecated {
+		header.Set("Deprecation", "true")
 	}
+	return header
+}
 
-	var deviceID uuid.UUID
-	var err error
+// clientOptions devuelve las opciones de conexión para el tipo de cliente
+// indicado en la petición (parámetro client_type, por ejemplo "mobile")
+func (m *WebSocketManager) clientOptions(r *http.Request) ClientOptions {
+	return m.options.forClientType(r.URL.Query().Get("client_type"))
+}
+
+// rejectIfDraining responde 503 si el servidor se está cerrando
+func (m *WebSocketManager) rejectIfDraining(w http.ResponseWriter) bool {
+	if !m.draining.Load() {
+		return false
+	}
+
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(m.options.ReconnectWindow.Seconds())))
+	http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+	return true
+}
+
+// rejectIfThrottled responde 429 si la IP del cliente supera la tasa de
+// conexiones permitida
+func (m *WebSocketManager) rejectIfThrottled(w http.ResponseWriter, r *http.Request) bool {
+	if m.throttler == nil || m.throttler.Allow(clientIP(r, m.options.TrustForwardedFor)) {
+		return false
+	}
 
-	// Obtener deviceID del token
-	if claims.DeviceID != "" {
-		deviceID, err = uuid.Parse(claims.DeviceID)
+	metrics.WebSocketConnectionsRejected.WithLabelValues("rate_limited").Inc()
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "Too many connection attempts", http.StatusTooManyRequests)
+	return true
+}
+
+// rejectIfOverLimit responde 429 si el dispositivo o el usuario ya tiene el
+// máximo de conexiones y la política es rechazar las nuevas
+func (m *WebSocketManager) rejectIfOverLimit(w http.ResponseWriter, claims *usecase.Claims, deviceID uuid.UUID) bool {
+	if m.hub.canRegister(deviceID, userKey(claims.Tenant(), claims.UserID)) {
+		return false
+	}
+
+	metrics.WebSocketConnectionsRejected.WithLabelValues("connection_limit").Inc()
+	http.Error(w, "Too many connections", http.StatusTooManyRequests)
+	return true
+}
+
+// HandleConnection maneja una nueva conexión WebSocket. El token puede
+// llegar en la cabecera Authorization, como subprotocolo bearer.<token>, en
+// un primer mensaje auth tras el handshake o, de forma obsoleta, en la URL.
+func (m *WebSocketManager) HandleConnection(w http.ResponseWriter, r *http.Request) {
+	if m.rejectIfDraining(w) || m.rejectIfThrottled(w, r) {
+		return
+	}
+
+	// Sin token en el handshake: la conexión se autentica con su primer mensaje
+	if token, _ := tokenFromRequest(r); token == "" {
+		conn, err := m.upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			http.Error(w, "Invalid device ID", http.StatusBadRequest)
 			return
 		}
+		m.authenticateFirstMessage(conn, r)
+		return
 	}
 
-	// Actualizar dispositivo
-	// En una implementación real, obtendríamos el dispositivo de la BD y lo actualizaríamos
+	claims, deviceID, token, ok := m.authenticate(w, r)
+	if !ok || m.rejectIfOverLimit(w, claims, deviceID) {
+		return
+	}
 
 	// Actualizar la conexión a WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := m.upgrader.Upgrade(w, r, deprecationHeader(r))
 	if err != nil {
 		return
 	}
 
-	// Crear nuevo cliente
+	m.startClient(conn, r, negotiateCodec(conn, r), claims, deviceID, token)
+}
+
+// startClient crea el cliente de una conexión WebSocket autenticada, lo
+// registra en el hub e inicia el bombeo de mensajes. Si el hub rechaza la
+// conexión por los límites de conexiones, la cierra con el código 1008.
+func (m *WebSocketManager) startClient(
+	conn *websocket.Conn,
+	r *http.Request,
+	codec FrameCodec,
+	claims *usecase.Claims,
+	deviceID uuid.UUID,
+	token string,
+) (*Client, error) {
+	// Crear nuevo cliente con el formato de frames negociado y los ajustes
+	// de su tipo de cliente
+	options := m.clientOptions(r)
 	client := NewClient(
 		m.hub,
 		conn,
+		claims.Tenant(),
 		claims.UserID,
 		deviceID,
 		claims.DeviceIdentifier,
 		token,
 		m.connectionHandler,
+		codec,
+		options,
 	)
 
-	// Registrar cliente en el hub
-	m.hub.register <- client
+	// Programar el cierre de la conexión cuando expire el token
+	client.setAuth(token, claims)
+
+	// Registrar cliente en el hub y reenviar lo que quedó pendiente
+	if err := m.hub.Register(client); err != nil {
+		client.stopExpiry()
+		closeWithPolicyViolation(conn, options, "too many connections")
+		return nil, err
+	}
+	go m.hub.replaySpilled(client, options.SendBufferSize)
 
 	// Iniciar el bombeo de mensajes
-	go client.writePump()
+	m.activeConnections.Add(1)
+	go func() {
+		defer m.activeConnections.Add(-1)
+		client.writePump()
+	}()
 	go client.readPump()
+
+	return client, nil
+}
+
+// HandleSSE maneja una nueva conexión Server-Sent Events. Es una alternativa
+// a /ws para redes que bloquean WebSocket: usa el mismo token, se registra en
+// el hub y recibe los mismos mensajes que un cliente WebSocket.
+func (m *WebSocketManager) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	if m.rejectIfDraining(w) || m.rejectIfThrottled(w, r) {
+		return
+	}
+
+	claims, deviceID, _, ok := m.authenticate(w, r)
+	if !ok || m.rejectIfOverLimit(w, claims, deviceID) {
+		return
+	}
+	if _, deprecated := tokenFromRequest(r); deprecated {
+		w.Header().Set("Deprecation", "true")
+	}
+
+	options := m.clientOptions(r)
+	client := NewSSEClient(m.hub, claims.Tenant(), claims.UserID, deviceID, claims.DeviceIdentifier, claims.Session(), options)
+
+	// Registrar cliente en el hub y reenviar lo que quedó pendiente
+	if err := m.hub.Register(client); err != nil {
+		http.Error(w, "Too many connections", http.StatusTooManyRequests)
+		return
+	}
+	go m.hub.replaySpilled(client, options.SendBufferSize)
+	if m.connectionHandler != nil {
+		m.connectionHandler.OnConnect(client)
+	}
+
+	m.activeConnections.Add(1)
+	defer func() {
+		m.activeConnections.Add(-1)
+		m.hub.Unregister(client)
+		if m.connectionHandler != nil {
+			m.connectionHandler.OnDisconnect(client)
+		}
+	}()
+
+	if err := client.serve(w, r); err != nil && m.connectionHandler != nil {
+		m.connectionHandler.OnError(client, err)
+	}
 }
 
 // SendToDevice envía un mensaje a un dispositivo
@@ -282,11 +472,38 @@ func (m *WebSocketManager) GetConnectedUsers() []string {
 	return m.hub.GetConnectedUsers()
 } */
 
+// Drain cierra ordenadamente las conexiones antes de apagar el servidor:
+// deja de aceptar conexiones nuevas, avisa a los clientes de cuándo
+// reconectarse, vacía sus colas y espera a que se envíen los frames de
+// cierre o a que venza ctx.
+func (m *WebSocketManager) Drain(ctx context.Context) {
+	m.draining.Store(true)
+	m.hub.Drain(ctx, m.options.ReconnectWindow)
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for m.activeConnections.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // Shutdown cierra el WebSocketManager
 func (m *WebSocketManager) Shutdown() {
+	m.cleaner.Stop()
 	m.hub.Shutdown()
 }
 
+// DisconnectSession cierra las conexiones de una sesión revocada. Satisface
+// la interfaz usecase.SessionDisconnector.
+func (m *WebSocketManager) DisconnectSession(sessionID string) int {
+	return m.hub.DisconnectSession(sessionID)
+}
+
 // Adaptar el WebSocketManager para que implemente la interfaz usecase.WebSocketManager
 // Añadir estos métodos a internal/infrastructure/websocket/server.go
 
@@ -296,10 +513,10 @@ func (m *WebSocketManager) SendToDevice(deviceID uuid.UUID, payload []byte) bool
 	return m.hub.SendToDevice(deviceID, payload)
 }
 
-// SendToUser envía un mensaje a un usuario
+// SendToUser envía un mensaje a un usuario de un tenant
 // Este método satisface la interfaz usecase.WebSocketManager
-func (m *WebSocketManager) SendToUser(userID string, payload []byte) bool {
-	return m.hub.SendToUser(userID, payload)
+func (m *WebSocketManager) SendToUser(tenantID, userID string, payload []byte) bool {
+	return m.hub.SendToUser(tenantID, userID, payload)
 }
 
 // GetConnectedDevices devuelve todos los dispositivos conectados
@@ -321,3 +538,28 @@ func (m *WebSocketManager) SendMessage(deviceID uuid.UUID, payload []byte) error
 	}
 	return nil
 }
+
+// GetUserConnections devuelve las conexiones activas de un usuario de un tenant
+// Este método satisface la interfaz usecase.PresenceTracker
+func (m *WebSocketManager) GetUserConnections(tenantID, userID string) []usecase.ConnectionInfo {
+	return connectionInfos(m.hub.GetUserConnections(tenantID, userID))
+}
+
+// GetDeviceConnections devuelve las conexiones activas de un dispositivo
+// Este método satisface la interfaz usecase.PresenceTracker
+func (m *WebSocketManager) GetDeviceConnections(deviceID uuid.UUID) []usecase.ConnectionInfo {
+	return connectionInfos(m.hub.GetDeviceConnections(deviceID))
+}
+
+// connectionInfos convierte conexiones del hub en su descripción pública
+func connectionInfos(connections []Connection) []usecase.ConnectionInfo {
+	infos := make([]usecase.ConnectionInfo, 0, len(connections))
+	for _, conn := range connections {
+		infos = append(infos, usecase.ConnectionInfo{
+			DeviceID:    conn.DeviceID(),
+			Transport:   string(conn.Transport()),
+			ConnectedAt: conn.ConnectedAt(),
+		})
+	}
+	return infos
+}
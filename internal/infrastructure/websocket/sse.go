@@ -0,0 +1,201 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sseRetry es el tiempo de reconexión sugerido al navegador, en milisegundos
+const sseRetry = 5000
+
+// SSEClient representa una conexión Server-Sent Events con un cliente.
+// Recibe los mismos mensajes que un cliente WebSocket; las confirmaciones
+// de entrega se envían por POST /api/notifications/confirm.
+type SSEClient struct {
+	hub              *Hub
+	send             *sendQueue
+	tenantID         string
+	userID           string
+	deviceID         uuid.UUID
+	deviceIdentifier string
+	sessionID        string
+	lastActivity     activityClock
+	connectedAt      time.Time
+	done             chan struct{}
+	closeOnce        sync.Once
+	options          ClientOptions
+}
+
+// NewSSEClient crea un nuevo cliente SSE
+func NewSSEClient(hub *Hub, tenantID, userID string, deviceID uuid.UUID, deviceIdentifier, sessionID string, options ClientOptions) *SSEClient {
+	options = options.withDefaults()
+	client := &SSEClient{
+		hub:              hub,
+		send:             newSendQueue(options),
+		tenantID:         tenantID,
+		userID:           userID,
+		deviceID:         deviceID,
+		deviceIdentifier: deviceIdentifier,
+		sessionID:        sessionID,
+		connectedAt:      time.Now(),
+		done:             make(chan struct{}),
+		options:          options,
+	}
+	client.lastActivity.touch()
+	return client
+}
+
+// serve escribe los mensajes del cliente en el stream hasta que se cierra
+func (c *SSEClient) serve(w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming not supported")
+	}
+
+	// El stream es de larga duración: se anulan los timeouts del servidor y
+	// se usa un plazo por escritura
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc.SetWriteDeadline(time.Now().Add(c.options.WriteWait))
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", sseRetry); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(c.options.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message, ok := <-c.send.ch:
+			if !ok {
+				// El hub cerró el canal
+				return nil
+			}
+			rc.SetWriteDeadline(time.Now().Add(c.options.WriteWait))
+			if err := writeSSEEvent(w, message); err != nil {
+				return err
+			}
+			flusher.Flush()
+			c.lastActivity.touch()
+
+		case <-ticker.C:
+			// Comentario SSE para mantener viva la conexión a través de proxies
+			rc.SetWriteDeadline(time.Now().Add(c.options.WriteWait))
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return err
+			}
+			flusher.Flush()
+			c.lastActivity.touch()
+
+		case <-r.Context().Done():
+			return nil
+
+		case <-c.done:
+			return nil
+		}
+	}
+}
+
+// writeSSEEvent escribe un mensaje como evento SSE. El campo event es el
+// tipo del mensaje y el id es el de la notificación, si lo tiene.
+func writeSSEEvent(w io.Writer, message []byte) error {
+	var header struct {
+		Type           string `json:"type"`
+		NotificationID string `json:"notification_id"`
+		ReconnectAfter int64  `json:"reconnect_after"`
+	}
+	_ = json.Unmarshal(message, &header)
+
+	var buf bytes.Buffer
+	if header.ReconnectAfter > 0 {
+		// El navegador reconecta solo; se ajusta su espera al aviso de cierre
+		fmt.Fprintf(&buf, "retry: %d\n", header.ReconnectAfter)
+	}
+	if header.NotificationID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", header.NotificationID)
+	}
+	if header.Type != "" {
+		fmt.Fprintf(&buf, "event: %s\n", header.Type)
+	}
+	for _, line := range bytes.Split(message, []byte{'\n'}) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Send envía un mensaje al cliente
+func (c *SSEClient) Send(message []byte) bool {
+	return c.hub.deliver(c, message).queued()
+}
+
+// Close termina el stream del cliente
+func (c *SSEClient) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// queue devuelve la cola de salida del cliente
+func (c *SSEClient) queue() *sendQueue {
+	return c.send
+}
+
+// TenantID devuelve el tenant del dispositivo del cliente
+func (c *SSEClient) TenantID() string {
+	return c.tenantID
+}
+
+// UserID devuelve el usuario asociado al cliente
+func (c *SSEClient) UserID() string {
+	return c.userID
+}
+
+// DeviceID devuelve el dispositivo asociado al cliente
+func (c *SSEClient) DeviceID() uuid.UUID {
+	return c.deviceID
+}
+
+// DeviceIdentifier devuelve el identificador físico del dispositivo
+func (c *SSEClient) DeviceIdentifier() string {
+	return c.deviceIdentifier
+}
+
+// SessionID devuelve la sesión del token con el que se abrió el stream
+func (c *SSEClient) SessionID() string {
+	return c.sessionID
+}
+
+// Transport devuelve el transporte del cliente
+func (c *SSEClient) Transport() TransportType {
+	return TransportSSE
+}
+
+// GetLastActivity devuelve la última vez que el cliente estuvo activo
+func (c *SSEClient) GetLastActivity() time.Time {
+	return c.lastActivity.last()
+}
+
+// ConnectedAt devuelve el momento en que se estableció la conexión
+func (c *SSEClient) ConnectedAt() time.Time {
+	return c.connectedAt
+}
@@ -20,6 +20,7 @@ var (
 	ErrFailedToUpdateDelivery   = errors.New("failed to update delivery record")
 	ErrInvalidDeliveryStatus    = errors.New("invalid delivery status")
 	ErrDeliveryAlreadyCompleted = errors.New("delivery already completed")
+	ErrDeliveryNotOwned         = errors.New("notification does not belong to this device or user")
 )
 
 // DeliveryService define las operaciones para gestionar el seguimiento de entregas de notificaciones
@@ -206,6 +207,39 @@ func (s *DeliveryService) ConfirmDelivery(ctx context.Context, notificationID uu
 	return nil
 }
 
+// ConfirmOwnedDelivery confirma la entrega de una notificación solo si va
+// dirigida al dispositivo (tiene un registro de entrega para él) o al usuario
+// indicado del tenant indicado; los IDs de usuario solo son únicos dentro de
+// un tenant. Las confirmaciones que envían los clientes por su conexión pasan
+// por aquí para que nadie pueda confirmar notificaciones ajenas.
+func (s *DeliveryService) ConfirmOwnedDelivery(ctx context.Context, notificationID, deviceID uuid.UUID, tenantID, userID string) error {
+	deliveries, err := s.deliveryRepo.GetByNotificationID(ctx, notificationID)
+	if err != nil {
+		s.logger.Error("Error getting delivery records: %v, notificationID: %s", err, notificationID)
+		return err
+	}
+
+	owned := false
+	for _, delivery := range deliveries {
+		if delivery.DeviceID == deviceID {
+			owned = true
+			break
+		}
+	}
+
+	if !owned && userID != "" {
+		notification, err := s.notificationRepo.GetByID(ctx, notificationID)
+		owned = err == nil && notification.TenantID == tenantID && notification.UserID == userID
+	}
+
+	if !owned {
+		s.logger.Warn("Rejected delivery confirmation for notification %s from device %s", notificationID, deviceID)
+		return ErrDeliveryNotOwned
+	}
+
+	return s.ConfirmDelivery(ctx, notificationID, deviceID)
+}
+
 // GetDeliveryStatus obtiene el estado de entrega de una notificación
 func (s *DeliveryService) GetDeliveryStatus(ctx context.Context, notificationID uuid.UUID) ([]*entity.DeliveryTracking, error) {
 	deliveries, err := s.deliveryRepo.GetByNotificationID(ctx, notificationID)
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// storedNotificationRepo tiene una sola notificación
+type storedNotificationRepo struct {
+	repository.NotificationRepository
+	notification *entity.Notification
+}
+
+func (r storedNotificationRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.Notification, error) {
+	if id != r.notification.ID {
+		return nil, repository.ErrNotificationNotFound
+	}
+	return r.notification, nil
+}
+
+func (r *memDeliveryRepo) GetByNotificationID(ctx context.Context, notificationID uuid.UUID) ([]*entity.DeliveryTracking, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deliveries []*entity.DeliveryTracking
+	for _, delivery := range r.deliveries {
+		if delivery.NotificationID == notificationID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *memDeliveryRepo) MarkAsDelivered(ctx context.Context, id uuid.UUID) error {
+	r.setStatus(id, entity.DeliveryStatusDelivered)
+	return nil
+}
+
+func TestConfirmOwnedDeliveryChecksTenant(t *testing.T) {
+	// Notificación del usuario 7 del tenant acme, sin entregas todavía
+	notification, err := entity.NewNotification("7", "Hola", "Mensaje", nil, entity.NotificationTypeNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification.SetTenant("acme")
+
+	tests := []struct {
+		name     string
+		tenantID string
+		userID   string
+		wantErr  error
+	}{
+		{"same user in another tenant", entity.DefaultTenantID, "7", ErrDeliveryNotOwned},
+		{"another user", "acme", "8", ErrDeliveryNotOwned},
+		{"temporary client", "acme", "", ErrDeliveryNotOwned},
+		{"owner", "acme", "7", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deliveries := newMemDeliveryRepo()
+			service := NewDeliveryService(deliveries, storedNotificationRepo{notification: notification}, nil,
+				logging.NewLogger(logging.WithOutput(io.Discard)))
+			deviceID := uuid.New()
+
+			err := service.ConfirmOwnedDelivery(context.Background(), notification.ID, deviceID, tt.tenantID, tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			status, confirmed := deliveries.statuses(deviceID)[entity.TokenTypeWebSocket]
+			if confirmed != (tt.wantErr == nil) {
+				t.Errorf("confirmed = %v, status %q", confirmed, status)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ var (
 	ErrFailedToSaveDevice   = errors.New("failed to save device")
 	ErrFailedToUpdateDevice = errors.New("failed to update device")
 	ErrInvalidUserID        = errors.New("invalid user ID")
+	ErrDeviceLinkedToUser   = errors.New("device is linked to a user")
 )
 
 // DeviceService define las operaciones de negocio para gestionar dispositivos
@@ -35,19 +36,28 @@ func NewDeviceService(deviceRepo repository.DeviceRepository, tokenRepo reposito
 	}
 }
 
-// RegisterDeviceWithoutUser registra un nuevo dispositivo sin usuario asociado
-func (s *DeviceService) RegisterDeviceWithoutUser(ctx context.Context, deviceIdentifier string, model *string) (*entity.Device, error) {
-	// Verificar si ya existe
-	existingDevice, err := s.deviceRepo.GetByDeviceIdentifier(ctx, deviceIdentifier)
-	if err != nil && !errors.Is(err, ErrDeviceNotFound) {
+// RegisterDeviceWithoutUser registra un nuevo dispositivo sin usuario asociado.
+// Un idioma vacío conserva el que tuviera el dispositivo. Un dispositivo ya
+// vinculado a un usuario no se puede volver a registrar así: devuelve
+// ErrDeviceLinkedToUser, porque cualquiera que conozca su identificador
+// obtendría un token temporal para él.
+func (s *DeviceService) RegisterDeviceWithoutUser(ctx context.Context, deviceIdentifier string, model *string, locale string) (*entity.Device, error) {
+	// Verificar si ya existe en el tenant
+	tenantID := tenantFromContext(ctx)
+	existingDevice, err := s.deviceRepo.GetByDeviceIdentifier(ctx, tenantID, deviceIdentifier)
+	if err != nil && !isDeviceNotFound(err) {
 		return nil, err
 	}
 
 	if existingDevice != nil {
+		if existingDevice.UserID != nil {
+			return nil, ErrDeviceLinkedToUser
+		}
 		existingDevice.UpdateLastAccess()
 		if model != nil {
 			existingDevice.Model = model
 		}
+		existingDevice.SetLocale(locale)
 		if err := s.deviceRepo.Update(ctx, existingDevice); err != nil {
 			return nil, ErrFailedToUpdateDevice
 		}
@@ -55,7 +65,8 @@ func (s *DeviceService) RegisterDeviceWithoutUser(ctx context.Context, deviceIde
 	}
 
 	// Crear nuevo dispositivo
-	newDevice := entity.NewDevice(deviceIdentifier, nil, model)
+	newDevice := entity.NewDevice(tenantID, deviceIdentifier, nil, model)
+	newDevice.SetLocale(locale)
 	if err := s.deviceRepo.Save(ctx, newDevice); err != nil {
 		return nil, ErrFailedToSaveDevice
 	}
@@ -63,11 +74,13 @@ func (s *DeviceService) RegisterDeviceWithoutUser(ctx context.Context, deviceIde
 	return newDevice, nil
 }
 
-// RegisterDeviceWithUser registra un nuevo dispositivo asociado a un usuario
-func (s *DeviceService) RegisterDeviceWithUser(ctx context.Context, deviceIdentifier string, userID uint, model *string) (*entity.Device, error) {
-	// Verificar si ya existe
-	existingDevice, err := s.deviceRepo.GetByDeviceIdentifier(ctx, deviceIdentifier)
-	if err != nil && !errors.Is(err, ErrDeviceNotFound) {
+// RegisterDeviceWithUser registra un nuevo dispositivo asociado a un usuario.
+// Un idioma vacío conserva el que tuviera el dispositivo.
+func (s *DeviceService) RegisterDeviceWithUser(ctx context.Context, deviceIdentifier string, userID uint, model *string, locale string) (*entity.Device, error) {
+	// Verificar si ya existe en el tenant
+	tenantID := tenantFromContext(ctx)
+	existingDevice, err := s.deviceRepo.GetByDeviceIdentifier(ctx, tenantID, deviceIdentifier)
+	if err != nil && !isDeviceNotFound(err) {
 		return nil, err
 	}
 
@@ -77,6 +90,7 @@ func (s *DeviceService) RegisterDeviceWithUser(ctx context.Context, deviceIdenti
 		if model != nil {
 			existingDevice.Model = model
 		}
+		existingDevice.SetLocale(locale)
 		if err := s.deviceRepo.Update(ctx, existingDevice); err != nil {
 			return nil, ErrFailedToUpdateDevice
 		}
@@ -84,7 +98,8 @@ func (s *DeviceService) RegisterDeviceWithUser(ctx context.Context, deviceIdenti
 	}
 
 	// Crear nuevo dispositivo
-	newDevice := entity.NewDevice(deviceIdentifier, &userID, model)
+	newDevice := entity.NewDevice(tenantID, deviceIdentifier, &userID, model)
+	newDevice.SetLocale(locale)
 	if err := s.deviceRepo.Save(ctx, newDevice); err != nil {
 		return nil, ErrFailedToSaveDevice
 	}
@@ -92,19 +107,29 @@ func (s *DeviceService) RegisterDeviceWithUser(ctx context.Context, deviceIdenti
 	return newDevice, nil
 }
 
-// GetDevice obtiene un dispositivo por su ID
+// isDeviceNotFound indica si un error es el de un dispositivo que no existe,
+// del repositorio o del servicio
+func isDeviceNotFound(err error) bool {
+	return errors.Is(err, repository.ErrDeviceNotFound) || errors.Is(err, ErrDeviceNotFound)
+}
+
+// GetDevice obtiene un dispositivo del tenant de la petición por su ID. Los
+// de otros tenants no se encuentran.
 func (s *DeviceService) GetDevice(ctx context.Context, deviceID uuid.UUID) (*entity.Device, error) {
 	device, err := s.deviceRepo.GetByID(ctx, deviceID)
 	if err != nil {
 		return nil, err
 	}
+	if device.TenantID != tenantFromContext(ctx) {
+		return nil, ErrDeviceNotFound
+	}
 	return device, nil
 }
 
 // LinkDeviceToUser vincula un dispositivo a un usuario
 func (s *DeviceService) LinkDeviceToUser(ctx context.Context, deviceID uuid.UUID, userID uint) error {
-	// Verificar si el dispositivo existe
-	device, err := s.deviceRepo.GetByID(ctx, deviceID)
+	// Verificar si el dispositivo existe en el tenant
+	device, err := s.GetDevice(ctx, deviceID)
 	if err != nil {
 		return ErrDeviceNotFound
 	}
@@ -123,9 +148,10 @@ func (s *DeviceService) UpdateDeviceLastAccess(ctx context.Context, deviceID uui
 	return s.deviceRepo.UpdateLastAccess(ctx, deviceID, time.Now())
 }
 
-// GetUserDevices obtiene todos los dispositivos de un usuario
+// GetUserDevices obtiene todos los dispositivos de un usuario del tenant de
+// la petición
 func (s *DeviceService) GetUserDevices(ctx context.Context, userID uint) ([]*entity.Device, error) {
-	return s.deviceRepo.GetByUserID(ctx, userID)
+	return s.deviceRepo.GetByUserID(ctx, tenantFromContext(ctx), userID)
 }
 
 // CleanupInactiveDevices elimina los dispositivos inactivos
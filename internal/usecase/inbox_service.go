@@ -0,0 +1,241 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// Errores del buzón de long-polling
+var (
+	ErrInvalidCursor = errors.New("invalid poll cursor")
+)
+
+const (
+	// Valor del token que marca a un dispositivo como cliente de long-polling
+	pollTokenValue = "long-polling"
+
+	// Intervalo con el que se vuelve a consultar la base de datos mientras se
+	// espera, para recoger notificaciones encoladas por otras instancias
+	pollRecheckInterval = 2 * time.Second
+)
+
+// PollResult es el resultado de un sondeo del buzón de un dispositivo
+type PollResult struct {
+	Notifications []*entity.Notification
+	Cursor        string
+	HasMore       bool
+}
+
+// InboxService gestiona el buzón de notificaciones de los dispositivos que
+// solo pueden hacer peticiones HTTP (long-polling).
+//
+// Las notificaciones se guardan como entregas pendientes del canal "poll".
+// Cada sondeo las marca como enviadas y devuelve un cursor; el siguiente
+// sondeo con ese cursor (o una confirmación explícita) las marca como
+// entregadas.
+type InboxService struct {
+	deliveryRepo     repository.DeliveryRepository
+	notificationRepo repository.NotificationRepository
+	tokenRepo        repository.TokenRepository
+	deviceRepo       repository.DeviceRepository
+	logger           *logging.Logger
+
+	mu      sync.Mutex
+	waiters map[uuid.UUID]map[chan struct{}]struct{}
+}
+
+// NewInboxService crea una nueva instancia del servicio de buzón
+func NewInboxService(
+	deliveryRepo repository.DeliveryRepository,
+	notificationRepo repository.NotificationRepository,
+	tokenRepo repository.TokenRepository,
+	deviceRepo repository.DeviceRepository,
+	logger *logging.Logger,
+) *InboxService {
+	return &InboxService{
+		deliveryRepo:     deliveryRepo,
+		notificationRepo: notificationRepo,
+		tokenRepo:        tokenRepo,
+		deviceRepo:       deviceRepo,
+		logger:           logger,
+		waiters:          make(map[uuid.UUID]map[chan struct{}]struct{}),
+	}
+}
+
+// IsPollingDevice indica si un dispositivo recibe notificaciones por long-polling
+func (s *InboxService) IsPollingDevice(ctx context.Context, deviceID uuid.UUID) bool {
+	token, err := s.tokenRepo.GetByDeviceAndType(ctx, deviceID, entity.TokenTypePoll)
+	return err == nil && token != nil && token.IsValid()
+}
+
+// Enqueue deja una notificación pendiente en el buzón de un dispositivo
+func (s *InboxService) Enqueue(ctx context.Context, notificationID, deviceID uuid.UUID) error {
+	delivery := entity.NewDeliveryTracking(notificationID, deviceID, entity.TokenTypePoll)
+	if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
+		return err
+	}
+
+	s.notify(deviceID)
+	return nil
+}
+
+// Poll devuelve las notificaciones pendientes de un dispositivo. Si no hay
+// ninguna, espera hasta que llegue alguna o venza el tiempo de espera.
+// El cursor recibido confirma la entrega del lote anterior.
+func (s *InboxService) Poll(ctx context.Context, deviceID uuid.UUID, cursor string, wait time.Duration, limit int) (*PollResult, error) {
+	// El dispositivo debe ser del tenant de la petición
+	device, err := s.deviceRepo.GetByID(ctx, deviceID)
+	if err != nil || device.TenantID != tenantFromContext(ctx) {
+		return nil, ErrDeviceNotFound
+	}
+
+	// Confirmar el lote anterior
+	if cursor != "" {
+		sentAt, err := decodePollCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.deliveryRepo.MarkDeliveredUpTo(ctx, deviceID, entity.TokenTypePoll, sentAt); err != nil {
+			return nil, err
+		}
+	}
+
+	// Registrar el dispositivo como cliente de long-polling para que los
+	// próximos envíos se encolen en su buzón
+	if err := s.tokenRepo.Upsert(ctx, deviceID, pollTokenValue, entity.TokenTypePoll); err != nil {
+		s.logger.Warn("Failed to register poll token for device %s: %v", deviceID, err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		// Suscribirse antes de consultar para no perder avisos
+		ch := s.subscribe(deviceID)
+
+		result, err := s.collect(ctx, deviceID, limit)
+		remaining := time.Until(deadline)
+		if err != nil || len(result.Notifications) > 0 || remaining <= 0 {
+			s.unsubscribe(deviceID, ch)
+			if err == nil && result.Cursor == "" {
+				result.Cursor = cursor
+			}
+			return result, err
+		}
+
+		timer := time.NewTimer(min(remaining, pollRecheckInterval))
+		select {
+		case <-ch:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			s.unsubscribe(deviceID, ch)
+			return &PollResult{Cursor: cursor}, nil
+		}
+		timer.Stop()
+		s.unsubscribe(deviceID, ch)
+	}
+}
+
+// collect reclama un lote de entregas pendientes y carga sus notificaciones
+func (s *InboxService) collect(ctx context.Context, deviceID uuid.UUID, limit int) (*PollResult, error) {
+	// Postgres guarda microsegundos: truncar para que el cursor coincida
+	sentAt := time.Now().Truncate(time.Microsecond)
+
+	deliveries, err := s.deliveryRepo.ClaimPendingForDevice(ctx, deviceID, entity.TokenTypePoll, limit, sentAt)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PollResult{
+		Notifications: make([]*entity.Notification, 0, len(deliveries)),
+		HasMore:       len(deliveries) == limit,
+	}
+	if len(deliveries) == 0 {
+		return result, nil
+	}
+
+	for _, delivery := range deliveries {
+		notification, err := s.notificationRepo.GetByID(ctx, delivery.NotificationID)
+		if err != nil {
+			s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Notification not found")
+			continue
+		}
+
+		if notification.IsExpired() {
+			s.deliveryRepo.UpdateStatus(ctx, delivery.ID, entity.DeliveryStatusExpired)
+			continue
+		}
+
+		result.Notifications = append(result.Notifications, notification)
+	}
+
+	result.Cursor = encodePollCursor(sentAt)
+	return result, nil
+}
+
+// subscribe registra un canal que se cierra cuando llega una notificación
+func (s *InboxService) subscribe(deviceID uuid.UUID) chan struct{} {
+	ch := make(chan struct{})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.waiters[deviceID]; !ok {
+		s.waiters[deviceID] = make(map[chan struct{}]struct{})
+	}
+	s.waiters[deviceID][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe elimina un canal de espera
+func (s *InboxService) unsubscribe(deviceID uuid.UUID, ch chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if waiters, ok := s.waiters[deviceID]; ok {
+		delete(waiters, ch)
+		if len(waiters) == 0 {
+			delete(s.waiters, deviceID)
+		}
+	}
+}
+
+// notify despierta a los sondeos que esperan notificaciones de un dispositivo
+func (s *InboxService) notify(deviceID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.waiters[deviceID] {
+		close(ch)
+	}
+	delete(s.waiters, deviceID)
+}
+
+// encodePollCursor codifica el instante de envío de un lote como cursor opaco
+func encodePollCursor(sentAt time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(sentAt.UnixMicro(), 10)))
+}
+
+// decodePollCursor obtiene el instante de envío de un lote a partir del cursor
+func decodePollCursor(cursor string) (time.Time, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, ErrInvalidCursor
+	}
+
+	micros, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || micros <= 0 {
+		return time.Time{}, ErrInvalidCursor
+	}
+
+	return time.UnixMicro(micros), nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 
 	"notification-service/internal/domain/entity"
 	"notification-service/internal/domain/repository"
@@ -20,6 +21,7 @@ var (
 	ErrInvalidNotificationData  = errors.New("invalid notification data")
 	ErrDeliveryFailed           = errors.New("failed to deliver notification")
 	ErrUserHasNoDevices         = errors.New("user has no registered devices")
+	ErrPushNotConfigured        = errors.New("push channels not configured")
 )
 
 // NotificationService define las operaciones de negocio para gestionar notificaciones
@@ -29,9 +31,28 @@ type NotificationService struct {
 	deviceRepo       repository.DeviceRepository
 	tokenRepo        repository.TokenRepository
 	wsManager        WebSocketManager
+	inbox            *InboxService
+	quotas           *QuotaService
+	scheduler        *SendScheduler
+	templates        *TemplateService
+	adapters         PushAdapterResolver
 	logger           *logging.Logger
 }
 
+// TemplateRef indica la plantilla con la que se renderizan el título, el
+// mensaje y los datos de una notificación
+type TemplateRef struct {
+	ID string
+
+	// Versión a renderizar; 0 para la publicada
+	Version int
+
+	// Idioma; vacío para el de los dispositivos del destinatario
+	Locale string
+
+	Variables map[string]interface{}
+}
+
 // WebSocketManager define las operaciones para enviar mensajes WebSocket
 type WebSocketManager interface {
 	SendMessage(deviceID uuid.UUID, payload []byte) error
@@ -40,7 +61,7 @@ type WebSocketManager interface {
 
 	//
 	SendToDevice(deviceID uuid.UUID, payload []byte) bool
-	SendToUser(userID string, payload []byte) bool
+	SendToUser(tenantID, userID string, payload []byte) bool
 }
 
 // NewNotificationService crea una nueva instancia del servicio de notificaciones
@@ -50,6 +71,10 @@ func NewNotificationService(
 	deviceRepo repository.DeviceRepository,
 	tokenRepo repository.TokenRepository,
 	wsManager WebSocketManager,
+	inbox *InboxService,
+	quotas *QuotaService,
+	scheduler *SendScheduler,
+	templates *TemplateService,
 	logger *logging.Logger,
 ) *NotificationService {
 	return &NotificationService{
@@ -58,38 +83,81 @@ func NewNotificationService(
 		deviceRepo:       deviceRepo,
 		tokenRepo:        tokenRepo,
 		wsManager:        wsManager,
+		inbox:            inbox,
+		quotas:           quotas,
+		scheduler:        scheduler,
+		templates:        templates,
 		logger:           logger,
 	}
 }
 
-// SendNotification envía una notificación a un usuario
+// SetPushAdapters indica con qué adaptadores se envía por FCM y APNS a los
+// dispositivos sin conexión WebSocket ni long-polling
+func (s *NotificationService) SetPushAdapters(adapters PushAdapterResolver) {
+	s.adapters = adapters
+}
+
+// SendNotification envía una notificación a un usuario en el carril de su
+// tipo y prioridad. Con una plantilla, el título y el mensaje se renderizan
+// con ella en lugar de usar los indicados. Si supera una cuota de envío
+// devuelve un *QuotaExceededError y no se guarda; los dispositivos que
+// superan la suya se omiten. Si el carril está lleno devuelve
+// ErrSendQueueFull.
 func (s *NotificationService) SendNotification(
 	ctx context.Context,
 	userID, title, message string,
 	data map[string]interface{},
+	template *TemplateRef,
 	notificationType entity.NotificationType,
+	priority int,
 ) (string, error) {
 	// Crear notificación
 	notification, err := entity.NewNotification(userID, title, message, data, notificationType)
 	if err != nil {
 		return "", ErrInvalidNotificationData
 	}
+	notification.SetTenant(tenantFromContext(ctx))
+	notification.SetSender(senderFromContext(ctx))
+	notification.SetPriority(priority)
 
-	// Guardar en repositorio
-	if err := s.notificationRepo.Save(ctx, notification); err != nil {
-		return "", ErrFailedToSaveNotification
+	if err := s.ApplyTemplate(ctx, notification, template, nil); err != nil {
+		return "", err
 	}
 
+	err = s.Schedule(ctx, notification, func(ctx context.Context) error {
+		return s.sendToUser(ctx, notification)
+	})
+	return sendResult(notification, err)
+}
+
+// sendToUser guarda y entrega una notificación a los dispositivos de su
+// usuario
+func (s *NotificationService) sendToUser(ctx context.Context, notification *entity.Notification) error {
 	// Obtener dispositivos del usuario
 	var userIDUint uint
-	fmt.Sscanf(userID, "%d", &userIDUint)
-	devices, err := s.deviceRepo.GetByUserID(ctx, userIDUint)
+	fmt.Sscanf(notification.UserID, "%d", &userIDUint)
+	devices, err := s.deviceRepo.GetByUserID(ctx, notification.TenantID, userIDUint)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	// Comprobar las cuotas de envío antes de guardar la notificación
+	deviceIDs := make([]uuid.UUID, 0, len(devices))
+	for _, device := range devices {
+		deviceIDs = append(deviceIDs, device.ID)
+	}
+	allowed, err := s.admit(ctx, notification.UserID, deviceIDs)
+	if err != nil {
+		return err
+	}
+
+	// Guardar en repositorio
+	if err := s.notificationRepo.Save(ctx, notification); err != nil {
+		return ErrFailedToSaveNotification
 	}
 
 	if len(devices) == 0 {
-		return notification.ID.String(), ErrUserHasNoDevices
+		return ErrUserHasNoDevices
 	}
 
 	// Intentar enviar a cada dispositivo
@@ -97,6 +165,10 @@ func (s *NotificationService) SendNotification(
 	deliveredToAny := false
 
 	for _, device := range devices {
+		if !slices.Contains(allowed, device.ID) {
+			continue
+		}
+
 		// Primero intentar WebSocket si está conectado
 		if s.wsManager.IsDeviceConnected(device.ID) {
 			// Crear registro de entrega
@@ -107,7 +179,7 @@ func (s *NotificationService) SendNotification(
 			}
 
 			// Preparar payload
-			payload, err := s.prepareNotificationPayload(notification)
+			payload, err := notificationPayload(notification)
 			if err != nil {
 				deliveryErrors = append(deliveryErrors, err)
 				s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Failed to prepare payload")
@@ -127,43 +199,203 @@ func (s *NotificationService) SendNotification(
 			continue
 		}
 
-		// Si WebSocket no está disponible, intentar otros canales (implementar después)
-		// ...
+		// Si el dispositivo usa long-polling, dejar la notificación en su buzón
+		if s.inbox != nil && s.inbox.IsPollingDevice(ctx, device.ID) {
+			if err := s.inbox.Enqueue(ctx, notification.ID, device.ID); err != nil {
+				deliveryErrors = append(deliveryErrors, err)
+				continue
+			}
+			deliveredToAny = true
+			continue
+		}
+
+		// Si WebSocket no está disponible, intentar FCM y luego APNS
+		for _, tokenType := range []entity.TokenType{entity.TokenTypeFCM, entity.TokenTypeAPNS} {
+			sent, err := s.sendPush(ctx, notification, device, tokenType)
+			if err != nil {
+				deliveryErrors = append(deliveryErrors, err)
+				continue
+			}
+			if sent {
+				deliveredToAny = true
+				break
+			}
+		}
 	}
 
 	if !deliveredToAny && len(deliveryErrors) > 0 {
-		return notification.ID.String(), ErrDeliveryFailed
+		return ErrDeliveryFailed
+	}
+
+	return nil
+}
+
+// ApplyTemplate renderiza con una plantilla del tenant de la petición el
+// título, el mensaje y los datos de una notificación, y guarda en ella la
+// referencia a la plantilla. Sin idioma se usa el del dispositivo con acceso
+// más reciente de entre deviceIDs o, si no se indica ninguno, de los del
+// usuario. Los datos de la notificación prevalecen sobre los de la
+// plantilla. Sin plantilla no hace nada.
+func (s *NotificationService) ApplyTemplate(ctx context.Context, notification *entity.Notification, template *TemplateRef, deviceIDs []uuid.UUID) error {
+	if template == nil {
+		return nil
+	}
+	if s.templates == nil {
+		return ErrTemplateNotFound
+	}
+
+	locale := template.Locale
+	if locale == "" {
+		locale = s.deviceLocale(ctx, notification.UserID, deviceIDs)
+	}
+
+	rendered, err := s.templates.RenderVersion(ctx, template.ID, template.Version, locale, template.Variables)
+	if err != nil {
+		return err
+	}
+
+	data, err := notification.GetDataMap()
+	if err != nil {
+		return ErrInvalidNotificationData
+	}
+	for k, v := range rendered.Data {
+		if _, ok := data[k]; !ok {
+			data[k] = v
+		}
+	}
+
+	if err := notification.SetContent(rendered.Title, rendered.Body, data); err != nil {
+		return ErrInvalidNotificationData
+	}
+	notification.SetTemplate(template.ID, rendered.Version, rendered.Locale)
+	return nil
+}
+
+// deviceLocale devuelve el idioma del dispositivo con acceso más reciente que
+// lo tenga de entre deviceIDs o, si no se indica ninguno, de los de un
+// usuario del tenant de la petición; vacío si ninguno lo tiene
+func (s *NotificationService) deviceLocale(ctx context.Context, userID string, deviceIDs []uuid.UUID) string {
+	tenantID := tenantFromContext(ctx)
+
+	var devices []*entity.Device
+	if len(deviceIDs) == 0 {
+		var userIDUint uint
+		fmt.Sscanf(userID, "%d", &userIDUint)
+		userDevices, err := s.deviceRepo.GetByUserID(ctx, tenantID, userIDUint)
+		if err != nil {
+			s.logger.Warn("Error getting devices of user %s: %v", userID, err)
+			return ""
+		}
+		devices = userDevices
+	} else {
+		for _, deviceID := range deviceIDs {
+			device, err := s.deviceRepo.GetByID(ctx, deviceID)
+			if err != nil || device.TenantID != tenantID {
+				continue
+			}
+			devices = append(devices, device)
+		}
+	}
+
+	var latest *entity.Device
+	for _, device := range devices {
+		if device.Locale != nil && (latest == nil || device.LastAccess.After(latest.LastAccess)) {
+			latest = device
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return *latest.Locale
+}
+
+// AdmitUserNotification comprueba las cuotas de envío de una notificación a
+// los dispositivos de un usuario del tenant de la petición y devuelve los que
+// no superan la suya, para enviarla por otro medio
+func (s *NotificationService) AdmitUserNotification(ctx context.Context, userID string) ([]uuid.UUID, error) {
+	var userIDUint uint
+	fmt.Sscanf(userID, "%d", &userIDUint)
+	devices, err := s.deviceRepo.GetByUserID(ctx, tenantFromContext(ctx), userIDUint)
+	if err != nil {
+		return nil, err
 	}
 
-	return notification.ID.String(), nil
+	deviceIDs := make([]uuid.UUID, 0, len(devices))
+	for _, device := range devices {
+		deviceIDs = append(deviceIDs, device.ID)
+	}
+	return s.admit(ctx, userID, deviceIDs)
 }
 
-// prepareNotificationPayload prepara el payload para enviar
-func (s *NotificationService) prepareNotificationPayload(notification *entity.Notification) ([]byte, error) {
+// Schedule ejecuta send, el envío de una notificación, cuando le toca el
+// turno al carril de la notificación y devuelve su error. Sin planificador
+// lo ejecuta directamente.
+func (s *NotificationService) Schedule(ctx context.Context, notification *entity.Notification, send func(ctx context.Context) error) error {
+	if s.scheduler == nil {
+		return send(ctx)
+	}
+	return s.scheduler.Do(ctx, notification.Lane(), send)
+}
+
+// sendResult devuelve el ID de la notificación, si el envío llegó a
+// guardarla, junto con el error del envío
+func sendResult(notification *entity.Notification, err error) (string, error) {
+	if err != nil && !errors.Is(err, ErrUserHasNoDevices) && !errors.Is(err, ErrDeliveryFailed) {
+		return "", err
+	}
+	return notification.ID.String(), err
+}
+
+// admit comprueba las cuotas de envío de una notificación y devuelve los
+// dispositivos que no superan la suya. Sin servicio de cuotas no hay límites.
+func (s *NotificationService) admit(ctx context.Context, userID string, deviceIDs []uuid.UUID) ([]uuid.UUID, error) {
+	if s.quotas == nil {
+		return deviceIDs, nil
+	}
+	return s.quotas.Admit(ctx, userID, deviceIDs)
+}
+
+// notificationPayload prepara el mensaje con el que se envía una
+// notificación por WebSocket
+func notificationPayload(notification *entity.Notification) ([]byte, error) {
 	dataMap, err := notification.GetDataMap()
 	if err != nil {
 		return nil, err
 	}
 
 	payload := map[string]interface{}{
-		"type":            "notification",
-		"notification_id": notification.ID.String(),
-		"title":           notification.Title,
-		"message":         notification.Message,
-		"data":            dataMap,
-		"timestamp":       notification.CreatedAt.Unix(),
+		"type":              "notification",
+		"notification_id":   notification.ID.String(),
+		"title":             notification.Title,
+		"message":           notification.Message,
+		"data":              dataMap,
+		"notification_type": string(notification.NotificationType),
+		"priority":          notification.Priority,
+		"timestamp":         notification.CreatedAt.Unix(),
 	}
 
 	return json.Marshal(payload)
 }
 
-// GetNotification obtiene una notificación por su ID
+// GetNotification obtiene una notificación del tenant de la petición por su
+// ID. Las de otros tenants no se encuentran.
 func (s *NotificationService) GetNotification(ctx context.Context, id uuid.UUID) (*entity.Notification, error) {
-	return s.notificationRepo.GetByID(ctx, id)
+	notification, err := s.notificationRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if notification.TenantID != tenantFromContext(ctx) {
+		return nil, ErrNotificationNotFound
+	}
+	return notification, nil
 }
 
-// GetDeliveryStatus obtiene el estado de entrega de una notificación
+// GetDeliveryStatus obtiene el estado de entrega de una notificación del
+// tenant de la petición
 func (s *NotificationService) GetDeliveryStatus(ctx context.Context, notificationID uuid.UUID) ([]*entity.DeliveryTracking, error) {
+	if _, err := s.GetNotification(ctx, notificationID); err != nil {
+		return nil, err
+	}
 	return s.deliveryRepo.GetByNotificationID(ctx, notificationID)
 }
 
@@ -189,7 +421,7 @@ func (s *NotificationService) ResendFailedNotifications(ctx context.Context, max
 		}
 
 		// Preparar payload
-		payload, err := s.prepareNotificationPayload(notification)
+		payload, err := notificationPayload(notification)
 		if err != nil {
 			s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Failed to prepare payload")
 			continue
@@ -207,7 +439,20 @@ func (s *NotificationService) ResendFailedNotifications(ctx context.Context, max
 			} else {
 				s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Device not connected")
 			}
-		// Implementar otros canales como FCM, APNS, etc.
+		case entity.TokenTypePoll:
+			// Las entregas por long-polling esperan en el buzón del dispositivo
+			continue
+		case entity.TokenTypeFCM, entity.TokenTypeAPNS:
+			token, err := s.tokenRepo.GetByDeviceAndType(ctx, delivery.DeviceID, delivery.Channel)
+			if err != nil || token == nil {
+				s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Token not found")
+				continue
+			}
+			if err := s.push(ctx, notification.TenantID, delivery.Channel, token.Token, notification); err != nil {
+				s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, err.Error())
+				continue
+			}
+			s.deliveryRepo.MarkAsSent(ctx, delivery.ID)
 		default:
 			s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Unsupported channel")
 		}
@@ -218,6 +463,11 @@ func (s *NotificationService) ResendFailedNotifications(ctx context.Context, max
 
 // ConfirmDelivery confirma la entrega de una notificación a un dispositivo
 func (s *NotificationService) ConfirmDelivery(ctx context.Context, notificationID, deviceID uuid.UUID) error {
+	// La notificación debe ser del tenant de la petición
+	if _, err := s.GetNotification(ctx, notificationID); err != nil {
+		return err
+	}
+
 	// Buscar el registro de entrega
 	deliveries, err := s.deliveryRepo.GetByNotificationID(ctx, notificationID)
 	if err != nil {
@@ -233,14 +483,16 @@ func (s *NotificationService) ConfirmDelivery(ctx context.Context, notificationI
 	return errors.New("delivery record not found")
 }
 
-// GetUserNotifications obtiene las notificaciones de un usuario
+// GetUserNotifications obtiene las notificaciones de un usuario del tenant
+// de la petición
 func (s *NotificationService) GetUserNotifications(ctx context.Context, userID string, limit, offset int) ([]*entity.Notification, error) {
-	return s.notificationRepo.GetByUserID(ctx, userID, limit, offset)
+	return s.notificationRepo.GetByUserID(ctx, tenantFromContext(ctx), userID, limit, offset)
 }
 
 // CountUnreadNotifications cuenta las notificaciones no leídas de un usuario
+// del tenant de la petición
 func (s *NotificationService) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
-	return s.notificationRepo.CountUnreadByUser(ctx, userID)
+	return s.notificationRepo.CountUnreadByUser(ctx, tenantFromContext(ctx), userID)
 }
 
 // Añadir estos dos nuevos métodos a la implementación de NotificationService
@@ -255,13 +507,16 @@ func (s *NotificationService) SaveNotification(ctx context.Context, notification
 	return nil
 }
 
-// SendNotificationToDevices envía una notificación a dispositivos específicos de un usuario
+// SendNotificationToDevices envía una notificación a dispositivos específicos de un usuario.
+// Las plantillas, las cuotas de envío y los carriles se aplican como en
+// SendNotification.
 func (s *NotificationService) SendNotificationToDevices(
 	ctx context.Context,
 	userID string,
 	deviceIDs []uuid.UUID,
 	title, message string,
 	data map[string]interface{},
+	template *TemplateRef,
 	notificationType entity.NotificationType,
 	priority int,
 	channels []string,
@@ -271,32 +526,57 @@ func (s *NotificationService) SendNotificationToDevices(
 	if err != nil {
 		return "", ErrInvalidNotificationData
 	}
+	notification.SetTenant(tenantFromContext(ctx))
+	notification.SetSender(senderFromContext(ctx))
+
+	notification.SetPriority(priority)
 
-	// Establecer prioridad si se especificó
-	if priority > 0 {
-		notification.SetPriority(priority)
+	// Si no se proporcionaron deviceIDs, error
+	if len(deviceIDs) == 0 {
+		return "", errors.New("no devices specified")
 	}
 
-	// Guardar en repositorio
-	if err := s.notificationRepo.Save(ctx, notification); err != nil {
-		return "", ErrFailedToSaveNotification
+	if err := s.ApplyTemplate(ctx, notification, template, deviceIDs); err != nil {
+		return "", err
 	}
 
-	// Si no se proporcionaron deviceIDs, error
-	if len(deviceIDs) == 0 {
-		return notification.ID.String(), errors.New("no devices specified")
+	err = s.Schedule(ctx, notification, func(ctx context.Context) error {
+		return s.sendToDevices(ctx, notification, deviceIDs, channels)
+	})
+	return sendResult(notification, err)
+}
+
+// sendToDevices guarda y entrega una notificación a dispositivos concretos
+// por los canales indicados (todos si no se indica ninguno)
+func (s *NotificationService) sendToDevices(
+	ctx context.Context,
+	notification *entity.Notification,
+	deviceIDs []uuid.UUID,
+	channels []string,
+) error {
+	// Comprobar las cuotas de envío antes de guardar la notificación
+	deviceIDs, err := s.admit(ctx, notification.UserID, deviceIDs)
+	if err != nil {
+		return err
+	}
+
+	// Guardar en repositorio
+	if err := s.notificationRepo.Save(ctx, notification); err != nil {
+		return ErrFailedToSaveNotification
 	}
 
 	// Determinar qué canales usar
 	useWebSocket := true
 	useFCM := true
 	useAPNS := true
+	usePoll := true
 
 	if len(channels) > 0 {
 		// Reiniciar flags si se especificaron canales
 		useWebSocket = false
 		useFCM = false
 		useAPNS = false
+		usePoll = false
 
 		// Establecer flags según los canales especificados
 		for _, channel := range channels {
@@ -307,6 +587,8 @@ func (s *NotificationService) SendNotificationToDevices(
 				useFCM = true
 			case "apns":
 				useAPNS = true
+			case "poll":
+				usePoll = true
 			}
 		}
 	}
@@ -316,9 +598,9 @@ func (s *NotificationService) SendNotificationToDevices(
 	deliveredToAny := false
 
 	for _, deviceID := range deviceIDs {
-		// Verificar si el dispositivo existe
-		_, err := s.deviceRepo.GetByID(ctx, deviceID)
-		if err != nil {
+		// Verificar si el dispositivo existe en el tenant de la notificación
+		device, err := s.deviceRepo.GetByID(ctx, deviceID)
+		if err != nil || device.TenantID != notification.TenantID {
 			s.logger.Warn("Device not found: %s", deviceID)
 			continue
 		}
@@ -333,7 +615,7 @@ func (s *NotificationService) SendNotificationToDevices(
 			}
 
 			// Preparar payload
-			payload, err := s.prepareNotificationPayload(notification)
+			payload, err := notificationPayload(notification)
 			if err != nil {
 				deliveryErrors = append(deliveryErrors, err)
 				s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Failed to prepare payload")
@@ -353,54 +635,88 @@ func (s *NotificationService) SendNotificationToDevices(
 			continue
 		}
 
+		// Dejar en el buzón si el dispositivo usa long-polling
+		if usePoll && s.inbox != nil && s.inbox.IsPollingDevice(ctx, deviceID) {
+			if err := s.inbox.Enqueue(ctx, notification.ID, deviceID); err != nil {
+				deliveryErrors = append(deliveryErrors, err)
+			} else {
+				deliveredToAny = true
+			}
+		}
+
 		// Intentar FCM si está habilitado
 		if useFCM {
-			// Obtener token FCM
-			fcmToken, err := s.tokenRepo.GetByDeviceAndType(ctx, deviceID, entity.TokenTypeFCM)
-			if err == nil && fcmToken != nil {
-				// Crear registro de entrega
-				delivery := entity.NewDeliveryTracking(notification.ID, deviceID, entity.TokenTypeFCM)
-				if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
-					deliveryErrors = append(deliveryErrors, err)
-				} else {
-					// Aquí se implementaría el envío FCM
-					// Como no tenemos el servicio FCM implementado, marcamos como pendiente
-					s.logger.Info("FCM delivery would be attempted here for device %s", deviceID)
-
-					// En una implementación real, aquí llamaríamos al servicio FCM
-					// y marcaríamos como enviado o fallido según corresponda
-
-					deliveredToAny = true
-				}
+			if sent, err := s.sendPush(ctx, notification, device, entity.TokenTypeFCM); err != nil {
+				deliveryErrors = append(deliveryErrors, err)
+			} else if sent {
+				deliveredToAny = true
 			}
 		}
 
 		// Intentar APNS si está habilitado
 		if useAPNS {
-			// Obtener token APNS
-			apnsToken, err := s.tokenRepo.GetByDeviceAndType(ctx, deviceID, entity.TokenTypeAPNS)
-			if err == nil && apnsToken != nil {
-				// Crear registro de entrega
-				delivery := entity.NewDeliveryTracking(notification.ID, deviceID, entity.TokenTypeAPNS)
-				if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
-					deliveryErrors = append(deliveryErrors, err)
-				} else {
-					// Aquí se implementaría el envío APNS
-					// Como no tenemos el servicio APNS implementado, marcamos como pendiente
-					s.logger.Info("APNS delivery would be attempted here for device %s", deviceID)
-
-					// En una implementación real, aquí llamaríamos al servicio APNS
-					// y marcaríamos como enviado o fallido según corresponda
-
-					deliveredToAny = true
-				}
+			if sent, err := s.sendPush(ctx, notification, device, entity.TokenTypeAPNS); err != nil {
+				deliveryErrors = append(deliveryErrors, err)
+			} else if sent {
+				deliveredToAny = true
 			}
 		}
 	}
 
 	if !deliveredToAny && len(deliveryErrors) > 0 {
-		return notification.ID.String(), ErrDeliveryFailed
+		return ErrDeliveryFailed
+	}
+
+	return nil
+}
+
+// sendPush entrega una notificación a un dispositivo por FCM o APNS, con el
+// adaptador del tenant del dispositivo, y registra la entrega. Devuelve false
+// sin error si el dispositivo no tiene token de ese canal.
+func (s *NotificationService) sendPush(
+	ctx context.Context,
+	notification *entity.Notification,
+	device *entity.Device,
+	tokenType entity.TokenType,
+) (bool, error) {
+	token, err := s.tokenRepo.GetByDeviceAndType(ctx, device.ID, tokenType)
+	if err != nil || token == nil {
+		return false, nil
+	}
+
+	delivery := entity.NewDeliveryTracking(notification.ID, device.ID, tokenType)
+	if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
+		return false, err
+	}
+
+	if err := s.push(ctx, device.TenantID, tokenType, token.Token, notification); err != nil {
+		s.logger.Warn("Error sending notification to device %s via %s: %v", device.ID, tokenType, err)
+		s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, err.Error())
+		return false, err
+	}
+
+	s.deliveryRepo.MarkAsSent(ctx, delivery.ID)
+	return true, nil
+}
+
+// push envía una notificación a un token de FCM o APNS con el adaptador de
+// un tenant
+func (s *NotificationService) push(
+	ctx context.Context,
+	tenantID string,
+	tokenType entity.TokenType,
+	token string,
+	notification *entity.Notification,
+) error {
+	if s.adapters == nil {
+		return ErrPushNotConfigured
+	}
+
+	adapter, err := s.adapters.Resolve(ctx, tenantID, tokenType)
+	if err != nil {
+		return err
 	}
 
-	return notification.ID.String(), nil
+	_, err = adapter.Send(ctx, token, notification)
+	return err
 }
@@ -0,0 +1,251 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// memNotificationRepo acepta las notificaciones sin guardarlas
+type memNotificationRepo struct {
+	repository.NotificationRepository
+}
+
+func (memNotificationRepo) Save(ctx context.Context, notification *entity.Notification) error {
+	return nil
+}
+
+// memDeliveryRepo guarda el estado de cada entrega
+type memDeliveryRepo struct {
+	repository.DeliveryRepository
+
+	mu         sync.Mutex
+	deliveries map[uuid.UUID]*entity.DeliveryTracking
+}
+
+func newMemDeliveryRepo() *memDeliveryRepo {
+	return &memDeliveryRepo{deliveries: make(map[uuid.UUID]*entity.DeliveryTracking)}
+}
+
+func (r *memDeliveryRepo) Create(ctx context.Context, delivery *entity.DeliveryTracking) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (r *memDeliveryRepo) MarkAsSent(ctx context.Context, id uuid.UUID) error {
+	r.setStatus(id, entity.DeliveryStatusSent)
+	return nil
+}
+
+func (r *memDeliveryRepo) MarkAsFailed(ctx context.Context, id uuid.UUID, errorMsg string) error {
+	r.setStatus(id, entity.DeliveryStatusFailed)
+	return nil
+}
+
+func (r *memDeliveryRepo) setStatus(id uuid.UUID, status entity.DeliveryStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries[id].Status = status
+}
+
+// statuses devuelve el estado de las entregas de cada canal de un dispositivo
+func (r *memDeliveryRepo) statuses(deviceID uuid.UUID) map[entity.TokenType]entity.DeliveryStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make(map[entity.TokenType]entity.DeliveryStatus)
+	for _, delivery := range r.deliveries {
+		if delivery.DeviceID == deviceID {
+			statuses[delivery.Channel] = delivery.Status
+		}
+	}
+	return statuses
+}
+
+// memDeviceRepo tiene los dispositivos de un usuario
+type memDeviceRepo struct {
+	repository.DeviceRepository
+	devices []*entity.Device
+}
+
+func (r *memDeviceRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.Device, error) {
+	for _, device := range r.devices {
+		if device.ID == id {
+			return device, nil
+		}
+	}
+	return nil, repository.ErrDeviceNotFound
+}
+
+func (r *memDeviceRepo) GetByUserID(ctx context.Context, tenantID string, userID uint) ([]*entity.Device, error) {
+	var devices []*entity.Device
+	for _, device := range r.devices {
+		if device.TenantID == tenantID && device.UserID != nil && *device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+// memTokenRepo tiene los tokens de cada dispositivo
+type memTokenRepo struct {
+	repository.TokenRepository
+	tokens []*entity.NotificationToken
+}
+
+func (r *memTokenRepo) GetByDeviceAndType(ctx context.Context, deviceID uuid.UUID, tokenType entity.TokenType) (*entity.NotificationToken, error) {
+	for _, token := range r.tokens {
+		if token.DeviceID == deviceID && token.TokenType == tokenType {
+			return token, nil
+		}
+	}
+	return nil, repository.ErrTokenNotFound
+}
+
+// offlineWebSocket no tiene ningún dispositivo conectado
+type offlineWebSocket struct {
+	WebSocketManager
+}
+
+func (offlineWebSocket) IsDeviceConnected(deviceID uuid.UUID) bool { return false }
+
+// recordingAdapter anota los tokens a los que envía
+type recordingAdapter struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (a *recordingAdapter) Send(ctx context.Context, token string, notification *entity.Notification) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sent = append(a.sent, token)
+	return "message-id", nil
+}
+
+// tenantResolver devuelve el adaptador de cada tenant y canal configurado
+type tenantResolver struct {
+	adapters map[string]map[entity.TokenType]PushAdapter
+}
+
+var errChannelNotConfigured = errors.New("channel not configured")
+
+func (r tenantResolver) Resolve(ctx context.Context, tenantID string, tokenType entity.TokenType) (PushAdapter, error) {
+	adapter, ok := r.adapters[tenantID][tokenType]
+	if !ok {
+		return nil, errChannelNotConfigured
+	}
+	return adapter, nil
+}
+
+// pushTestEnv es un servicio de notificaciones con un dispositivo Android y
+// otro iOS del usuario 7 del tenant acme
+type pushTestEnv struct {
+	service    *NotificationService
+	deliveries *memDeliveryRepo
+	android    *entity.Device
+	ios        *entity.Device
+	fcm        *recordingAdapter
+	apns       *recordingAdapter
+}
+
+func newPushTestEnv(configured ...entity.TokenType) *pushTestEnv {
+	userID := uint(7)
+	android := entity.NewDevice("acme", "android", &userID, nil)
+	ios := entity.NewDevice("acme", "ios", &userID, nil)
+
+	env := &pushTestEnv{
+		deliveries: newMemDeliveryRepo(),
+		android:    android,
+		ios:        ios,
+		fcm:        &recordingAdapter{},
+		apns:       &recordingAdapter{},
+	}
+
+	adapters := map[entity.TokenType]PushAdapter{}
+	for _, tokenType := range configured {
+		switch tokenType {
+		case entity.TokenTypeFCM:
+			adapters[tokenType] = env.fcm
+		case entity.TokenTypeAPNS:
+			adapters[tokenType] = env.apns
+		}
+	}
+
+	env.service = NewNotificationService(
+		memNotificationRepo{},
+		env.deliveries,
+		&memDeviceRepo{devices: []*entity.Device{android, ios}},
+		&memTokenRepo{tokens: []*entity.NotificationToken{
+			entity.NewNotificationToken(android.ID, "fcm-token", entity.TokenTypeFCM),
+			entity.NewNotificationToken(ios.ID, "apns-token", entity.TokenTypeAPNS),
+		}},
+		offlineWebSocket{},
+		nil, nil, nil, nil,
+		logging.NewLogger(logging.WithOutput(io.Discard)),
+	)
+	env.service.SetPushAdapters(tenantResolver{adapters: map[string]map[entity.TokenType]PushAdapter{"acme": adapters}})
+	return env
+}
+
+func TestSendNotificationUsesTenantPushAdapters(t *testing.T) {
+	env := newPushTestEnv(entity.TokenTypeFCM, entity.TokenTypeAPNS)
+	ctx := auth.WithTenant(context.Background(), "acme")
+
+	if _, err := env.service.SendNotification(ctx, "7", "Hola", "Mensaje", nil, nil, entity.NotificationTypeNormal, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(env.fcm.sent) != 1 || env.fcm.sent[0] != "fcm-token" {
+		t.Errorf("FCM sent to %v, want [fcm-token]", env.fcm.sent)
+	}
+	if len(env.apns.sent) != 1 || env.apns.sent[0] != "apns-token" {
+		t.Errorf("APNS sent to %v, want [apns-token]", env.apns.sent)
+	}
+	if status := env.deliveries.statuses(env.android.ID)[entity.TokenTypeFCM]; status != entity.DeliveryStatusSent {
+		t.Errorf("android FCM delivery = %s, want sent", status)
+	}
+	if status := env.deliveries.statuses(env.ios.ID)[entity.TokenTypeAPNS]; status != entity.DeliveryStatusSent {
+		t.Errorf("ios APNS delivery = %s, want sent", status)
+	}
+}
+
+func TestSendNotificationFailsWithoutTenantCredentials(t *testing.T) {
+	env := newPushTestEnv()
+	ctx := auth.WithTenant(context.Background(), "acme")
+
+	_, err := env.service.SendNotification(ctx, "7", "Hola", "Mensaje", nil, nil, entity.NotificationTypeNormal, 0)
+	if !errors.Is(err, ErrDeliveryFailed) {
+		t.Fatalf("err = %v, want ErrDeliveryFailed", err)
+	}
+	if status := env.deliveries.statuses(env.android.ID)[entity.TokenTypeFCM]; status != entity.DeliveryStatusFailed {
+		t.Errorf("android FCM delivery = %s, want failed", status)
+	}
+}
+
+func TestSendNotificationToDevicesHonoursChannels(t *testing.T) {
+	env := newPushTestEnv(entity.TokenTypeFCM, entity.TokenTypeAPNS)
+	ctx := auth.WithTenant(context.Background(), "acme")
+
+	_, err := env.service.SendNotificationToDevices(ctx, "7", []uuid.UUID{env.android.ID, env.ios.ID},
+		"Hola", "Mensaje", nil, nil, entity.NotificationTypeNormal, 0, []string{"apns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(env.fcm.sent) != 0 {
+		t.Errorf("FCM sent to %v with only the apns channel", env.fcm.sent)
+	}
+	if len(env.apns.sent) != 1 {
+		t.Errorf("APNS sent to %v, want [apns-token]", env.apns.sent)
+	}
+}
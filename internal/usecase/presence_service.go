@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"notification-service/internal/domain/repository"
+
+	"github.com/google/uuid"
+)
+
+// Errores del servicio de presencia
+var (
+	ErrTooManyPresenceIDs = errors.New("too many ids in presence query")
+)
+
+// MaxPresenceQuerySize es el número máximo de usuarios por consulta masiva
+const MaxPresenceQuerySize = 100
+
+// ConnectionInfo describe una conexión en tiempo real activa
+type ConnectionInfo struct {
+	DeviceID    uuid.UUID `json:"device_id"`
+	Transport   string    `json:"transport"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// UserPresence describe el estado de conexión de un usuario
+type UserPresence struct {
+	UserID      string           `json:"user_id"`
+	Online      bool             `json:"online"`
+	Connections []ConnectionInfo `json:"connections"`
+}
+
+// DevicePresence describe el estado de conexión de un dispositivo
+type DevicePresence struct {
+	DeviceID    uuid.UUID        `json:"device_id"`
+	Online      bool             `json:"online"`
+	Connections []ConnectionInfo `json:"connections"`
+	LastAccess  *time.Time       `json:"last_access,omitempty"`
+}
+
+// PresenceTracker define las operaciones para consultar las conexiones activas
+type PresenceTracker interface {
+	GetUserConnections(tenantID, userID string) []ConnectionInfo
+	GetDeviceConnections(deviceID uuid.UUID) []ConnectionInfo
+}
+
+// PresenceService expone quién está conectado, por usuario y por dispositivo
+type PresenceService struct {
+	tracker    PresenceTracker
+	deviceRepo repository.DeviceRepository
+}
+
+// NewPresenceService crea una nueva instancia del servicio de presencia
+func NewPresenceService(tracker PresenceTracker, deviceRepo repository.DeviceRepository) *PresenceService {
+	return &PresenceService{
+		tracker:    tracker,
+		deviceRepo: deviceRepo,
+	}
+}
+
+// GetUserPresence obtiene la presencia de un usuario del tenant de la
+// petición
+func (s *PresenceService) GetUserPresence(ctx context.Context, userID string) *UserPresence {
+	connections := s.tracker.GetUserConnections(tenantFromContext(ctx), userID)
+	return &UserPresence{
+		UserID:      userID,
+		Online:      len(connections) > 0,
+		Connections: connections,
+	}
+}
+
+// GetUsersPresence obtiene la presencia de varios usuarios
+func (s *PresenceService) GetUsersPresence(ctx context.Context, userIDs []string) ([]*UserPresence, error) {
+	if len(userIDs) > MaxPresenceQuerySize {
+		return nil, ErrTooManyPresenceIDs
+	}
+
+	result := make([]*UserPresence, 0, len(userIDs))
+	for _, userID := range userIDs {
+		result = append(result, s.GetUserPresence(ctx, userID))
+	}
+
+	return result, nil
+}
+
+// GetDevicePresence obtiene la presencia de un dispositivo, incluyendo su
+// último acceso registrado
+func (s *PresenceService) GetDevicePresence(ctx context.Context, deviceID uuid.UUID) (*DevicePresence, error) {
+	device, err := s.deviceRepo.GetByID(ctx, deviceID)
+	if err != nil || device.TenantID != tenantFromContext(ctx) {
+		return nil, ErrDeviceNotFound
+	}
+
+	connections := s.tracker.GetDeviceConnections(deviceID)
+	presence := &DevicePresence{
+		DeviceID:    deviceID,
+		Online:      len(connections) > 0,
+		Connections: connections,
+	}
+	if !device.LastAccess.IsZero() {
+		lastAccess := device.LastAccess
+		presence.LastAccess = &lastAccess
+	}
+
+	return presence, nil
+}
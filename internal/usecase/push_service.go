@@ -14,6 +14,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// ErrUnsupportedTokenType indica que no hay canal para un tipo de token
+var ErrUnsupportedTokenType = errors.New("unsupported token type")
+
 // PushService define la interfaz para enviar notificaciones push
 type PushService interface {
 	// SendNotification envía una notificación a un dispositivo específico
@@ -32,15 +35,20 @@ type PushAdapter interface {
 	Send(ctx context.Context, token string, notification *entity.Notification) (string, error)
 }
 
+// PushAdapterResolver obtiene el adaptador de FCM o APNS de un tenant, creado
+// con sus propias credenciales
+type PushAdapterResolver interface {
+	Resolve(ctx context.Context, tenantID string, tokenType entity.TokenType) (PushAdapter, error)
+}
+
 // PushServiceImpl implementa PushService
 type PushServiceImpl struct {
 	deviceRepo   repository.DeviceRepository
 	tokenRepo    repository.TokenRepository
 	deliveryRepo repository.DeliveryRepository
-	wsAdapter    PushAdapter // Para WebSocket
-	fcmAdapter   PushAdapter // Para FCM (Android)
-	apnsAdapter  PushAdapter // Para APNS (iOS)
-	logger       logging.Logger
+	wsManager    WebSocketManager    // Para los dispositivos conectados por WebSocket
+	adapters     PushAdapterResolver // Para FCM (Android) y APNS (iOS) de cada tenant
+	logger       *logging.Logger
 }
 
 // NewPushService crea una nueva instancia de PushServiceImpl
@@ -48,69 +56,98 @@ func NewPushService(
 	deviceRepo repository.DeviceRepository,
 	tokenRepo repository.TokenRepository,
 	deliveryRepo repository.DeliveryRepository,
-	wsAdapter PushAdapter,
-	fcmAdapter PushAdapter,
-	apnsAdapter PushAdapter,
-	logger logging.Logger,
+	wsManager WebSocketManager,
+	adapters PushAdapterResolver,
+	logger *logging.Logger,
 ) PushService {
 	return &PushServiceImpl{
 		deviceRepo:   deviceRepo,
 		tokenRepo:    tokenRepo,
 		deliveryRepo: deliveryRepo,
-		wsAdapter:    wsAdapter,
-		fcmAdapter:   fcmAdapter,
-		apnsAdapter:  apnsAdapter,
+		wsManager:    wsManager,
+		adapters:     adapters,
 		logger:       logger,
 	}
 }
 
+// adapterFor devuelve el adaptador con el que se envía a un token de un
+// dispositivo. FCM y APNS usan las credenciales del tenant del dispositivo.
+func (s *PushServiceImpl) adapterFor(ctx context.Context, device *entity.Device, tokenType entity.TokenType) (PushAdapter, error) {
+	switch tokenType {
+	case entity.TokenTypeFCM, entity.TokenTypeAPNS:
+		if s.adapters == nil {
+			return nil, nil
+		}
+		return s.adapters.Resolve(ctx, device.TenantID, tokenType)
+	default:
+		return nil, ErrUnsupportedTokenType
+	}
+}
+
 // SendNotification envía una notificación a un dispositivo específico
 func (s *PushServiceImpl) SendNotification(ctx context.Context, deviceID uuid.UUID, notification *entity.Notification) (string, error) {
-	// nose usa devices revisar urgente
 	// Obtener el dispositivo
-	devices, err := s.deviceRepo.GetByID(ctx, deviceID)
+	device, err := s.deviceRepo.GetByID(ctx, deviceID)
 	if err != nil {
-		s.logger.Error("Error getting device", "deviceID", deviceID, "error", err, "devices", devices)
+		s.logger.Error("Error getting device %s: %v", deviceID, err)
 		return "", fmt.Errorf("error getting device: %w", err)
 	}
 
+	// Una notificación solo se envía a dispositivos de su tenant
+	if device.TenantID != notification.TenantID {
+		s.logger.Warn("Device %s belongs to another tenant", deviceID)
+		return "", ErrDeviceNotFound
+	}
+
+	// Si el dispositivo está conectado, enviar por WebSocket
+	if s.wsManager != nil && s.wsManager.IsDeviceConnected(deviceID) {
+		messageID, err := s.sendWebSocket(ctx, deviceID, notification)
+		if err == nil {
+			return messageID, nil
+		}
+		s.logger.Warn("Error sending notification over WebSocket to device %s: %v", deviceID, err)
+	}
+
 	// Obtener todos los tokens para el dispositivo
 	tokens, err := s.tokenRepo.GetAllForDevice(ctx, deviceID)
 	if err != nil {
-		s.logger.Error("Error getting tokens", "deviceID", deviceID, "error", err)
+		s.logger.Error("Error getting tokens of device %s: %v", deviceID, err)
 		return "", fmt.Errorf("error getting tokens: %w", err)
 	}
 
 	if len(tokens) == 0 {
-		s.logger.Warn("No tokens found for device", "deviceID", deviceID)
+		s.logger.Warn("No tokens found for device %s", deviceID)
 		return "", errors.New("no tokens found for device")
 	}
 
 	// Intentar enviar por cada tipo de token disponible
 	for _, token := range tokens {
+		// WebSocket solo entrega a los dispositivos conectados, ya intentados
+		if token.TokenType == entity.TokenTypeWebSocket {
+			continue
+		}
+
 		// Crear registro de entrega
 		delivery := entity.NewDeliveryTracking(notification.ID, deviceID, token.TokenType)
 		if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
-			s.logger.Error("Error creating delivery tracking", "deviceID", deviceID, "error", err)
+			s.logger.Error("Error creating delivery tracking for device %s: %v", deviceID, err)
 			continue
 		}
 
-		var adapter PushAdapter
-		switch token.TokenType {
-		case entity.TokenTypeWebSocket:
-			adapter = s.wsAdapter
-		case entity.TokenTypeFCM:
-			adapter = s.fcmAdapter
-		case entity.TokenTypeAPNS:
-			adapter = s.apnsAdapter
-		default:
-			s.logger.Warn("Unsupported token type", "type", token.TokenType, "deviceID", deviceID)
+		adapter, err := s.adapterFor(ctx, device, token.TokenType)
+		if errors.Is(err, ErrUnsupportedTokenType) {
+			s.logger.Warn("Unsupported token type %s for device %s", token.TokenType, deviceID)
 			s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Unsupported token type")
 			continue
 		}
+		if err != nil {
+			s.logger.Warn("Adapter %s not available for device %s: %v", token.TokenType, deviceID, err)
+			s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, err.Error())
+			continue
+		}
 
 		if adapter == nil {
-			s.logger.Warn("Adapter not configured", "type", token.TokenType, "deviceID", deviceID)
+			s.logger.Warn("Adapter %s not configured for device %s", token.TokenType, deviceID)
 			s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Adapter not configured")
 			continue
 		}
@@ -118,22 +155,14 @@ func (s *PushServiceImpl) SendNotification(ctx context.Context, deviceID uuid.UU
 		// Enviar notificación
 		messageID, err := adapter.Send(ctx, token.Token, notification)
 		if err != nil {
-			s.logger.Error("Error sending notification",
-				"deviceID", deviceID,
-				"tokenType", token.TokenType,
-				"error", err,
-			)
+			s.logger.Error("Error sending notification to device %s via %s: %v", deviceID, token.TokenType, err)
 			s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, err.Error())
 			continue
 		}
 
 		// Marcar como enviado
 		s.deliveryRepo.MarkAsSent(ctx, delivery.ID)
-		s.logger.Info("Notification sent",
-			"deviceID", deviceID,
-			"tokenType", token.TokenType,
-			"messageID", messageID,
-		)
+		s.logger.Info("Notification sent to device %s via %s: %s", deviceID, token.TokenType, messageID)
 
 		// Devolver el ID del mensaje y salir al primer éxito
 		return messageID, nil
@@ -143,6 +172,29 @@ func (s *PushServiceImpl) SendNotification(ctx context.Context, deviceID uuid.UU
 	return "", errors.New("failed to send notification through any channel")
 }
 
+// sendWebSocket envía una notificación a un dispositivo conectado por
+// WebSocket y devuelve el ID de su registro de entrega
+func (s *PushServiceImpl) sendWebSocket(ctx context.Context, deviceID uuid.UUID, notification *entity.Notification) (string, error) {
+	delivery := entity.NewDeliveryTracking(notification.ID, deviceID, entity.TokenTypeWebSocket)
+	if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
+		return "", fmt.Errorf("error creating delivery tracking: %w", err)
+	}
+
+	payload, err := notificationPayload(notification)
+	if err != nil {
+		s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Failed to prepare payload")
+		return "", err
+	}
+
+	if err := s.wsManager.SendMessage(deviceID, payload); err != nil {
+		s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, err.Error())
+		return "", err
+	}
+
+	s.deliveryRepo.MarkAsSent(ctx, delivery.ID)
+	return delivery.ID.String(), nil
+}
+
 // SendBatchNotification envía una notificación a múltiples dispositivos
 func (s *PushServiceImpl) SendBatchNotification(
 	ctx context.Context,
@@ -191,15 +243,15 @@ func (s *PushServiceImpl) SendUserNotification(
 	var userIDUint uint
 	fmt.Sscanf(userID, "%d", &userIDUint)
 
-	// Obtener todos los dispositivos del usuario
-	devices, err := s.deviceRepo.GetByUserID(ctx, userIDUint)
+	// Obtener todos los dispositivos del usuario en el tenant de la notificación
+	devices, err := s.deviceRepo.GetByUserID(ctx, notification.TenantID, userIDUint)
 	if err != nil {
-		s.logger.Error("Error getting user devices", "userID", userID, "error", err)
+		s.logger.Error("Error getting devices of user %s: %v", userID, err)
 		return nil, map[uuid.UUID]error{uuid.Nil: fmt.Errorf("error getting user devices: %w", err)}
 	}
 
 	if len(devices) == 0 {
-		s.logger.Warn("No devices found for user", "userID", userID)
+		s.logger.Warn("No devices found for user %s", userID)
 		return nil, map[uuid.UUID]error{uuid.Nil: errors.New("no devices found for user")}
 	}
 
@@ -212,87 +264,3 @@ func (s *PushServiceImpl) SendUserNotification(
 	// Enviar a todos los dispositivos
 	return s.SendBatchNotification(ctx, deviceIDs, notification)
 }
-
-// SendNotification envía una notificación a un dispositivo específico
-func (s *PushServiceImpl) SendNotificationX(ctx context.Context, deviceID uuid.UUID, notification *entity.Notification) (string, error) {
-	// Obtener el dispositivo (simplemente para verificar que existe)
-	device, err := s.deviceRepo.GetByID(ctx, deviceID)
-	if err != nil {
-		s.logger.Error("Error getting device", "deviceID", deviceID, "error", err)
-		return "", fmt.Errorf("error getting device: %w", err)
-	}
-
-	// Verificar que el dispositivo existe
-	if device == nil {
-		s.logger.Error("Device not found", "deviceID", deviceID)
-		return "", errors.New("device not found")
-	}
-
-	// Obtener todos los tokens para el dispositivo
-	tokens, err := s.tokenRepo.GetAllForDevice(ctx, deviceID)
-	if err != nil {
-		s.logger.Error("Error getting tokens", "deviceID", deviceID, "error", err)
-		return "", fmt.Errorf("error getting tokens: %w", err)
-	}
-
-	if len(tokens) == 0 {
-		s.logger.Warn("No tokens found for device", "deviceID", deviceID)
-		return "", errors.New("no tokens found for device")
-	}
-
-	// Intentar enviar por cada tipo de token disponible
-	for _, token := range tokens {
-		// Crear registro de entrega
-		delivery := entity.NewDeliveryTracking(notification.ID, deviceID, token.TokenType)
-		if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
-			s.logger.Error("Error creating delivery tracking", "deviceID", deviceID, "error", err)
-			continue
-		}
-
-		var adapter PushAdapter
-		switch token.TokenType {
-		case entity.TokenTypeWebSocket:
-			adapter = s.wsAdapter
-		case entity.TokenTypeFCM:
-			adapter = s.fcmAdapter
-		case entity.TokenTypeAPNS:
-			adapter = s.apnsAdapter
-		default:
-			s.logger.Warn("Unsupported token type", "type", token.TokenType, "deviceID", deviceID)
-			s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Unsupported token type")
-			continue
-		}
-
-		if adapter == nil {
-			s.logger.Warn("Adapter not configured", "type", token.TokenType, "deviceID", deviceID)
-			s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, "Adapter not configured")
-			continue
-		}
-
-		// Enviar notificación
-		messageID, err := adapter.Send(ctx, token.Token, notification)
-		if err != nil {
-			s.logger.Error("Error sending notification",
-				"deviceID", deviceID,
-				"tokenType", token.TokenType,
-				"error", err,
-			)
-			s.deliveryRepo.MarkAsFailed(ctx, delivery.ID, err.Error())
-			continue
-		}
-
-		// Marcar como enviado
-		s.deliveryRepo.MarkAsSent(ctx, delivery.ID)
-		s.logger.Info("Notification sent",
-			"deviceID", deviceID,
-			"tokenType", token.TokenType,
-			"messageID", messageID,
-		)
-
-		// Devolver el ID del mensaje y salir al primer éxito
-		return messageID, nil
-	}
-
-	// Si llegamos aquí, es que ningún envío tuvo éxito
-	return "", errors.New("failed to send notification through any channel")
-}
@@ -0,0 +1,463 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/events"
+	"notification-service/pkg/logging"
+	"notification-service/pkg/throttling"
+
+	"github.com/google/uuid"
+)
+
+// ErrQuotaExceeded indica que un envío supera una cuota; el error concreto
+// es un *QuotaExceededError
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Ventanas de las cuotas de envío
+const (
+	QuotaWindowSecond = "second"
+	QuotaWindowDay    = "day"
+)
+
+// quotaDayLayout es el formato de los días (UTC) de los contadores de uso
+const quotaDayLayout = "2006-01-02"
+
+// Quota es el límite de envíos de un nivel por segundo y por día. 0 no
+// limita.
+type Quota struct {
+	PerSecond float64
+	PerDay    int64
+}
+
+// valid indica si la cuota no es negativa
+func (q Quota) valid() bool {
+	return q.PerSecond >= 0 && q.PerDay >= 0
+}
+
+// QuotaExceededError indica el nivel y la ventana cuya cuota supera un envío
+// y cuándo puede reintentarse
+type QuotaExceededError struct {
+	Level      entity.QuotaLevel
+	Window     string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s quota exceeded (per %s)", e.Level, e.Window)
+}
+
+// Is hace que errors.Is(err, ErrQuotaExceeded) reconozca el error
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// quotaSubject es lo limitado en un nivel: vacío en los niveles global y
+// tenant, el nombre del servicio, el ID del usuario o el del dispositivo
+type quotaSubject struct {
+	level   entity.QuotaLevel
+	subject string
+}
+
+// usageKey identifica el contador de uso de un día
+type usageKey struct {
+	day      string
+	level    entity.QuotaLevel
+	tenantID string
+	subject  string
+}
+
+// usageCounter es el uso de un día. total incluye lo guardado por todas las
+// instancias hasta el último Flush y lo admitido aquí desde entonces.
+type usageCounter struct {
+	total     int64
+	pending   int64
+	throttled int64
+}
+
+// cachedTenantQuota es la cuota propia de un tenant leída de la base de datos
+type cachedTenantQuota struct {
+	quota    Quota
+	loadedAt time.Time
+}
+
+// QuotaService aplica las cuotas de envío por niveles: global, tenant,
+// servicio que envía, usuario destinatario y dispositivo. Las cuotas por
+// segundo se aplican con el RateLimiter configurado, en memoria o compartido
+// entre instancias; las diarias se comparten a través de los contadores de
+// uso, que se guardan cada flushInterval y sirven también para facturación e
+// informes.
+type QuotaService struct {
+	usageRepo     repository.QuotaUsageRepository
+	tenantService *TenantService
+	limiter       throttling.RateLimiter
+	defaults      map[entity.QuotaLevel]Quota
+	eventManager  *events.EventManager
+	flushInterval time.Duration
+	logger        *logging.Logger
+
+	mu           sync.Mutex
+	usage        map[usageKey]*usageCounter
+	tenantQuotas map[string]cachedTenantQuota
+}
+
+// NewQuotaService crea una nueva instancia del servicio de cuotas. defaults
+// son las cuotas de cada nivel; las de un tenant pueden sustituir a las del
+// nivel tenant.
+func NewQuotaService(
+	usageRepo repository.QuotaUsageRepository,
+	tenantService *TenantService,
+	limiter throttling.RateLimiter,
+	defaults map[entity.QuotaLevel]Quota,
+	eventManager *events.EventManager,
+	flushInterval time.Duration,
+	logger *logging.Logger,
+) *QuotaService {
+	return &QuotaService{
+		usageRepo:     usageRepo,
+		tenantService: tenantService,
+		limiter:       limiter,
+		defaults:      defaults,
+		eventManager:  eventManager,
+		flushInterval: flushInterval,
+		logger:        logger,
+		usage:         make(map[usageKey]*usageCounter),
+		tenantQuotas:  make(map[string]cachedTenantQuota),
+	}
+}
+
+// Admit comprueba las cuotas de un envío a un usuario del tenant de la
+// petición, del nivel global al de cada dispositivo, y lo cuenta si se
+// admite. Devuelve los dispositivos que no superan su cuota. Si un nivel
+// superior la supera, o la superan todos los dispositivos, devuelve un
+// *QuotaExceededError y no cuenta nada.
+func (s *QuotaService) Admit(ctx context.Context, userID string, deviceIDs []uuid.UUID) ([]uuid.UUID, error) {
+	tenantID := tenantFromContext(ctx)
+	tenantQuota, err := s.tenantQuota(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	subjects := []quotaSubject{
+		{level: entity.QuotaLevelGlobal},
+		{level: entity.QuotaLevelTenant},
+	}
+	if sender := senderFromContext(ctx); sender != "" {
+		subjects = append(subjects, quotaSubject{level: entity.QuotaLevelSender, subject: sender})
+	}
+	if userID != "" {
+		subjects = append(subjects, quotaSubject{level: entity.QuotaLevelUser, subject: userID})
+	}
+
+	now := time.Now()
+	day := now.UTC().Format(quotaDayLayout)
+
+	// Los eventos por segundo se devuelven si el envío no se admite
+	var reservations []*throttling.Reservation
+	cancel := func() {
+		for _, reservation := range reservations {
+			if err := reservation.Cancel(ctx); err != nil {
+				s.logger.Warn("Error releasing rate limit reservation: %v", err)
+			}
+		}
+	}
+
+	for _, subject := range subjects {
+		quota := s.quotaFor(subject.level, tenantQuota)
+		reservation, exceeded := s.check(ctx, now, day, tenantID, subject, quota)
+		if exceeded != nil {
+			cancel()
+			s.throttled(day, tenantID, subject, quota, exceeded)
+			return nil, exceeded
+		}
+		if reservation != nil {
+			reservations = append(reservations, reservation)
+		}
+	}
+
+	// Un dispositivo que supera su cuota se omite sin rechazar el envío
+	deviceQuota := s.defaults[entity.QuotaLevelDevice]
+	allowed := make([]uuid.UUID, 0, len(deviceIDs))
+	var deviceExceeded *QuotaExceededError
+	for _, deviceID := range deviceIDs {
+		subject := quotaSubject{level: entity.QuotaLevelDevice, subject: deviceID.String()}
+		reservation, exceeded := s.check(ctx, now, day, tenantID, subject, deviceQuota)
+		if exceeded != nil {
+			s.throttled(day, tenantID, subject, deviceQuota, exceeded)
+			if deviceExceeded == nil || exceeded.RetryAfter < deviceExceeded.RetryAfter {
+				deviceExceeded = exceeded
+			}
+			continue
+		}
+		if reservation != nil {
+			reservations = append(reservations, reservation)
+		}
+		allowed = append(allowed, deviceID)
+	}
+	if len(deviceIDs) > 0 && len(allowed) == 0 {
+		cancel()
+		return nil, deviceExceeded
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, subject := range subjects {
+		s.count(day, tenantID, subject, s.quotaFor(subject.level, tenantQuota))
+	}
+	for _, deviceID := range allowed {
+		s.count(day, tenantID, quotaSubject{level: entity.QuotaLevelDevice, subject: deviceID.String()}, deviceQuota)
+	}
+
+	return allowed, nil
+}
+
+// Usage obtiene el uso diario de los tenants y de sus servicios entre dos
+// días, de un tenant o, si tenantID está vacío, de todos y del servicio
+// completo. No incluye lo admitido desde el último Flush.
+func (s *QuotaService) Usage(ctx context.Context, tenantID string, from, to time.Time) ([]*entity.QuotaUsage, error) {
+	levels := []entity.QuotaLevel{entity.QuotaLevelTenant, entity.QuotaLevelSender}
+	if tenantID == "" {
+		levels = append(levels, entity.QuotaLevelGlobal)
+	}
+	return s.usageRepo.List(ctx, tenantID, levels, from, to)
+}
+
+// Run guarda periódicamente el uso de las cuotas hasta que se cancele el
+// contexto
+func (s *QuotaService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Flush(ctx); err != nil {
+				s.logger.Error("Error saving quota usage: %v", err)
+			}
+		}
+	}
+}
+
+// Flush guarda el uso admitido y rechazado desde la última vez y actualiza
+// los totales con lo que han guardado las demás instancias. Los contadores
+// de días anteriores se descartan una vez guardados.
+func (s *QuotaService) Flush(ctx context.Context) error {
+	today := time.Now().UTC().Format(quotaDayLayout)
+
+	s.mu.Lock()
+	batch := make(map[usageKey]*entity.QuotaUsage)
+	for key, counter := range s.usage {
+		if counter.pending == 0 && counter.throttled == 0 {
+			if key.day != today {
+				delete(s.usage, key)
+			}
+			continue
+		}
+
+		day, _ := time.Parse(quotaDayLayout, key.day)
+		batch[key] = &entity.QuotaUsage{
+			Day:       day,
+			Level:     key.level,
+			TenantID:  key.tenantID,
+			Subject:   key.subject,
+			Count:     counter.pending,
+			Throttled: counter.throttled,
+		}
+		counter.pending = 0
+		counter.throttled = 0
+	}
+	s.mu.Unlock()
+
+	var firstErr error
+	for key, usage := range batch {
+		total, err := s.usageRepo.Add(ctx, usage)
+
+		s.mu.Lock()
+		counter := s.counter(key)
+		if err != nil {
+			// Se reintenta en el siguiente Flush
+			counter.pending += usage.Count
+			counter.throttled += usage.Throttled
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			counter.total = total.Count + counter.pending
+		}
+		s.mu.Unlock()
+	}
+
+	return firstErr
+}
+
+// check comprueba la cuota diaria de un nivel y reserva su evento por
+// segundo, que debe cancelarse si el envío no se admite. Si el limitador
+// falla, el envío no se limita por segundo.
+func (s *QuotaService) check(ctx context.Context, now time.Time, day, tenantID string, subject quotaSubject, quota Quota) (*throttling.Reservation, *QuotaExceededError) {
+	if quota.PerDay > 0 {
+		s.mu.Lock()
+		counter, ok := s.usage[s.usageKey(day, tenantID, subject)]
+		exceeded := ok && counter.total >= quota.PerDay
+		s.mu.Unlock()
+
+		if exceeded {
+			return nil, &QuotaExceededError{
+				Level:      subject.level,
+				Window:     QuotaWindowDay,
+				RetryAfter: untilNextDay(now),
+			}
+		}
+	}
+
+	if quota.PerSecond <= 0 {
+		return nil, nil
+	}
+
+	limit := throttling.Limit{Rate: quota.PerSecond, Burst: quotaBurst(quota.PerSecond)}
+	reservation, err := s.limiter.Reserve(ctx, rateLimitKey(tenantID, subject), limit)
+	if err != nil {
+		s.logger.Error("Error checking %s rate limit: %v", subject.level, err)
+		return nil, nil
+	}
+	if !reservation.OK {
+		return nil, &QuotaExceededError{
+			Level:      subject.level,
+			Window:     QuotaWindowSecond,
+			RetryAfter: reservation.RetryAfter,
+		}
+	}
+
+	return reservation, nil
+}
+
+// count cuenta un envío admitido en el uso del día. Debe llamarse con el
+// mutex tomado.
+func (s *QuotaService) count(day, tenantID string, subject quotaSubject, quota Quota) {
+	if !tracksUsage(subject.level, quota) {
+		return
+	}
+
+	counter := s.counter(s.usageKey(day, tenantID, subject))
+	counter.total++
+	counter.pending++
+}
+
+// throttled cuenta un envío rechazado y emite EventThrottlingApplied
+func (s *QuotaService) throttled(day, tenantID string, subject quotaSubject, quota Quota, exceeded *QuotaExceededError) {
+	if tracksUsage(subject.level, quota) {
+		s.mu.Lock()
+		s.counter(s.usageKey(day, tenantID, subject)).throttled++
+		s.mu.Unlock()
+	}
+
+	s.eventManager.EmitEvent(events.EventThrottlingApplied, map[string]interface{}{
+		"tenant_id":   tenantID,
+		"level":       string(subject.level),
+		"subject":     subject.subject,
+		"window":      exceeded.Window,
+		"retry_after": exceeded.RetryAfter.Seconds(),
+	})
+}
+
+// counter obtiene el contador de uso de una clave, creándolo si no existe
+func (s *QuotaService) counter(key usageKey) *usageCounter {
+	counter, ok := s.usage[key]
+	if !ok {
+		counter = &usageCounter{}
+		s.usage[key] = counter
+	}
+	return counter
+}
+
+// usageKey devuelve la clave del contador de uso de un nivel. El nivel global
+// no pertenece a ningún tenant.
+func (s *QuotaService) usageKey(day, tenantID string, subject quotaSubject) usageKey {
+	if subject.level == entity.QuotaLevelGlobal {
+		tenantID = ""
+	}
+	return usageKey{day: day, level: subject.level, tenantID: tenantID, subject: subject.subject}
+}
+
+// rateLimitKey devuelve la clave del limitador por segundo de un nivel
+func rateLimitKey(tenantID string, subject quotaSubject) string {
+	switch subject.level {
+	case entity.QuotaLevelGlobal:
+		return "quota:global"
+	case entity.QuotaLevelDevice:
+		return "quota:device:" + subject.subject
+	default:
+		return "quota:" + string(subject.level) + ":" + tenantID + "/" + subject.subject
+	}
+}
+
+// quotaFor devuelve la cuota de un nivel; la del tenant sustituye a la de la
+// configuración en el nivel tenant
+func (s *QuotaService) quotaFor(level entity.QuotaLevel, tenantQuota Quota) Quota {
+	quota := s.defaults[level]
+	if level == entity.QuotaLevelTenant {
+		if tenantQuota.PerSecond > 0 {
+			quota.PerSecond = tenantQuota.PerSecond
+		}
+		if tenantQuota.PerDay > 0 {
+			quota.PerDay = tenantQuota.PerDay
+		}
+	}
+	return quota
+}
+
+// tenantQuota obtiene la cuota propia de un tenant, de la caché o de la base
+// de datos
+func (s *QuotaService) tenantQuota(ctx context.Context, tenantID string) (Quota, error) {
+	s.mu.Lock()
+	cached, ok := s.tenantQuotas[tenantID]
+	s.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < tenantCacheTTL {
+		return cached.quota, nil
+	}
+
+	tenant, err := s.tenantService.GetTenant(ctx, tenantID)
+	if err != nil {
+		return Quota{}, err
+	}
+	quota := Quota{PerSecond: tenant.QuotaPerSecond, PerDay: tenant.QuotaPerDay}
+
+	s.mu.Lock()
+	s.tenantQuotas[tenantID] = cachedTenantQuota{quota: quota, loadedAt: time.Now()}
+	s.mu.Unlock()
+
+	return quota, nil
+}
+
+// tracksUsage indica si se lleva el uso diario de un nivel: siempre el
+// global, el de los tenants y el de los servicios, que se facturan, y el de
+// los usuarios y dispositivos solo si tienen cuota diaria
+func tracksUsage(level entity.QuotaLevel, quota Quota) bool {
+	switch level {
+	case entity.QuotaLevelGlobal, entity.QuotaLevelTenant, entity.QuotaLevelSender:
+		return true
+	default:
+		return quota.PerDay > 0
+	}
+}
+
+// quotaBurst es el número de envíos seguidos que admite una cuota por
+// segundo: los de un segundo completo
+func quotaBurst(perSecond float64) int {
+	return max(int(math.Ceil(perSecond)), 1)
+}
+
+// untilNextDay es el tiempo que falta para que empiece el siguiente día UTC
+func untilNextDay(now time.Time) time.Duration {
+	today := now.UTC().Truncate(24 * time.Hour)
+	return today.Add(24 * time.Hour).Sub(now)
+}
@@ -0,0 +1,219 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/pkg/logging"
+	"notification-service/pkg/metrics"
+)
+
+// Errores del planificador de envíos
+var (
+	ErrSendQueueFull = errors.New("send queue is full")
+)
+
+// sendLaneOrder es el orden en que se recorren los carriles; a igualdad de
+// turno gana el primero
+var sendLaneOrder = []entity.SendLane{entity.SendLaneUrgent, entity.SendLaneNormal, entity.SendLaneBulk}
+
+// SendLaneConfig es la configuración de un carril de envío
+type SendLaneConfig struct {
+	// Con todos los carriles ocupados, cada uno recibe una parte de los
+	// workers proporcional a su peso
+	Weight int
+
+	// Máximo de envíos en espera; los que no caben se rechazan con
+	// ErrSendQueueFull
+	Capacity int
+}
+
+// SendScheduler reparte los workers de envío entre los carriles de prioridad
+// con round-robin ponderado suave. Un carril sin envíos en espera no consume
+// turnos, y uno ocupado nunca deja sin turno a los demás: una campaña masiva
+// no retrasa más que un envío en curso a un código de verificación urgente.
+type SendScheduler struct {
+	lanes   map[entity.SendLane]*sendLane
+	workers int
+	logger  *logging.Logger
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// sendLane es la cola de envíos en espera de un carril
+type sendLane struct {
+	SendLaneConfig
+	name    entity.SendLane
+	current int
+	jobs    []*sendJob
+}
+
+// sendJob es un envío en espera de un worker
+type sendJob struct {
+	ctx      context.Context
+	run      func(ctx context.Context) error
+	queuedAt time.Time
+	done     chan error
+}
+
+// NewSendScheduler crea un nuevo SendScheduler con workers workers. Los
+// carriles sin configuración tienen peso 1 y capacidad ilimitada.
+func NewSendScheduler(lanes map[entity.SendLane]SendLaneConfig, workers int, logger *logging.Logger) *SendScheduler {
+	s := &SendScheduler{
+		lanes:   make(map[entity.SendLane]*sendLane, len(sendLaneOrder)),
+		workers: max(workers, 1),
+		logger:  logger,
+	}
+	s.cond = sync.NewCond(&s.mu)
+
+	for _, name := range sendLaneOrder {
+		config := lanes[name]
+		config.Weight = max(config.Weight, 1)
+		s.lanes[name] = &sendLane{SendLaneConfig: config, name: name}
+	}
+
+	return s
+}
+
+// Start arranca los workers
+func (s *SendScheduler) Start() {
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+}
+
+// Stop deja de aceptar envíos y espera a que los workers terminen los que
+// quedan en espera
+func (s *SendScheduler) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Do ejecuta run en un worker cuando le toca el turno a su carril y espera a
+// que termine. Si el contexto se cancela antes, devuelve su error, y run no
+// se ejecuta si aún no había empezado. Con el planificador detenido, run se
+// ejecuta directamente.
+func (s *SendScheduler) Do(ctx context.Context, lane entity.SendLane, run func(ctx context.Context) error) error {
+	l, ok := s.lanes[lane]
+	if !ok {
+		l = s.lanes[entity.SendLaneNormal]
+	}
+
+	job := &sendJob{
+		ctx:      ctx,
+		run:      run,
+		queuedAt: time.Now(),
+		done:     make(chan error, 1),
+	}
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return run(ctx)
+	}
+	if l.Capacity > 0 && len(l.jobs) >= l.Capacity {
+		s.mu.Unlock()
+		return ErrSendQueueFull
+	}
+	l.jobs = append(l.jobs, job)
+	depth := len(l.jobs)
+	s.cond.Signal()
+	s.mu.Unlock()
+
+	metrics.SetNotificationQueueSize(string(l.name), depth)
+
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work ejecuta envíos hasta que el planificador se detiene y no queda
+// ninguno en espera
+func (s *SendScheduler) work() {
+	defer s.wg.Done()
+
+	for {
+		s.mu.Lock()
+		lane := s.next()
+		for lane == nil && !s.stopped {
+			s.cond.Wait()
+			lane = s.next()
+		}
+		if lane == nil {
+			s.mu.Unlock()
+			return
+		}
+
+		job := lane.jobs[0]
+		lane.jobs[0] = nil
+		lane.jobs = lane.jobs[1:]
+		depth := len(lane.jobs)
+		if depth == 0 {
+			// Un carril vacío no acumula turnos para cuando vuelva a tener envíos
+			lane.current = 0
+		}
+		s.mu.Unlock()
+
+		metrics.SetNotificationQueueSize(string(lane.name), depth)
+		metrics.ObserveNotificationQueueLatency(string(lane.name), time.Since(job.queuedAt))
+
+		job.done <- s.run(job)
+	}
+}
+
+// next elige, entre los carriles con envíos en espera, el del siguiente
+// envío, o nil si no hay ninguno. Debe llamarse con el mutex bloqueado.
+func (s *SendScheduler) next() *sendLane {
+	var best *sendLane
+	total := 0
+
+	for _, name := range sendLaneOrder {
+		lane := s.lanes[name]
+		if len(lane.jobs) == 0 {
+			continue
+		}
+
+		lane.current += lane.Weight
+		total += lane.Weight
+		if best == nil || lane.current > best.current {
+			best = lane
+		}
+	}
+
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+// run ejecuta un envío salvo que su contexto ya esté cancelado. Un pánico
+// del envío se registra y no detiene el worker.
+func (s *SendScheduler) run(job *sendJob) (err error) {
+	if err := job.ctx.Err(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("Panic while sending notification: %v", r)
+			err = fmt.Errorf("%w: %v", ErrDeliveryFailed, r)
+		}
+	}()
+
+	return job.run(job.ctx)
+}
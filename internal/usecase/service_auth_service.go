@@ -0,0 +1,296 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"sync"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/logging"
+	"notification-service/pkg/throttling"
+
+	"github.com/google/uuid"
+)
+
+// Errores de la autenticación de servicios
+var (
+	ErrCredentialsRequired      = errors.New("credentials required")
+	ErrInvalidAPIKey            = errors.New("invalid api key")
+	ErrUnknownClientCertificate = errors.New("unknown client certificate")
+	ErrAPIKeyNotFound           = errors.New("api key not found")
+	ErrInvalidAPIKeyRequest     = errors.New("invalid api key request")
+)
+
+// apiKeyCacheTTL es cuánto tiempo se reutiliza una API key leída de la base
+// de datos; una revocación en otra instancia tarda como mucho esto en aplicarse
+const apiKeyCacheTTL = 30 * time.Second
+
+// bootstrapPrincipalName es el nombre del servicio que usa la API key de
+// administración de la configuración
+const bootstrapPrincipalName = "admin"
+
+// cachedAPIKey es una API key leída de la base de datos
+type cachedAPIKey struct {
+	key      *entity.APIKey
+	loadedAt time.Time
+}
+
+// ServiceAuthService autentica a los servicios que llaman a la API, con API
+// keys guardadas en la base de datos o con certificados de cliente (mTLS)
+// cuyo Common Name está en la lista de identidades configuradas, y aplica el
+// límite de peticiones de cada uno.
+type ServiceAuthService struct {
+	keyRepo          repository.APIKeyRepository
+	identities       map[string][]string
+	bootstrapKeyHash string
+	defaultRateLimit float64
+	defaultRateBurst int
+	limiter          throttling.RateLimiter
+	logger           *logging.Logger
+
+	mu    sync.Mutex
+	cache map[string]cachedAPIKey
+}
+
+// NewServiceAuthService crea una nueva instancia del servicio de
+// autenticación de servicios. identities asocia el Common Name de cada
+// certificado de cliente aceptado con sus ámbitos; bootstrapKey, si no está
+// vacía, es una API key con ámbito admin para crear las demás. Los límites
+// de peticiones se aplican con limiter.
+func NewServiceAuthService(
+	keyRepo repository.APIKeyRepository,
+	identities map[string][]string,
+	bootstrapKey string,
+	defaultRateLimit float64,
+	defaultRateBurst int,
+	limiter throttling.RateLimiter,
+	logger *logging.Logger,
+) *ServiceAuthService {
+	service := &ServiceAuthService{
+		keyRepo:          keyRepo,
+		identities:       identities,
+		defaultRateLimit: defaultRateLimit,
+		defaultRateBurst: defaultRateBurst,
+		limiter:          limiter,
+		logger:           logger,
+		cache:            make(map[string]cachedAPIKey),
+	}
+	if bootstrapKey != "" {
+		service.bootstrapKeyHash = auth.HashOpaqueToken(bootstrapKey)
+	}
+	return service
+}
+
+// AuthenticateAPIKey autentica a un servicio por su API key
+func (s *ServiceAuthService) AuthenticateAPIKey(ctx context.Context, apiKey string) (*auth.Principal, error) {
+	if apiKey == "" {
+		return nil, ErrCredentialsRequired
+	}
+
+	// La clave de administración de la configuración puede no tener prefijo
+	hash := auth.HashOpaqueToken(apiKey)
+	if s.bootstrapKeyHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(s.bootstrapKeyHash)) == 1 {
+		return &auth.Principal{
+			Name:   bootstrapPrincipalName,
+			Method: auth.AuthMethodAPIKey,
+			Scopes: []string{auth.ScopeAdmin},
+		}, nil
+	}
+	if !auth.IsAPIKey(apiKey) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.lookupAPIKey(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !key.IsActive() {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return &auth.Principal{
+		Name:      key.Name,
+		Method:    auth.AuthMethodAPIKey,
+		Scopes:    key.Scopes,
+		KeyID:     key.ID.String(),
+		RateLimit: key.RateLimit,
+		RateBurst: key.RateBurst,
+		TenantID:  key.TenantID,
+	}, nil
+}
+
+// AuthenticateCertificate autentica a un servicio por su certificado de
+// cliente, ya verificado contra la CA en el handshake TLS
+func (s *ServiceAuthService) AuthenticateCertificate(cert *x509.Certificate) (*auth.Principal, error) {
+	name := cert.Subject.CommonName
+	scopes, ok := s.identities[name]
+	if !ok || name == "" {
+		return nil, ErrUnknownClientCertificate
+	}
+
+	return &auth.Principal{
+		Name:   name,
+		Method: auth.AuthMethodMTLS,
+		Scopes: scopes,
+	}, nil
+}
+
+// Allow indica si el servicio puede hacer otra petición según su límite y,
+// si no, cuánto debe esperar. Si el limitador falla, la petición se admite.
+func (s *ServiceAuthService) Allow(ctx context.Context, principal *auth.Principal) (bool, time.Duration) {
+	if principal.RateLimit <= 0 {
+		return true, 0
+	}
+
+	// Un límite por servicio de cada tenant
+	limit := throttling.Limit{Rate: principal.RateLimit, Burst: max(principal.RateBurst, 1)}
+	reservation, err := s.limiter.Reserve(ctx, "api_key:"+principal.TenantID+"/"+principal.Name, limit)
+	if err != nil {
+		s.logger.Error("Error checking rate limit of service %s: %v", principal.Name, err)
+		return true, 0
+	}
+
+	return reservation.OK, reservation.RetryAfter
+}
+
+// CreateAPIKey crea una API key de un tenant, o de la plataforma si tenantID
+// está vacío. Devuelve la clave en claro, que no se vuelve a poder consultar.
+// Sin límite explícito se aplica el por defecto.
+func (s *ServiceAuthService) CreateAPIKey(
+	ctx context.Context,
+	tenantID string,
+	name string,
+	scopes []string,
+	rateLimit float64,
+	rateBurst int,
+	expiresAt *time.Time,
+) (*entity.APIKey, string, error) {
+	if name == "" || name == bootstrapPrincipalName || len(scopes) == 0 || rateLimit < 0 || rateBurst < 0 {
+		return nil, "", ErrInvalidAPIKeyRequest
+	}
+	for _, scope := range scopes {
+		if !auth.IsKnownScope(scope) {
+			return nil, "", ErrInvalidAPIKeyRequest
+		}
+	}
+
+	if rateLimit == 0 {
+		rateLimit = s.defaultRateLimit
+	}
+	if rateBurst == 0 {
+		rateBurst = s.defaultRateBurst
+	}
+
+	plaintext, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &entity.APIKey{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		Name:      name,
+		Prefix:    plaintext[:len(auth.APIKeyPrefix)+6],
+		KeyHash:   auth.HashOpaqueToken(plaintext),
+		Scopes:    scopes,
+		RateLimit: rateLimit,
+		RateBurst: rateBurst,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.keyRepo.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	s.logger.Info("Created API key %s for service %s of tenant %q with scopes %v", key.Prefix, key.Name, key.TenantID, key.Scopes)
+	return key, plaintext, nil
+}
+
+// ListAPIKeys obtiene las API keys de un tenant, o todas si tenantID está
+// vacío
+func (s *ServiceAuthService) ListAPIKeys(ctx context.Context, tenantID string) ([]*entity.APIKey, error) {
+	if tenantID == "" {
+		return s.keyRepo.List(ctx)
+	}
+	return s.keyRepo.ListByTenant(ctx, tenantID)
+}
+
+// RevokeAPIKey revoca una API key de un tenant, o cualquiera si tenantID
+// está vacío. Las de otros tenants no se encuentran.
+func (s *ServiceAuthService) RevokeAPIKey(ctx context.Context, tenantID string, id uuid.UUID) error {
+	if tenantID != "" {
+		key, err := s.keyRepo.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrAPIKeyNotFound) {
+				return ErrAPIKeyNotFound
+			}
+			return err
+		}
+		if key.TenantID != tenantID {
+			return ErrAPIKeyNotFound
+		}
+	}
+
+	if err := s.keyRepo.Revoke(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		return err
+	}
+
+	// En esta instancia la revocación se aplica al momento
+	s.mu.Lock()
+	for hash, cached := range s.cache {
+		if cached.key.ID == id {
+			delete(s.cache, hash)
+		}
+	}
+	s.mu.Unlock()
+
+	s.logger.Info("Revoked API key %s", id)
+	return nil
+}
+
+// lookupAPIKey obtiene una API key por su hash, de la caché o de la base
+// de datos
+func (s *ServiceAuthService) lookupAPIKey(ctx context.Context, hash string) (*entity.APIKey, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[hash]
+	s.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < apiKeyCacheTTL {
+		return cached.key, nil
+	}
+
+	key, err := s.keyRepo.GetByHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cache[hash] = cachedAPIKey{key: key, loadedAt: time.Now()}
+	s.mu.Unlock()
+
+	return key, nil
+}
+
+// tenantFromContext devuelve el tenant sobre el que actúa la petición
+func tenantFromContext(ctx context.Context) string {
+	return auth.TenantFromContext(ctx)
+}
+
+// senderFromContext devuelve el servicio autenticado que hace la petición,
+// que se guarda como remitente de las notificaciones
+func senderFromContext(ctx context.Context) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && !principal.IsDevice() {
+		return principal.Name
+	}
+	return ""
+}
@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// Errores del servicio de sesiones
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrTokenRevoked    = errors.New("token has been revoked")
+)
+
+// SessionDisconnector cierra las conexiones en tiempo real abiertas con el
+// token de una sesión
+type SessionDisconnector interface {
+	DisconnectSession(sessionID string) int
+}
+
+// SessionService gestiona las sesiones de usuario y su revocación. Los jti
+// revocados se mantienen en memoria para que verificar un token no consulte
+// la base de datos; la caché se recarga cada refreshInterval para recoger
+// las revocaciones hechas en otras instancias.
+type SessionService struct {
+	sessionRepo     repository.SessionRepository
+	disconnector    SessionDisconnector
+	refreshInterval time.Duration
+	logger          *logging.Logger
+
+	// jti revocados y la expiración de su token
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+// NewSessionService crea una nueva instancia del servicio de sesiones
+func NewSessionService(
+	sessionRepo repository.SessionRepository,
+	refreshInterval time.Duration,
+	logger *logging.Logger,
+) *SessionService {
+	return &SessionService{
+		sessionRepo:     sessionRepo,
+		refreshInterval: refreshInterval,
+		logger:          logger,
+		revoked:         make(map[string]time.Time),
+	}
+}
+
+// SetDisconnector indica cómo cerrar las conexiones de las sesiones revocadas
+func (s *SessionService) SetDisconnector(disconnector SessionDisconnector) {
+	s.disconnector = disconnector
+}
+
+// CreateSession registra la sesión de un token permanente recién emitido
+func (s *SessionService) CreateSession(ctx context.Context, session *entity.Session) error {
+	return s.sessionRepo.Create(ctx, session)
+}
+
+// ListUserSessions obtiene las sesiones activas de un usuario del tenant de
+// la petición
+func (s *SessionService) ListUserSessions(ctx context.Context, userID string) ([]*entity.Session, error) {
+	return s.sessionRepo.ListActiveByUser(ctx, tenantFromContext(ctx), userID)
+}
+
+// RevokeSession revoca una sesión de un usuario de un tenant y cierra sus
+// conexiones
+func (s *SessionService) RevokeSession(ctx context.Context, tenantID, userID string, sessionID uuid.UUID) error {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if session.TenantID != tenantID || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	if err := s.sessionRepo.Revoke(ctx, sessionID); err != nil {
+		return err
+	}
+
+	s.revoke(session)
+	return nil
+}
+
+// RevokeUserSessions revoca todas las sesiones de un usuario del tenant de
+// la petición y devuelve cuántas se revocaron
+func (s *SessionService) RevokeUserSessions(ctx context.Context, userID string) (int, error) {
+	sessions, err := s.sessionRepo.RevokeByUser(ctx, tenantFromContext(ctx), userID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, session := range sessions {
+		s.revoke(session)
+	}
+	return len(sessions), nil
+}
+
+// RevokeDeviceSessions revoca todas las sesiones de un dispositivo
+func (s *SessionService) RevokeDeviceSessions(ctx context.Context, deviceID uuid.UUID) error {
+	sessions, err := s.sessionRepo.RevokeByDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		s.revoke(session)
+	}
+	return nil
+}
+
+// IsRevoked indica si el token con el jti indicado está revocado
+func (s *SessionService) IsRevoked(jti string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, revoked := s.revoked[jti]
+	return revoked
+}
+
+// Load carga en memoria las sesiones revocadas. Las que no estaban ya en la
+// caché (revocadas en otra instancia) cierran sus conexiones.
+func (s *SessionService) Load(ctx context.Context) error {
+	sessions, err := s.sessionRepo.ListRevoked(ctx)
+	if err != nil {
+		return err
+	}
+
+	revoked := make(map[string]time.Time, len(sessions))
+	var newlyRevoked []string
+	now := time.Now()
+
+	s.mu.Lock()
+	// Una revocación no se deshace: se conservan las de la caché hasta que
+	// expira su token, aunque la consulta no las recoja todavía
+	for jti, expiresAt := range s.revoked {
+		if expiresAt.After(now) {
+			revoked[jti] = expiresAt
+		}
+	}
+	for _, session := range sessions {
+		jti := session.ID.String()
+		revoked[jti] = session.ExpiresAt
+		if _, known := s.revoked[jti]; !known {
+			newlyRevoked = append(newlyRevoked, jti)
+		}
+	}
+	s.revoked = revoked
+	s.mu.Unlock()
+
+	for _, jti := range newlyRevoked {
+		s.disconnect(jti)
+	}
+	return nil
+}
+
+// Run recarga la caché de revocaciones cada refreshInterval y elimina las
+// sesiones expiradas, hasta que se cancela ctx
+func (s *SessionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Load(ctx); err != nil {
+				s.logger.Error("Error refreshing revoked sessions: %v", err)
+			}
+			if err := s.sessionRepo.DeleteExpired(ctx); err != nil {
+				s.logger.Error("Error deleting expired sessions: %v", err)
+			}
+		}
+	}
+}
+
+// revoke añade una sesión a la caché de revocaciones y cierra sus conexiones
+func (s *SessionService) revoke(session *entity.Session) {
+	jti := session.ID.String()
+
+	s.mu.Lock()
+	s.revoked[jti] = session.ExpiresAt
+	s.mu.Unlock()
+
+	s.disconnect(jti)
+}
+
+// disconnect cierra las conexiones abiertas con el token de una sesión
+func (s *SessionService) disconnect(jti string) {
+	if s.disconnector == nil {
+		return
+	}
+
+	if closed := s.disconnector.DisconnectSession(jti); closed > 0 {
+		s.logger.Info("Closed %d connections of revoked session %s", closed, jti)
+	}
+}
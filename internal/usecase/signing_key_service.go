@@ -0,0 +1,184 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// SigningKeyService mantiene las claves de firma de los tokens: crea la
+// primera, rota la activa periódicamente y retira las anteriores cuando
+// termina su periodo de gracia. Las claves se comparten entre instancias a
+// través de la base de datos, y cada instancia recarga las que crean las demás
+// cada refreshInterval.
+//
+// Una clave nueva no firma hasta que ha pasado refreshInterval desde su
+// creación: así todas las instancias la conocen antes de recibir tokens
+// firmados con ella. Mientras tanto sigue firmando la anterior.
+type SigningKeyService struct {
+	keyRepo          repository.SigningKeyRepository
+	keyRing          *auth.KeyRing
+	cipher           *auth.KeyCipher
+	algorithm        string
+	rotationInterval time.Duration
+	gracePeriod      time.Duration
+	refreshInterval  time.Duration
+	logger           *logging.Logger
+}
+
+// NewSigningKeyService crea una nueva instancia del servicio de claves. El
+// periodo de gracia debe cubrir la duración de los tokens, para que los
+// firmados con una clave retirada sigan siendo válidos hasta que expiren.
+func NewSigningKeyService(
+	keyRepo repository.SigningKeyRepository,
+	keyRing *auth.KeyRing,
+	cipher *auth.KeyCipher,
+	algorithm string,
+	rotationInterval time.Duration,
+	gracePeriod time.Duration,
+	refreshInterval time.Duration,
+	logger *logging.Logger,
+) *SigningKeyService {
+	return &SigningKeyService{
+		keyRepo:          keyRepo,
+		keyRing:          keyRing,
+		cipher:           cipher,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		gracePeriod:      gracePeriod,
+		refreshInterval:  refreshInterval,
+		logger:           logger,
+	}
+}
+
+// Load carga las claves en el anillo. Si no hay clave activa, si la activa
+// usa otro algoritmo que el configurado o si le toca rotar, crea una nueva.
+func (s *SigningKeyService) Load(ctx context.Context) error {
+	active, err := s.reload(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Sin clave activa, con otro algoritmo o con la antigüedad de rotación
+	if active == nil || active.Algorithm != s.algorithm || time.Since(active.CreatedAt) >= s.rotationInterval {
+		return s.Rotate(ctx)
+	}
+	return nil
+}
+
+// Rotate crea una clave nueva, que pasa a firmar los tokens cuando se ha
+// propagado, y retira las anteriores con su periodo de gracia
+func (s *SigningKeyService) Rotate(ctx context.Context) error {
+	key, err := auth.GenerateKey(uuid.New().String(), s.algorithm)
+	if err != nil {
+		return fmt.Errorf("error generating signing key: %w", err)
+	}
+
+	private, err := key.MarshalPrivate()
+	if err != nil {
+		return fmt.Errorf("error encoding signing key: %w", err)
+	}
+	encrypted, err := s.cipher.Encrypt(private)
+	if err != nil {
+		return fmt.Errorf("error encrypting signing key: %w", err)
+	}
+
+	now := time.Now()
+	if err := s.keyRepo.Create(ctx, &entity.SigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: encrypted,
+		CreatedAt:  now,
+	}); err != nil {
+		return fmt.Errorf("error saving signing key: %w", err)
+	}
+
+	// La clave anterior firma todavía durante refreshInterval
+	if err := s.keyRepo.RetireBefore(ctx, now, now, now.Add(s.refreshInterval+s.gracePeriod)); err != nil {
+		return fmt.Errorf("error retiring signing keys: %w", err)
+	}
+
+	s.logger.Info("Rotated JWT signing key: kid=%s, algorithm=%s", key.ID, key.Algorithm)
+
+	_, err = s.reload(ctx)
+	return err
+}
+
+// Run recarga las claves cada refreshInterval, rota la activa cuando cumple
+// rotationInterval y elimina las expiradas, hasta que se cancela ctx
+func (s *SigningKeyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Load(ctx); err != nil {
+				s.logger.Error("Error refreshing JWT signing keys: %v", err)
+			}
+			if err := s.keyRepo.DeleteExpired(ctx); err != nil {
+				s.logger.Error("Error deleting expired JWT signing keys: %v", err)
+			}
+		}
+	}
+}
+
+// reload lee las claves válidas de la base de datos y las pone en el anillo.
+// Devuelve la clave activa, o nil si no hay ninguna; en ese caso el anillo
+// no cambia.
+func (s *SigningKeyService) reload(ctx context.Context) (*entity.SigningKey, error) {
+	stored, err := s.keyRepo.ListValid(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading signing keys: %w", err)
+	}
+
+	var active *entity.SigningKey
+	var signing *auth.Key
+	keys := make([]*auth.Key, 0, len(stored))
+	publishedBefore := time.Now().Add(-s.refreshInterval)
+
+	// Las claves llegan de la más reciente a la más antigua
+	for _, record := range stored {
+		private, err := s.cipher.Decrypt(record.PrivateKey)
+		if err != nil {
+			s.logger.Error("Error decrypting JWT signing key %s: %v", record.ID, err)
+			continue
+		}
+
+		key, err := auth.ParseKey(record.ID, record.Algorithm, private)
+		if err != nil {
+			s.logger.Error("Error parsing JWT signing key %s: %v", record.ID, err)
+			continue
+		}
+		keys = append(keys, key)
+
+		if record.IsActive() && active == nil {
+			active = record
+		}
+		// Firma la clave más reciente que ya conocen todas las instancias
+		if signing == nil && !record.CreatedAt.After(publishedBefore) {
+			signing = key
+		}
+	}
+
+	if active == nil {
+		return nil, nil
+	}
+
+	// Sin una clave publicada (primer arranque), firma la más reciente
+	if signing == nil {
+		signing = keys[0]
+	}
+
+	s.keyRing.Replace(signing, keys)
+	return active, nil
+}
@@ -1,38 +1,131 @@
 package usecase
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"sync"
+	"time"
 
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/logging"
 	"notification-service/pkg/template"
 )
 
-// TemplateService define las operaciones de negocio para gestionar plantillas de notificaciones
+// Errores del servicio de plantillas
+var (
+	ErrTemplateNotFound      = errors.New("template not found")
+	ErrTemplateAlreadyExists = errors.New("template already exists")
+	ErrInvalidTemplate       = errors.New("invalid template")
+	ErrTemplateRenderFailed  = errors.New("failed to render template")
+)
+
+// templateIDPattern es el formato de los IDs de plantilla; no admiten '/',
+// que separa el tenant en las claves del gestor
+var templateIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// TemplateContent es el contenido de una versión de una plantilla
+type TemplateContent struct {
+	Title     string
+	Body      string
+	Data      map[string]string
+	Locales   map[string]entity.TemplateLocale
+	Variables map[string]entity.TemplateVariable
+}
+
+// RenderedTemplate es el contenido de una notificación renderizado con una
+// plantilla
+type RenderedTemplate struct {
+	Title string
+	Body  string
+	Data  map[string]string
+
+	// Versión renderizada (0 para las plantillas de ficheros) e idioma usado
+	Version int
+	Locale  string
+}
+
+// TemplateService define las operaciones de negocio para gestionar plantillas de notificaciones.
+// Cada tenant tiene sus plantillas: en el gestor se guardan como tenant/id,
+// salvo las del tenant por defecto (y las cargadas de ficheros), que
+// conservan su ID.
+//
+// Las plantillas y sus versiones se guardan en la base de datos; el gestor
+// tiene la versión publicada de cada una para renderizar sin consultarla, y
+// se recarga cada refreshInterval para recoger lo publicado en otras
+// instancias. Las plantillas de ficheros son las del tenant por defecto
+// mientras no haya una publicada con el mismo ID.
 type TemplateService struct {
+	templateRepo    repository.TemplateRepository
 	templateManager *template.TemplateManager
+	refreshInterval time.Duration
+	logger          *logging.Logger
+
+	// Plantillas cargadas de ficheros y versión cargada de la base de datos
+	// de cada clave del gestor
+	mu     sync.Mutex
+	files  map[string]template.Template
+	loaded map[string]int
 }
 
-// NewTemplateService crea una nueva instancia de TemplateService
-func NewTemplateService(templateManager *template.TemplateManager) *TemplateService {
+// NewTemplateService crea una nueva instancia de TemplateService. Las
+// plantillas de ficheros deben estar ya cargadas en el gestor.
+func NewTemplateService(
+	templateRepo repository.TemplateRepository,
+	templateManager *template.TemplateManager,
+	refreshInterval time.Duration,
+	logger *logging.Logger,
+) *TemplateService {
+	files := make(map[string]template.Template)
+	for _, tmpl := range templateManager.GetAllTemplates() {
+		files[tmpl.ID] = tmpl
+	}
+
 	return &TemplateService{
+		templateRepo:    templateRepo,
 		templateManager: templateManager,
+		refreshInterval: refreshInterval,
+		logger:          logger,
+		files:           files,
+		loaded:          make(map[string]int),
+	}
+}
+
+// validTemplateID indica si un ID de plantilla tiene el formato de los IDs.
+// Los que llegan al enviar se comprueban antes de construir la clave: con una
+// '/' el tenant por defecto alcanzaría las plantillas de otro tenant.
+func validTemplateID(templateID string) bool {
+	return templateIDPattern.MatchString(templateID)
+}
+
+// templateKey devuelve la clave de una plantilla de un tenant en el gestor
+func templateKey(tenantID, templateID string) string {
+	if tenantID == entity.DefaultTenantID {
+		return templateID
 	}
+	return tenantID + "/" + templateID
 }
 
-// RenderTemplate renderiza una plantilla con los datos proporcionados
-func (s *TemplateService) RenderTemplate(templateID, locale string, data map[string]interface{}) (string, string, map[string]string, error) {
+// RenderTemplate renderiza una plantilla del tenant de la petición con los datos proporcionados
+func (s *TemplateService) RenderTemplate(ctx context.Context, templateID, locale string, data map[string]interface{}) (string, string, map[string]string, error) {
 	if templateID == "" {
 		return "", "", nil, errors.New("template ID cannot be empty")
 	}
+	if !validTemplateID(templateID) {
+		return "", "", nil, ErrTemplateNotFound
+	}
 
 	// Validar los datos requeridos según la plantilla
-	_, exists := s.templateManager.GetTemplate(templateID)
+	key := templateKey(tenantFromContext(ctx), templateID)
+	_, exists := s.templateManager.GetTemplate(key)
 	if !exists {
 		return "", "", nil, fmt.Errorf("template '%s' not found", templateID)
 	}
 
 	// Renderizar la plantilla
-	title, body, extraData, err := s.templateManager.RenderTemplate(templateID, locale, data)
+	title, body, extraData, err := s.templateManager.RenderTemplate(key, locale, data)
 	if err != nil {
 		return "", "", nil, fmt.Errorf("failed to render template: %w", err)
 	}
@@ -40,65 +133,396 @@ func (s *TemplateService) RenderTemplate(templateID, locale string, data map[str
 	return title, body, extraData, nil
 }
 
-// GetTemplates obtiene todas las plantillas disponibles
-func (s *TemplateService) GetTemplates() []template.Template {
-	return s.templateManager.GetAllTemplates()
+// Load carga en el gestor la versión publicada de todas las plantillas y
+// descarga las que ya no tienen ninguna
+func (s *TemplateService) Load(ctx context.Context) error {
+	versions, err := s.templateRepo.ListPublished(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	published := make(map[string]bool, len(versions))
+	for _, version := range versions {
+		key := templateKey(version.TenantID, version.TemplateID)
+		published[key] = true
+		if s.loaded[key] == version.Version {
+			continue
+		}
+		if err := s.load(key, version); err != nil {
+			s.logger.Error("Error loading template %s version %d: %v", key, version.Version, err)
+		}
+	}
+
+	for key := range s.loaded {
+		if !published[key] {
+			s.unload(key)
+		}
+	}
+	return nil
 }
 
-// GetTemplate obtiene una plantilla específica
-func (s *TemplateService) GetTemplate(templateID string) (template.Template, error) {
-	tmpl, exists := s.templateManager.GetTemplate(templateID)
-	if !exists {
-		return template.Template{}, fmt.Errorf("template '%s' not found", templateID)
+// Run recarga las plantillas publicadas cada refreshInterval hasta que se
+// cancela ctx
+func (s *TemplateService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Load(ctx); err != nil {
+				s.logger.Error("Error refreshing templates: %v", err)
+			}
+		}
+	}
+}
+
+// ListTemplates obtiene las plantillas del tenant de la petición
+func (s *TemplateService) ListTemplates(ctx context.Context) ([]*entity.Template, error) {
+	return s.templateRepo.List(ctx, tenantFromContext(ctx))
+}
+
+// GetTemplate obtiene una plantilla del tenant de la petición
+func (s *TemplateService) GetTemplate(ctx context.Context, templateID string) (*entity.Template, error) {
+	tmpl, err := s.templateRepo.GetByID(ctx, tenantFromContext(ctx), templateID)
+	if errors.Is(err, repository.ErrTemplateNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	return tmpl, err
+}
+
+// CreateTemplate crea una plantilla en el tenant de la petición con su
+// contenido como primera versión, en borrador
+func (s *TemplateService) CreateTemplate(ctx context.Context, templateID, name, description string, content TemplateContent) (*entity.Template, *entity.TemplateVersion, error) {
+	if !validTemplateID(templateID) {
+		return nil, nil, fmt.Errorf("%w: invalid template ID", ErrInvalidTemplate)
+	}
+	if err := content.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	tmpl := &entity.Template{
+		TenantID:      tenantFromContext(ctx),
+		ID:            templateID,
+		Name:          name,
+		Description:   description,
+		LatestVersion: 1,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	version := content.version(tmpl, senderFromContext(ctx), now)
+	version.Version = 1
+
+	if err := s.templateRepo.Create(ctx, tmpl, version); err != nil {
+		if errors.Is(err, repository.ErrTemplateExists) {
+			return nil, nil, ErrTemplateAlreadyExists
+		}
+		return nil, nil, err
+	}
+
+	s.logger.Info("Created template %s of tenant %s", tmpl.ID, tmpl.TenantID)
+	return tmpl, version, nil
+}
+
+// UpdateTemplate crea una versión nueva, en borrador, de una plantilla del
+// tenant de la petición. Un nombre o una descripción vacíos conservan los
+// actuales.
+func (s *TemplateService) UpdateTemplate(ctx context.Context, templateID, name, description string, content TemplateContent) (*entity.TemplateVersion, error) {
+	if err := content.validate(); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := s.GetTemplate(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		tmpl.Name = name
+	}
+	if description != "" {
+		tmpl.Description = description
+	}
+	tmpl.UpdatedAt = time.Now()
+
+	version := content.version(tmpl, senderFromContext(ctx), tmpl.UpdatedAt)
+	if err := s.templateRepo.AddVersion(ctx, tmpl, version); err != nil {
+		if errors.Is(err, repository.ErrTemplateNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// DeleteTemplate elimina una plantilla del tenant de la petición con todas
+// sus versiones
+func (s *TemplateService) DeleteTemplate(ctx context.Context, templateID string) error {
+	tenantID := tenantFromContext(ctx)
+	if err := s.templateRepo.Delete(ctx, tenantID, templateID); err != nil {
+		if errors.Is(err, repository.ErrTemplateNotFound) {
+			return ErrTemplateNotFound
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	s.unload(templateKey(tenantID, templateID))
+	s.mu.Unlock()
+
+	s.logger.Info("Deleted template %s of tenant %s", templateID, tenantID)
+	return nil
+}
+
+// ListVersions obtiene las versiones de una plantilla del tenant de la
+// petición, de la más reciente a la más antigua
+func (s *TemplateService) ListVersions(ctx context.Context, templateID string) ([]*entity.TemplateVersion, error) {
+	if _, err := s.GetTemplate(ctx, templateID); err != nil {
+		return nil, err
 	}
-	return tmpl, nil
+	return s.templateRepo.ListVersions(ctx, tenantFromContext(ctx), templateID)
 }
 
-// CreateTemplate crea una nueva plantilla
-func (s *TemplateService) CreateTemplate(tmpl template.Template) error {
-	if tmpl.ID == "" || tmpl.Title == "" || tmpl.Body == "" {
-		return errors.New("template ID, title and body are required")
+// GetVersion obtiene una versión de una plantilla del tenant de la petición;
+// la versión 0 es la última
+func (s *TemplateService) GetVersion(ctx context.Context, templateID string, version int) (*entity.TemplateVersion, error) {
+	if !validTemplateID(templateID) {
+		return nil, ErrTemplateNotFound
+	}
+
+	tenantID := tenantFromContext(ctx)
+	if version == 0 {
+		tmpl, err := s.GetTemplate(ctx, templateID)
+		if err != nil {
+			return nil, err
+		}
+		version = tmpl.LatestVersion
+	}
+
+	templateVersion, err := s.templateRepo.GetVersion(ctx, tenantID, templateID, version)
+	if errors.Is(err, repository.ErrTemplateNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	return templateVersion, err
+}
+
+// PublishVersion publica una versión de una plantilla del tenant de la
+// petición, que pasa a usarse al enviar. Publicar una versión anterior
+// deshace los cambios posteriores.
+func (s *TemplateService) PublishVersion(ctx context.Context, templateID string, version int) (*entity.TemplateVersion, error) {
+	tenantID := tenantFromContext(ctx)
+	if err := s.templateRepo.Publish(ctx, tenantID, templateID, version); err != nil {
+		if errors.Is(err, repository.ErrTemplateNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+
+	published, err := s.templateRepo.GetVersion(ctx, tenantID, templateID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Esta instancia la usa ya; las demás, al recargar
+	key := templateKey(tenantID, templateID)
+	s.mu.Lock()
+	err = s.load(key, published)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Published version %d of template %s of tenant %s", version, templateID, tenantID)
+	return published, nil
+}
+
+// PreviewVersion renderiza una versión de una plantilla del tenant de la
+// petición (0 es la última), publicada o no, sin enviarla. Si los datos no
+// encajan con la plantilla devuelve ErrTemplateRenderFailed.
+func (s *TemplateService) PreviewVersion(ctx context.Context, templateID string, version int, locale string, data map[string]interface{}) (string, string, map[string]string, error) {
+	templateVersion, err := s.GetVersion(ctx, templateID, version)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	preview := template.NewTemplateManager("", s.templateManager.DefaultLocale(), s.logger)
+	if err := preview.AddTemplate(managerTemplate(templateID, templateVersion)); err != nil {
+		return "", "", nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+
+	title, body, extraData, err := preview.RenderTemplate(templateID, locale, data)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%w: %w", ErrTemplateRenderFailed, err)
+	}
+	return title, body, extraData, nil
+}
+
+// RenderVersion renderiza para enviarla una versión de una plantilla del
+// tenant de la petición: la publicada si version es 0 o una publicada antes
+// (no un borrador). Si los datos no encajan con la plantilla devuelve
+// ErrTemplateRenderFailed.
+func (s *TemplateService) RenderVersion(ctx context.Context, templateID string, version int, locale string, data map[string]interface{}) (*RenderedTemplate, error) {
+	if version == 0 {
+		return s.renderPublished(ctx, templateID, locale, data)
+	}
+
+	templateVersion, err := s.GetVersion(ctx, templateID, version)
+	if err != nil {
+		return nil, err
+	}
+	if templateVersion.Status == entity.TemplateStatusDraft {
+		return nil, fmt.Errorf("%w: version %d has not been published", ErrInvalidTemplate, version)
+	}
+
+	renderer := template.NewTemplateManager("", s.templateManager.DefaultLocale(), s.logger)
+	tmpl := managerTemplate(templateID, templateVersion)
+	if err := renderer.AddTemplate(tmpl); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
 	}
 
-	return s.templateManager.AddTemplate(tmpl)
+	return renderTemplate(renderer, tmpl, version, locale, data)
 }
 
-// UpdateTemplate actualiza una plantilla existente
-func (s *TemplateService) UpdateTemplate(tmpl template.Template) error {
-	if tmpl.ID == "" {
-		return errors.New("template ID is required")
+// renderPublished renderiza la versión publicada de una plantilla del tenant
+// de la petición, o la de ficheros si no hay ninguna
+func (s *TemplateService) renderPublished(ctx context.Context, templateID, locale string, data map[string]interface{}) (*RenderedTemplate, error) {
+	if !validTemplateID(templateID) {
+		return nil, ErrTemplateNotFound
 	}
+	key := templateKey(tenantFromContext(ctx), templateID)
 
-	// Verificar si la plantilla existe
-	_, exists := s.templateManager.GetTemplate(tmpl.ID)
+	// Con el mutex bloqueado, la versión cargada es la que se renderiza
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpl, exists := s.templateManager.GetTemplate(key)
 	if !exists {
-		return fmt.Errorf("template '%s' not found", tmpl.ID)
+		return nil, ErrTemplateNotFound
 	}
 
-	// Eliminar la plantilla existente
-	s.templateManager.RemoveTemplate(tmpl.ID)
+	return renderTemplate(s.templateManager, tmpl, s.loaded[key], locale, data)
+}
 
-	// Agregar la plantilla actualizada
-	return s.templateManager.AddTemplate(tmpl)
+// renderTemplate renderiza una plantilla del gestor. El idioma usado es el
+// pedido si la plantilla lo tiene y el por defecto del gestor si no.
+func renderTemplate(manager *template.TemplateManager, tmpl template.Template, version int, locale string, data map[string]interface{}) (*RenderedTemplate, error) {
+	title, body, extraData, err := manager.RenderTemplate(tmpl.ID, locale, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTemplateRenderFailed, err)
+	}
+
+	locale = template.ResolveLocale(tmpl, locale, manager.DefaultLocale())
+	if locale == "" {
+		locale = manager.DefaultLocale()
+	}
+
+	return &RenderedTemplate{
+		Title:   title,
+		Body:    body,
+		Data:    extraData,
+		Version: version,
+		Locale:  locale,
+	}, nil
 }
 
-// DeleteTemplate elimina una plantilla
-func (s *TemplateService) DeleteTemplate(templateID string) error {
-	if !s.templateManager.RemoveTemplate(templateID) {
-		return fmt.Errorf("template '%s' not found", templateID)
+// load pone en el gestor una versión publicada. Debe llamarse con el mutex
+// bloqueado.
+func (s *TemplateService) load(key string, version *entity.TemplateVersion) error {
+	if err := s.templateManager.AddTemplate(managerTemplate(key, version)); err != nil {
+		return err
 	}
+	s.loaded[key] = version.Version
 	return nil
 }
 
+// unload quita del gestor una plantilla cargada de la base de datos y
+// restaura la del fichero con el mismo ID, si la hay. Debe llamarse con el
+// mutex bloqueado.
+func (s *TemplateService) unload(key string) {
+	if _, ok := s.loaded[key]; !ok {
+		return
+	}
+	delete(s.loaded, key)
+
+	s.templateManager.RemoveTemplate(key)
+	if file, ok := s.files[key]; ok {
+		if err := s.templateManager.AddTemplate(file); err != nil {
+			s.logger.Error("Error restoring template %s: %v", key, err)
+		}
+	}
+}
+
+// managerTemplate convierte una versión de una plantilla en la plantilla del
+// gestor con la clave indicada
+func managerTemplate(key string, version *entity.TemplateVersion) template.Template {
+	tmpl := template.Template{
+		ID:    key,
+		Title: version.Title,
+		Body:  version.Body,
+		Data:  version.Data,
+	}
+	if len(version.Locales) > 0 {
+		tmpl.Locales = make(map[string]template.Localized, len(version.Locales))
+		for locale, localized := range version.Locales {
+			tmpl.Locales[locale] = template.Localized(localized)
+		}
+	}
+	if len(version.Variables) > 0 {
+		tmpl.Variables = make(map[string]template.Variable, len(version.Variables))
+		for name, variable := range version.Variables {
+			tmpl.Variables[name] = template.Variable(variable)
+		}
+	}
+	return tmpl
+}
+
+// validate comprueba que el contenido compila como plantilla y que declara
+// las variables que usa
+func (c TemplateContent) validate() error {
+	err := template.Validate(managerTemplate("", &entity.TemplateVersion{
+		Title:     c.Title,
+		Body:      c.Body,
+		Locales:   c.Locales,
+		Variables: c.Variables,
+	}))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return nil
+}
+
+// version crea una versión en borrador de una plantilla con el contenido
+func (c TemplateContent) version(tmpl *entity.Template, createdBy string, createdAt time.Time) *entity.TemplateVersion {
+	return &entity.TemplateVersion{
+		TenantID:   tmpl.TenantID,
+		TemplateID: tmpl.ID,
+		Title:      c.Title,
+		Body:       c.Body,
+		Data:       c.Data,
+		Locales:    c.Locales,
+		Variables:  c.Variables,
+		Status:     entity.TemplateStatusDraft,
+		CreatedBy:  createdBy,
+		CreatedAt:  createdAt,
+	}
+}
+
 // RenderNotificationFromTemplate renderiza una notificación completa a partir de una plantilla
 func (s *TemplateService) RenderNotificationFromTemplate(
+	ctx context.Context,
 	templateID string,
 	locale string,
 	data map[string]interface{},
 ) (title string, body string, notificationData map[string]interface{}, err error) {
 
 	// Renderizar la plantilla
-	title, body, extraData, err := s.RenderTemplate(templateID, locale, data)
+	title, body, extraData, err := s.RenderTemplate(ctx, templateID, locale, data)
 	if err != nil {
 		return "", "", nil, err
 	}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/logging"
+	"notification-service/pkg/template"
+)
+
+// memTemplateRepo tiene publicada la versión 1 de la plantilla promo del
+// tenant acme
+type memTemplateRepo struct {
+	repository.TemplateRepository
+}
+
+func (memTemplateRepo) GetVersion(ctx context.Context, tenantID, id string, version int) (*entity.TemplateVersion, error) {
+	if tenantID != "acme" || id != "promo" || version != 1 {
+		return nil, repository.ErrTemplateNotFound
+	}
+	return &entity.TemplateVersion{
+		TenantID:   tenantID,
+		TemplateID: id,
+		Version:    version,
+		Title:      "Oferta de acme",
+		Body:       "Solo para clientes de acme",
+		Status:     entity.TemplateStatusPublished,
+	}, nil
+}
+
+func (memTemplateRepo) Publish(ctx context.Context, tenantID, id string, version int) error {
+	return nil
+}
+
+func TestTemplatesAreIsolatedByTenant(t *testing.T) {
+	logger := logging.NewLogger(logging.WithOutput(io.Discard))
+	service := NewTemplateService(memTemplateRepo{}, template.NewTemplateManager("", "es", logger), time.Minute, logger)
+
+	acme := auth.WithTenant(context.Background(), "acme")
+	if _, err := service.PublishVersion(acme, "promo", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := service.RenderVersion(acme, "promo", 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Title != "Oferta de acme" {
+		t.Errorf("title = %q, want the acme template", rendered.Title)
+	}
+
+	// El tenant por defecto no alcanza la plantilla de acme por su clave
+	defaultTenant := auth.WithTenant(context.Background(), entity.DefaultTenantID)
+	for _, version := range []int{0, 1} {
+		if _, err := service.RenderVersion(defaultTenant, "acme/promo", version, "", nil); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("render version %d of acme/promo: err = %v, want ErrTemplateNotFound", version, err)
+		}
+	}
+	if _, _, _, err := service.RenderTemplate(defaultTenant, "acme/promo", "", nil); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("render acme/promo: err = %v, want ErrTemplateNotFound", err)
+	}
+	if _, err := service.GetVersion(defaultTenant, "acme/promo", 1); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("get version of acme/promo: err = %v, want ErrTemplateNotFound", err)
+	}
+}
@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"notification-service/internal/domain/entity"
+	"notification-service/internal/domain/repository"
+	"notification-service/pkg/auth"
+	"notification-service/pkg/logging"
+)
+
+// Errores del servicio de tenants
+var (
+	ErrTenantNotFound       = errors.New("tenant not found")
+	ErrTenantAlreadyExists  = errors.New("tenant already exists")
+	ErrInvalidTenantRequest = errors.New("invalid tenant request")
+)
+
+// tenantCacheTTL es cuánto tiempo se recuerda que un tenant existe
+const tenantCacheTTL = time.Minute
+
+// tenantIDPattern es el formato de los IDs de tenant: se usan en cabeceras,
+// claims y claves, así que se limitan a minúsculas, dígitos y guiones
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// TenantCredentials son las credenciales de FCM y APNS de un tenant. La
+// server key de FCM llega en claro y se guarda cifrada; vacía la conserva.
+type TenantCredentials struct {
+	APNSBundleID        string
+	APNSCertificatePath string
+	APNSProduction      bool
+	FCMServerKey        string
+}
+
+// TenantService gestiona los tenants que comparten el servicio y comprueba
+// que existe el tenant de cada petición
+type TenantService struct {
+	tenantRepo repository.TenantRepository
+	keyCipher  *auth.KeyCipher
+	logger     *logging.Logger
+
+	mu    sync.Mutex
+	known map[string]time.Time
+}
+
+// NewTenantService crea una nueva instancia del servicio de tenants
+func NewTenantService(tenantRepo repository.TenantRepository, keyCipher *auth.KeyCipher, logger *logging.Logger) *TenantService {
+	return &TenantService{
+		tenantRepo: tenantRepo,
+		keyCipher:  keyCipher,
+		logger:     logger,
+		known:      make(map[string]time.Time),
+	}
+}
+
+// Exists indica si un tenant existe. Los tenants no se eliminan, así que se
+// recuerdan los encontrados durante tenantCacheTTL.
+func (s *TenantService) Exists(ctx context.Context, tenantID string) (bool, error) {
+	s.mu.Lock()
+	loadedAt, ok := s.known[tenantID]
+	s.mu.Unlock()
+	if ok && time.Since(loadedAt) < tenantCacheTTL {
+		return true, nil
+	}
+
+	if _, err := s.tenantRepo.GetByID(ctx, tenantID); err != nil {
+		if errors.Is(err, repository.ErrTenantNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	s.mu.Lock()
+	s.known[tenantID] = time.Now()
+	s.mu.Unlock()
+
+	return true, nil
+}
+
+// CreateTenant crea un tenant con sus credenciales y sus cuotas de envío;
+// una cuota a 0 usa la de la configuración
+func (s *TenantService) CreateTenant(ctx context.Context, id, name string, credentials TenantCredentials, quota Quota) (*entity.Tenant, error) {
+	if !tenantIDPattern.MatchString(id) || name == "" || !quota.valid() {
+		return nil, ErrInvalidTenantRequest
+	}
+
+	if _, err := s.tenantRepo.GetByID(ctx, id); err == nil {
+		return nil, ErrTenantAlreadyExists
+	} else if !errors.Is(err, repository.ErrTenantNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	tenant := &entity.Tenant{
+		ID:             id,
+		Name:           name,
+		QuotaPerSecond: quota.PerSecond,
+		QuotaPerDay:    quota.PerDay,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.setCredentials(tenant, credentials); err != nil {
+		return nil, err
+	}
+
+	if err := s.tenantRepo.Create(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Created tenant %s", tenant.ID)
+	return tenant, nil
+}
+
+// GetTenant obtiene un tenant por su ID
+func (s *TenantService) GetTenant(ctx context.Context, id string) (*entity.Tenant, error) {
+	tenant, err := s.tenantRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrTenantNotFound) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// ListTenants obtiene todos los tenants
+func (s *TenantService) ListTenants(ctx context.Context) ([]*entity.Tenant, error) {
+	return s.tenantRepo.List(ctx)
+}
+
+// UpdateTenant cambia el nombre, las credenciales y, si quota no es nil, las
+// cuotas de un tenant. Los envíos usan las credenciales y cuotas nuevas en
+// cuanto caducan las cachés.
+func (s *TenantService) UpdateTenant(ctx context.Context, id, name string, credentials TenantCredentials, quota *Quota) (*entity.Tenant, error) {
+	if quota != nil && !quota.valid() {
+		return nil, ErrInvalidTenantRequest
+	}
+
+	tenant, err := s.GetTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		tenant.Name = name
+	}
+	if err := s.setCredentials(tenant, credentials); err != nil {
+		return nil, err
+	}
+	if quota != nil {
+		tenant.QuotaPerSecond = quota.PerSecond
+		tenant.QuotaPerDay = quota.PerDay
+	}
+	tenant.UpdatedAt = time.Now()
+
+	if err := s.tenantRepo.Update(ctx, tenant); err != nil {
+		if errors.Is(err, repository.ErrTenantNotFound) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, err
+	}
+
+	s.logger.Info("Updated tenant %s", tenant.ID)
+	return tenant, nil
+}
+
+// setCredentials copia las credenciales en el tenant, cifrando la server key
+// de FCM
+func (s *TenantService) setCredentials(tenant *entity.Tenant, credentials TenantCredentials) error {
+	tenant.APNSBundleID = credentials.APNSBundleID
+	tenant.APNSCertificatePath = credentials.APNSCertificatePath
+	tenant.APNSProduction = credentials.APNSProduction
+
+	if credentials.FCMServerKey != "" {
+		encrypted, err := s.keyCipher.Encrypt([]byte(credentials.FCMServerKey))
+		if err != nil {
+			return err
+		}
+		tenant.FCMServerKey = encrypted
+	}
+
+	return nil
+}
@@ -8,7 +8,7 @@ import (
 
 	"notification-service/internal/domain/entity"
 	"notification-service/internal/domain/repository"
-	"notification-service/pkg/utils"
+	"notification-service/pkg/auth"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
@@ -22,109 +22,257 @@ var (
 	ErrFailedToGenerateToken = errors.New("failed to generate token")
 	ErrFailedToSaveToken     = errors.New("failed to save token")
 	ErrInvalidSigningMethod  = errors.New("invalid signing method")
+	ErrInvalidRefreshToken   = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired   = errors.New("refresh token has expired")
+	ErrRefreshTokenReused    = errors.New("refresh token reuse detected")
 )
 
+// Tipos de token de acceso
+const (
+	TokenTypeTemporary = "temporary"
+	TokenTypePermanent = "permanent"
+)
+
+// refreshTokenCleanupInterval es cada cuánto se eliminan los tokens de
+// refresco expirados
+const refreshTokenCleanupInterval = time.Hour
+
 // Claims define los datos que se almacenan en un JWT
 type Claims struct {
 	DeviceID         string `json:"device_id,omitempty"`
 	DeviceIdentifier string `json:"device_identifier,omitempty"`
 	UserID           string `json:"user_id,omitempty"`
 	IsTemporary      bool   `json:"temp,omitempty"`
+	SessionID        string `json:"sid,omitempty"`
+	TenantID         string `json:"tid,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Tenant devuelve el tenant del dispositivo del token. Los tokens emitidos
+// antes de la multi-tenencia no tienen tid: son del tenant por defecto.
+func (c *Claims) Tenant() string {
+	if c.TenantID == "" {
+		return entity.DefaultTenantID
+	}
+	return c.TenantID
+}
+
+// Session devuelve la sesión a la que pertenece el token, o "" si no
+// pertenece a ninguna (tokens temporales). Los tokens permanentes emitidos
+// antes de los tokens de refresco no tienen sid: su sesión es su jti.
+func (c *Claims) Session() string {
+	if c.SessionID != "" || c.IsTemporary {
+		return c.SessionID
+	}
+	return c.ID
+}
+
+// Ámbitos de los tokens de dispositivo; solo valen para el propio
+// dispositivo y su usuario. Un token temporal no tiene usuario, y solo
+// tiene estos ámbitos mientras su dispositivo no esté vinculado a ninguno:
+// después no tiene ninguno y hay que usar el token permanente.
+var (
+	temporaryDeviceScopes = []string{
+		auth.ScopeDevicesRead,
+		auth.ScopeDevicesWrite,
+		auth.ScopeNotificationsRead,
+	}
+	permanentDeviceScopes = append([]string{
+		auth.ScopeSessionsRead,
+		auth.ScopeSessionsWrite,
+	}, temporaryDeviceScopes...)
+)
+
+// TokenPair es un token de acceso de corta duración y el token de refresco
+// con el que se obtiene el siguiente
+type TokenPair struct {
+	AccessToken      string
+	TokenType        string
+	ExpiresAt        time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
 // TokenService define las operaciones de negocio para gestionar tokens
 type TokenService struct {
-	tokenRepo   repository.TokenRepository
-	deviceRepo  repository.DeviceRepository
-	jwtSecret   []byte
-	tokenExpiry time.Duration
-	tempExpiry  time.Duration
+	tokenRepo     repository.TokenRepository
+	deviceRepo    repository.DeviceRepository
+	refreshRepo   repository.RefreshTokenRepository
+	keyRing       *auth.KeyRing
+	sessions      *SessionService
+	tokenExpiry   time.Duration
+	tempExpiry    time.Duration
+	refreshExpiry time.Duration
 }
 
 // NewTokenService crea una nueva instancia del servicio de tokens
 func NewTokenService(
 	tokenRepo repository.TokenRepository,
 	deviceRepo repository.DeviceRepository,
-	jwtSecret string,
+	refreshRepo repository.RefreshTokenRepository,
+	keyRing *auth.KeyRing,
+	sessions *SessionService,
 	tokenExpiry time.Duration,
 	tempExpiry time.Duration,
+	refreshExpiry time.Duration,
 ) *TokenService {
 	return &TokenService{
-		tokenRepo:   tokenRepo,
-		deviceRepo:  deviceRepo,
-		jwtSecret:   []byte(jwtSecret),
-		tokenExpiry: tokenExpiry,
-		tempExpiry:  tempExpiry,
+		tokenRepo:     tokenRepo,
+		deviceRepo:    deviceRepo,
+		refreshRepo:   refreshRepo,
+		keyRing:       keyRing,
+		sessions:      sessions,
+		tokenExpiry:   tokenExpiry,
+		tempExpiry:    tempExpiry,
+		refreshExpiry: refreshExpiry,
 	}
 }
 
-// GenerateTemporaryToken genera un token temporal para un dispositivo
-func (s *TokenService) GenerateTemporaryToken(deviceIdentifier string) (string, error) {
-	// Crear claims
-	claims := Claims{
-		DeviceIdentifier: deviceIdentifier,
-		IsTemporary:      true,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tempExpiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// IssueTemporaryTokens emite un token de acceso temporal y su token de
+// refresco para un dispositivo sin usuario
+func (s *TokenService) IssueTemporaryTokens(ctx context.Context, deviceID uuid.UUID) (*TokenPair, error) {
+	device, err := s.deviceRepo.GetByID(ctx, deviceID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
 	}
 
-	// Crear token con claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return s.issueTokens(ctx, device, "", uuid.New(), time.Now().Add(s.refreshExpiry))
+}
 
-	// Firmar token
-	tokenString, err := token.SignedString(s.jwtSecret)
+// IssuePermanentTokens abre una sesión para un usuario en un dispositivo y
+// emite su primer token de acceso y su token de refresco. La familia de
+// tokens de refresco comparte el ID de la sesión.
+func (s *TokenService) IssuePermanentTokens(ctx context.Context, userID string, deviceID uuid.UUID) (*TokenPair, error) {
+	device, err := s.deviceRepo.GetByID(ctx, deviceID)
 	if err != nil {
-		return "", ErrFailedToGenerateToken
+		return nil, ErrDeviceNotFound
+	}
+
+	session := entity.NewSession(uuid.New(), device.TenantID, userID, deviceID, device.DeviceIdentifier, time.Now().Add(s.refreshExpiry))
+	if err := s.sessions.CreateSession(ctx, session); err != nil {
+		return nil, ErrFailedToSaveToken
 	}
 
-	return tokenString, nil
+	return s.issueTokens(ctx, device, userID, session.ID, session.ExpiresAt)
 }
 
-// GeneratePermanentToken genera un token permanente para un usuario y dispositivo
-func (s *TokenService) GeneratePermanentToken(userID string, deviceID uuid.UUID) (string, error) {
-	// Verificar si el dispositivo existe
-	device, err := s.deviceRepo.GetByID(context.Background(), deviceID)
+// Refresh canjea un token de refresco por un token de acceso nuevo y el
+// siguiente token de refresco de la familia. Cada token de refresco se puede
+// usar una sola vez: si se presenta uno ya usado, alguien lo ha copiado, y
+// se revoca la familia entera (y su sesión).
+func (s *TokenService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	record, err := s.refreshRepo.GetByHash(ctx, auth.HashOpaqueToken(refreshToken))
 	if err != nil {
-		return "", ErrDeviceNotFound
+		if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if record.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if record.UsedAt != nil {
+		return nil, s.revokeFamily(ctx, record)
+	}
+	if !time.Now().Before(record.ExpiresAt) {
+		return nil, ErrRefreshTokenExpired
+	}
+	if !record.IsTemporary() && s.sessions.IsRevoked(record.FamilyID.String()) {
+		return nil, ErrTokenRevoked
+	}
+
+	// Si otra petición lo ha usado entretanto, también es una reutilización
+	used, err := s.refreshRepo.MarkUsed(ctx, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, s.revokeFamily(ctx, record)
+	}
+
+	device, err := s.deviceRepo.GetByID(ctx, record.DeviceID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+
+	return s.issueTokens(ctx, device, record.UserID, record.FamilyID, record.ExpiresAt)
+}
+
+// revokeFamily revoca una familia de tokens de refresco reutilizada y, si
+// es de una sesión, la sesión. Devuelve ErrRefreshTokenReused si lo consigue.
+func (s *TokenService) revokeFamily(ctx context.Context, record *entity.RefreshToken) error {
+	if err := s.refreshRepo.RevokeFamily(ctx, record.FamilyID); err != nil {
+		return err
+	}
+
+	if !record.IsTemporary() {
+		device, err := s.deviceRepo.GetByID(ctx, record.DeviceID)
+		if err != nil {
+			return err
+		}
+		err = s.sessions.RevokeSession(ctx, device.TenantID, record.UserID, record.FamilyID)
+		if err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
 	}
 
-	// Crear claims
+	return ErrRefreshTokenReused
+}
+
+// issueTokens firma un token de acceso y emite el siguiente token de
+// refresco de una familia. Los tokens de refresco no alargan la familia:
+// todos expiran cuando expira el primero.
+func (s *TokenService) issueTokens(ctx context.Context, device *entity.Device, userID string, familyID uuid.UUID, familyExpiresAt time.Time) (*TokenPair, error) {
+	now := time.Now()
 	claims := Claims{
-		DeviceID:         deviceID.String(),
 		DeviceIdentifier: device.DeviceIdentifier,
-		UserID:           userID,
-		IsTemporary:      false,
+		IsTemporary:      userID == "",
+		TenantID:         device.TenantID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenExpiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.tempExpiry)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
+	tokenType := TokenTypeTemporary
+	if userID != "" {
+		tokenType = TokenTypePermanent
+		claims.DeviceID = device.ID.String()
+		claims.UserID = userID
+		claims.SessionID = familyID.String()
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(s.tokenExpiry))
+	}
 
-	// Crear token con claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Firmar token con la clave activa
+	accessToken, err := s.keyRing.Sign(claims)
+	if err != nil {
+		return nil, ErrFailedToGenerateToken
+	}
 
-	// Firmar token
-	tokenString, err := token.SignedString(s.jwtSecret)
+	refreshToken, err := auth.GenerateOpaqueToken()
 	if err != nil {
-		return "", ErrFailedToGenerateToken
+		return nil, ErrFailedToGenerateToken
+	}
+
+	record := entity.NewRefreshToken(familyID, device.ID, userID, auth.HashOpaqueToken(refreshToken), familyExpiresAt)
+	if err := s.refreshRepo.Create(ctx, record); err != nil {
+		return nil, ErrFailedToSaveToken
 	}
 
-	return tokenString, nil
+	return &TokenPair{
+		AccessToken:      accessToken,
+		TokenType:        tokenType,
+		ExpiresAt:        claims.ExpiresAt.Time,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: familyExpiresAt,
+	}, nil
 }
 
 // VerifyToken verifica un token y devuelve los datos del dispositivo
 func (s *TokenService) VerifyToken(ctx context.Context, tokenString string) (*Claims, error) {
-	// Parsear token
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validar método de firma
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidSigningMethod
-		}
-		return s.jwtSecret, nil
-	})
+	// Parsear token; la clave se elige por su kid y debe coincidir el método de firma
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keyRing.Keyfunc)
 
 	// Manejar errores específicos
 	if err != nil {
@@ -136,64 +284,62 @@ func (s *TokenService) VerifyToken(ctx context.Context, tokenString string) (*Cl
 
 	// Obtener claims
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		// Los tokens anteriores a las sesiones no tienen jti y no se pueden revocar
+		if session := claims.Session(); session != "" && s.sessions.IsRevoked(session) {
+			return nil, ErrTokenRevoked
+		}
 		return claims, nil
 	}
 
 	return nil, ErrInvalidToken
 }
 
-// ExtractDeviceIdentifierFromToken extrae el identificador del dispositivo de un token expirado
-func (s *TokenService) ExtractDeviceIdentifierFromToken(tokenString string) (string, error) {
-	// Dividir token en partes
-	parts := strings.Split(tokenString, ".")
-	if len(parts) != 3 {
-		return "", ErrInvalidToken
-	}
-
-	// Decodificar la parte de los claims
-	claimsJSON, err := utils.DecodeBase64URL(parts[1])
+// VerifyTemporaryToken verifica si un token temporal es válido para un dispositivo
+func (s *TokenService) VerifyTemporaryToken(tokenString, deviceIdentifier string) bool {
+	claims, err := s.VerifyToken(context.Background(), tokenString)
 	if err != nil {
-		return "", err
+		return false
 	}
 
-	// Extraer deviceIdentifier
-	var claims map[string]interface{}
-	if err := utils.JSONUnmarshal(claimsJSON, &claims); err != nil {
-		return "", err
-	}
+	return claims.IsTemporary && claims.DeviceIdentifier == deviceIdentifier
+}
 
-	// Buscar por deviceIdentifier primero
-	if deviceIdentifier, ok := claims["device_identifier"].(string); ok && deviceIdentifier != "" {
-		return deviceIdentifier, nil
+// Authenticate verifica el token de un dispositivo y devuelve quién llama a
+// la API con él. Los tokens temporales no llevan el ID del dispositivo, que se
+// obtiene de su identificador dentro del tenant del token.
+func (s *TokenService) Authenticate(ctx context.Context, tokenString string) (*auth.Principal, error) {
+	claims, err := s.VerifyToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
 	}
 
-	// Si no hay deviceIdentifier, buscar por deviceID
-	if deviceID, ok := claims["device_id"].(string); ok && deviceID != "" {
-		// Si tenemos deviceID, intentar obtener el dispositivo de la base de datos
-		deviceUUID, err := uuid.Parse(deviceID)
+	deviceID := claims.DeviceID
+	linked := false
+	if deviceID == "" && claims.DeviceIdentifier != "" {
+		device, err := s.deviceRepo.GetByDeviceIdentifier(ctx, claims.Tenant(), claims.DeviceIdentifier)
 		if err != nil {
-			return "", err
+			return nil, ErrInvalidToken
 		}
-
-		device, err := s.deviceRepo.GetByID(context.Background(), deviceUUID)
-		if err != nil {
-			return "", err
-		}
-
-		return device.DeviceIdentifier, nil
+		deviceID = device.ID.String()
+		linked = device.UserID != nil
 	}
 
-	return "", errors.New("no device identifier found in token")
-}
-
-// VerifyTemporaryToken verifica si un token temporal es válido para un dispositivo
-func (s *TokenService) VerifyTemporaryToken(tokenString, deviceIdentifier string) bool {
-	claims, err := s.VerifyToken(context.Background(), tokenString)
-	if err != nil {
-		return false
+	scopes := permanentDeviceScopes
+	if claims.IsTemporary || claims.UserID == "" {
+		scopes = temporaryDeviceScopes
+		if linked {
+			scopes = nil
+		}
 	}
 
-	return claims.IsTemporary && claims.DeviceIdentifier == deviceIdentifier
+	return &auth.Principal{
+		Name:     "device:" + deviceID,
+		Method:   auth.AuthMethodDeviceToken,
+		Scopes:   scopes,
+		DeviceID: deviceID,
+		UserID:   claims.UserID,
+		TenantID: claims.Tenant(),
+	}, nil
 }
 
 // RevokeToken revoca un token específico
@@ -201,13 +347,25 @@ func (s *TokenService) RevokeToken(ctx context.Context, tokenID uuid.UUID) error
 	return s.tokenRepo.Revoke(ctx, tokenID)
 }
 
-// RevokeAllForDevice revoca todos los tokens de un dispositivo
+// RevokeAllForDevice revoca todos los tokens de un dispositivo, incluidos sus
+// tokens de refresco y sus sesiones, y cierra sus conexiones
 func (s *TokenService) RevokeAllForDevice(ctx context.Context, deviceID uuid.UUID) error {
-	return s.tokenRepo.RevokeAllForDevice(ctx, deviceID)
+	if err := s.tokenRepo.RevokeAllForDevice(ctx, deviceID); err != nil {
+		return err
+	}
+	if err := s.refreshRepo.RevokeByDevice(ctx, deviceID); err != nil {
+		return err
+	}
+	return s.sessions.RevokeDeviceSessions(ctx, deviceID)
 }
 
-// SaveToken guarda un token en el sistema
+// SaveToken guarda un token de un dispositivo del tenant de la petición
 func (s *TokenService) SaveToken(ctx context.Context, deviceID uuid.UUID, tokenValue string, tokenType entity.TokenType) error {
+	device, err := s.deviceRepo.GetByID(ctx, deviceID)
+	if err != nil || device.TenantID != tenantFromContext(ctx) {
+		return ErrDeviceNotFound
+	}
+
 	token := entity.NewNotificationToken(deviceID, tokenValue, tokenType)
 	return s.tokenRepo.Create(ctx, token)
 }
@@ -221,3 +379,20 @@ func (s *TokenService) GetToken(ctx context.Context, deviceID uuid.UUID, tokenTy
 func (s *TokenService) CleanupExpiredTokens(ctx context.Context) error {
 	return s.tokenRepo.CleanupExpired(ctx)
 }
+
+// Run elimina periódicamente los tokens de refresco expirados, hasta que se
+// cancela ctx
+func (s *TokenService) Run(ctx context.Context) {
+	ticker := time.NewTicker(refreshTokenCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Si falla, se reintenta en el siguiente ciclo
+			_ = s.refreshRepo.DeleteExpired(ctx)
+		}
+	}
+}
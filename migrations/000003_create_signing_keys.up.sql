@@ -0,0 +1,13 @@
+-- Claves de firma de los tokens JWT. La clave privada se guarda cifrada con
+-- AES-GCM; retired_at marca cuándo dejó de firmar y expires_at hasta cuándo
+-- se aceptan los tokens que firmó.
+CREATE TABLE notification_service.signing_keys (
+  id TEXT PRIMARY KEY,
+  algorithm TEXT NOT NULL,
+  private_key BYTEA NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  retired_at TIMESTAMP WITH TIME ZONE,
+  expires_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_signing_keys_expires_at ON notification_service.signing_keys(expires_at);
@@ -0,0 +1,15 @@
+-- Sesiones de usuario: una por token permanente emitido, identificada por el
+-- jti del token. Las sesiones revocadas se consultan al verificar tokens.
+CREATE TABLE notification_service.sessions (
+  id UUID PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  device_id UUID NOT NULL,
+  device_identifier TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_sessions_user_id ON notification_service.sessions(user_id);
+CREATE INDEX idx_sessions_device_id ON notification_service.sessions(device_id);
+CREATE INDEX idx_sessions_revoked ON notification_service.sessions(expires_at) WHERE revoked_at IS NOT NULL;
@@ -0,0 +1,17 @@
+-- Tokens de refresco: se guarda el hash SHA-256 del token. Los tokens usados
+-- se conservan hasta que expiran para detectar su reutilización.
+CREATE TABLE notification_service.refresh_tokens (
+  id UUID PRIMARY KEY,
+  family_id UUID NOT NULL,
+  device_id UUID NOT NULL,
+  user_id TEXT,
+  token_hash TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  used_at TIMESTAMP WITH TIME ZONE,
+  revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON notification_service.refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_device_id ON notification_service.refresh_tokens(device_id);
+CREATE INDEX idx_refresh_tokens_expires_at ON notification_service.refresh_tokens(expires_at);
@@ -0,0 +1,14 @@
+-- API keys de los servicios que llaman a la API. Se guarda el hash SHA-256
+-- de la clave; prefix son sus primeros caracteres, para reconocerla.
+CREATE TABLE notification_service.api_keys (
+  id UUID PRIMARY KEY,
+  name TEXT NOT NULL UNIQUE,
+  prefix TEXT NOT NULL,
+  key_hash TEXT NOT NULL UNIQUE,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  rate_limit DOUBLE PRECISION NOT NULL DEFAULT 0,
+  rate_burst INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP WITH TIME ZONE,
+  revoked_at TIMESTAMP WITH TIME ZONE
+);
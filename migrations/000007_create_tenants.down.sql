@@ -0,0 +1,20 @@
+DROP INDEX IF EXISTS notification_service.idx_api_keys_tenant_name;
+ALTER TABLE notification_service.api_keys ADD CONSTRAINT api_keys_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS notification_service.idx_sessions_user_id;
+CREATE INDEX idx_sessions_user_id ON notification_service.sessions(user_id);
+
+DROP INDEX IF EXISTS notification_service.idx_notifications_user_id;
+CREATE INDEX idx_notifications_user_id ON notification_service.notifications(user_id);
+
+DROP INDEX IF EXISTS notification_service.idx_devices_identifier;
+DROP INDEX IF EXISTS notification_service.idx_devices_user_id;
+CREATE INDEX idx_devices_identifier ON notification_service.devices(device_identifier);
+CREATE INDEX idx_devices_user_id ON notification_service.devices(user_id);
+
+ALTER TABLE notification_service.api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE notification_service.sessions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE notification_service.notifications DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE notification_service.devices DROP COLUMN IF EXISTS tenant_id;
+
+DROP TABLE IF EXISTS notification_service.tenants;
@@ -0,0 +1,41 @@
+-- Tenants: aplicaciones que comparten el servicio. La server key de FCM se
+-- guarda cifrada; el certificado de APNS se lee de apns_certificate_path.
+CREATE TABLE notification_service.tenants (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  apns_bundle_id TEXT NOT NULL DEFAULT '',
+  apns_certificate_path TEXT NOT NULL DEFAULT '',
+  apns_production BOOLEAN NOT NULL DEFAULT FALSE,
+  fcm_server_key BYTEA,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Los datos existentes pasan al tenant por defecto
+INSERT INTO notification_service.tenants (id, name) VALUES ('default', 'Default');
+
+ALTER TABLE notification_service.devices
+  ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES notification_service.tenants(id);
+ALTER TABLE notification_service.notifications
+  ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES notification_service.tenants(id);
+ALTER TABLE notification_service.sessions
+  ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES notification_service.tenants(id);
+ALTER TABLE notification_service.api_keys
+  ADD COLUMN tenant_id TEXT REFERENCES notification_service.tenants(id);
+
+-- Los identificadores de dispositivo y los usuarios son únicos dentro de
+-- cada tenant
+DROP INDEX notification_service.idx_devices_user_id;
+DROP INDEX notification_service.idx_devices_identifier;
+CREATE INDEX idx_devices_user_id ON notification_service.devices(tenant_id, user_id);
+CREATE INDEX idx_devices_identifier ON notification_service.devices(tenant_id, device_identifier);
+
+DROP INDEX notification_service.idx_notifications_user_id;
+CREATE INDEX idx_notifications_user_id ON notification_service.notifications(tenant_id, user_id);
+
+DROP INDEX notification_service.idx_sessions_user_id;
+CREATE INDEX idx_sessions_user_id ON notification_service.sessions(tenant_id, user_id);
+
+-- Un servicio se identifica por su nombre dentro de su tenant
+ALTER TABLE notification_service.api_keys DROP CONSTRAINT api_keys_name_key;
+CREATE UNIQUE INDEX idx_api_keys_tenant_name ON notification_service.api_keys(COALESCE(tenant_id, ''), name);
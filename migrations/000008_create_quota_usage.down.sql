@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS notification_service.quota_usage;
+
+ALTER TABLE notification_service.tenants
+  DROP COLUMN IF EXISTS quota_per_day,
+  DROP COLUMN IF EXISTS quota_per_second;
@@ -0,0 +1,20 @@
+-- Cuotas de envío propias de cada tenant; 0 usa la de la configuración
+ALTER TABLE notification_service.tenants
+  ADD COLUMN quota_per_second DOUBLE PRECISION NOT NULL DEFAULT 0,
+  ADD COLUMN quota_per_day BIGINT NOT NULL DEFAULT 0;
+
+-- Uso diario de las cuotas de envío (días en UTC), para facturación e
+-- informes y para compartir las cuotas diarias entre instancias. El nivel
+-- global usa tenant_id y subject vacíos.
+CREATE TABLE notification_service.quota_usage (
+  day DATE NOT NULL,
+  level TEXT NOT NULL,
+  tenant_id TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  count BIGINT NOT NULL DEFAULT 0,
+  throttled BIGINT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (day, level, tenant_id, subject)
+);
+
+CREATE INDEX idx_quota_usage_tenant_day ON notification_service.quota_usage(tenant_id, day);
@@ -0,0 +1,9 @@
+-- Estado de los límites de tasa compartidos entre instancias (GCRA): el
+-- instante teórico de llegada (TAT) del siguiente evento de cada clave. Las
+-- filas con tat en el pasado equivalen a no tener fila y se borran.
+CREATE TABLE notification_service.rate_limits (
+  key TEXT PRIMARY KEY,
+  tat TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_tat ON notification_service.rate_limits(tat);
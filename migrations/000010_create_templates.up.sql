@@ -0,0 +1,34 @@
+-- Plantillas de notificaciones de cada tenant. published_version es la
+-- versión que se usa al enviar (0 mientras no se publica ninguna).
+CREATE TABLE notification_service.templates (
+  tenant_id TEXT NOT NULL REFERENCES notification_service.tenants(id),
+  id TEXT NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  description TEXT NOT NULL DEFAULT '',
+  latest_version INTEGER NOT NULL DEFAULT 0,
+  published_version INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (tenant_id, id)
+);
+
+-- Versiones de las plantillas. El contenido de una versión no cambia; solo
+-- su estado: draft al crearse, published mientras es la versión en uso y
+-- archived cuando se publica otra.
+CREATE TABLE notification_service.template_versions (
+  tenant_id TEXT NOT NULL,
+  template_id TEXT NOT NULL,
+  version INTEGER NOT NULL,
+  title TEXT NOT NULL,
+  body TEXT NOT NULL,
+  data JSONB,
+  locales JSONB,
+  status TEXT NOT NULL DEFAULT 'draft',
+  created_by TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  published_at TIMESTAMP WITH TIME ZONE,
+  PRIMARY KEY (tenant_id, template_id, version),
+  FOREIGN KEY (tenant_id, template_id) REFERENCES notification_service.templates(tenant_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_template_versions_status ON notification_service.template_versions(status);
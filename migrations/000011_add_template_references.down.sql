@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS notification_service.idx_notifications_template;
+
+ALTER TABLE notification_service.notifications
+  DROP COLUMN IF EXISTS locale,
+  DROP COLUMN IF EXISTS template_version,
+  DROP COLUMN IF EXISTS template_id;
+
+ALTER TABLE notification_service.devices DROP COLUMN IF EXISTS locale;
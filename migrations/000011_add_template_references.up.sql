@@ -0,0 +1,12 @@
+-- Idioma del dispositivo, con el que se renderizan las plantillas que se le
+-- envían sin indicar uno
+ALTER TABLE notification_service.devices ADD COLUMN locale TEXT;
+
+-- Plantilla, versión e idioma con los que se renderizó una notificación
+ALTER TABLE notification_service.notifications
+  ADD COLUMN template_id TEXT,
+  ADD COLUMN template_version INTEGER,
+  ADD COLUMN locale TEXT;
+
+CREATE INDEX idx_notifications_template ON notification_service.notifications(tenant_id, template_id, template_version)
+  WHERE template_id IS NOT NULL;
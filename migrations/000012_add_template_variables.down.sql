@@ -0,0 +1 @@
+ALTER TABLE notification_service.template_versions DROP COLUMN IF EXISTS variables;
@@ -0,0 +1,3 @@
+-- Variables que declara cada versión de una plantilla: tipo, si son
+-- obligatorias y valor por defecto
+ALTER TABLE notification_service.template_versions ADD COLUMN variables JSONB;
@@ -1 +1,234 @@
-package auth
\ No newline at end of file
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Algoritmos de firma soportados
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Tamaño de las claves generadas
+const (
+	hmacKeySize = 32
+	rsaKeyBits  = 2048
+)
+
+// Errores de las claves de firma
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrNoSigningKey         = errors.New("no active signing key")
+	ErrUnknownKey           = errors.New("unknown signing key")
+	ErrInvalidSigningMethod = errors.New("invalid signing method")
+)
+
+// Key es una clave de firma en memoria
+type Key struct {
+	ID        string
+	Algorithm string
+
+	// []byte para HS256, crypto.Signer para el resto
+	private interface{}
+}
+
+// GenerateKey crea una clave nueva para el algoritmo indicado
+func GenerateKey(id, algorithm string) (*Key, error) {
+	var private interface{}
+	var err error
+
+	switch algorithm {
+	case AlgorithmHS256:
+		secret := make([]byte, hmacKeySize)
+		_, err = rand.Read(secret)
+		private = secret
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{ID: id, Algorithm: algorithm, private: private}, nil
+}
+
+// NewHMACKey crea una clave HS256 a partir de un secreto compartido
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Algorithm: AlgorithmHS256, private: secret}
+}
+
+// ParseKey reconstruye una clave a partir de su forma serializada
+// (MarshalPrivate)
+func ParseKey(id, algorithm string, data []byte) (*Key, error) {
+	if algorithm == AlgorithmHS256 {
+		return NewHMACKey(id, data), nil
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: id, Algorithm: algorithm, private: private}
+	if !key.matchesAlgorithm() {
+		return nil, fmt.Errorf("%w: key %s is not a %s key", ErrUnsupportedAlgorithm, id, algorithm)
+	}
+	return key, nil
+}
+
+// MarshalPrivate serializa la clave: el secreto en HS256 y PKCS#8 en el resto
+func (k *Key) MarshalPrivate() ([]byte, error) {
+	if secret, ok := k.private.([]byte); ok {
+		return secret, nil
+	}
+	return x509.MarshalPKCS8PrivateKey(k.private)
+}
+
+// Public devuelve la clave pública, o nil si la clave es simétrica
+func (k *Key) Public() crypto.PublicKey {
+	if signer, ok := k.private.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return nil
+}
+
+// matchesAlgorithm verifica que el tipo de la clave corresponde a su algoritmo
+func (k *Key) matchesAlgorithm() bool {
+	switch k.private.(type) {
+	case []byte:
+		return k.Algorithm == AlgorithmHS256
+	case *rsa.PrivateKey:
+		return k.Algorithm == AlgorithmRS256
+	case *ecdsa.PrivateKey:
+		return k.Algorithm == AlgorithmES256
+	case ed25519.PrivateKey:
+		return k.Algorithm == AlgorithmEdDSA
+	default:
+		return false
+	}
+}
+
+// verificationKey devuelve la clave con la que se verifican las firmas
+func (k *Key) verificationKey() interface{} {
+	if public := k.Public(); public != nil {
+		return public
+	}
+	return k.private
+}
+
+// KeyRing contiene la clave con la que se firman los tokens y todas las que
+// se aceptan al verificarlos, indexadas por kid
+type KeyRing struct {
+	mu      sync.RWMutex
+	signing *Key
+	keys    map[string]*Key
+
+	// Clave para los tokens sin kid, firmados antes de la rotación de claves
+	legacy *Key
+}
+
+// NewKeyRing crea un KeyRing vacío
+func NewKeyRing() *KeyRing {
+	return &KeyRing{
+		keys: make(map[string]*Key),
+	}
+}
+
+// SetLegacySecret acepta los tokens HS256 sin kid firmados con el secreto
+// compartido anterior a la rotación de claves
+func (r *KeyRing) SetLegacySecret(secret []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.legacy = NewHMACKey("", secret)
+}
+
+// Replace sustituye las claves del anillo. signing firma los tokens nuevos;
+// keys son todas las que se aceptan al verificar (signing se añade si no
+// está).
+func (r *KeyRing) Replace(signing *Key, keys []*Key) {
+	index := make(map[string]*Key, len(keys)+1)
+	for _, key := range keys {
+		index[key.ID] = key
+	}
+	if signing != nil {
+		index[signing.ID] = signing
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.signing = signing
+	r.keys = index
+}
+
+// SigningKeyID devuelve el kid de la clave activa
+func (r *KeyRing) SigningKeyID() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.signing == nil {
+		return ""
+	}
+	return r.signing.ID
+}
+
+// Sign firma unos claims con la clave activa e indica su kid en la cabecera
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	r.mu.RLock()
+	signing := r.signing
+	r.mu.RUnlock()
+
+	if signing == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(signing.Algorithm), claims)
+	token.Header["kid"] = signing.ID
+
+	return token.SignedString(signing.private)
+}
+
+// Keyfunc devuelve la clave con la que verificar un token según su kid, y
+// rechaza los tokens cuyo algoritmo no es el de la clave. Se usa con
+// jwt.Parse.
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var key *Key
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		key = r.keys[kid]
+	} else {
+		key = r.legacy
+	}
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, ErrInvalidSigningMethod
+	}
+
+	return key.verificationKey(), nil
+}
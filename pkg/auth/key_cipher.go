@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// ErrInvalidCiphertext indica que una clave cifrada está dañada o se cifró
+// con otra clave maestra
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// KeyCipher cifra las claves privadas que se guardan en la base de datos
+// con AES-256-GCM
+type KeyCipher struct {
+	aead cipher.AEAD
+}
+
+// NewKeyCipher crea un KeyCipher. La clave AES se deriva del secreto con
+// SHA-256, de modo que admite secretos de cualquier longitud.
+func NewKeyCipher(secret []byte) (*KeyCipher, error) {
+	key := sha256.Sum256(secret)
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyCipher{aead: aead}, nil
+}
+
+// Encrypt cifra un texto; el resultado lleva el nonce delante
+func (c *KeyCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt descifra un texto cifrado con Encrypt
+func (c *KeyCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, ErrInvalidCiphertext
+	}
+
+	plaintext, err := c.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// opaqueTokenSize es el número de bytes aleatorios de un token opaco
+const opaqueTokenSize = 32
+
+// GenerateOpaqueToken crea un token aleatorio sin estructura, como los
+// tokens de refresco. Solo se entrega al cliente; se guarda su hash.
+func GenerateOpaqueToken() (string, error) {
+	data := make([]byte, opaqueTokenSize)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// HashOpaqueToken devuelve el hash SHA-256 de un token opaco, en hexadecimal.
+// Los tokens tienen entropía suficiente para no necesitar sal.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
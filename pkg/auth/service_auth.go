@@ -1 +1,184 @@
-package auth
\ No newline at end of file
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefijo de las API keys de servicio. Permite reconocerlas (por ejemplo,
+// en escáneres de secretos) y distinguirlas de los JWT de dispositivo.
+const APIKeyPrefix = "nsk_"
+
+// Ámbitos de las credenciales. Cada ruta de la API exige uno.
+const (
+	ScopeNotificationsSend = "notifications:send"
+	ScopeNotificationsRead = "notifications:read"
+	ScopeDevicesRead       = "devices:read"
+	ScopeDevicesWrite      = "devices:write"
+	ScopeSessionsRead      = "sessions:read"
+	ScopeSessionsWrite     = "sessions:write"
+	ScopePresenceRead      = "presence:read"
+	ScopeTemplatesRead     = "templates:read"
+	ScopeTemplatesWrite    = "templates:write"
+	ScopeAdmin             = "admin"
+)
+
+// knownScopes son los ámbitos que se pueden asignar a una credencial
+var knownScopes = map[string]bool{
+	ScopeNotificationsSend: true,
+	ScopeNotificationsRead: true,
+	ScopeDevicesRead:       true,
+	ScopeDevicesWrite:      true,
+	ScopeSessionsRead:      true,
+	ScopeSessionsWrite:     true,
+	ScopePresenceRead:      true,
+	ScopeTemplatesRead:     true,
+	ScopeTemplatesWrite:    true,
+	ScopeAdmin:             true,
+}
+
+// IsKnownScope indica si un ámbito existe
+func IsKnownScope(scope string) bool {
+	return knownScopes[scope]
+}
+
+// Métodos con los que se autentica quien llama a la API
+const (
+	AuthMethodAPIKey      = "api_key"
+	AuthMethodMTLS        = "mtls"
+	AuthMethodDeviceToken = "device_token"
+)
+
+// Principal es quien llama a la API: un servicio, con API key o mTLS, o un
+// dispositivo con su JWT
+type Principal struct {
+	// Nombre del servicio; se guarda como remitente de sus notificaciones
+	Name   string
+	Method string
+	Scopes []string
+
+	// ID de la API key, vacío con mTLS
+	KeyID string
+
+	// Límite de peticiones por segundo del servicio; 0 es sin límite
+	RateLimit float64
+	RateBurst int
+
+	// Dispositivo y usuario del token; un dispositivo solo puede actuar
+	// sobre sí mismo y su usuario
+	DeviceID string
+	UserID   string
+
+	// Tenant de la credencial; vacío en las de la plataforma, que pueden
+	// actuar sobre cualquier tenant
+	TenantID string
+}
+
+// IsPlatform indica si la credencial es de la plataforma y no de un tenant
+func (p *Principal) IsPlatform() bool {
+	return p.TenantID == ""
+}
+
+// Tenant devuelve el tenant sobre el que actúa quien llama: el de su
+// credencial o, en las de la plataforma, el que pide (requested), y el
+// tenant por defecto si no pide ninguno. ok es false si una credencial de
+// un tenant pide otro.
+func (p *Principal) Tenant(requested string) (tenantID string, ok bool) {
+	if !p.IsPlatform() {
+		return p.TenantID, requested == "" || requested == p.TenantID
+	}
+	if requested == "" {
+		return DefaultTenant, true
+	}
+	return requested, true
+}
+
+// IsDevice indica si quien llama es un dispositivo
+func (p *Principal) IsDevice() bool {
+	return p.Method == AuthMethodDeviceToken
+}
+
+// CanAccessDevice indica si quien llama puede actuar sobre un dispositivo.
+// Un servicio puede sobre cualquiera; un dispositivo, solo sobre sí mismo.
+func (p *Principal) CanAccessDevice(deviceID string) bool {
+	if !p.IsDevice() {
+		return true
+	}
+	return p.DeviceID != "" && p.DeviceID == deviceID
+}
+
+// CanAccessUser indica si quien llama puede actuar sobre un usuario. Un
+// servicio puede sobre cualquiera; un dispositivo, solo sobre su usuario.
+func (p *Principal) CanAccessUser(userID string) bool {
+	if !p.IsDevice() {
+		return true
+	}
+	return p.UserID != "" && p.UserID == userID
+}
+
+// HasScope indica si el servicio tiene un ámbito. admin los incluye todos.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// principalKey es la clave del Principal en el contexto
+type principalKey struct{}
+
+// WithPrincipal devuelve un contexto con quien llama a la API
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext devuelve quien llama a la API
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// GenerateAPIKey crea una API key nueva
+func GenerateAPIKey() (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + token, nil
+}
+
+// NewServerTLSConfig crea la configuración TLS de un servidor que acepta
+// certificados de cliente firmados por las CA de clientCAFile (mTLS). El
+// certificado es opcional: los dispositivos se conectan sin él y los servicios
+// pueden autenticarse también con API key.
+func NewServerTLSConfig(clientCAFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading client CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+	}
+
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	return config, nil
+}
+
+// IsAPIKey indica si un valor tiene el formato de una API key
+func IsAPIKey(value string) bool {
+	return strings.HasPrefix(value, APIKeyPrefix) && len(value) > len(APIKeyPrefix)
+}
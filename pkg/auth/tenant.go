@@ -0,0 +1,27 @@
+package auth
+
+import "context"
+
+// DefaultTenant es el tenant de las peticiones que no indican otro
+const DefaultTenant = "default"
+
+// Cabecera HTTP (y metadato gRPC, en minúsculas) con la que una credencial de
+// la plataforma elige el tenant sobre el que actúa
+const TenantHeader = "X-Tenant-ID"
+
+// tenantKey es la clave del tenant en el contexto
+type tenantKey struct{}
+
+// WithTenant devuelve un contexto con el tenant sobre el que actúa la petición
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext devuelve el tenant sobre el que actúa la petición, o el
+// tenant por defecto si no se indicó ninguno
+func TenantFromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(tenantKey{}).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return DefaultTenant
+}
@@ -25,9 +25,13 @@ type NotificationRequest struct {
 	Message          string            // Mensaje de la notificación
 	Data             map[string]string // Datos adicionales (opcional)
 	NotificationType string            // Tipo: normal, urgent, system, message
-	SenderID         string            // ID del remitente (opcional)
+	SenderID         string            // Ignorado: el remitente es el servicio autenticado
 	Priority         int               // Prioridad: 0-normal, 1-alta
 	Expiry           int64             // Tiempo de expiración en segundos (opcional)
+	TemplateID       string            // Plantilla con la que renderizar título, mensaje y datos (opcional)
+	TemplateVersion  int               // Versión de la plantilla; 0 para la publicada
+	Locale           string            // Idioma; por defecto, el de los dispositivos del usuario
+	Variables        map[string]string // Variables de la plantilla
 }
 
 // DeviceRegistrationRequest representa una solicitud para registrar un dispositivo
@@ -35,6 +39,7 @@ type DeviceRegistrationRequest struct {
 	DeviceIdentifier string // Identificador único del dispositivo
 	DeviceModel      string // Modelo del dispositivo (opcional)
 	UserID           string // ID del usuario (opcional)
+	Locale           string // Idioma del dispositivo, p. ej. es-ES (opcional)
 }
 
 // DeviceLinkRequest representa una solicitud para vincular un dispositivo a un usuario
@@ -70,13 +75,37 @@ type DeliveryInfo struct {
 	ErrorMessage string // Mensaje de error (si hay)
 }
 
-// NewNotificationClient crea un nuevo cliente para el servicio de notificaciones
-func NewNotificationClient(serverAddress string) (*NotificationClient, error) {
+// apiKeyCredentials envía la API key del servicio en los metadatos de cada
+// llamada
+type apiKeyCredentials struct {
+	apiKey string
+}
+
+// GetRequestMetadata devuelve los metadatos con la API key
+func (c apiKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"x-api-key": c.apiKey}, nil
+}
+
+// RequireTransportSecurity permite la clave sin TLS para desarrollo; en
+// producción la conexión debería usar TLS
+func (c apiKeyCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// WithAPIKey autentica las llamadas del cliente con una API key de servicio
+func WithAPIKey(apiKey string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(apiKeyCredentials{apiKey: apiKey})
+}
+
+// NewNotificationClient crea un nuevo cliente para el servicio de
+// notificaciones. opts se añaden a las opciones por defecto, por ejemplo
+// WithAPIKey o unas credenciales TLS con certificado de cliente.
+func NewNotificationClient(serverAddress string, opts ...grpc.DialOption) (*NotificationClient, error) {
 	// Configurar opciones de conexión
-	opts := []grpc.DialOption{
+	opts = append([]grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithBlock(),
-	}
+	}, opts...)
 
 	// Establecer conexión con timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -113,6 +142,10 @@ func (c *NotificationClient) SendNotification(ctx context.Context, req *Notifica
 		SenderId:         req.SenderID,
 		Priority:         int32(req.Priority),
 		Expiry:           req.Expiry,
+		TemplateId:       req.TemplateID,
+		TemplateVersion:  int32(req.TemplateVersion),
+		Locale:           req.Locale,
+		Variables:        req.Variables,
 	}
 
 	// Convertir Data a formato requerido
@@ -166,6 +199,7 @@ func (c *NotificationClient) RegisterDevice(ctx context.Context, req *DeviceRegi
 		DeviceIdentifier: req.DeviceIdentifier,
 		DeviceModel:      req.DeviceModel,
 		UserId:           req.UserID,
+		Locale:           req.Locale,
 	}
 
 	// Configurar backoff para reintentos
@@ -90,6 +90,30 @@ var (
 		[]string{"type"},
 	)
 
+	WebSocketFramesDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_frames_dropped_total",
+			Help: "Total number of outbound frames dropped because a client queue was full",
+		},
+		[]string{"transport", "policy"},
+	)
+
+	WebSocketFramesSpilled = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_frames_spilled_total",
+			Help: "Total number of dropped frames saved to the persistent queue",
+		},
+		[]string{"result"},
+	)
+
+	WebSocketConnectionsRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_connections_rejected_total",
+			Help: "Total number of real-time connections rejected or replaced by connection limits",
+		},
+		[]string{"reason"},
+	)
+
 	// Métricas de tokens
 	TokensGenerated = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -36,20 +36,6 @@ var (
 		},
 	)
 
-	websocketMessagesSent = promauto.NewCounter(
-		prometheus.CounterOpts{
-			Name: "websocket_messages_sent_total",
-			Help: "Total de mensajes WebSocket enviados",
-		},
-	)
-
-	websocketMessagesReceived = promauto.NewCounter(
-		prometheus.CounterOpts{
-			Name: "websocket_messages_received_total",
-			Help: "Total de mensajes WebSocket recibidos",
-		},
-	)
-
 	// Métricas de notificaciones
 	notificationSentTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -67,11 +53,21 @@ var (
 		[]string{"status", "channel"},
 	)
 
-	notificationQueueSize = promauto.NewGauge(
+	notificationQueueSize = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "notification_queue_size",
-			Help: "Número de notificaciones en la cola de procesamiento",
+			Help: "Número de notificaciones en la cola de procesamiento de cada carril",
+		},
+		[]string{"lane"},
+	)
+
+	notificationQueueLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "notification_queue_latency_seconds",
+			Help:    "Tiempo de espera de las notificaciones en la cola de cada carril",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14), // De 1ms a ~8s
 		},
+		[]string{"lane"},
 	)
 
 	notificationRetryTotal = promauto.NewCounter(
@@ -90,15 +86,7 @@ var (
 		[]string{"operation", "result"},
 	)
 
-	// Métricas de base de datos
-	dbOperationsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "db_operations_total",
-			Help: "Total de operaciones de base de datos",
-		},
-		[]string{"operation", "table", "result"},
-	)
-
+	// Métricas de base de datos; el total de operaciones es DBOperations
 	dbOperationDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "db_operation_duration_seconds",
@@ -161,12 +149,12 @@ func WebSocketConnectionsChange(delta int) {
 
 // WebSocketMessageSent incrementa el contador de mensajes WebSocket enviados
 func WebSocketMessageSent() {
-	websocketMessagesSent.Inc()
+	WebSocketMessagesSent.Inc()
 }
 
 // WebSocketMessageReceived incrementa el contador de mensajes WebSocket recibidos
 func WebSocketMessageReceived() {
-	websocketMessagesReceived.Inc()
+	WebSocketMessagesReceived.Inc()
 }
 
 // NotificationSent registra una notificación enviada
@@ -180,8 +168,15 @@ func NotificationDelivery(status, channel string) {
 }
 
 // SetNotificationQueueSize actualiza el tamaño de la cola de notificaciones
-func SetNotificationQueueSize(size int) {
-	notificationQueueSize.Set(float64(size))
+// de un carril
+func SetNotificationQueueSize(lane string, size int) {
+	notificationQueueSize.WithLabelValues(lane).Set(float64(size))
+}
+
+// ObserveNotificationQueueLatency registra lo que esperó una notificación en
+// la cola de un carril
+func ObserveNotificationQueueLatency(lane string, wait time.Duration) {
+	notificationQueueLatency.WithLabelValues(lane).Observe(wait.Seconds())
 }
 
 // NotificationRetry registra un reintento de envío de notificación
@@ -194,9 +189,10 @@ func TokenOperation(operation, result string) {
 	tokenOperationsTotal.WithLabelValues(operation, result).Inc()
 }
 
-// ObserveDatabaseOperation registra una operación de base de datos con su duración
+// ObserveDatabaseOperation registra una operación de base de datos con su
+// duración. El total se cuenta en DBOperations, con la tabla como repositorio.
 func ObserveDatabaseOperation(operation, table, result string, duration time.Duration) {
-	dbOperationsTotal.WithLabelValues(operation, table, result).Inc()
+	DBOperations.WithLabelValues(operation, table).Inc()
 	dbOperationDuration.WithLabelValues(operation, table).Observe(duration.Seconds())
 }
 
@@ -19,9 +19,10 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	BusinessService_ValidateUser_FullMethodName   = "/business.BusinessService/ValidateUser"
-	BusinessService_GetDeviceInfo_FullMethodName  = "/business.BusinessService/GetDeviceInfo"
-	BusinessService_GetUserDevices_FullMethodName = "/business.BusinessService/GetUserDevices"
+	BusinessService_ValidateUser_FullMethodName      = "/business.BusinessService/ValidateUser"
+	BusinessService_GetDeviceInfo_FullMethodName     = "/business.BusinessService/GetDeviceInfo"
+	BusinessService_GetUserDevices_FullMethodName    = "/business.BusinessService/GetUserDevices"
+	BusinessService_ReportClientEvent_FullMethodName = "/business.BusinessService/ReportClientEvent"
 )
 
 // BusinessServiceClient is the client API for BusinessService service.
@@ -34,6 +35,9 @@ type BusinessServiceClient interface {
 	GetDeviceInfo(ctx context.Context, in *GetDeviceInfoRequest, opts ...grpc.CallOption) (*GetDeviceInfoResponse, error)
 	// Obtener dispositivos de un usuario
 	GetUserDevices(ctx context.Context, in *GetUserDevicesRequest, opts ...grpc.CallOption) (*GetUserDevicesResponse, error)
+	// Informar de un evento enviado por un cliente conectado (escribiendo,
+	// confirmación de lectura, estado de la app, etc.)
+	ReportClientEvent(ctx context.Context, in *ReportClientEventRequest, opts ...grpc.CallOption) (*ReportClientEventResponse, error)
 }
 
 type businessServiceClient struct {
@@ -74,6 +78,16 @@ func (c *businessServiceClient) GetUserDevices(ctx context.Context, in *GetUserD
 	return out, nil
 }
 
+func (c *businessServiceClient) ReportClientEvent(ctx context.Context, in *ReportClientEventRequest, opts ...grpc.CallOption) (*ReportClientEventResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportClientEventResponse)
+	err := c.cc.Invoke(ctx, BusinessService_ReportClientEvent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BusinessServiceServer is the server API for BusinessService service.
 // All implementations must embed UnimplementedBusinessServiceServer
 // for forward compatibility.
@@ -84,6 +98,9 @@ type BusinessServiceServer interface {
 	GetDeviceInfo(context.Context, *GetDeviceInfoRequest) (*GetDeviceInfoResponse, error)
 	// Obtener dispositivos de un usuario
 	GetUserDevices(context.Context, *GetUserDevicesRequest) (*GetUserDevicesResponse, error)
+	// Informar de un evento enviado por un cliente conectado (escribiendo,
+	// confirmación de lectura, estado de la app, etc.)
+	ReportClientEvent(context.Context, *ReportClientEventRequest) (*ReportClientEventResponse, error)
 	mustEmbedUnimplementedBusinessServiceServer()
 }
 
@@ -103,6 +120,9 @@ func (UnimplementedBusinessServiceServer) GetDeviceInfo(context.Context, *GetDev
 func (UnimplementedBusinessServiceServer) GetUserDevices(context.Context, *GetUserDevicesRequest) (*GetUserDevicesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserDevices not implemented")
 }
+func (UnimplementedBusinessServiceServer) ReportClientEvent(context.Context, *ReportClientEventRequest) (*ReportClientEventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportClientEvent not implemented")
+}
 func (UnimplementedBusinessServiceServer) mustEmbedUnimplementedBusinessServiceServer() {}
 func (UnimplementedBusinessServiceServer) testEmbeddedByValue()                         {}
 
@@ -178,6 +198,24 @@ func _BusinessService_GetUserDevices_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _BusinessService_ReportClientEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportClientEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessServiceServer).ReportClientEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BusinessService_ReportClientEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessServiceServer).ReportClientEvent(ctx, req.(*ReportClientEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // BusinessService_ServiceDesc is the grpc.ServiceDesc for BusinessService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -197,6 +235,10 @@ var BusinessService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserDevices",
 			Handler:    _BusinessService_GetUserDevices_Handler,
 		},
+		{
+			MethodName: "ReportClientEvent",
+			Handler:    _BusinessService_ReportClientEvent_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/proto/business_service.proto",
@@ -25,6 +25,8 @@ const (
 	NotificationService_LinkDeviceToUser_FullMethodName  = "/notification.NotificationService/LinkDeviceToUser"
 	NotificationService_UpdateDeviceToken_FullMethodName = "/notification.NotificationService/UpdateDeviceToken"
 	NotificationService_GetDeliveryStatus_FullMethodName = "/notification.NotificationService/GetDeliveryStatus"
+	NotificationService_GetUserPresence_FullMethodName   = "/notification.NotificationService/GetUserPresence"
+	NotificationService_GetUsersPresence_FullMethodName  = "/notification.NotificationService/GetUsersPresence"
 )
 
 // NotificationServiceClient is the client API for NotificationService service.
@@ -43,6 +45,10 @@ type NotificationServiceClient interface {
 	UpdateDeviceToken(ctx context.Context, in *UpdateDeviceTokenRequest, opts ...grpc.CallOption) (*UpdateDeviceTokenResponse, error)
 	// Obtener el estado de entrega de una notificación
 	GetDeliveryStatus(ctx context.Context, in *GetDeliveryStatusRequest, opts ...grpc.CallOption) (*GetDeliveryStatusResponse, error)
+	// Obtener la presencia (conexiones activas) de un usuario
+	GetUserPresence(ctx context.Context, in *GetUserPresenceRequest, opts ...grpc.CallOption) (*GetUserPresenceResponse, error)
+	// Obtener la presencia de varios usuarios
+	GetUsersPresence(ctx context.Context, in *GetUsersPresenceRequest, opts ...grpc.CallOption) (*GetUsersPresenceResponse, error)
 }
 
 type notificationServiceClient struct {
@@ -113,6 +119,26 @@ func (c *notificationServiceClient) GetDeliveryStatus(ctx context.Context, in *G
 	return out, nil
 }
 
+func (c *notificationServiceClient) GetUserPresence(ctx context.Context, in *GetUserPresenceRequest, opts ...grpc.CallOption) (*GetUserPresenceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUserPresenceResponse)
+	err := c.cc.Invoke(ctx, NotificationService_GetUserPresence_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) GetUsersPresence(ctx context.Context, in *GetUsersPresenceRequest, opts ...grpc.CallOption) (*GetUsersPresenceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUsersPresenceResponse)
+	err := c.cc.Invoke(ctx, NotificationService_GetUsersPresence_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NotificationServiceServer is the server API for NotificationService service.
 // All implementations must embed UnimplementedNotificationServiceServer
 // for forward compatibility.
@@ -129,6 +155,10 @@ type NotificationServiceServer interface {
 	UpdateDeviceToken(context.Context, *UpdateDeviceTokenRequest) (*UpdateDeviceTokenResponse, error)
 	// Obtener el estado de entrega de una notificación
 	GetDeliveryStatus(context.Context, *GetDeliveryStatusRequest) (*GetDeliveryStatusResponse, error)
+	// Obtener la presencia (conexiones activas) de un usuario
+	GetUserPresence(context.Context, *GetUserPresenceRequest) (*GetUserPresenceResponse, error)
+	// Obtener la presencia de varios usuarios
+	GetUsersPresence(context.Context, *GetUsersPresenceRequest) (*GetUsersPresenceResponse, error)
 	mustEmbedUnimplementedNotificationServiceServer()
 }
 
@@ -157,6 +187,12 @@ func (UnimplementedNotificationServiceServer) UpdateDeviceToken(context.Context,
 func (UnimplementedNotificationServiceServer) GetDeliveryStatus(context.Context, *GetDeliveryStatusRequest) (*GetDeliveryStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetDeliveryStatus not implemented")
 }
+func (UnimplementedNotificationServiceServer) GetUserPresence(context.Context, *GetUserPresenceRequest) (*GetUserPresenceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserPresence not implemented")
+}
+func (UnimplementedNotificationServiceServer) GetUsersPresence(context.Context, *GetUsersPresenceRequest) (*GetUsersPresenceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUsersPresence not implemented")
+}
 func (UnimplementedNotificationServiceServer) mustEmbedUnimplementedNotificationServiceServer() {}
 func (UnimplementedNotificationServiceServer) testEmbeddedByValue()                             {}
 
@@ -286,6 +322,42 @@ func _NotificationService_GetDeliveryStatus_Handler(srv interface{}, ctx context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NotificationService_GetUserPresence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserPresenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetUserPresence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_GetUserPresence_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetUserPresence(ctx, req.(*GetUserPresenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_GetUsersPresence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUsersPresenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetUsersPresence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_GetUsersPresence_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetUsersPresence(ctx, req.(*GetUsersPresenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // NotificationService_ServiceDesc is the grpc.ServiceDesc for NotificationService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -317,6 +389,14 @@ var NotificationService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetDeliveryStatus",
 			Handler:    _NotificationService_GetDeliveryStatus_Handler,
 		},
+		{
+			MethodName: "GetUserPresence",
+			Handler:    _NotificationService_GetUserPresence_Handler,
+		},
+		{
+			MethodName: "GetUsersPresence",
+			Handler:    _NotificationService_GetUsersPresence_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/proto/notification_service.proto",
@@ -0,0 +1,256 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	_ "time/tzdata" // formatDate admite zonas horarias sin zoneinfo en el sistema
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// ellipsis es lo que se añade a un texto cortado
+const ellipsis = "…"
+
+// Formas de plural de CLDR que admite plural
+var pluralForms = map[string]plural.Form{
+	"zero":  plural.Zero,
+	"one":   plural.One,
+	"two":   plural.Two,
+	"few":   plural.Few,
+	"many":  plural.Many,
+	"other": plural.Other,
+}
+
+// templateFuncs devuelve las funciones de las plantillas para un idioma:
+//
+//	{{plural .count "artículo" "artículos"}}
+//	{{plural .count "one" "# archivo" "few" "# archivos" "other" "# archivos"}}
+//	{{formatDate .date "02/01/2006 15:04" "Europe/Madrid"}}
+//	{{formatNumber .amount 2}}
+//	{{.name | truncate 20}}
+//	{{.code | upper}}
+func templateFuncs(locale string) template.FuncMap {
+	tag := languageTag(locale)
+	return template.FuncMap{
+		"plural": func(count interface{}, forms ...string) (string, error) {
+			return pluralize(tag, count, forms)
+		},
+		"formatDate": formatDate,
+		"formatNumber": func(value interface{}, decimals ...int) (string, error) {
+			return formatNumber(tag, value, decimals...)
+		},
+		"truncate": func(max int, s string) string {
+			return Truncate(s, max)
+		},
+		"upper": func(s string) string {
+			return cases.Upper(tag).String(s)
+		},
+	}
+}
+
+// languageTag convierte un idioma al de x/text; uno desconocido se trata
+// como su idioma base o, si tampoco se conoce, como indeterminado
+func languageTag(locale string) language.Tag {
+	if tag, err := language.Parse(normalizeLocale(locale)); err == nil {
+		return tag
+	}
+	if tag, err := language.Parse(baseLanguage(locale)); err == nil {
+		return tag
+	}
+	return language.Und
+}
+
+// pluralize elige la forma de plural de una cantidad según las reglas del
+// idioma. Con dos formas son la de uno y la del resto; si no, son pares de
+// forma de CLDR y texto, y "other" es la que se usa si falta la que toca.
+// Un # en el texto se sustituye por la cantidad.
+func pluralize(tag language.Tag, count interface{}, forms []string) (string, error) {
+	n, ok := toNumber(count)
+	if !ok {
+		return "", fmt.Errorf("plural: %v is not a number", count)
+	}
+
+	texts := make(map[plural.Form]string, len(forms))
+	switch {
+	case len(forms) == 2:
+		texts[plural.One] = forms[0]
+		texts[plural.Other] = forms[1]
+	case len(forms) > 0 && len(forms)%2 == 0:
+		for i := 0; i < len(forms); i += 2 {
+			form, ok := pluralForms[forms[i]]
+			if !ok {
+				return "", fmt.Errorf("plural: unknown form %q", forms[i])
+			}
+			texts[form] = forms[i+1]
+		}
+	default:
+		return "", errors.New("plural: expected two forms or pairs of form and text")
+	}
+
+	text, ok := texts[matchPlural(tag, n)]
+	if !ok {
+		if text, ok = texts[plural.Other]; !ok {
+			return "", errors.New(`plural: missing "other" form`)
+		}
+	}
+
+	if strings.Contains(text, "#") {
+		formatted, _ := formatNumber(tag, n)
+		text = strings.ReplaceAll(text, "#", formatted)
+	}
+	return text, nil
+}
+
+// matchPlural devuelve la forma de plural de CLDR de un número, calculando
+// sus operandos a partir de su representación decimal
+func matchPlural(tag language.Tag, n float64) plural.Form {
+	s := strconv.FormatFloat(math.Abs(n), 'f', -1, 64)
+	integer, fraction, _ := strings.Cut(s, ".")
+
+	i, _ := strconv.Atoi(integer)
+	f, _ := strconv.Atoi(fraction)
+	trimmed := strings.TrimRight(fraction, "0")
+	t, _ := strconv.Atoi(trimmed)
+	return plural.Cardinal.MatchPlural(tag, i, len(fraction), len(trimmed), f, t)
+}
+
+// formatNumber formatea un número con los separadores del idioma y, si se
+// indica, con un número fijo de decimales
+func formatNumber(tag language.Tag, value interface{}, decimals ...int) (string, error) {
+	n, ok := toNumber(value)
+	if !ok {
+		return "", fmt.Errorf("formatNumber: %v is not a number", value)
+	}
+
+	var options []number.Option
+	if len(decimals) > 0 {
+		if decimals[0] < 0 {
+			return "", errors.New("formatNumber: decimals must not be negative")
+		}
+		options = append(options, number.MinFractionDigits(decimals[0]), number.MaxFractionDigits(decimals[0]))
+	}
+	return message.NewPrinter(tag).Sprint(number.Decimal(n, options...)), nil
+}
+
+// formatDate formatea una fecha con un layout de Go, opcionalmente en una
+// zona horaria IANA. La fecha puede ser un time.Time, un texto RFC 3339 o
+// AAAA-MM-DD, o segundos Unix.
+func formatDate(value interface{}, layout string, timezone ...string) (string, error) {
+	date, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+
+	if len(timezone) > 0 && timezone[0] != "" {
+		location, err := time.LoadLocation(timezone[0])
+		if err != nil {
+			return "", fmt.Errorf("formatDate: %w", err)
+		}
+		date = date.In(location)
+	}
+	return date.Format(layout), nil
+}
+
+// toTime convierte a time.Time los valores que admite formatDate
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case string:
+		if date, err := time.Parse(time.RFC3339, v); err == nil {
+			return date, nil
+		}
+		if date, err := time.Parse(time.DateOnly, v); err == nil {
+			return date, nil
+		}
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return unixTime(seconds), nil
+		}
+	default:
+		if seconds, ok := toNumber(v); ok {
+			return unixTime(seconds), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("formatDate: %v is not a date", value)
+}
+
+// unixTime convierte segundos Unix, con decimales, a time.Time en UTC
+func unixTime(seconds float64) time.Time {
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9)).UTC()
+}
+
+// Truncate corta un texto a como mucho max caracteres y termina el texto
+// cortado en "…". Los caracteres se cuentan como los ve el usuario, así que
+// nunca separa una letra de sus tildes combinadas ni parte un emoji
+// compuesto o una bandera. Con max menor o igual que cero no corta.
+func Truncate(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	clusters := 0
+	cut := len(s)
+	var prev rune
+	regional := 0
+	for i, r := range s {
+		if !extendsCluster(prev, r, regional) {
+			clusters++
+			if clusters == max {
+				cut = i
+			}
+			if clusters > max {
+				return strings.TrimRightFunc(s[:cut], unicode.IsSpace) + ellipsis
+			}
+		}
+
+		if isRegionalIndicator(r) {
+			regional++
+		} else {
+			regional = 0
+		}
+		prev = r
+	}
+	return s
+}
+
+// extendsCluster indica si un carácter forma parte del mismo carácter
+// visible que el anterior. regional es cuántos indicadores regionales
+// seguidos lo preceden: cada dos forman una bandera.
+func extendsCluster(prev, r rune, regional int) bool {
+	switch {
+	case prev == 0:
+		return false
+	case prev == '\u200d': // tras un ZWJ sigue el emoji compuesto
+		return true
+	case r == '\u200d',
+		unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc, unicode.Variation_Selector),
+		r >= 0x1f3fb && r <= 0x1f3ff, // tonos de piel
+		r >= 0xe0020 && r <= 0xe007f: // etiquetas de las banderas de subdivisiones
+		return true
+	case isRegionalIndicator(r):
+		return regional%2 == 1
+	}
+	return false
+}
+
+// isRegionalIndicator indica si un carácter es un indicador regional, las
+// letras con las que se escriben las banderas
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
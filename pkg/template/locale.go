@@ -0,0 +1,59 @@
+package template
+
+import "strings"
+
+// ResolveLocale devuelve la clave de Locales de una plantilla con la que se
+// renderiza un idioma BCP-47, o "" si se usa el idioma por defecto. Se busca
+// el idioma quitando subetiquetas de la derecha (es-MX, es) sin distinguir
+// mayúsculas ni entre "-" y "_".
+func ResolveLocale(tmpl Template, locale, defaultLocale string) string {
+	for _, candidate := range localeChain(locale) {
+		for key := range tmpl.Locales {
+			if normalizeLocale(key) == candidate {
+				return key
+			}
+		}
+		if candidate == normalizeLocale(defaultLocale) {
+			return ""
+		}
+	}
+	return ""
+}
+
+// localeChain devuelve los idiomas a probar para un idioma, del más
+// concreto al más general: zh-hant-tw, zh-hant, zh
+func localeChain(locale string) []string {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return nil
+	}
+
+	var chain []string
+	for {
+		chain = append(chain, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			return chain
+		}
+		locale = locale[:i]
+		// Una subetiqueta de un carácter (x-, u-) introduce una extensión
+		// y no es un idioma por sí sola
+		if j := strings.LastIndex(locale, "-"); j >= 0 && len(locale)-j == 2 {
+			locale = locale[:j]
+		}
+	}
+}
+
+// normalizeLocale pasa un idioma a minúsculas y con guiones
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// baseLanguage devuelve la subetiqueta de idioma de un idioma: es de es-MX
+func baseLanguage(locale string) string {
+	locale = normalizeLocale(locale)
+	if i := strings.Index(locale, "-"); i >= 0 {
+		return locale[:i]
+	}
+	return locale
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"sync"
 	"text/template"
+	"text/template/parse"
 
 	"notification-service/pkg/logging"
 )
@@ -21,6 +23,7 @@ type Template struct {
 	Body        string               `json:"body"`
 	Data        map[string]string    `json:"data,omitempty"`
 	Locales     map[string]Localized `json:"locales,omitempty"`
+	Variables   map[string]Variable  `json:"variables,omitempty"`
 }
 
 // Localized representa una versión localizada de una plantilla
@@ -102,27 +105,33 @@ func (m *TemplateManager) loadTemplateFile(filePath string) error {
 		return errors.New("template ID cannot be empty")
 	}
 
+	// Validar los textos y las variables declaradas
+	if err := Validate(tmpl); err != nil {
+		return err
+	}
+
 	// Compilar plantilla de título
-	titleTmpl, err := template.New(tmpl.ID + "_title").Parse(tmpl.Title)
+	titleTmpl, err := newTemplate(tmpl.ID + "_title").Parse(tmpl.Title)
 	if err != nil {
 		return err
 	}
 
 	// Compilar plantilla de cuerpo
-	bodyTmpl, err := template.New(tmpl.ID + "_body").Parse(tmpl.Body)
+	bodyTmpl, err := newTemplate(tmpl.ID + "_body").Parse(tmpl.Body)
 	if err != nil {
 		return err
 	}
 
-	// Combinar las plantillas
-	combinedTmpl := template.New(tmpl.ID)
+	// Combinar las plantillas. Una variable que falta es un error, no un
+	// "<no value>" en el texto.
+	combinedTmpl := newTemplate(tmpl.ID).Option("missingkey=error")
 	combinedTmpl.AddParseTree(titleTmpl.Name(), titleTmpl.Tree)
 	combinedTmpl.AddParseTree(bodyTmpl.Name(), bodyTmpl.Tree)
 
 	// Procesar las versiones localizadas
 	for locale, localized := range tmpl.Locales {
 		if localized.Title != "" {
-			localizedTitleTmpl, err := template.New(tmpl.ID + "_" + locale + "_title").Parse(localized.Title)
+			localizedTitleTmpl, err := newTemplate(tmpl.ID + "_" + locale + "_title").Parse(localized.Title)
 			if err != nil {
 				m.logger.Error("Error parsing localized title template for locale %s: %v", locale, err)
 				continue
@@ -131,7 +140,7 @@ func (m *TemplateManager) loadTemplateFile(filePath string) error {
 		}
 
 		if localized.Body != "" {
-			localizedBodyTmpl, err := template.New(tmpl.ID + "_" + locale + "_body").Parse(localized.Body)
+			localizedBodyTmpl, err := newTemplate(tmpl.ID + "_" + locale + "_body").Parse(localized.Body)
 			if err != nil {
 				m.logger.Error("Error parsing localized body template for locale %s: %v", locale, err)
 				continue
@@ -167,24 +176,42 @@ func (m *TemplateManager) RenderTemplate(templateID, locale string, data map[str
 		return "", "", nil, errors.New("template not found")
 	}
 
-	// Determinar qué nombre de plantilla usar según el locale
+	// Determinar qué versión localizada usar: es-MX, es o la por defecto
 	titleTemplate := templateID + "_title"
 	bodyTemplate := templateID + "_body"
-
-	// Verificar si existe una versión localizada
-	localizedTitle := templateID + "_" + locale + "_title"
-	localizedBody := templateID + "_" + locale + "_body"
+	locale = ResolveLocale(templateData, locale, m.defaultLocale)
 
 	// Si existe una versión localizada para el título, usarla
-	if localizedTmpl := tmpl.Lookup(localizedTitle); localizedTmpl != nil {
+	localizedTitle := templateID + "_" + locale + "_title"
+	if localizedTmpl := tmpl.Lookup(localizedTitle); locale != "" && localizedTmpl != nil {
 		titleTemplate = localizedTitle
 	}
 
 	// Si existe una versión localizada para el cuerpo, usarla
-	if localizedTmpl := tmpl.Lookup(localizedBody); localizedTmpl != nil {
+	localizedBody := templateID + "_" + locale + "_body"
+	if localizedTmpl := tmpl.Lookup(localizedBody); locale != "" && localizedTmpl != nil {
 		bodyTemplate = localizedBody
 	}
 
+	// Comprobar los datos contra las variables de la plantilla
+	fields := referencedFields(tmpl.Lookup(titleTemplate).Tree, tmpl.Lookup(bodyTemplate).Tree)
+	data, err := resolveVariables(templateData.Variables, fields, data)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	// Las funciones de plural y formato siguen las reglas del idioma del
+	// texto. Se aplican a una copia para no cambiarlas en otros renders.
+	funcsLocale := locale
+	if funcsLocale == "" {
+		funcsLocale = m.defaultLocale
+	}
+	tmpl, err = tmpl.Clone()
+	if err != nil {
+		return "", "", nil, err
+	}
+	tmpl.Funcs(templateFuncs(funcsLocale))
+
 	// Renderizar título
 	var titleBuf bytes.Buffer
 	if err := tmpl.ExecuteTemplate(&titleBuf, titleTemplate, data); err != nil {
@@ -199,7 +226,7 @@ func (m *TemplateManager) RenderTemplate(templateID, locale string, data map[str
 
 	// Obtener datos adicionales
 	extraData := templateData.Data
-	if locale != "" && locale != m.defaultLocale {
+	if locale != "" {
 		if localized, exists := templateData.Locales[locale]; exists && localized.Data != nil {
 			// Combinar datos adicionales localizados
 			mergedData := make(map[string]string)
@@ -230,25 +257,26 @@ func (m *TemplateManager) AddTemplate(tmpl Template) error {
 	defer m.mu.Unlock()
 
 	// Compilar plantillas
-	titleTmpl, err := template.New(tmpl.ID + "_title").Parse(tmpl.Title)
+	titleTmpl, err := newTemplate(tmpl.ID + "_title").Parse(tmpl.Title)
 	if err != nil {
 		return err
 	}
 
-	bodyTmpl, err := template.New(tmpl.ID + "_body").Parse(tmpl.Body)
+	bodyTmpl, err := newTemplate(tmpl.ID + "_body").Parse(tmpl.Body)
 	if err != nil {
 		return err
 	}
 
-	// Combinar las plantillas
-	combinedTmpl := template.New(tmpl.ID)
+	// Combinar las plantillas. Una variable que falta es un error, no un
+	// "<no value>" en el texto.
+	combinedTmpl := newTemplate(tmpl.ID).Option("missingkey=error")
 	combinedTmpl.AddParseTree(titleTmpl.Name(), titleTmpl.Tree)
 	combinedTmpl.AddParseTree(bodyTmpl.Name(), bodyTmpl.Tree)
 
 	// Procesar las versiones localizadas
 	for locale, localized := range tmpl.Locales {
 		if localized.Title != "" {
-			localizedTitleTmpl, err := template.New(tmpl.ID + "_" + locale + "_title").Parse(localized.Title)
+			localizedTitleTmpl, err := newTemplate(tmpl.ID + "_" + locale + "_title").Parse(localized.Title)
 			if err != nil {
 				m.logger.Error("Error parsing localized title template for locale %s: %v", locale, err)
 				continue
@@ -257,7 +285,7 @@ func (m *TemplateManager) AddTemplate(tmpl Template) error {
 		}
 
 		if localized.Body != "" {
-			localizedBodyTmpl, err := template.New(tmpl.ID + "_" + locale + "_body").Parse(localized.Body)
+			localizedBodyTmpl, err := newTemplate(tmpl.ID + "_" + locale + "_body").Parse(localized.Body)
 			if err != nil {
 				m.logger.Error("Error parsing localized body template for locale %s: %v", locale, err)
 				continue
@@ -286,6 +314,43 @@ func (m *TemplateManager) RemoveTemplate(templateID string) bool {
 	return false
 }
 
+// DefaultLocale devuelve el idioma por defecto de las plantillas
+func (m *TemplateManager) DefaultLocale() string {
+	return m.defaultLocale
+}
+
+// Validate comprueba que el título, el cuerpo y sus versiones localizadas
+// son plantillas válidas y, si la plantilla declara variables, que son
+// válidas y que declaran todos los campos que usan los textos
+func Validate(tmpl Template) error {
+	if tmpl.Title == "" || tmpl.Body == "" {
+		return errors.New("template title and body cannot be empty")
+	}
+
+	texts := map[string]string{"title": tmpl.Title, "body": tmpl.Body}
+	for locale, localized := range tmpl.Locales {
+		texts[locale+" title"] = localized.Title
+		texts[locale+" body"] = localized.Body
+	}
+
+	trees := make([]*parse.Tree, 0, len(texts))
+	for name, text := range texts {
+		parsed, err := newTemplate(name).Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		trees = append(trees, parsed.Tree)
+	}
+
+	return validateVariables(tmpl.Variables, referencedFields(trees...))
+}
+
+// newTemplate crea una plantilla vacía con las funciones de las plantillas
+// de notificación. Al renderizar se cambian por las del idioma.
+func newTemplate(name string) *template.Template {
+	return template.New(name).Funcs(templateFuncs(""))
+}
+
 // GetAllTemplates devuelve todas las plantillas disponibles
 func (m *TemplateManager) GetAllTemplates() []Template {
 	m.mu.RLock()
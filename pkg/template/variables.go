@@ -0,0 +1,316 @@
+package template
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// Tipos de las variables de una plantilla. Una variable sin tipo admite
+// cualquier valor.
+const (
+	VariableString  = "string"
+	VariableNumber  = "number"
+	VariableInteger = "integer"
+	VariableBoolean = "boolean"
+	VariableObject  = "object"
+	VariableArray   = "array"
+)
+
+// Variable describe una variable de una plantilla. Una variable opcional
+// sin valor por defecto que no se indica se renderiza con el valor cero de
+// su tipo.
+type Variable struct {
+	Type        string      `json:"type,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+// VariablesError es el error de unos datos que no encajan con las variables
+// de una plantilla
+type VariablesError struct {
+	// Variables requeridas (o usadas y sin declarar) que faltan
+	Missing []string `json:"missing,omitempty"`
+
+	// Variables con un valor del tipo equivocado, con el motivo
+	Invalid map[string]string `json:"invalid,omitempty"`
+}
+
+// Error implementa error
+func (e *VariablesError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing variables: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Invalid) > 0 {
+		names := make([]string, 0, len(e.Invalid))
+		for name := range e.Invalid {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		invalid := make([]string, 0, len(names))
+		for _, name := range names {
+			invalid = append(invalid, name+" ("+e.Invalid[name]+")")
+		}
+		parts = append(parts, "invalid variables: "+strings.Join(invalid, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// validateVariables comprueba que las variables declaradas tienen un tipo
+// conocido y un valor por defecto de su tipo, y que todos los campos usados
+// en las plantillas están declarados. Sin variables declaradas no hay nada
+// que comprobar: los campos usados se exigen al renderizar.
+func validateVariables(variables map[string]Variable, fields []string) error {
+	if len(variables) == 0 {
+		return nil
+	}
+
+	for name, variable := range variables {
+		if !knownVariableType(variable.Type) {
+			return fmt.Errorf("variable %s has unknown type %q", name, variable.Type)
+		}
+		if variable.Default == nil {
+			continue
+		}
+		if _, ok := convertVariable(variable.Type, variable.Default); !ok {
+			return fmt.Errorf("default of variable %s must be %s", name, typeDescription(variable.Type))
+		}
+	}
+
+	var undeclared []string
+	for _, field := range fields {
+		if _, ok := variables[field]; !ok {
+			undeclared = append(undeclared, field)
+		}
+	}
+	if len(undeclared) > 0 {
+		return fmt.Errorf("undeclared variables: %s", strings.Join(undeclared, ", "))
+	}
+	return nil
+}
+
+// resolveVariables devuelve los datos con los que renderizar una plantilla:
+// los indicados, convertidos al tipo de su variable, más el valor por
+// defecto (o el cero) de las opcionales que faltan. Si falta una variable
+// requerida o un campo usado sin declarar, o un valor no es de su tipo,
+// devuelve un *VariablesError.
+func resolveVariables(variables map[string]Variable, fields []string, data map[string]interface{}) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(data)+len(variables))
+	for name, value := range data {
+		resolved[name] = value
+	}
+
+	verr := &VariablesError{}
+	for name, variable := range variables {
+		value, ok := data[name]
+		if !ok || value == nil {
+			switch {
+			case variable.Default != nil:
+				resolved[name], _ = convertVariable(variable.Type, variable.Default)
+			case variable.Required:
+				verr.Missing = append(verr.Missing, name)
+			default:
+				resolved[name] = zeroVariable(variable.Type)
+			}
+			continue
+		}
+
+		converted, ok := convertVariable(variable.Type, value)
+		if !ok {
+			if verr.Invalid == nil {
+				verr.Invalid = make(map[string]string)
+			}
+			verr.Invalid[name] = "must be " + typeDescription(variable.Type)
+			continue
+		}
+		resolved[name] = converted
+	}
+
+	for _, field := range fields {
+		if _, declared := variables[field]; declared {
+			continue
+		}
+		if _, ok := data[field]; !ok {
+			verr.Missing = append(verr.Missing, field)
+		}
+	}
+
+	if len(verr.Missing) > 0 || len(verr.Invalid) > 0 {
+		sort.Strings(verr.Missing)
+		return nil, verr
+	}
+	return resolved, nil
+}
+
+// knownVariableType indica si un tipo de variable es uno de los conocidos
+func knownVariableType(variableType string) bool {
+	switch variableType {
+	case "", VariableString, VariableNumber, VariableInteger, VariableBoolean, VariableObject, VariableArray:
+		return true
+	}
+	return false
+}
+
+// typeDescription describe un tipo de variable para los mensajes de error
+func typeDescription(variableType string) string {
+	switch variableType {
+	case VariableInteger, VariableObject, VariableArray:
+		return "an " + variableType
+	default:
+		return "a " + variableType
+	}
+}
+
+// convertVariable convierte un valor al tipo de una variable. Los números y
+// booleanos se admiten también como texto, que es como llegan por gRPC.
+func convertVariable(variableType string, value interface{}) (interface{}, bool) {
+	switch variableType {
+	case VariableString:
+		s, ok := value.(string)
+		return s, ok
+	case VariableNumber:
+		return toNumber(value)
+	case VariableInteger:
+		n, ok := toNumber(value)
+		if !ok || n != math.Trunc(n) {
+			return nil, false
+		}
+		return int64(n), true
+	case VariableBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			b, err := strconv.ParseBool(v)
+			return b, err == nil
+		}
+		return nil, false
+	case VariableObject:
+		m, ok := value.(map[string]interface{})
+		return m, ok
+	case VariableArray:
+		a, ok := value.([]interface{})
+		return a, ok
+	default:
+		return value, true
+	}
+}
+
+// toNumber convierte a float64 un número o un texto con un número
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// zeroVariable devuelve el valor cero de un tipo de variable
+func zeroVariable(variableType string) interface{} {
+	switch variableType {
+	case VariableNumber:
+		return float64(0)
+	case VariableInteger:
+		return int64(0)
+	case VariableBoolean:
+		return false
+	case VariableObject:
+		return map[string]interface{}{}
+	case VariableArray:
+		return []interface{}{}
+	case VariableString:
+		return ""
+	default:
+		return nil
+	}
+}
+
+// referencedFields devuelve, ordenados y sin repetir, los campos del dato
+// raíz que usan unos árboles de plantilla: Name en {{.Name}},
+// {{.Name.First}} o {{$.Name}}. Dentro de range y with el punto cambia, así
+// que sus cuerpos solo aportan los campos usados con $.
+func referencedFields(trees ...*parse.Tree) []string {
+	seen := make(map[string]bool)
+	for _, tree := range trees {
+		if tree != nil && tree.Root != nil {
+			collectFields(tree.Root, true, seen)
+		}
+	}
+
+	fields := make([]string, 0, len(seen))
+	for field := range seen {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// collectFields añade a seen los campos del dato raíz que usa un nodo. root
+// indica si el punto es el dato raíz.
+func collectFields(node parse.Node, root bool, seen map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectFields(child, root, seen)
+		}
+	case *parse.ActionNode:
+		collectFields(n.Pipe, root, seen)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectFields(cmd, root, seen)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectFields(arg, root, seen)
+		}
+	case *parse.FieldNode:
+		if root && len(n.Ident) > 0 {
+			seen[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			seen[n.Ident[1]] = true
+		}
+	case *parse.ChainNode:
+		collectFields(n.Node, root, seen)
+	case *parse.IfNode:
+		collectFields(n.Pipe, root, seen)
+		collectFields(n.List, root, seen)
+		collectFields(n.ElseList, root, seen)
+	case *parse.RangeNode:
+		collectFields(n.Pipe, root, seen)
+		collectFields(n.List, false, seen)
+		collectFields(n.ElseList, root, seen)
+	case *parse.WithNode:
+		collectFields(n.Pipe, root, seen)
+		collectFields(n.List, false, seen)
+		collectFields(n.ElseList, root, seen)
+	case *parse.TemplateNode:
+		collectFields(n.Pipe, root, seen)
+	}
+}
@@ -0,0 +1,83 @@
+package throttling
+
+import (
+	"context"
+	"time"
+)
+
+// Limit es un límite de tasa: Rate eventos por segundo con ráfagas de hasta
+// Burst
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimiter aplica límites de tasa por clave. MemoryRateLimiter guarda el
+// estado en la instancia; con varias réplicas hace falta una implementación
+// compartida para que el límite sea el del conjunto y no el de cada una.
+type RateLimiter interface {
+	// Reserve consume un evento de la clave si su límite lo permite, sin
+	// esperar
+	Reserve(ctx context.Context, key string, limit Limit) (*Reservation, error)
+}
+
+// Reservation es el resultado de RateLimiter.Reserve
+type Reservation struct {
+	// OK indica si se consumió el evento; si no, RetryAfter es cuánto falta
+	// para que el límite lo permita
+	OK         bool
+	RetryAfter time.Duration
+
+	cancel func(ctx context.Context) error
+}
+
+// NewReservation crea una reserva admitida; cancel devuelve el evento
+func NewReservation(cancel func(ctx context.Context) error) *Reservation {
+	return &Reservation{OK: true, cancel: cancel}
+}
+
+// NewRejection crea una reserva rechazada
+func NewRejection(retryAfter time.Duration) *Reservation {
+	return &Reservation{RetryAfter: retryAfter}
+}
+
+// Cancel devuelve el evento de una reserva admitida que finalmente no se usa
+func (r *Reservation) Cancel(ctx context.Context) error {
+	if !r.OK || r.cancel == nil {
+		return nil
+	}
+	return r.cancel(ctx)
+}
+
+// MemoryRateLimiter implementa RateLimiter con un rate.Limiter por clave en
+// la memoria de la instancia. Sirve para despliegues de una sola réplica.
+type MemoryRateLimiter struct {
+	throttler *UserThrottler
+}
+
+// NewMemoryRateLimiter crea un nuevo MemoryRateLimiter. Los limitadores de
+// las claves sin uso durante expiry se descartan.
+func NewMemoryRateLimiter(expiry time.Duration) *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		throttler: NewUserThrottler(0, 0, expiry, StrategyDrop),
+	}
+}
+
+// Reserve consume un evento de la clave si su límite lo permite
+func (l *MemoryRateLimiter) Reserve(ctx context.Context, key string, limit Limit) (*Reservation, error) {
+	reservation := l.throttler.Reserve(key, limit.Rate, limit.Burst)
+	if delay := reservation.Delay(); !reservation.OK() || delay > 0 {
+		reservation.Cancel()
+		return NewRejection(delay), nil
+	}
+
+	return NewReservation(func(context.Context) error {
+		reservation.Cancel()
+		return nil
+	}), nil
+}
+
+// Stop detiene la limpieza de los limitadores
+func (l *MemoryRateLimiter) Stop() {
+	l.throttler.Stop()
+}
@@ -19,16 +19,6 @@ const (
 	StrategyBlock ThrottleStrategy = "block"
 )
 
-// RateLimiter es una interfaz para el control de tasas
-type RateLimiter interface {
-	// Allow comprueba si se permite una solicitud y consume un token
-	Allow() bool
-	// Wait espera hasta que se pueda consumir un token
-	Wait() bool
-	// Reset restablece el limitador
-	Reset()
-}
-
 // UserThrottler limita la tasa de notificaciones por usuario
 type UserThrottler struct {
 	// Limitadores por usuario
@@ -130,6 +120,22 @@ func (t *UserThrottler) Allow(userID string) bool {
 	}
 }
 
+// Reserve reserva un token del limitador de una clave sin esperar, con el
+// límite indicado en lugar del general (el limitador se ajusta si cambia).
+// Si la reserva tiene retraso y no se va a esperar, debe cancelarse.
+func (t *UserThrottler) Reserve(key string, rps float64, burst int) *rate.Reservation {
+	limiter := t.getLimiter(key)
+
+	if limiter.Limit() != rate.Limit(rps) {
+		limiter.SetLimit(rate.Limit(rps))
+	}
+	if limiter.Burst() != burst {
+		limiter.SetBurst(burst)
+	}
+
+	return limiter.Reserve()
+}
+
 // Reset restablece el limitador para un usuario
 func (t *UserThrottler) Reset(userID string) {
 	t.mu.Lock()
@@ -204,6 +210,12 @@ func (t *DeviceThrottler) Allow(deviceID string) bool {
 	return t.throttler.Allow(deviceID)
 }
 
+// Reserve reserva un token del limitador de un dispositivo sin esperar,
+// con el límite indicado
+func (t *DeviceThrottler) Reserve(deviceID string, rps float64, burst int) *rate.Reservation {
+	return t.throttler.Reserve(deviceID, rps, burst)
+}
+
 // Reset restablece el limitador para un dispositivo
 func (t *DeviceThrottler) Reset(deviceID string) {
 	t.throttler.Reset(deviceID)
@@ -256,6 +268,12 @@ func (t *GlobalThrottler) Allow() bool {
 	}
 }
 
+// Reserve reserva un token del limitador global sin esperar. Si la reserva
+// tiene retraso y no se va a esperar, debe cancelarse.
+func (t *GlobalThrottler) Reserve() *rate.Reservation {
+	return t.limiter.Reserve()
+}
+
 // Reset restablece el limitador global
 func (t *GlobalThrottler) Reset() {
 	// Crear un nuevo limitador con los mismos parámetros